@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/simonm/qRest/internal/auth"
+	"github.com/simonm/qRest/internal/cache"
 	"github.com/simonm/qRest/internal/config"
 	"github.com/simonm/qRest/internal/executor"
 	"github.com/simonm/qRest/internal/grammar"
@@ -24,6 +27,8 @@ var (
 	tableName  string
 	verbose    bool
 	apiName    string
+	flatten    bool
+	offline    bool
 )
 
 func main() {
@@ -60,6 +65,14 @@ Bridging the gap between SQL and modern REST APIs through intelligent discovery.
 		RunE:  runCapabilities,
 	}
 
+	// Shell command
+	var shellCmd = &cobra.Command{
+		Use:   "shell",
+		Short: "Start an interactive SQL shell",
+		Long:  `Start an interactive SQL shell that loads the API once and keeps its tables in memory. Statements end with ';'; type \? for the meta-commands.`,
+		RunE:  runShell,
+	}
+
 	// Init command
 	var initCmd = &cobra.Command{
 		Use:   "init",
@@ -71,18 +84,26 @@ Bridging the gap between SQL and modern REST APIs through intelligent discovery.
 	// Add flags
 	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Path to configuration file")
 	rootCmd.PersistentFlags().StringVar(&specURL, "spec", "", "OpenAPI specification URL (overrides config)")
-	rootCmd.PersistentFlags().StringVar(&baseURL, "base-url", "", "API base URL (overrides config)")
+	rootCmd.PersistentFlags().StringVar(&baseURL, "base-url", "", "API base URL (overrides config; defaults to the spec's servers)")
 	rootCmd.PersistentFlags().StringVar(&authType, "auth-type", "bearer", "Authentication type (bearer, apikey, basic)")
 	rootCmd.PersistentFlags().StringVar(&authToken, "auth-token", "", "Authentication token")
 	rootCmd.PersistentFlags().StringVar(&apiName, "api", "", "API name from config to use")
 	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "Enable verbose output")
+	rootCmd.PersistentFlags().BoolVar(&flatten, "flatten", false, "Expose nested objects as parent_child columns")
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "Load specs from the local spec cache without network access")
 
 	grammarCmd.Flags().StringVar(&tableName, "table", "", "Show grammar for specific table")
+	for _, cmd := range []*cobra.Command{queryCmd, shellCmd} {
+		cmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format: table, csv, tsv, json, ndjson, markdown or yaml (default table on a terminal, json otherwise)")
+		cmd.Flags().BoolVar(&noHeader, "no-header", false, "Omit the header row of table, csv and tsv output")
+		cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Print the record count to stderr, leaving only the results on stdout")
+	}
 
 	// Add commands
 	rootCmd.AddCommand(queryCmd)
 	rootCmd.AddCommand(grammarCmd)
 	rootCmd.AddCommand(capabilitiesCmd)
+	rootCmd.AddCommand(shellCmd)
 	rootCmd.AddCommand(initCmd)
 
 	// Execute
@@ -94,6 +115,9 @@ Bridging the gap between SQL and modern REST APIs through intelligent discovery.
 
 func runQuery(cmd *cobra.Command, args []string) error {
 	sql := args[0]
+	if err := resolveOutputFormat(); err != nil {
+		return err
+	}
 
 	// Load configuration
 	cfg, apiConfig, err := loadConfig()
@@ -107,26 +131,35 @@ func runQuery(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load API capabilities: %w", err)
 	}
 
-	// Extract table name from SQL
-	tableNameFromSQL, err := extractTableName(sql)
+	return runSQL(newAPITables(cfg, apiConfig, capabilities, grammars), sql)
+}
+
+// runSQL executes one statement against the tables of apis and prints its
+// results
+func runSQL(apis *apiTables, sql string) error {
+	// Extract the tables the SQL reads from
+	tableNames, err := translator.ExtractTableNames(sql)
 	if err != nil {
 		return fmt.Errorf("failed to parse SQL: %w", err)
 	}
 
-	// Find corresponding capability and grammar
-	capability, exists := capabilities[tableNameFromSQL]
-	if !exists {
-		available := make([]string, 0, len(capabilities))
-		for table := range capabilities {
-			available = append(available, table)
+	// Resolve every table to its capability, grammar and executor
+	tables := make([]executor.JoinTable, len(tableNames))
+	joinGrammars := make(map[string]grammar.SQLGrammar)
+	for i, name := range tableNames {
+		table, tableGrammar, err := apis.resolve(name)
+		if err != nil {
+			return err
 		}
-		return fmt.Errorf("table '%s' not found. Available tables: %v", tableNameFromSQL, available)
+		tables[i] = table
+		joinGrammars[name] = tableGrammar
 	}
 
-	grammar := grammars[tableNameFromSQL]
+	grammar := joinGrammars[tableNames[0]]
 
 	// Parse and validate SQL
 	sqlTranslator := translator.NewSimpleSQLTranslator(grammar)
+	sqlTranslator.SetJoinTables(joinGrammars)
 	parsedQuery, err := sqlTranslator.ParseSQL(sql)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "SQL Error: %v\n", err)
@@ -147,8 +180,7 @@ func runQuery(cmd *cobra.Command, args []string) error {
 	}
 
 	// Execute query
-	executor := executor.NewRESTExecutor(apiConfig.Auth.Type, apiConfig.Auth.Token)
-	result, err := executor.ExecuteQuery(capability, parsedQuery)
+	result, err := executor.Execute(tables, parsedQuery)
 	if err != nil {
 		return fmt.Errorf("query execution failed: %w", err)
 	}
@@ -166,20 +198,7 @@ func runQuery(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Output results
-	if len(result.Data) == 0 {
-		fmt.Println("No results found.")
-		return nil
-	}
-
-	// Pretty print JSON results
-	jsonData, err := json.MarshalIndent(result.Data, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to format results: %w", err)
-	}
-
-	fmt.Printf("Results (%d records):\n%s\n", result.Total, string(jsonData))
-	return nil
+	return printResult(result)
 }
 
 func runGrammar(cmd *cobra.Command, args []string) error {
@@ -245,15 +264,51 @@ func runCapabilities(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Printf("API Capabilities:\n%s\n", string(jsonData))
+
+	// Summarise which operations need which security scheme
+	tables := make([]string, 0, len(capabilities))
+	for table := range capabilities {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	fmt.Println("\nSecurity:")
+	for _, table := range tables {
+		capability := capabilities[table]
+		fmt.Printf("  %-24s %-6s %-32s %s\n", table, capability.Method, capability.Path, describeSecurity(capability.Security))
+	}
 	return nil
 }
 
+// describeSecurity lists an operation's security alternatives, schemes used
+// together joined with "+", e.g. "api_key or petstore_auth[read:pets]"
+func describeSecurity(security [][]parser.SecurityScheme) string {
+	if len(security) == 0 {
+		return "none declared"
+	}
+	alternatives := make([]string, len(security))
+	for i, alternative := range security {
+		if len(alternative) == 0 {
+			alternatives[i] = "anonymous"
+			continue
+		}
+		schemes := make([]string, len(alternative))
+		for j, scheme := range alternative {
+			schemes[j] = scheme.Name
+			if len(scheme.Scopes) > 0 {
+				schemes[j] += "[" + strings.Join(scheme.Scopes, " ") + "]"
+			}
+		}
+		alternatives[i] = strings.Join(schemes, "+")
+	}
+	return strings.Join(alternatives, " or ")
+}
+
 func loadConfig() (*config.Config, *config.APIConfig, error) {
 	var cfg *config.Config
 	var err error
 	
 	// If CLI flags are provided, use them directly
-	if specURL != "" && baseURL != "" {
+	if specURL != "" {
 		cfg, err = config.LoadConfigFromAPI(specURL, baseURL, authType, authToken)
 		if err != nil {
 			return nil, nil, err
@@ -291,10 +346,12 @@ func loadAPICapabilities(cfg *config.Config, apiConfig *config.APIConfig) (map[s
 		apiConfig.BaseURL, 
 		apiConfig.Auth.Type, 
 		apiConfig.Auth.Token,
+		specCache(cfg),
 	)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to load OpenAPI spec: %w", err)
 	}
+	apiParser.SetFlattenNested(flatten || apiConfig.FlattenNested)
 
 	// Extract capabilities
 	capabilities, err := apiParser.ParseCapabilities()
@@ -323,6 +380,115 @@ func loadAPICapabilities(cfg *config.Config, apiConfig *config.APIConfig) (map[s
 	return capabilityMap, grammarMap, nil
 }
 
+// specCache returns the on-disk cache remote specs are loaded through
+func specCache(cfg *config.Config) *parser.SpecCache {
+	cache := parser.NewSpecCache(config.GetSpecCacheDir())
+	cache.SetOffline(offline || cfg.Defaults.Offline)
+	return cache
+}
+
+// apiTables resolves the tables a query names. Plain names belong to the API
+// the query runs against; "api.table" names a table of another configured
+// API, whose specification is loaded on first use.
+type apiTables struct {
+	cfg          *config.Config
+	apiConfig    *config.APIConfig
+	capabilities map[string]map[string]parser.APICapability
+	grammars     map[string]map[string]grammar.SQLGrammar
+	executors    map[string]*executor.RESTExecutor
+	responses    *cache.Cache // shared by the executors of every API
+}
+
+func newAPITables(cfg *config.Config, apiConfig *config.APIConfig, capabilities map[string]parser.APICapability, grammars map[string]grammar.SQLGrammar) *apiTables {
+	return &apiTables{
+		cfg:          cfg,
+		apiConfig:    apiConfig,
+		capabilities: map[string]map[string]parser.APICapability{apiConfig.Name: capabilities},
+		grammars:     map[string]map[string]grammar.SQLGrammar{apiConfig.Name: grammars},
+		executors:    make(map[string]*executor.RESTExecutor),
+		responses:    cache.New(cache.NewStore(cfg.Defaults.CacheBackend, config.GetResponseCacheDir())),
+	}
+}
+
+// resolve returns the table name refers to and the grammar statements on it
+// are checked against. information_schema and qrest name the virtual tables
+// describing the current API's tables, unless an API of that name is
+// configured.
+func (a *apiTables) resolve(name string) (executor.JoinTable, grammar.SQLGrammar, error) {
+	apiConfig, table := a.apiConfig, name
+	if prefix, rest, qualified := strings.Cut(name, "."); qualified {
+		apiConfig = a.cfg.FindAPI(prefix)
+		if apiConfig == nil {
+			current := a.apiConfig.Name
+			if metadata, ok := translator.LookupMetadataTable(name, a.capabilities[current], a.grammars[current]); ok {
+				return executor.JoinTable{Capability: metadata.Capability, Records: metadata.Records}, metadata.Grammar, nil
+			}
+			return executor.JoinTable{}, grammar.SQLGrammar{}, fmt.Errorf("API '%s' of table '%s' not found in configuration", prefix, name)
+		}
+		table = rest
+	}
+
+	if err := a.load(apiConfig); err != nil {
+		return executor.JoinTable{}, grammar.SQLGrammar{}, err
+	}
+
+	capability, exists := a.capabilities[apiConfig.Name][table]
+	if !exists {
+		available := make([]string, 0, len(a.capabilities[apiConfig.Name]))
+		for table := range a.capabilities[apiConfig.Name] {
+			available = append(available, table)
+		}
+		return executor.JoinTable{}, grammar.SQLGrammar{}, fmt.Errorf("table '%s' not found. Available tables: %v", name, available)
+	}
+
+	// The grammar is named as the query names the table
+	tableGrammar := a.grammars[apiConfig.Name][table]
+	tableGrammar.TableName = name
+
+	tableExecutor, exists := a.executors[apiConfig.Name]
+	if !exists {
+		authenticator, err := auth.NewSchemeResolver(apiConfig.Auth)
+		if err != nil {
+			return executor.JoinTable{}, grammar.SQLGrammar{}, fmt.Errorf("failed to set up auth for '%s': %w", apiConfig.Name, err)
+		}
+		tableExecutor = executor.NewRESTExecutor(authenticator)
+		tableExecutor.SetTimeout(apiConfig.GetRequestTimeout(&a.cfg.Defaults))
+		tableExecutor.SetMaxRequests(a.cfg.Defaults.MaxRequests)
+		tableExecutor.SetFlattenNested(flatten || apiConfig.FlattenNested)
+		tableExecutor.SetRetryPolicy(apiConfig.Retry.Attempts, apiConfig.Retry.GetRetryDelay(), apiConfig.Retry.RetryPost)
+		tableExecutor.SetCache(a.responses, apiConfig.GetResponseCacheTTL(&a.cfg.Defaults))
+		a.executors[apiConfig.Name] = tableExecutor
+	}
+	return executor.JoinTable{Capability: capability, Executor: tableExecutor}, tableGrammar, nil
+}
+
+// load parses the specification of an API unless it already is
+func (a *apiTables) load(apiConfig *config.APIConfig) error {
+	if _, loaded := a.capabilities[apiConfig.Name]; loaded {
+		return nil
+	}
+	capabilities, grammars, err := loadAPICapabilities(a.cfg, apiConfig)
+	if err != nil {
+		return fmt.Errorf("failed to load API capabilities for '%s': %w", apiConfig.Name, err)
+	}
+	a.capabilities[apiConfig.Name] = capabilities
+	a.grammars[apiConfig.Name] = grammars
+	return nil
+}
+
+// use makes plain table names refer to the tables of the named API
+func (a *apiTables) use(name string) error {
+	apiConfig := a.cfg.FindAPI(name)
+	if apiConfig == nil {
+		return fmt.Errorf("API '%s' not found in configuration", name)
+	}
+	if err := a.load(apiConfig); err != nil {
+		return err
+	}
+	a.apiConfig = apiConfig
+	return nil
+}
+
 func runInit(cmd *cobra.Command, args []string) error {
 	var configFile string
 	if configPath != "" {
@@ -364,6 +530,16 @@ func runInit(cmd *cobra.Command, args []string) error {
 host = "localhost"
 port = 8080
 
+# PostgreSQL wire protocol listener for psql and BI tools; 0 leaves it off
+[server.postgres]
+port = 0
+password = "${QREST_PG_PASSWORD}"
+
+# MySQL wire protocol listener for the mysql client and BI tools; 0 leaves it off
+[server.mysql]
+port = 0
+password = "${QREST_MYSQL_PASSWORD}"
+
 [server.cors]
 allow_origins = ["*"]
 allow_methods = ["GET", "POST", "OPTIONS"]
@@ -376,15 +552,18 @@ description = "Swagger Petstore API"
 spec_url = "https://petstore.swagger.io/v2/swagger.json"
 base_url = "https://petstore.swagger.io/v2"
 timeout = "30s"
+flatten_nested = false  # true exposes category.name as category_name
 
 [apis.auth]
-type = "apikey"
+type = "apikey"     # none, bearer, apikey, basic, oauth2, custom
 token = "special-key"
-header = "X-API-Key"
+# The spec's securityDefinitions name the api_key header; header = "..." or
+# query = "..." would override it
 
 [apis.retry]
-attempts = 3
-delay = "1s"
+attempts = 3        # tries per request on network errors, 429 and 5xx
+delay = "1s"        # first backoff, doubled per retry; Retry-After wins
+retry_post = false  # INSERTs are not idempotent, so retrying them is opt-in
 
 [apis.cache]
 enabled = true
@@ -415,8 +594,11 @@ token = "${GITHUB_TOKEN}"  # Environment variable
 [defaults]
 max_limit = 1000
 default_limit = 100
+max_requests = 20   # API requests a single query may issue (pages and OR alternatives)
 timeout = "30s"
 cache_ttl = "5m"
+offline = false     # load specs from the spec cache only (also --offline)
+cache_backend = "memory"  # response cache: memory, or disk to share it between CLI runs
 
 # Logging configuration
 [logging]
@@ -439,21 +621,3 @@ file = ""         # empty for stdout
 	return nil
 }
 
-func extractTableName(sql string) (string, error) {
-	// Simple extraction - for demo purposes
-	// In production, we'd use the full SQL parser
-	sqlLower := strings.ToLower(sql)
-	fromIndex := strings.Index(sqlLower, "from ")
-	if fromIndex == -1 {
-		return "", fmt.Errorf("no FROM clause found in SQL")
-	}
-
-	// Extract table name after FROM
-	remaining := sql[fromIndex+5:]
-	words := strings.Fields(remaining)
-	if len(words) == 0 {
-		return "", fmt.Errorf("no table name found after FROM")
-	}
-
-	return strings.TrimSpace(words[0]), nil
-}
\ No newline at end of file
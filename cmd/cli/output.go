@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/simonm/qRest/internal/executor"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	outputFormat string
+	noHeader     bool
+	quiet        bool
+)
+
+// outputFormats are the values --output accepts
+var outputFormats = []string{"table", "csv", "tsv", "json", "ndjson", "markdown", "yaml"}
+
+// resolveOutputFormat checks the output flags, choosing an aligned table for
+// a terminal and JSON for anything else when no format is given
+func resolveOutputFormat() error {
+	outputFormat = strings.ToLower(outputFormat)
+	switch outputFormat {
+	case "":
+		outputFormat = "json"
+		if term.IsTerminal(int(os.Stdout.Fd())) {
+			outputFormat = "table"
+		}
+	case "md":
+		outputFormat = "markdown"
+	case "yml":
+		outputFormat = "yaml"
+	}
+	if !contains(outputFormats, outputFormat) {
+		return fmt.Errorf("unknown output format %q (use %s)", outputFormat, strings.Join(outputFormats, ", "))
+	}
+	if noHeader && !contains([]string{"table", "csv", "tsv"}, outputFormat) {
+		return fmt.Errorf("--no-header applies to table, csv and tsv output, not %s", outputFormat)
+	}
+	return nil
+}
+
+// printResult writes a result's records in the output format, followed by
+// their count, which quiet mode sends to stderr to keep stdout to the data
+func printResult(result *executor.QueryResult) error {
+	columns := resultColumns(result)
+	var out bytes.Buffer
+	var err error
+	switch outputFormat {
+	case "table":
+		writeTable(&out, columns, result.Data)
+	case "csv":
+		err = writeDelimited(&out, columns, result.Data, ',')
+	case "tsv":
+		writeTSV(&out, columns, result.Data)
+	case "json":
+		err = writeJSON(&out, columns, result.Data)
+	case "ndjson":
+		err = writeNDJSON(&out, columns, result.Data)
+	case "markdown":
+		writeMarkdown(&out, columns, result.Data)
+	case "yaml":
+		err = writeYAML(&out, columns, result.Data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to format results: %w", err)
+	}
+	os.Stdout.Write(out.Bytes())
+
+	count := fmt.Sprintf("(%d records)", result.Total)
+	if result.Total == 1 {
+		count = "(1 record)"
+	}
+	if quiet {
+		fmt.Fprintln(os.Stderr, count)
+	} else {
+		fmt.Println(count)
+	}
+	return nil
+}
+
+// resultColumns returns the columns in SELECT order, or those the records
+// carry, sorted, when the statement names none
+func resultColumns(result *executor.QueryResult) []string {
+	if len(result.Columns) > 0 {
+		return result.Columns
+	}
+	seen := make(map[string]bool)
+	var columns []string
+	for _, record := range result.Data {
+		for column := range record {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// cell formats a value for the text formats: nested objects and arrays as
+// compact JSON, numbers without exponents and null as empty
+func cell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
+
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case float64, float32, int, int64, json.Number:
+		return true
+	}
+	return false
+}
+
+// tableEscaper keeps each value of a table on one line and in its column
+var tableEscaper = strings.NewReplacer("\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// writeTable aligns the records in columns, numbers to the right
+func writeTable(out io.Writer, columns []string, data []map[string]interface{}) {
+	widths := make([]int, len(columns))
+	numeric := make([]bool, len(columns))
+	cells := make([][]string, len(data))
+	for i, column := range columns {
+		if !noHeader {
+			widths[i] = utf8.RuneCountInString(column)
+		}
+		numeric[i] = len(data) > 0
+	}
+	for r, record := range data {
+		cells[r] = make([]string, len(columns))
+		for i, column := range columns {
+			value := record[column]
+			text := tableEscaper.Replace(cell(value))
+			cells[r][i] = text
+			if width := utf8.RuneCountInString(text); width > widths[i] {
+				widths[i] = width
+			}
+			if value != nil && !isNumber(value) {
+				numeric[i] = false
+			}
+		}
+	}
+
+	writeRow := func(row []string, alignRight bool) {
+		parts := make([]string, len(row))
+		for i, text := range row {
+			padding := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(text))
+			if alignRight && numeric[i] {
+				parts[i] = padding + text
+			} else {
+				parts[i] = text + padding
+			}
+		}
+		fmt.Fprintln(out, strings.TrimRight(strings.Join(parts, " | "), " "))
+	}
+
+	if !noHeader {
+		writeRow(columns, false)
+		rules := make([]string, len(columns))
+		for i := range columns {
+			rules[i] = strings.Repeat("-", widths[i])
+		}
+		fmt.Fprintln(out, strings.Join(rules, "-+-"))
+	}
+	for _, row := range cells {
+		writeRow(row, true)
+	}
+}
+
+func writeDelimited(out io.Writer, columns []string, data []map[string]interface{}, comma rune) error {
+	writer := csv.NewWriter(out)
+	writer.Comma = comma
+	if !noHeader {
+		writer.Write(columns)
+	}
+	row := make([]string, len(columns))
+	for _, record := range data {
+		for i, column := range columns {
+			row[i] = cell(record[column])
+		}
+		writer.Write(row)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeTSV escapes tabs, newlines and backslashes in values, so each record
+// stays on one line of tab-separated fields
+func writeTSV(out io.Writer, columns []string, data []map[string]interface{}) {
+	escaper := strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+	if !noHeader {
+		fmt.Fprintln(out, strings.Join(columns, "\t"))
+	}
+	row := make([]string, len(columns))
+	for _, record := range data {
+		for i, column := range columns {
+			row[i] = escaper.Replace(cell(record[column]))
+		}
+		fmt.Fprintln(out, strings.Join(row, "\t"))
+	}
+}
+
+// orderedRecord encodes a record as a JSON object with its keys in column
+// order, which encoding/json would sort
+func orderedRecord(columns []string, record map[string]interface{}) ([]byte, error) {
+	var object bytes.Buffer
+	object.WriteByte('{')
+	for i, column := range columns {
+		key, err := json.Marshal(column)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(record[column])
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			object.WriteByte(',')
+		}
+		object.Write(key)
+		object.WriteByte(':')
+		object.Write(value)
+	}
+	object.WriteByte('}')
+	return object.Bytes(), nil
+}
+
+func writeJSON(out *bytes.Buffer, columns []string, data []map[string]interface{}) error {
+	var array bytes.Buffer
+	array.WriteByte('[')
+	for i, record := range data {
+		object, err := orderedRecord(columns, record)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			array.WriteByte(',')
+		}
+		array.Write(object)
+	}
+	array.WriteByte(']')
+	if err := json.Indent(out, array.Bytes(), "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	return nil
+}
+
+func writeNDJSON(out io.Writer, columns []string, data []map[string]interface{}) error {
+	for _, record := range data {
+		object, err := orderedRecord(columns, record)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s\n", object)
+	}
+	return nil
+}
+
+func writeMarkdown(out io.Writer, columns []string, data []map[string]interface{}) {
+	escaper := strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+	row := make([]string, len(columns))
+	for i, column := range columns {
+		row[i] = escaper.Replace(column)
+	}
+	fmt.Fprintf(out, "| %s |\n", strings.Join(row, " | "))
+	for i := range columns {
+		row[i] = "---"
+	}
+	fmt.Fprintf(out, "| %s |\n", strings.Join(row, " | "))
+	for _, record := range data {
+		for i, column := range columns {
+			row[i] = escaper.Replace(cell(record[column]))
+		}
+		fmt.Fprintf(out, "| %s |\n", strings.Join(row, " | "))
+	}
+}
+
+// writeYAML writes the records as a sequence of mappings, keys in column
+// order
+func writeYAML(out io.Writer, columns []string, data []map[string]interface{}) error {
+	sequence := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, record := range data {
+		mapping := &yaml.Node{Kind: yaml.MappingNode}
+		for _, column := range columns {
+			var value yaml.Node
+			if err := value.Encode(yamlValue(record[column])); err != nil {
+				return err
+			}
+			mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: column}, &value)
+		}
+		sequence.Content = append(sequence.Content, mapping)
+	}
+	if len(data) == 0 {
+		sequence.Style = yaml.FlowStyle
+	}
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(sequence); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// yamlValue encodes whole numbers decoded as float64 as integers, which YAML
+// would otherwise write with an exponent once they are large
+func yamlValue(value interface{}) interface{} {
+	if number, ok := value.(float64); ok && number == math.Trunc(number) && math.Abs(number) < 1<<53 {
+		return int64(number)
+	}
+	return value
+}
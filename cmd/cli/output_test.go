@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/simonm/qRest/internal/executor"
+)
+
+// outputColumns are in SELECT order, which the writers keep
+var outputColumns = []string{"name", "id", "price", "tags"}
+
+var outputData = []map[string]interface{}{
+	{"id": float64(1), "name": "Rex", "price": 12.5, "tags": []interface{}{"a", "b"}},
+	{"id": float64(10), "name": "Tab\tand|pipe\nline", "price": nil},
+}
+
+func TestWriteFormats(t *testing.T) {
+	tests := []struct {
+		format   string
+		noHeader bool
+		want     string
+	}{
+		{
+			format: "table",
+			want: "name                | id | price | tags\n" +
+				"--------------------+----+-------+----------\n" +
+				"Rex                 |  1 |  12.5 | [\"a\",\"b\"]\n" +
+				"Tab\\tand|pipe\\nline | 10 |       |\n",
+		},
+		{
+			format:   "table",
+			noHeader: true,
+			want: "Rex                 |  1 | 12.5 | [\"a\",\"b\"]\n" +
+				"Tab\\tand|pipe\\nline | 10 |      |\n",
+		},
+		{
+			format: "csv",
+			want: "name,id,price,tags\n" +
+				"Rex,1,12.5,\"[\"\"a\"\",\"\"b\"\"]\"\n" +
+				"\"Tab\tand|pipe\nline\",10,,\n",
+		},
+		{
+			format:   "csv",
+			noHeader: true,
+			want: "Rex,1,12.5,\"[\"\"a\"\",\"\"b\"\"]\"\n" +
+				"\"Tab\tand|pipe\nline\",10,,\n",
+		},
+		{
+			format: "tsv",
+			want: "name\tid\tprice\ttags\n" +
+				"Rex\t1\t12.5\t[\"a\",\"b\"]\n" +
+				"Tab\\tand|pipe\\nline\t10\t\t\n",
+		},
+		{
+			format: "json",
+			want: "[\n" +
+				"  {\n" +
+				"    \"name\": \"Rex\",\n" +
+				"    \"id\": 1,\n" +
+				"    \"price\": 12.5,\n" +
+				"    \"tags\": [\n" +
+				"      \"a\",\n" +
+				"      \"b\"\n" +
+				"    ]\n" +
+				"  },\n" +
+				"  {\n" +
+				"    \"name\": \"Tab\\tand|pipe\\nline\",\n" +
+				"    \"id\": 10,\n" +
+				"    \"price\": null,\n" +
+				"    \"tags\": null\n" +
+				"  }\n" +
+				"]\n",
+		},
+		{
+			format: "ndjson",
+			want: "{\"name\":\"Rex\",\"id\":1,\"price\":12.5,\"tags\":[\"a\",\"b\"]}\n" +
+				"{\"name\":\"Tab\\tand|pipe\\nline\",\"id\":10,\"price\":null,\"tags\":null}\n",
+		},
+		{
+			format: "markdown",
+			want: "| name | id | price | tags |\n" +
+				"| --- | --- | --- | --- |\n" +
+				"| Rex | 1 | 12.5 | [\"a\",\"b\"] |\n" +
+				"| Tab\tand\\|pipe<br>line | 10 |  |  |\n",
+		},
+		{
+			format: "yaml",
+			want: "- name: Rex\n" +
+				"  id: 1\n" +
+				"  price: 12.5\n" +
+				"  tags:\n" +
+				"    - a\n" +
+				"    - b\n" +
+				"- name: |-\n" +
+				"    Tab\tand|pipe\n" +
+				"    line\n" +
+				"  id: 10\n" +
+				"  price: null\n" +
+				"  tags: null\n",
+		},
+	}
+
+	for _, tt := range tests {
+		noHeader = tt.noHeader
+		var out bytes.Buffer
+		var err error
+		switch tt.format {
+		case "table":
+			writeTable(&out, outputColumns, outputData)
+		case "csv":
+			err = writeDelimited(&out, outputColumns, outputData, ',')
+		case "tsv":
+			writeTSV(&out, outputColumns, outputData)
+		case "json":
+			err = writeJSON(&out, outputColumns, outputData)
+		case "ndjson":
+			err = writeNDJSON(&out, outputColumns, outputData)
+		case "markdown":
+			writeMarkdown(&out, outputColumns, outputData)
+		case "yaml":
+			err = writeYAML(&out, outputColumns, outputData)
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.format, err)
+		} else if got := out.String(); got != tt.want {
+			t.Errorf("%s (no header %v): got\n%s\nwant\n%s", tt.format, tt.noHeader, got, tt.want)
+		}
+	}
+	noHeader = false
+}
+
+func TestWriteFormatsEmpty(t *testing.T) {
+	columns := []string{"id"}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"table", "id\n--\n"},
+		{"json", "[]\n"},
+		{"ndjson", ""},
+		{"yaml", "[]\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		var err error
+		switch tt.format {
+		case "table":
+			writeTable(&out, columns, nil)
+		case "json":
+			err = writeJSON(&out, columns, nil)
+		case "ndjson":
+			err = writeNDJSON(&out, columns, nil)
+		case "yaml":
+			err = writeYAML(&out, columns, nil)
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.format, err)
+		} else if got := out.String(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestCell(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, ""},
+		{"Rex", "Rex"},
+		{float64(3), "3"},
+		{1e21, "1000000000000000000000"},
+		{0.000001, "0.000001"},
+		{true, "true"},
+		{json.Number("12345678901234567890"), "12345678901234567890"},
+		{map[string]interface{}{"b": 1, "a": "x"}, `{"a":"x","b":1}`},
+		{[]interface{}{1, "two", nil}, `[1,"two",null]`},
+	}
+	for _, tt := range tests {
+		if got := cell(tt.value); got != tt.want {
+			t.Errorf("cell(%#v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestYAMLValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  interface{}
+	}{
+		{float64(3), int64(3)},
+		{1e15, int64(1000000000000000)},
+		{1e17, 1e17},
+		{2.5, 2.5},
+		{1e300, 1e300},
+		{"3", "3"},
+	}
+	for _, tt := range tests {
+		if got := yamlValue(tt.value); got != tt.want {
+			t.Errorf("yamlValue(%#v) = %#v, want %#v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestResultColumns(t *testing.T) {
+	selected := &executor.QueryResult{Columns: []string{"name", "id"}, Data: outputData}
+	if got := resultColumns(selected); !reflect.DeepEqual(got, []string{"name", "id"}) {
+		t.Errorf("got columns %q, want the selected ones", got)
+	}
+
+	records := &executor.QueryResult{Data: []map[string]interface{}{
+		{"name": "Rex", "id": 1},
+		{"status": "sold", "id": 2},
+	}}
+	if got, want := resultColumns(records), []string{"id", "name", "status"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got columns %q, want %q", got, want)
+	}
+}
+
+func TestResolveOutputFormat(t *testing.T) {
+	tests := []struct {
+		format   string
+		noHeader bool
+		want     string
+		err      string
+	}{
+		{format: "CSV", want: "csv"},
+		{format: "md", want: "markdown"},
+		{format: "yml", want: "yaml"},
+		{format: "tsv", noHeader: true, want: "tsv"},
+		{format: "xml", err: `unknown output format "xml" (use table, csv, tsv, json, ndjson, markdown, yaml)`},
+		{format: "json", noHeader: true, err: "--no-header applies to table, csv and tsv output, not json"},
+	}
+
+	for _, tt := range tests {
+		outputFormat, noHeader = tt.format, tt.noHeader
+		err := resolveOutputFormat()
+		switch {
+		case tt.err != "":
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: got error %v, want %q", tt.format, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", tt.format, err)
+		case outputFormat != tt.want:
+			t.Errorf("%s: got format %q, want %q", tt.format, outputFormat, tt.want)
+		}
+	}
+	outputFormat, noHeader = "", false
+}
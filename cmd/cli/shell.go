@@ -0,0 +1,561 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/simonm/qRest/internal/config"
+	"github.com/simonm/qRest/internal/grammar"
+	"github.com/simonm/qRest/internal/translator"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// maxHistory bounds the lines the shell keeps in its history file
+const maxHistory = 1000
+
+// localOperators are the comparisons qRest evaluates itself on any response
+// column
+var localOperators = []string{"=", "!=", "<", "<=", ">", ">=", "LIKE", "ILIKE", "IN", "NOT", "BETWEEN", "IS"}
+
+var metaCommands = []string{`\?`, `\apis`, `\d`, `\q`, `\tables`, `\use`}
+
+const shellHelp = `Statements end with ";" and may span several lines.
+
+  \tables        list the tables of the current API
+  \d <table>     describe a table: columns, filters, limits and security
+  \use <api>     switch to another configured API
+  \apis          list the configured APIs
+  \?             show this help
+  \q             quit (or Ctrl-D)
+
+SHOW TABLES, DESCRIBE <table> and SELECTs on information_schema.tables,
+information_schema.columns and qrest.operators describe the tables as rows.
+Tab completes table names, columns and the operators a column allows.
+Ctrl-C discards the statement being typed.`
+
+// shell runs the statements typed at its prompt against tables that stay
+// loaded between them
+type shell struct {
+	apis     *apiTables
+	buffer   []string       // lines of the statement being typed
+	terminal *term.Terminal // nil when reading a script
+	failures int
+}
+
+func runShell(cmd *cobra.Command, args []string) error {
+	if err := resolveOutputFormat(); err != nil {
+		return err
+	}
+
+	// Load configuration
+	cfg, apiConfig, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	// Load and parse API specification once for the session
+	capabilities, grammars, err := loadAPICapabilities(cfg, apiConfig)
+	if err != nil {
+		return fmt.Errorf("failed to load API capabilities: %w", err)
+	}
+
+	s := &shell{apis: newAPITables(cfg, apiConfig, capabilities, grammars)}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return s.runScript(os.Stdin)
+	}
+	return s.runInteractive(fd)
+}
+
+// runInteractive reads lines with editing, history and completion until \q,
+// Ctrl-D or the end of input
+func (s *shell) runInteractive(fd int) error {
+	history := loadShellHistory(config.GetHistoryPath())
+	input := &interruptReader{reader: os.Stdin}
+	s.terminal = s.newTerminal(input, history)
+
+	fmt.Printf("qRest shell on API '%s'. Type \\? for help, \\q to quit.\n", s.apis.apiConfig.Name)
+	for {
+		if width, height, err := term.GetSize(fd); err == nil && width > 0 {
+			s.terminal.SetSize(width, height)
+		}
+		s.terminal.SetPrompt(s.prompt())
+
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		line, err := s.terminal.ReadLine()
+		term.Restore(fd, state)
+
+		if err == io.EOF && input.interrupted {
+			// The terminal keeps the abandoned line, so start afresh
+			fmt.Println("^C")
+			input.interrupted = false
+			s.buffer = nil
+			s.terminal = s.newTerminal(input, history)
+			continue
+		}
+		if err == io.EOF {
+			fmt.Println()
+			return nil
+		}
+		if err != nil && err != term.ErrPasteIndicator {
+			return err
+		}
+
+		if quit := s.feed(line); quit {
+			return nil
+		}
+	}
+}
+
+func (s *shell) newTerminal(input io.Reader, history term.History) *term.Terminal {
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{input, os.Stdout}, s.prompt())
+	terminal.History = history
+	terminal.AutoCompleteCallback = s.complete
+	return terminal
+}
+
+// runScript runs the statements of piped input, carrying on past failures
+// but reporting them when it ends
+func (s *shell) runScript(input io.Reader) error {
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		if quit := s.feed(scanner.Text()); quit {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if statement := strings.TrimSpace(strings.Join(s.buffer, "\n")); statement != "" {
+		return fmt.Errorf("statement not terminated with ';': %s", statement)
+	}
+	if s.failures > 0 {
+		return fmt.Errorf("%d statements failed", s.failures)
+	}
+	return nil
+}
+
+func (s *shell) prompt() string {
+	if len(s.buffer) > 0 {
+		return s.apis.apiConfig.Name + "-> "
+	}
+	return s.apis.apiConfig.Name + "=> "
+}
+
+// feed takes a line of input: a meta-command when no statement is open, else
+// part of a statement, which runs once ";" ends it. It reports whether the
+// shell should quit.
+func (s *shell) feed(line string) bool {
+	if len(s.buffer) == 0 && strings.HasPrefix(strings.TrimSpace(line), `\`) {
+		return s.meta(strings.Fields(line))
+	}
+	if len(s.buffer) == 0 && strings.TrimSpace(line) == "" {
+		return false
+	}
+
+	statements, rest := splitStatements(strings.Join(append(s.buffer, line), "\n"))
+	s.buffer = nil
+	if strings.TrimSpace(rest) != "" {
+		s.buffer = strings.Split(rest, "\n")
+	}
+	for _, statement := range statements {
+		if err := runSQL(s.apis, statement); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			s.failures++
+		}
+	}
+	return false
+}
+
+// splitStatements cuts text at each ";" outside quotes and comments,
+// returning the complete statements and the unterminated rest
+func splitStatements(text string) ([]string, string) {
+	var statements []string
+	var quote byte
+	comment := "" // "--" or "/*" inside a comment
+	start := 0
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case comment == "--":
+			if c == '\n' {
+				comment = ""
+			}
+		case comment == "/*":
+			if strings.HasPrefix(text[i:], "*/") {
+				comment = ""
+				i++
+			}
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case strings.HasPrefix(text[i:], "--"), strings.HasPrefix(text[i:], "/*"):
+			comment = text[i : i+2]
+			i++
+		case c == ';':
+			if statement := strings.TrimSpace(text[start:i]); statement != "" {
+				statements = append(statements, statement)
+			}
+			start = i + 1
+		}
+	}
+	return statements, text[start:]
+}
+
+// meta runs a backslash command, reporting whether the shell should quit
+func (s *shell) meta(fields []string) bool {
+	command, args := fields[0], fields[1:]
+	var err error
+	switch command {
+	case `\q`, `\quit`:
+		return true
+	case `\?`, `\h`, `\help`:
+		fmt.Println(shellHelp)
+	case `\tables`, `\dt`:
+		s.listTables()
+	case `\d`:
+		if len(args) == 0 {
+			s.listTables()
+		} else {
+			err = s.describe(args[0])
+		}
+	case `\use`:
+		if len(args) == 0 {
+			s.listAPIs()
+		} else if err = s.apis.use(args[0]); err == nil {
+			fmt.Printf("Using API '%s'\n", s.apis.apiConfig.Name)
+		}
+	case `\apis`:
+		s.listAPIs()
+	default:
+		err = fmt.Errorf("unknown command %s; type \\? for help", command)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		s.failures++
+	}
+	return false
+}
+
+func (s *shell) listTables() {
+	capabilities := s.apis.capabilities[s.apis.apiConfig.Name]
+	for _, table := range sortedKeys(capabilities) {
+		capability := capabilities[table]
+		fmt.Printf("  %-32s %-6s %s\n", table, capability.Method, capability.Path)
+	}
+}
+
+func (s *shell) listAPIs() {
+	for _, api := range s.apis.cfg.APIs {
+		marker := " "
+		if api.Name == s.apis.apiConfig.Name {
+			marker = "*"
+		}
+		status := ""
+		if _, loaded := s.apis.capabilities[api.Name]; loaded {
+			status = "(loaded)"
+		}
+		fmt.Printf("%s %-24s %-9s %s\n", marker, api.Name, status, api.SpecURL)
+	}
+}
+
+// describe prints what a table can be queried with
+func (s *shell) describe(name string) error {
+	table, tableGrammar, err := s.apis.resolve(name)
+	if err != nil {
+		return err
+	}
+	capability := table.Capability
+
+	fmt.Printf("Table '%s': %s %s%s\n", name, capability.Method, capability.BaseURL, capability.Path)
+	fmt.Println("Columns:")
+	for _, column := range tableGrammar.AllowedColumns {
+		if operators := tableGrammar.WhereClause.AllowedColumns[column]; len(operators) > 0 {
+			fmt.Printf("  %-32s API filters: %s\n", column, strings.Join(operators, " "))
+		} else {
+			fmt.Printf("  %s\n", column)
+		}
+	}
+
+	var parameters []string
+	for column := range tableGrammar.WhereClause.AllowedColumns {
+		if !contains(tableGrammar.AllowedColumns, column) {
+			parameters = append(parameters, column)
+		}
+	}
+	if len(parameters) > 0 {
+		sort.Strings(parameters)
+		fmt.Println("Filter-only parameters:")
+		for _, column := range parameters {
+			fmt.Printf("  %-32s %s\n", column, strings.Join(tableGrammar.WhereClause.AllowedColumns[column], " "))
+		}
+	}
+
+	paging := "no paging"
+	if tableGrammar.Limit.HasPaging {
+		paging = "paged"
+	}
+	fmt.Printf("Limit: up to %d, %s\n", tableGrammar.Limit.MaxLimit, paging)
+	fmt.Printf("Security: %s\n", describeSecurity(capability.Security))
+	return nil
+}
+
+// complete is the terminal's tab completion. It offers meta-commands, table
+// names where a table is expected, the operators of a column in a condition,
+// and otherwise the columns of the statement's tables and SQL keywords.
+// Several matches are completed to their common prefix and listed.
+func (s *shell) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	head := line[:pos]
+	start := strings.LastIndexAny(head, " \t(,=<>!") + 1
+	word := head[start:]
+	before := strings.Join(append(append([]string{}, s.buffer...), head[:start]), "\n")
+	statement := strings.Join(append(append([]string{}, s.buffer...), line), "\n")
+
+	var matches []string
+	for _, candidate := range s.candidates(before, statement, word) {
+		if strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(word)) && !contains(matches, candidate) {
+			matches = append(matches, candidate)
+		}
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+
+	completion := commonPrefix(matches)
+	if len(matches) == 1 {
+		completion += " "
+	}
+	if len(completion) <= len(word) {
+		s.terminal.Write([]byte(strings.Join(matches, "  ") + "\n"))
+		return line, pos, true
+	}
+	return head[:start] + completion + line[pos:], start + len(completion), true
+}
+
+// candidates lists the words that may follow before in statement
+func (s *shell) candidates(before, statement, word string) []string {
+	fields := strings.Fields(before)
+	if len(fields) == 0 {
+		if strings.HasPrefix(word, `\`) {
+			return metaCommands
+		}
+		return matchCase(translator.Keywords(), word)
+	}
+
+	switch strings.ToLower(fields[0]) {
+	case `\d`:
+		return s.tableNames()
+	case `\use`:
+		return s.apis.cfg.ListAPINames()
+	}
+
+	previous := strings.ToUpper(fields[len(fields)-1])
+	switch previous {
+	case "FROM", "JOIN", "INTO", "UPDATE":
+		return s.tableNames()
+	}
+
+	grammars := s.statementGrammars(statement)
+	if operators := conditionOperators(fields, grammars); operators != nil {
+		return operators
+	}
+
+	var candidates []string
+	for _, tableGrammar := range grammars {
+		candidates = append(candidates, tableGrammar.AllowedColumns...)
+	}
+	return append(candidates, matchCase(translator.Keywords(), word)...)
+}
+
+// conditionOperators returns the operators a column allows when it was just
+// named in a condition, or nil
+func conditionOperators(fields []string, grammars []grammar.SQLGrammar) []string {
+	column := fields[len(fields)-1]
+	if _, field, qualified := strings.Cut(column, "."); qualified && !strings.Contains(field, ".") {
+		column = field
+	}
+
+	clause := ""
+	for _, field := range fields[:len(fields)-1] {
+		switch upper := strings.ToUpper(field); upper {
+		case "SELECT", "FROM", "JOIN", "WHERE", "AND", "OR", "ON", "HAVING", "GROUP", "ORDER", "SET", "VALUES":
+			clause = upper
+		}
+	}
+	switch clause {
+	case "WHERE", "AND", "OR", "ON", "HAVING":
+	default:
+		return nil
+	}
+
+	var operators []string
+	for _, tableGrammar := range grammars {
+		operators = append(operators, tableGrammar.WhereClause.AllowedColumns[column]...)
+		if contains(tableGrammar.WhereClause.LocalColumns, column) {
+			operators = append(operators, localOperators...)
+		}
+	}
+	return operators
+}
+
+// tableNames lists the tables of the current API, those of other loaded APIs
+// by their qualified names, and the virtual tables describing them
+func (s *shell) tableNames() []string {
+	names := sortedKeys(s.apis.capabilities[s.apis.apiConfig.Name])
+	for _, api := range sortedKeys(s.apis.capabilities) {
+		if api == s.apis.apiConfig.Name {
+			continue
+		}
+		for _, table := range sortedKeys(s.apis.capabilities[api]) {
+			names = append(names, api+"."+table)
+		}
+	}
+	return append(names, translator.MetadataTableNames()...)
+}
+
+// statementGrammars returns the grammars of the tables a statement names so
+// far; names that do not resolve are skipped
+func (s *shell) statementGrammars(statement string) []grammar.SQLGrammar {
+	var grammars []grammar.SQLGrammar
+	fields := strings.Fields(statement)
+	for i := 0; i+1 < len(fields); i++ {
+		switch strings.ToUpper(fields[i]) {
+		case "FROM", "JOIN", "INTO", "UPDATE":
+			name := strings.TrimRight(fields[i+1], ";,()")
+			if _, tableGrammar, err := s.apis.resolve(name); err == nil {
+				grammars = append(grammars, tableGrammar)
+			}
+		}
+	}
+	return grammars
+}
+
+// matchCase lower-cases keywords when the word being completed is lower case
+func matchCase(keywords []string, word string) []string {
+	if word == "" || word != strings.ToLower(word) {
+		return keywords
+	}
+	lower := make([]string, len(keywords))
+	for i, keyword := range keywords {
+		lower[i] = strings.ToLower(keyword)
+	}
+	return lower
+}
+
+// commonPrefix returns the longest prefix, ignoring case, that all words
+// share, as the first word spells it
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		n := 0
+		for n < len(prefix) && n < len(word) && strings.EqualFold(prefix[n:n+1], word[n:n+1]) {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return prefix
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
+
+// interruptReader notes a Ctrl-C in the input, which the terminal reports as
+// io.EOF just like a Ctrl-D on an empty line
+type interruptReader struct {
+	reader      io.Reader
+	interrupted bool
+}
+
+func (r *interruptReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if bytes.IndexByte(p[:n], 3) >= 0 {
+		r.interrupted = true
+	}
+	return n, err
+}
+
+// shellHistory is the terminal's history, kept in a file so that it carries
+// over between sessions. Writing it is best effort.
+type shellHistory struct {
+	path    string
+	entries []string // oldest first
+}
+
+func loadShellHistory(path string) *shellHistory {
+	h := &shellHistory{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return h
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+		os.WriteFile(path, []byte(strings.Join(h.entries, "\n")+"\n"), 0600)
+	}
+	return h
+}
+
+func (h *shellHistory) Add(entry string) {
+	if strings.TrimSpace(entry) == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry) {
+		return
+	}
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[1:]
+	}
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return
+	}
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	file.WriteString(entry + "\n")
+}
+
+func (h *shellHistory) Len() int {
+	return len(h.entries)
+}
+
+func (h *shellHistory) At(idx int) string {
+	return h.entries[len(h.entries)-1-idx]
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/simonm/qRest/internal/grammar"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		text       string
+		statements []string
+		rest       string
+	}{
+		{"SELECT * FROM pets", nil, "SELECT * FROM pets"},
+		{"SELECT * FROM pets;", []string{"SELECT * FROM pets"}, ""},
+		{"SELECT 1; SELECT 2;\nSELECT", []string{"SELECT 1", "SELECT 2"}, "\nSELECT"},
+		{";;  ;", nil, ""},
+		{"SELECT * FROM pets WHERE name = 'a;b';", []string{"SELECT * FROM pets WHERE name = 'a;b'"}, ""},
+		{"SELECT \"x;y\" FROM pets;", []string{"SELECT \"x;y\" FROM pets"}, ""},
+		{"SELECT * FROM pets WHERE name = 'it''s';", []string{"SELECT * FROM pets WHERE name = 'it''s'"}, ""},
+		{"SELECT * FROM pets WHERE name = 'open;", nil, "SELECT * FROM pets WHERE name = 'open;"},
+		{"SELECT 1 -- don't; stop\n;", []string{"SELECT 1 -- don't; stop"}, ""},
+		{"SELECT /* a; 'b */ 1;", []string{"SELECT /* a; 'b */ 1"}, ""},
+		{"SELECT /* open; ", nil, "SELECT /* open; "},
+		{"SELECT 2 - -1;", []string{"SELECT 2 - -1"}, ""},
+	}
+	for _, tt := range tests {
+		statements, rest := splitStatements(tt.text)
+		if !reflect.DeepEqual(statements, tt.statements) || rest != tt.rest {
+			t.Errorf("splitStatements(%q) = %q, %q, want %q, %q", tt.text, statements, rest, tt.statements, tt.rest)
+		}
+	}
+}
+
+func TestConditionOperators(t *testing.T) {
+	pets := grammar.SQLGrammar{
+		TableName: "pets",
+		WhereClause: grammar.WhereGrammar{
+			AllowedColumns: map[string][]string{"status": {"=", "IN"}},
+			LocalColumns:   []string{"age"},
+		},
+	}
+	tests := []struct {
+		before string
+		want   []string
+	}{
+		{"SELECT * FROM pets WHERE status", []string{"=", "IN"}},
+		{"SELECT * FROM pets p WHERE p.status", []string{"=", "IN"}},
+		{"SELECT * FROM pets WHERE status = 'sold' AND age", localOperators},
+		{"SELECT * FROM pets WHERE name", nil},
+		{"SELECT status", nil},
+		{"SELECT * FROM pets ORDER BY status", nil},
+	}
+	for _, tt := range tests {
+		if got := conditionOperators(strings.Fields(tt.before), []grammar.SQLGrammar{pets}); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.before, got, tt.want)
+		}
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		words []string
+		want  string
+	}{
+		{[]string{"status"}, "status"},
+		{[]string{"status", "stock"}, "st"},
+		{[]string{"SELECT", "select_all"}, "SELECT"},
+		{[]string{"name", "id"}, ""},
+	}
+	for _, tt := range tests {
+		if got := commonPrefix(tt.words); got != tt.want {
+			t.Errorf("commonPrefix(%q) = %q, want %q", tt.words, got, tt.want)
+		}
+	}
+}
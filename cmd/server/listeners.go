@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/simonm/qRest/internal/config"
+	"github.com/simonm/qRest/internal/executor"
+	"github.com/simonm/qRest/internal/grammar"
+	"github.com/simonm/qRest/internal/mysqlwire"
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/pgwire"
+	"github.com/simonm/qRest/internal/translator"
+)
+
+// wireBackend serves the gateway's tables over the database wire protocols
+type wireBackend struct {
+	gateway *SQLGateway
+}
+
+func (b wireBackend) Tables() map[string]parser.APICapability {
+	return b.gateway.capabilities
+}
+
+func (b wireBackend) Grammars() map[string]grammar.SQLGrammar {
+	return b.gateway.grammars
+}
+
+func (b wireBackend) Translate(sql string) (*translator.ParsedQuery, []executor.JoinTable, error) {
+	query, tables, _, err := b.gateway.translate(sql)
+	return query, tables, err
+}
+
+// startPostgres listens for Postgres clients next to the HTTP API, failing
+// when the port is taken
+func startPostgres(gateway *SQLGateway, cfg *config.Config) error {
+	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Postgres.Port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to start Postgres listener: %w", err)
+	}
+
+	server := pgwire.NewServer(wireBackend{gateway: gateway}, cfg.Server.Postgres.Password)
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Printf("Postgres listener stopped: %v", err)
+		}
+	}()
+
+	fmt.Printf("\nPostgres wire protocol listening on %s\n", addr)
+	if cfg.Server.Postgres.Password == "" {
+		fmt.Printf("  Warning: no password set; any client can connect\n")
+	}
+	fmt.Printf("  psql -h %s -p %d -U qrest qrest\n", cfg.Server.Host, cfg.Server.Postgres.Port)
+	return nil
+}
+
+// startMySQL listens for MySQL clients next to the HTTP API, failing when
+// the port is taken
+func startMySQL(gateway *SQLGateway, cfg *config.Config) error {
+	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.MySQL.Port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to start MySQL listener: %w", err)
+	}
+
+	server := mysqlwire.NewServer(wireBackend{gateway: gateway}, cfg.Server.MySQL.Password)
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Printf("MySQL listener stopped: %v", err)
+		}
+	}()
+
+	fmt.Printf("\nMySQL wire protocol listening on %s\n", addr)
+	if cfg.Server.MySQL.Password == "" {
+		fmt.Printf("  Warning: no password set; any client can connect\n")
+	}
+	fmt.Printf("  mysql -h %s -P %d -u qrest -p qrest\n", cfg.Server.Host, cfg.Server.MySQL.Port)
+	return nil
+}
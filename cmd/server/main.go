@@ -8,6 +8,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/cobra"
+	"github.com/simonm/qRest/internal/auth"
+	"github.com/simonm/qRest/internal/cache"
 	"github.com/simonm/qRest/internal/config"
 	"github.com/simonm/qRest/internal/executor"
 	"github.com/simonm/qRest/internal/grammar"
@@ -19,7 +21,8 @@ type SQLGateway struct {
 	config       *config.Config
 	capabilities map[string]parser.APICapability
 	grammars     map[string]grammar.SQLGrammar
-	executor     *executor.RESTExecutor
+	executors    map[string]*executor.RESTExecutor // by table, each with its API's credentials
+	responses    *cache.Cache                      // response cache shared by the executors
 }
 
 type QueryRequest struct {
@@ -28,6 +31,7 @@ type QueryRequest struct {
 
 type QueryResponse struct {
 	Data         []map[string]interface{} `json:"data,omitempty"`
+	Columns      []string                 `json:"columns,omitempty"`
 	Total        int                      `json:"total"`
 	Error        string                   `json:"error,omitempty"`
 	Warnings     []string                 `json:"warnings,omitempty"`
@@ -111,6 +115,10 @@ func runServer(cmd *cobra.Command, args []string) error {
 	// Configuration endpoint
 	r.GET("/config", gateway.handleConfig)
 
+	// Response cache endpoints
+	r.GET("/cache", gateway.handleCache)
+	r.DELETE("/cache", gateway.handleCachePurge)
+
 	// Start server
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
 	
@@ -120,12 +128,26 @@ func runServer(cmd *cobra.Command, args []string) error {
 	fmt.Printf("  GET /grammar - View allowed SQL grammar\n")
 	fmt.Printf("  GET /capabilities - View API capabilities\n")
 	fmt.Printf("  GET /config - View configuration\n")
+	fmt.Printf("  GET /cache - Inspect the response cache\n")
+	fmt.Printf("  DELETE /cache - Purge the response cache (?table= for one table)\n")
 	fmt.Printf("  GET /health - Health check\n")
 	fmt.Printf("\nConfigured APIs: %d\n", len(cfg.APIs))
 	for _, api := range cfg.APIs {
 		fmt.Printf("  - %s (%s)\n", api.Name, api.BaseURL)
 	}
 
+	if cfg.Server.Postgres.Port > 0 {
+		if err := startPostgres(gateway, cfg); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Server.MySQL.Port > 0 {
+		if err := startMySQL(gateway, cfg); err != nil {
+			return err
+		}
+	}
+
 	if err := r.Run(addr); err != nil {
 		return fmt.Errorf("failed to start server: %w", err)
 	}
@@ -138,6 +160,8 @@ func initializeGateway(cfg *config.Config) (*SQLGateway, error) {
 		config:       cfg,
 		capabilities: make(map[string]parser.APICapability),
 		grammars:     make(map[string]grammar.SQLGrammar),
+		executors:    make(map[string]*executor.RESTExecutor),
+		responses:    cache.New(cache.NewStore(cfg.Defaults.CacheBackend, config.GetResponseCacheDir())),
 	}
 
 	if len(cfg.APIs) == 0 {
@@ -148,6 +172,8 @@ func initializeGateway(cfg *config.Config) (*SQLGateway, error) {
 
 	// Initialize parsers and grammars for each API
 	grammarGen := grammar.NewGrammarGenerator()
+	specCache := parser.NewSpecCache(config.GetSpecCacheDir())
+	specCache.SetOffline(cfg.Defaults.Offline)
 	
 	for _, apiCfg := range cfg.APIs {
 		// Parse OpenAPI spec
@@ -156,11 +182,13 @@ func initializeGateway(cfg *config.Config) (*SQLGateway, error) {
 			apiCfg.BaseURL,
 			apiCfg.Auth.Type,
 			apiCfg.Auth.Token,
+			specCache,
 		)
 		if err != nil {
 			log.Printf("Warning: Failed to load API spec for '%s': %v", apiCfg.Name, err)
 			continue
 		}
+		apiParser.SetFlattenNested(apiCfg.FlattenNested)
 
 		// Extract capabilities
 		capabilities, err := apiParser.ParseCapabilities()
@@ -169,6 +197,18 @@ func initializeGateway(cfg *config.Config) (*SQLGateway, error) {
 			continue
 		}
 
+		authenticator, err := auth.NewSchemeResolver(apiCfg.Auth)
+		if err != nil {
+			log.Printf("Warning: Failed to set up auth for '%s': %v", apiCfg.Name, err)
+			continue
+		}
+		apiExecutor := executor.NewRESTExecutor(authenticator)
+		apiExecutor.SetTimeout(apiCfg.GetRequestTimeout(&cfg.Defaults))
+		apiExecutor.SetMaxRequests(cfg.Defaults.MaxRequests)
+		apiExecutor.SetFlattenNested(apiCfg.FlattenNested)
+		apiExecutor.SetRetryPolicy(apiCfg.Retry.Attempts, apiCfg.Retry.GetRetryDelay(), apiCfg.Retry.RetryPost)
+		apiExecutor.SetCache(gateway.responses, apiCfg.GetResponseCacheTTL(&cfg.Defaults))
+
 		// Generate grammars
 		for _, capability := range capabilities {
 			// Prefix table name with API name to avoid conflicts
@@ -177,16 +217,17 @@ func initializeGateway(cfg *config.Config) (*SQLGateway, error) {
 				tableName = apiCfg.Name + "_" + capability.TableName
 			}
 			
+			tableGrammar := grammarGen.GenerateGrammar(capability)
+			tableGrammar.TableName = tableName
+			
 			gateway.capabilities[tableName] = capability
-			gateway.grammars[tableName] = grammarGen.GenerateGrammar(capability)
+			gateway.grammars[tableName] = tableGrammar
+			gateway.executors[tableName] = apiExecutor
 			
 			log.Printf("Loaded table '%s' from API '%s'", tableName, apiCfg.Name)
 		}
 	}
 
-	// Initialize REST executor (will be created per-request with appropriate auth)
-	gateway.executor = executor.NewRESTExecutor("", "")
-
 	return gateway, nil
 }
 
@@ -199,44 +240,17 @@ func (g *SQLGateway) handleQuery(c *gin.Context) {
 		return
 	}
 
-	// Parse SQL to extract table name
-	tableName, err := g.extractTableName(req.SQL)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, QueryResponse{
-			Error: fmt.Sprintf("Failed to parse SQL: %v", err),
-		})
-		return
-	}
-
-	// Find corresponding capability and grammar
-	capability, exists := g.capabilities[tableName]
-	if !exists {
-		available := make([]string, 0, len(g.capabilities))
-		for table := range g.capabilities {
-			available = append(available, table)
-		}
-		
-		c.JSON(http.StatusBadRequest, QueryResponse{
-			Error: fmt.Sprintf("Table '%s' not found. Available tables: %v", tableName, available),
-		})
-		return
-	}
-
-	grammar := g.grammars[tableName]
-
-	// Parse and validate SQL
-	sqlTranslator := translator.NewSimpleSQLTranslator(grammar)
-	parsedQuery, err := sqlTranslator.ParseSQL(req.SQL)
+	parsedQuery, tables, suggestions, err := g.translate(req.SQL)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, QueryResponse{
 			Error: err.Error(),
-			Suggestions: grammar.WhereClause.Suggestions,
+			Suggestions: suggestions,
 		})
 		return
 	}
 
 	// Execute query
-	result, err := g.executor.ExecuteQuery(capability, parsedQuery)
+	result, err := executor.Execute(tables, parsedQuery)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, QueryResponse{
 			Error: fmt.Sprintf("Query execution failed: %v", err),
@@ -247,6 +261,7 @@ func (g *SQLGateway) handleQuery(c *gin.Context) {
 	// Return results
 	response := QueryResponse{
 		Data:     result.Data,
+		Columns:  result.Columns,
 		Total:    result.Total,
 		Warnings: result.Warnings,
 	}
@@ -258,6 +273,49 @@ func (g *SQLGateway) handleQuery(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// translate resolves the tables a statement names and parses it against
+// their grammars. When the statement does not fit the grammar, the FROM
+// table's suggestions are returned with the error.
+func (g *SQLGateway) translate(sql string) (*translator.ParsedQuery, []executor.JoinTable, []string, error) {
+	// Parse SQL to extract the tables it reads from
+	tableNames, err := g.extractTableNames(sql)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Failed to parse SQL: %v", err)
+	}
+
+	// Find corresponding capabilities and grammars
+	tables := make([]executor.JoinTable, len(tableNames))
+	joinGrammars := make(map[string]grammar.SQLGrammar)
+	for i, tableName := range tableNames {
+		capability, exists := g.capabilities[tableName]
+		if !exists {
+			if table, ok := translator.LookupMetadataTable(tableName, g.capabilities, g.grammars); ok {
+				tables[i] = executor.JoinTable{Capability: table.Capability, Records: table.Records}
+				joinGrammars[tableName] = table.Grammar
+				continue
+			}
+			available := make([]string, 0, len(g.capabilities))
+			for table := range g.capabilities {
+				available = append(available, table)
+			}
+			return nil, nil, nil, fmt.Errorf("Table '%s' not found. Available tables: %v", tableName, available)
+		}
+		tables[i] = executor.JoinTable{Capability: capability, Executor: g.executors[tableName]}
+		joinGrammars[tableName] = g.grammars[tableName]
+	}
+
+	grammar := joinGrammars[tableNames[0]]
+
+	// Parse and validate SQL
+	sqlTranslator := translator.NewSimpleSQLTranslator(grammar)
+	sqlTranslator.SetJoinTables(joinGrammars)
+	parsedQuery, err := sqlTranslator.ParseSQL(sql)
+	if err != nil {
+		return nil, nil, grammar.WhereClause.Suggestions, err
+	}
+	return parsedQuery, tables, nil, nil
+}
+
 func (g *SQLGateway) handleGrammar(c *gin.Context) {
 	tableName := c.Query("table")
 	
@@ -302,6 +360,7 @@ func (g *SQLGateway) handleConfig(c *gin.Context) {
 			"base_url":    api.BaseURL,
 			"auth_type":   api.Auth.Type,
 			"timeout":     api.Timeout,
+			"flatten_nested": api.FlattenNested,
 		}
 	}
 	
@@ -318,22 +377,27 @@ func (g *SQLGateway) handleConfig(c *gin.Context) {
 	c.JSON(http.StatusOK, configInfo)
 }
 
-func (g *SQLGateway) extractTableName(sql string) (string, error) {
-	// Quick extraction - for a more robust solution, we'd use the full SQL parser
-	// This is a simplified version for demo purposes
-	sqlLower := strings.ToLower(sql)
-	fromIndex := strings.Index(sqlLower, "from ")
-	if fromIndex == -1 {
-		return "", fmt.Errorf("no FROM clause found in SQL")
-	}
+func (g *SQLGateway) handleCache(c *gin.Context) {
+	c.JSON(http.StatusOK, g.responses.Stats())
+}
 
-	// Extract table name after FROM
-	remaining := sql[fromIndex+5:]
-	words := strings.Fields(remaining)
-	if len(words) == 0 {
-		return "", fmt.Errorf("no table name found after FROM")
+func (g *SQLGateway) handleCachePurge(c *gin.Context) {
+	tableName := c.Query("table")
+	if tableName == "" {
+		c.JSON(http.StatusOK, gin.H{"purged": g.responses.Purge()})
+		return
 	}
 
-	return strings.TrimSpace(words[0]), nil
+	capability, exists := g.capabilities[tableName]
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("Table '%s' not found", tableName),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"purged": g.executors[tableName].InvalidateCache(capability)})
 }
 
+func (g *SQLGateway) extractTableNames(sql string) ([]string, error) {
+	return translator.ExtractTableNames(sql)
+}
@@ -0,0 +1,64 @@
+// Package apitest serves JSON test APIs from httptest servers and records
+// the requests they receive, for the tests of packages that call REST APIs
+package apitest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// Server is a test API that records each request it receives as the
+// function it was started with describes it
+type Server struct {
+	*httptest.Server
+
+	describe func(*http.Request) string
+	mu       sync.Mutex
+	requests []string
+}
+
+// New starts a test API answering with handler. It is closed when the test
+// ends.
+func New(t testing.TB, describe func(*http.Request) string, handler http.HandlerFunc) *Server {
+	s := &Server{describe: describe}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, s.describe(r))
+		s.mu.Unlock()
+		handler(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Requests returns the requests received so far
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// RequestURI describes a request by its path and query string
+func RequestURI(r *http.Request) string {
+	return r.URL.RequestURI()
+}
+
+// Query describes a request by its query string
+func Query(r *http.Request) string {
+	return r.URL.RawQuery
+}
+
+// MethodPath describes a request by its method and path, e.g. "GET /pets"
+func MethodPath(r *http.Request) string {
+	return r.Method + " " + r.URL.Path
+}
+
+// WriteJSON answers a request with body encoded as JSON
+func WriteJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,83 @@
+package apitest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// Pets are the records a pets API serves
+var Pets = []map[string]interface{}{
+	{"id": 1, "name": "Rex", "status": "sold", "born": "2019-05-04T10:30:00Z", "vaccinated": true},
+	{"id": 2, "name": "Bo", "status": "available", "born": nil, "vaccinated": false},
+	{"id": 3, "name": "Tom", "status": "sold", "born": "2021-11-20T08:00:00Z", "vaccinated": true},
+}
+
+// PetsSpec describes the API NewPets serves, without servers, so the base
+// URL comes from the configuration
+const PetsSpec = `openapi: 3.0.3
+info: {title: pets, version: '1'}
+paths:
+  /pets:
+    get:
+      parameters:
+        - {name: status, in: query, schema: {type: string}}
+      responses:
+        '200':
+          description: pets
+          content:
+            application/json:
+              schema: {type: array, items: {$ref: '#/components/schemas/Pet'}}
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/Pet'}
+      responses:
+        '201':
+          description: created
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Pet'}
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [id, name]
+      properties:
+        id: {type: integer, format: int64}
+        name: {type: string}
+        status: {type: string}
+        born: {type: string, format: date-time, nullable: true}
+        vaccinated: {type: boolean}
+`
+
+// NewPets serves Pets from /pets, filtered by the status query parameter,
+// and creates pet 4 for a POST to /pets. It records the path and query
+// string of every request.
+func NewPets(t testing.TB) *Server {
+	return New(t, RequestURI, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pets" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			var pet map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&pet); err != nil {
+				WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			pet["id"] = 4
+			WriteJSON(w, http.StatusCreated, pet)
+			return
+		}
+
+		matched := []map[string]interface{}{}
+		for _, pet := range Pets {
+			if status := r.URL.Query().Get("status"); status == "" || status == pet["status"] {
+				matched = append(matched, pet)
+			}
+		}
+		WriteJSON(w, http.StatusOK, matched)
+	})
+}
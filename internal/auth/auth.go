@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/simonm/qRest/internal/config"
+)
+
+// Authenticator adds an API's credentials to each request sent to it
+type Authenticator interface {
+	// Authenticate sets the credentials on a request about to be sent
+	Authenticate(req *http.Request) error
+	// Identity tells apart the responses fetched with different credentials
+	// in the response cache, which hashes it into its keys
+	Identity() string
+}
+
+// New returns the authenticator for an API's auth settings, which
+// config.LoadConfig has validated
+func New(cfg config.AuthConfig) (Authenticator, error) {
+	switch strings.ToLower(cfg.Type) {
+	case "", "none":
+		return None{}, nil
+	case "bearer":
+		return Bearer{Token: cfg.Token}, nil
+	case "apikey":
+		if cfg.Query != "" {
+			return APIKey{Key: cfg.Token, Name: cfg.Query, InQuery: true}, nil
+		}
+		name := cfg.Header
+		if name == "" {
+			name = "X-API-Key"
+		}
+		return APIKey{Key: cfg.Token, Name: name}, nil
+	case "basic":
+		return basic(cfg), nil
+	case "oauth2":
+		return NewOAuth2(cfg.TokenURL, cfg.ClientID, cfg.ClientSecret, cfg.RefreshToken, cfg.Scopes, cfg.Params), nil
+	case "custom":
+		return Headers(cfg.Headers), nil
+	}
+	return nil, fmt.Errorf("unsupported auth type: %s", cfg.Type)
+}
+
+// None sends requests without credentials
+type None struct{}
+
+func (None) Authenticate(req *http.Request) error { return nil }
+
+func (None) Identity() string { return "none" }
+
+// Bearer sends a static token in the Authorization header
+type Bearer struct {
+	Token string
+}
+
+func (b Bearer) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+b.Token)
+	return nil
+}
+
+func (b Bearer) Identity() string { return "bearer:" + b.Token }
+
+// APIKey sends a key in a header or, with InQuery, a query parameter
+type APIKey struct {
+	Key     string
+	Name    string // header or query parameter
+	InQuery bool
+}
+
+func (k APIKey) Authenticate(req *http.Request) error {
+	if k.InQuery {
+		query := req.URL.Query()
+		query.Set(k.Name, k.Key)
+		req.URL.RawQuery = query.Encode()
+		return nil
+	}
+	req.Header.Set(k.Name, k.Key)
+	return nil
+}
+
+func (k APIKey) Identity() string {
+	return fmt.Sprintf("apikey:%s:%t:%s", k.Name, k.InQuery, k.Key)
+}
+
+// Basic sends a username and password with HTTP basic auth
+type Basic struct {
+	Username string
+	Password string
+}
+
+func (b Basic) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(b.Username+":"+b.Password)))
+	return nil
+}
+
+func (b Basic) Identity() string { return "basic:" + b.Username + ":" + b.Password }
+
+// basic reads basic auth credentials from a username and password, or from
+// a token holding "username:password"
+func basic(cfg config.AuthConfig) Basic {
+	if cfg.Username != "" {
+		return Basic{Username: cfg.Username, Password: cfg.Password}
+	}
+	username, password, _ := strings.Cut(cfg.Token, ":")
+	return Basic{Username: username, Password: password}
+}
+
+// Headers sends a fixed set of headers, for schemes the others do not cover
+type Headers map[string]string
+
+func (h Headers) Authenticate(req *http.Request) error {
+	for name, value := range h {
+		req.Header.Set(name, value)
+	}
+	return nil
+}
+
+func (h Headers) Identity() string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var identity strings.Builder
+	identity.WriteString("custom")
+	for _, name := range names {
+		identity.WriteString("\x00" + http.CanonicalHeaderKey(name) + ":" + h[name])
+	}
+	return identity.String()
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/simonm/qRest/internal/config"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    config.AuthConfig
+		header string // the header the request carries, as "Name: value"
+		query  string
+	}{
+		{name: "none", cfg: config.AuthConfig{}},
+		{name: "bearer", cfg: config.AuthConfig{Type: "Bearer", Token: "secret"}, header: "Authorization: Bearer secret"},
+		{name: "apikey header", cfg: config.AuthConfig{Type: "apikey", Token: "secret"}, header: "X-Api-Key: secret"},
+		{name: "apikey named header", cfg: config.AuthConfig{Type: "apikey", Token: "secret", Header: "api_key"}, header: "Api_key: secret"},
+		{name: "apikey query", cfg: config.AuthConfig{Type: "apikey", Token: "a b", Query: "key"}, query: "key=a+b&status=sold"},
+		{name: "basic token", cfg: config.AuthConfig{Type: "basic", Token: "user:pa:ss"}, header: "Authorization: Basic dXNlcjpwYTpzcw=="},
+		{name: "basic username", cfg: config.AuthConfig{Type: "basic", Username: "user", Password: "pass"}, header: "Authorization: Basic dXNlcjpwYXNz"},
+		{name: "custom", cfg: config.AuthConfig{Type: "custom", Headers: map[string]string{"x-tenant": "acme"}}, header: "X-Tenant: acme"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator, err := New(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, _ := http.NewRequest("GET", "https://api.example.com/pets?status=sold", nil)
+			if err := authenticator.Authenticate(req); err != nil {
+				t.Fatal(err)
+			}
+			var headers []string
+			for name := range req.Header {
+				headers = append(headers, name+": "+req.Header.Get(name))
+			}
+			switch {
+			case tt.header == "" && len(headers) > 0:
+				t.Errorf("got headers %q, want none", headers)
+			case tt.header != "" && (len(headers) != 1 || headers[0] != tt.header):
+				t.Errorf("got headers %q, want %q", headers, tt.header)
+			}
+			query := tt.query
+			if query == "" {
+				query = "status=sold"
+			}
+			if req.URL.RawQuery != query {
+				t.Errorf("got query %q, want %q", req.URL.RawQuery, query)
+			}
+		})
+	}
+
+	if _, err := New(config.AuthConfig{Type: "kerberos"}); err == nil {
+		t.Error("kerberos: got no error")
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	authenticators := []Authenticator{
+		None{},
+		Bearer{Token: "a"},
+		Bearer{Token: "b"},
+		APIKey{Key: "a", Name: "key"},
+		APIKey{Key: "a", Name: "key", InQuery: true},
+		Basic{Username: "a", Password: "b"},
+		Headers{"X-Tenant": "a"},
+		Headers{"X-Tenant": "b"},
+		NewOAuth2("https://auth.example.com/token", "client", "secret", "", nil, nil),
+		NewOAuth2("https://auth.example.com/token", "client", "secret", "", []string{"read"}, nil),
+	}
+	seen := make(map[string]int)
+	for i, authenticator := range authenticators {
+		identity := authenticator.Identity()
+		if j, exists := seen[identity]; exists {
+			t.Errorf("%#v and %#v share identity %q", authenticators[j], authenticator, identity)
+		}
+		seen[identity] = i
+	}
+	if Headers(map[string]string{"x-a": "1", "X-B": "2"}).Identity() != Headers(map[string]string{"X-B": "2", "x-a": "1"}).Identity() {
+		t.Error("identity of custom headers depends on their order")
+	}
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// expiryMargin renews an access token this long before it expires, so that
+// it does not lapse while a request is in flight
+const expiryMargin = 30 * time.Second
+
+// OAuth2 fetches access tokens from a token endpoint with the client
+// credentials grant, or the refresh token grant when given a refresh token,
+// and sends them as bearer tokens. A token is reused until it is about to
+// expire; a token served without expires_in is kept for the process.
+type OAuth2 struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	params       map[string]string // extra token request parameters, such as audience
+	client       *http.Client
+	identity     string
+
+	mu           sync.Mutex
+	refreshToken string // rotated when the endpoint issues a new one
+	accessToken  string
+	tokenType    string
+	expiry       time.Time // zero when the token does not expire
+}
+
+// tokenResponse is the token endpoint's reply, success or error (RFC 6749 5.1, 5.2)
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func NewOAuth2(tokenURL, clientID, clientSecret, refreshToken string, scopes []string, params map[string]string) *OAuth2 {
+	return &OAuth2{
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		refreshToken: refreshToken,
+		scopes:       scopes,
+		params:       params,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		// The refresh token stands for the user it was issued to; the one
+		// configured is used, since the endpoint may rotate it
+		identity: "oauth2:" + tokenURL + ":" + clientID + ":" + refreshToken + ":" + strings.Join(scopes, " "),
+	}
+}
+
+func (o *OAuth2) Authenticate(req *http.Request) error {
+	tokenType, token, err := o.token()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", tokenType+" "+token)
+	return nil
+}
+
+func (o *OAuth2) Identity() string {
+	return o.identity
+}
+
+// token returns the cached access token, fetching a new one once it is due
+func (o *OAuth2) token() (string, string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.accessToken != "" && (o.expiry.IsZero() || time.Now().Before(o.expiry.Add(-expiryMargin))) {
+		return o.tokenType, o.accessToken, nil
+	}
+
+	if o.tokenURL == "" {
+		return "", "", fmt.Errorf("no token_url: set it or declare an oauth2 scheme in the spec")
+	}
+
+	form := url.Values{}
+	if o.refreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", o.refreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if len(o.scopes) > 0 {
+		form.Set("scope", strings.Join(o.scopes, " "))
+	}
+	for name, value := range o.params {
+		form.Set(name, value)
+	}
+	if o.clientSecret == "" {
+		// A public client only identifies itself
+		form.Set("client_id", o.clientID)
+	}
+
+	req, err := http.NewRequest("POST", o.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if o.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(o.clientID), url.QueryEscape(o.clientSecret))
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", "", fmt.Errorf("token request failed: %w", err)
+	}
+	var reply tokenResponse
+	if err := json.Unmarshal(body, &reply); err != nil && resp.StatusCode < 400 {
+		return "", "", fmt.Errorf("invalid token response: %w", err)
+	}
+	if resp.StatusCode >= 400 || reply.AccessToken == "" {
+		if reply.Error != "" {
+			return "", "", fmt.Errorf("token request failed: %s %s", reply.Error, reply.ErrorDescription)
+		}
+		return "", "", fmt.Errorf("token request failed: %s", resp.Status)
+	}
+
+	o.accessToken = reply.AccessToken
+	o.tokenType = "Bearer"
+	if reply.TokenType != "" && !strings.EqualFold(reply.TokenType, "bearer") {
+		o.tokenType = reply.TokenType
+	}
+	o.expiry = time.Time{}
+	if reply.ExpiresIn > 0 {
+		o.expiry = time.Now().Add(time.Duration(reply.ExpiresIn) * time.Second)
+	}
+	if reply.RefreshToken != "" {
+		o.refreshToken = reply.RefreshToken
+	}
+	return o.tokenType, o.accessToken, nil
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/simonm/qRest/internal/apitest"
+)
+
+// tokenEndpoint issues access tokens token1, token2 and so on, expiring
+// after expiresIn seconds, and a new refresh token with each when rotate is
+// set. It answers invalid_client to client secrets other than "secret", and
+// records each request as its basic auth credentials and form.
+type tokenEndpoint struct {
+	*apitest.Server
+
+	expiresIn int
+	rotate    bool
+
+	mu     sync.Mutex
+	issued int
+}
+
+func newTokenEndpoint(t *testing.T, expiresIn int, rotate bool) *tokenEndpoint {
+	endpoint := &tokenEndpoint{expiresIn: expiresIn, rotate: rotate}
+	endpoint.Server = apitest.New(t, func(r *http.Request) string {
+		r.ParseForm()
+		username, password, _ := r.BasicAuth()
+		return username + ":" + password + " " + r.PostForm.Encode()
+	}, endpoint.serve)
+	return endpoint
+}
+
+func (e *tokenEndpoint) serve(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, password, ok := r.BasicAuth(); ok && password != "secret" {
+		apitest.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client", "error_description": "bad secret"})
+		return
+	}
+	e.issued++
+	reply := map[string]interface{}{"access_token": "token" + strconv.Itoa(e.issued), "token_type": "bearer"}
+	if e.expiresIn > 0 {
+		reply["expires_in"] = e.expiresIn
+	}
+	if e.rotate {
+		reply["refresh_token"] = "refresh" + strconv.Itoa(e.issued)
+	}
+	apitest.WriteJSON(w, http.StatusOK, reply)
+}
+
+// authorize authenticates a request with o and returns its Authorization
+// header
+func authorize(t *testing.T, o *OAuth2) string {
+	t.Helper()
+	req, _ := http.NewRequest("GET", "https://api.example.com/pets", nil)
+	if err := o.Authenticate(req); err != nil {
+		t.Fatal(err)
+	}
+	return req.Header.Get("Authorization")
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	endpoint := newTokenEndpoint(t, 3600, false)
+	o := NewOAuth2(endpoint.URL, "client", "secret", "", []string{"read", "write"}, map[string]string{"audience": "pets"})
+
+	for i := 0; i < 2; i++ {
+		if got := authorize(t, o); got != "Bearer token1" {
+			t.Errorf("request %d: got Authorization %q, want Bearer token1", i+1, got)
+		}
+	}
+	want := []string{"client:secret audience=pets&grant_type=client_credentials&scope=read+write"}
+	if requests := endpoint.Requests(); !reflect.DeepEqual(requests, want) {
+		t.Errorf("got token requests %q, want %q", requests, want)
+	}
+}
+
+func TestOAuth2Expiry(t *testing.T) {
+	// Tokens that expire within the margin are renewed for every request
+	endpoint := newTokenEndpoint(t, int(expiryMargin/time.Second), false)
+	o := NewOAuth2(endpoint.URL, "client", "secret", "", nil, nil)
+
+	for _, want := range []string{"Bearer token1", "Bearer token2"} {
+		if got := authorize(t, o); got != want {
+			t.Errorf("got Authorization %q, want %q", got, want)
+		}
+	}
+}
+
+func TestOAuth2RefreshToken(t *testing.T) {
+	endpoint := newTokenEndpoint(t, 1, true)
+	o := NewOAuth2(endpoint.URL, "public", "", "refresh0", nil, nil)
+
+	authorize(t, o)
+	authorize(t, o)
+	// A public client sends its ID in the form, and the rotated refresh
+	// token replaces the configured one
+	want := []string{
+		": client_id=public&grant_type=refresh_token&refresh_token=refresh0",
+		": client_id=public&grant_type=refresh_token&refresh_token=refresh1",
+	}
+	if requests := endpoint.Requests(); !reflect.DeepEqual(requests, want) {
+		t.Errorf("got token requests %q, want %q", requests, want)
+	}
+	if identity := o.Identity(); !strings.Contains(identity, "refresh0") {
+		t.Errorf("identity %q changed with the rotated refresh token", identity)
+	}
+}
+
+func TestOAuth2Errors(t *testing.T) {
+	endpoint := newTokenEndpoint(t, 0, false)
+
+	req, _ := http.NewRequest("GET", "https://api.example.com/pets", nil)
+	err := NewOAuth2(endpoint.URL, "client", "wrong", "", nil, nil).Authenticate(req)
+	if want := "token request failed: invalid_client bad secret"; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+	err = NewOAuth2("", "client", "secret", "", nil, nil).Authenticate(req)
+	if err == nil || !strings.Contains(err.Error(), "no token_url") {
+		t.Errorf("got error %v, want one about the missing token_url", err)
+	}
+}
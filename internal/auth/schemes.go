@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/simonm/qRest/internal/config"
+	"github.com/simonm/qRest/internal/parser"
+)
+
+// Resolver is an Authenticator that can also pick the credentials of each
+// operation from the security requirements its spec declares
+type Resolver interface {
+	Authenticator
+	Resolve(security [][]parser.SecurityScheme) Authenticator
+}
+
+// SchemeResolver sends the configured credentials the way each operation's
+// spec asks for them: in the header or query parameter its apiKey scheme
+// names, as basic or bearer auth, or as OAuth2 tokens from its token URL.
+// Settings given explicitly in the config win over the spec's. Operations
+// the spec declares no security for, or none the credentials satisfy, are
+// sent the configured authenticator as it is.
+type SchemeResolver struct {
+	cfg      config.AuthConfig
+	fallback Authenticator
+
+	mu     sync.Mutex
+	oauth2 map[string]*OAuth2 // by token URL and scopes, so operations share tokens
+}
+
+func NewSchemeResolver(cfg config.AuthConfig) (*SchemeResolver, error) {
+	fallback, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &SchemeResolver{cfg: cfg, fallback: fallback, oauth2: make(map[string]*OAuth2)}, nil
+}
+
+func (r *SchemeResolver) Authenticate(req *http.Request) error {
+	return r.fallback.Authenticate(req)
+}
+
+func (r *SchemeResolver) Identity() string {
+	return r.fallback.Identity()
+}
+
+// Resolve returns the authenticator of the first alternative whose schemes
+// the configured credentials all satisfy
+func (r *SchemeResolver) Resolve(security [][]parser.SecurityScheme) Authenticator {
+	for _, alternative := range security {
+		if len(alternative) == 0 {
+			continue
+		}
+		chain := make(Chain, 0, len(alternative))
+		for _, scheme := range alternative {
+			authenticator, ok := r.schemeAuthenticator(scheme)
+			if !ok {
+				chain = nil
+				break
+			}
+			chain = append(chain, authenticator)
+		}
+		switch len(chain) {
+		case 0:
+			continue
+		case 1:
+			return chain[0]
+		default:
+			return chain
+		}
+	}
+	return r.fallback
+}
+
+// schemeAuthenticator sends the configured credentials as scheme requires,
+// reporting false when they do not fit it
+func (r *SchemeResolver) schemeAuthenticator(scheme parser.SecurityScheme) (Authenticator, bool) {
+	cfg := r.cfg
+	authType := strings.ToLower(cfg.Type)
+	derived := authType == "" // only credentials are configured; the spec says how to send them
+
+	switch scheme.Type {
+	case "apiKey":
+		if (!derived && authType != "apikey") || cfg.Token == "" {
+			return nil, false
+		}
+		if authType == "apikey" && (cfg.Header != "" || cfg.Query != "") {
+			return r.fallback, true
+		}
+		switch scheme.In {
+		case "header":
+			return APIKey{Key: cfg.Token, Name: scheme.ParamName}, true
+		case "query":
+			return APIKey{Key: cfg.Token, Name: scheme.ParamName, InQuery: true}, true
+		}
+	case "http":
+		switch scheme.Scheme {
+		case "basic":
+			if (derived || authType == "basic") && (cfg.Username != "" || strings.Contains(cfg.Token, ":")) {
+				return basic(cfg), true
+			}
+		case "bearer":
+			if (derived || authType == "bearer") && cfg.Token != "" {
+				return Bearer{Token: cfg.Token}, true
+			}
+		}
+	case "oauth2":
+		if authType == "oauth2" || (derived && cfg.ClientID != "") {
+			tokenURL, scopes := cfg.TokenURL, cfg.Scopes
+			if tokenURL == "" {
+				tokenURL = scheme.TokenURL
+			}
+			if len(scopes) == 0 {
+				scopes = scheme.Scopes
+			}
+			if tokenURL == "" {
+				return nil, false
+			}
+			return r.oauth2Client(tokenURL, scopes), true
+		}
+		// A token obtained elsewhere is sent as it is
+		if (derived || authType == "bearer") && cfg.Token != "" {
+			return Bearer{Token: cfg.Token}, true
+		}
+	case "openIdConnect":
+		if (derived || authType == "bearer") && cfg.Token != "" {
+			return Bearer{Token: cfg.Token}, true
+		}
+	}
+	return nil, false
+}
+
+// oauth2Client returns the shared OAuth2 authenticator for a token URL and
+// scopes
+func (r *SchemeResolver) oauth2Client(tokenURL string, scopes []string) *OAuth2 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := tokenURL + " " + strings.Join(scopes, " ")
+	client, exists := r.oauth2[key]
+	if !exists {
+		client = NewOAuth2(tokenURL, r.cfg.ClientID, r.cfg.ClientSecret, r.cfg.RefreshToken, scopes, r.cfg.Params)
+		r.oauth2[key] = client
+	}
+	return client
+}
+
+// Chain sends the credentials of several schemes that an operation requires
+// together
+type Chain []Authenticator
+
+func (c Chain) Authenticate(req *http.Request) error {
+	for _, authenticator := range c {
+		if err := authenticator.Authenticate(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c Chain) Identity() string {
+	identities := make([]string, len(c))
+	for i, authenticator := range c {
+		identities[i] = authenticator.Identity()
+	}
+	return strings.Join(identities, "\x00")
+}
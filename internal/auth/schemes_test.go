@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/simonm/qRest/internal/config"
+	"github.com/simonm/qRest/internal/parser"
+)
+
+var (
+	headerKey   = parser.SecurityScheme{Name: "key", Type: "apiKey", In: "header", ParamName: "api_key"}
+	queryKey    = parser.SecurityScheme{Name: "key", Type: "apiKey", In: "query", ParamName: "token"}
+	basicScheme = parser.SecurityScheme{Name: "basic", Type: "http", Scheme: "basic"}
+	bearer      = parser.SecurityScheme{Name: "jwt", Type: "http", Scheme: "bearer"}
+	oauth2      = parser.SecurityScheme{Name: "oauth", Type: "oauth2", TokenURL: "https://auth.example.com/token", Scopes: []string{"read"}}
+)
+
+func TestSchemeResolverResolve(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.AuthConfig
+		security [][]parser.SecurityScheme
+		want     Authenticator
+	}{
+		{
+			name:     "key in the scheme's header",
+			cfg:      config.AuthConfig{Token: "secret"},
+			security: [][]parser.SecurityScheme{{headerKey}},
+			want:     APIKey{Key: "secret", Name: "api_key"},
+		},
+		{
+			name:     "key in the scheme's query parameter",
+			cfg:      config.AuthConfig{Type: "apikey", Token: "secret"},
+			security: [][]parser.SecurityScheme{{queryKey}},
+			want:     APIKey{Key: "secret", Name: "token", InQuery: true},
+		},
+		{
+			name:     "configured header wins",
+			cfg:      config.AuthConfig{Type: "apikey", Token: "secret", Header: "X-Key"},
+			security: [][]parser.SecurityScheme{{queryKey}},
+			want:     APIKey{Key: "secret", Name: "X-Key"},
+		},
+		{
+			name:     "first alternative the credentials fit",
+			cfg:      config.AuthConfig{Username: "user", Password: "pass"},
+			security: [][]parser.SecurityScheme{{headerKey}, {basicScheme}},
+			want:     Basic{Username: "user", Password: "pass"},
+		},
+		{
+			name:     "schemes used together",
+			cfg:      config.AuthConfig{Token: "secret"},
+			security: [][]parser.SecurityScheme{{headerKey, bearer}},
+			want:     Chain{APIKey{Key: "secret", Name: "api_key"}, Bearer{Token: "secret"}},
+		},
+		{
+			name:     "bearer token for an oauth2 scheme",
+			cfg:      config.AuthConfig{Type: "bearer", Token: "secret"},
+			security: [][]parser.SecurityScheme{{oauth2}},
+			want:     Bearer{Token: "secret"},
+		},
+		{
+			name:     "type that does not fit",
+			cfg:      config.AuthConfig{Type: "bearer", Token: "secret"},
+			security: [][]parser.SecurityScheme{{basicScheme}},
+			want:     Bearer{Token: "secret"},
+		},
+		{
+			name:     "no security",
+			cfg:      config.AuthConfig{Type: "basic", Token: "user:pass"},
+			security: nil,
+			want:     Basic{Username: "user", Password: "pass"},
+		},
+		{
+			name:     "anonymous alternative skipped",
+			cfg:      config.AuthConfig{Token: "secret"},
+			security: [][]parser.SecurityScheme{{}, {bearer}},
+			want:     Bearer{Token: "secret"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver, err := NewSchemeResolver(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := resolver.Resolve(tt.security); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemeResolverOAuth2(t *testing.T) {
+	resolver, err := NewSchemeResolver(config.AuthConfig{ClientID: "client", ClientSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, ok := resolver.Resolve([][]parser.SecurityScheme{{oauth2}}).(*OAuth2)
+	if !ok {
+		t.Fatalf("got %T, want *OAuth2", resolver.Resolve([][]parser.SecurityScheme{{oauth2}}))
+	}
+	if first.tokenURL != oauth2.TokenURL || !reflect.DeepEqual(first.scopes, []string{"read"}) {
+		t.Errorf("got token URL %q and scopes %v, want the scheme's", first.tokenURL, first.scopes)
+	}
+	// Operations with the same token URL and scopes share tokens
+	if second := resolver.Resolve([][]parser.SecurityScheme{{oauth2}}); second != first {
+		t.Error("operations with the same scopes got different clients")
+	}
+	write := oauth2
+	write.Scopes = []string{"write"}
+	if other := resolver.Resolve([][]parser.SecurityScheme{{write}}); other == first {
+		t.Error("operations with different scopes share a client")
+	}
+
+	// Without a token URL in the config or the spec the scheme cannot be
+	// satisfied
+	noURL := oauth2
+	noURL.TokenURL = ""
+	if got := resolver.Resolve([][]parser.SecurityScheme{{noURL}}); got != resolver.fallback {
+		t.Errorf("got %#v, want the configured authenticator", got)
+	}
+}
@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is a cached API response
+type Entry struct {
+	Key       string      `json:"key"`
+	Method    string      `json:"method"`
+	URL       string      `json:"url"`
+	Header    http.Header `json:"header,omitempty"` // validators and paging links
+	Body      []byte      `json:"body"`
+	StoredAt  time.Time   `json:"stored_at"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
+
+// Fresh reports whether the entry can be served without asking the API
+func (e *Entry) Fresh(now time.Time) bool {
+	return now.Before(e.ExpiresAt)
+}
+
+// Store holds cache entries by key
+type Store interface {
+	Get(key string) (*Entry, bool)
+	Put(entry *Entry) error
+	Delete(key string)
+	Entries() []*Entry
+}
+
+// Cache keeps API responses, keyed by method, URL and the identity of the
+// credentials they were fetched with, so that callers with different access
+// never share entries
+type Cache struct {
+	store  Store
+	mu     sync.Mutex
+	hits   int
+	misses int
+}
+
+func New(store Store) *Cache {
+	return &Cache{store: store}
+}
+
+// Key identifies a response. identity stands for the credentials; it is
+// hashed into the key and never stored.
+func Key(method, url, identity string) string {
+	sum := sha256.Sum256([]byte(method + " " + url + "\x00" + identity))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the entry for key, fresh or not; callers revalidate stale
+// entries that carry a validator
+func (c *Cache) Get(key string) (*Entry, bool) {
+	entry, ok := c.store.Get(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ok && entry.Fresh(time.Now()) {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	return entry, ok
+}
+
+// Put stores a response for ttl
+func (c *Cache) Put(key, method, url string, header http.Header, body []byte, ttl time.Duration) error {
+	now := time.Now().UTC()
+	return c.store.Put(&Entry{
+		Key:       key,
+		Method:    method,
+		URL:       url,
+		Header:    header,
+		Body:      body,
+		StoredAt:  now,
+		ExpiresAt: now.Add(ttl),
+	})
+}
+
+// Refresh extends a revalidated entry by ttl
+func (c *Cache) Refresh(entry *Entry, ttl time.Duration) error {
+	refreshed := *entry
+	refreshed.ExpiresAt = time.Now().UTC().Add(ttl)
+	return c.store.Put(&refreshed)
+}
+
+// Invalidate drops the entries whose URL is prefix or lies below it, as in
+// "https://api.example.com/pet" for ".../pet/7" and ".../pet?status=sold".
+// It returns the number of entries dropped.
+func (c *Cache) Invalidate(prefix string) int {
+	dropped := 0
+	for _, entry := range c.store.Entries() {
+		if underPrefix(entry.URL, prefix) {
+			c.store.Delete(entry.Key)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// Purge drops every entry and returns how many there were
+func (c *Cache) Purge() int {
+	entries := c.store.Entries()
+	for _, entry := range entries {
+		c.store.Delete(entry.Key)
+	}
+	return len(entries)
+}
+
+// Stats summarises the cache for inspection
+type Stats struct {
+	Entries int         `json:"entries"`
+	Fresh   int         `json:"fresh"`
+	Bytes   int         `json:"bytes"`
+	Hits    int         `json:"hits"`
+	Misses  int         `json:"misses"`
+	Items   []EntryInfo `json:"items"`
+}
+
+// EntryInfo describes an entry without its body
+type EntryInfo struct {
+	Key       string    `json:"key"`
+	Method    string    `json:"method"`
+	URL       string    `json:"url"`
+	Bytes     int       `json:"bytes"`
+	ETag      string    `json:"etag,omitempty"`
+	StoredAt  time.Time `json:"stored_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Fresh     bool      `json:"fresh"`
+}
+
+// Stats lists the entries, oldest first
+func (c *Cache) Stats() Stats {
+	now := time.Now()
+	entries := c.store.Entries()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].StoredAt.Before(entries[j].StoredAt) })
+
+	c.mu.Lock()
+	stats := Stats{Entries: len(entries), Hits: c.hits, Misses: c.misses, Items: []EntryInfo{}}
+	c.mu.Unlock()
+	for _, entry := range entries {
+		info := EntryInfo{
+			Key:       entry.Key,
+			Method:    entry.Method,
+			URL:       entry.URL,
+			Bytes:     len(entry.Body),
+			ETag:      entry.Header.Get("ETag"),
+			StoredAt:  entry.StoredAt,
+			ExpiresAt: entry.ExpiresAt,
+			Fresh:     entry.Fresh(now),
+		}
+		if info.Fresh {
+			stats.Fresh++
+		}
+		stats.Bytes += info.Bytes
+		stats.Items = append(stats.Items, info)
+	}
+	return stats
+}
+
+func underPrefix(url, prefix string) bool {
+	if !strings.HasPrefix(url, prefix) {
+		return false
+	}
+	rest := url[len(prefix):]
+	return rest == "" || rest[0] == '/' || rest[0] == '?'
+}
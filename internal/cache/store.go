@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxMemoryEntries caps a MemoryStore; once full, unusable entries are
+// dropped and then the oldest
+const maxMemoryEntries = 10000
+
+// MemoryStore keeps entries in process memory, for the server
+type MemoryStore struct {
+	mu         sync.RWMutex
+	entries    map[string]*Entry
+	maxEntries int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*Entry), maxEntries: maxMemoryEntries}
+}
+
+// Get drops an entry it finds unusable, which would otherwise stay as long
+// as the process
+func (s *MemoryStore) Get(key string) (*Entry, bool) {
+	s.mu.RLock()
+	entry, ok := s.entries[key]
+	s.mu.RUnlock()
+	if ok && unusable(entry, time.Now()) {
+		s.mu.Lock()
+		if s.entries[key] == entry {
+			delete(s.entries, key)
+		}
+		s.mu.Unlock()
+		return nil, false
+	}
+	return entry, ok
+}
+
+// Put makes room in a full store before adding a new key
+func (s *MemoryStore) Put(entry *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.entries[entry.Key]; !exists && len(s.entries) >= s.maxEntries {
+		s.sweep(time.Now())
+	}
+	s.entries[entry.Key] = entry
+	return nil
+}
+
+// sweep drops the unusable entries and, if the store is still full, the
+// oldest ones until there is room for one more
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, entry := range s.entries {
+		if unusable(entry, now) {
+			delete(s.entries, key)
+		}
+	}
+	if len(s.entries) < s.maxEntries {
+		return
+	}
+	entries := make([]*Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].StoredAt.Before(entries[j].StoredAt) })
+	for _, entry := range entries[:len(entries)-s.maxEntries+1] {
+		delete(s.entries, entry.Key)
+	}
+}
+
+func (s *MemoryStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+func (s *MemoryStore) Entries() []*Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries := make([]*Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// DiskStore keeps one JSON file per entry in a directory, so that entries
+// outlive a CLI run and are shared between processes
+type DiskStore struct {
+	dir string
+}
+
+// staleRetention is how long an expired entry is kept for revalidation
+// before a DiskStore removes it
+const staleRetention = 24 * time.Hour
+
+// unusable reports whether an entry can no longer be served: it has expired
+// with no validator to revalidate it by, or has been stale for longer than
+// staleRetention
+func unusable(entry *Entry, now time.Time) bool {
+	if entry.Fresh(now) {
+		return false
+	}
+	hasValidator := entry.Header.Get("ETag") != "" || entry.Header.Get("Last-Modified") != ""
+	return !hasValidator || now.Sub(entry.ExpiresAt) > staleRetention
+}
+
+func NewDiskStore(dir string) *DiskStore {
+	return &DiskStore{dir: dir}
+}
+
+func (s *DiskStore) Get(key string) (*Entry, bool) {
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Key != key {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// Put writes through a temporary file so that concurrent runs never read a
+// partial entry
+func (s *DiskStore) Put(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(entry.Key))
+}
+
+func (s *DiskStore) Delete(key string) {
+	os.Remove(s.path(key))
+}
+
+// Entries reads every entry, removing those long expired
+func (s *DiskStore) Entries() []*Entry {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil
+	}
+	var entries []*Entry
+	for _, file := range files {
+		key, isEntry := strings.CutSuffix(file.Name(), ".json")
+		if !isEntry {
+			continue
+		}
+		entry, ok := s.Get(key)
+		if !ok {
+			continue
+		}
+		if time.Since(entry.ExpiresAt) > staleRetention {
+			s.Delete(key)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func (s *DiskStore) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+// NewStore returns the store for a cache_backend setting: "disk" keeps
+// entries under dir, anything else in memory
+func NewStore(backend, dir string) Store {
+	if backend == "disk" {
+		return NewDiskStore(dir)
+	}
+	return NewMemoryStore()
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreGet(t *testing.T) {
+	now := time.Now()
+	withETag := http.Header{"Etag": {`"v1"`}}
+	tests := []struct {
+		name  string
+		entry Entry
+		found bool
+	}{
+		{name: "fresh", entry: Entry{ExpiresAt: now.Add(time.Minute)}, found: true},
+		{name: "expired without a validator", entry: Entry{ExpiresAt: now.Add(-time.Second)}},
+		{name: "expired with an ETag", entry: Entry{Header: withETag, ExpiresAt: now.Add(-time.Hour)}, found: true},
+		{name: "stale past retention", entry: Entry{Header: withETag, ExpiresAt: now.Add(-staleRetention - time.Hour)}},
+	}
+
+	for _, tt := range tests {
+		store := NewMemoryStore()
+		tt.entry.Key = "key"
+		store.Put(&tt.entry)
+
+		if _, found := store.Get("key"); found != tt.found {
+			t.Errorf("%s: got found %v, want %v", tt.name, found, tt.found)
+		}
+		want := 0
+		if tt.found {
+			want = 1
+		}
+		if kept := len(store.Entries()); kept != want {
+			t.Errorf("%s: store kept %d entries, want %d", tt.name, kept, want)
+		}
+	}
+}
+
+func TestMemoryStorePutWhenFull(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore()
+	store.maxEntries = 3
+
+	put := func(key string, age time.Duration, ttl time.Duration) {
+		store.Put(&Entry{Key: key, StoredAt: now.Add(-age), ExpiresAt: now.Add(-age + ttl)})
+	}
+	put("expired", 2*time.Hour, time.Minute)
+	put("old", time.Hour, 24*time.Hour)
+	put("new", time.Minute, 24*time.Hour)
+
+	// The expired entry makes room
+	put("a", 0, time.Hour)
+	if _, found := store.Get("expired"); found {
+		t.Error("expired entry was kept")
+	}
+	// Then the oldest goes
+	put("b", 0, time.Hour)
+	for key, want := range map[string]bool{"old": false, "new": true, "a": true, "b": true} {
+		if _, found := store.Get(key); found != want {
+			t.Errorf("%s: got found %v, want %v", key, found, want)
+		}
+	}
+	// Replacing a key needs no room
+	put("b", 0, 2*time.Hour)
+	if n := len(store.Entries()); n != 3 {
+		t.Errorf("got %d entries, want 3", n)
+	}
+
+	for i := 0; i < 10; i++ {
+		put(strconv.Itoa(i), 0, time.Hour)
+	}
+	if n := len(store.Entries()); n > store.maxEntries {
+		t.Errorf("got %d entries, want at most %d", n, store.maxEntries)
+	}
+}
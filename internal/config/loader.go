@@ -2,9 +2,11 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -72,7 +74,7 @@ func LoadConfig(configPath string) (*Config, error) {
 func LoadConfigFromAPI(specURL, baseURL, authType, authToken string) (*Config, error) {
 	config := GetDefaultConfig()
 	
-	if specURL != "" && baseURL != "" {
+	if specURL != "" {
 		apiConfig := APIConfig{
 			Name:    "cli-api",
 			SpecURL: specURL,
@@ -115,8 +117,11 @@ func setDefaults(v *viper.Viper) {
 	// Default settings
 	v.SetDefault("defaults.max_limit", defaults.Defaults.MaxLimit)
 	v.SetDefault("defaults.default_limit", defaults.Defaults.DefaultLimit)
+	v.SetDefault("defaults.max_requests", defaults.Defaults.MaxRequests)
 	v.SetDefault("defaults.timeout", defaults.Defaults.Timeout)
 	v.SetDefault("defaults.cache_ttl", defaults.Defaults.CacheTTL)
+	v.SetDefault("defaults.offline", defaults.Defaults.Offline)
+	v.SetDefault("defaults.cache_backend", defaults.Defaults.CacheBackend)
 	
 	// Logging defaults
 	v.SetDefault("logging.level", defaults.Logging.Level)
@@ -128,26 +133,98 @@ func setDefaults(v *viper.Viper) {
 func expandEnvVars(config *Config) error {
 	// Expand auth tokens
 	for i := range config.APIs {
-		config.APIs[i].Auth.Token = os.ExpandEnv(config.APIs[i].Auth.Token)
+		auth := &config.APIs[i].Auth
+		auth.Token = os.ExpandEnv(auth.Token)
+		auth.Username = os.ExpandEnv(auth.Username)
+		auth.Password = os.ExpandEnv(auth.Password)
+		auth.ClientID = os.ExpandEnv(auth.ClientID)
+		auth.ClientSecret = os.ExpandEnv(auth.ClientSecret)
+		auth.RefreshToken = os.ExpandEnv(auth.RefreshToken)
 		
-		// Expand custom auth params
-		for key, value := range config.APIs[i].Auth.Params {
-			config.APIs[i].Auth.Params[key] = os.ExpandEnv(value)
+		// Expand oauth2 params and custom headers
+		for key, value := range auth.Params {
+			auth.Params[key] = os.ExpandEnv(value)
+		}
+		for key, value := range auth.Headers {
+			auth.Headers[key] = os.ExpandEnv(value)
 		}
 	}
 	
+	// Expand the wire protocol listener passwords
+	config.Server.Postgres.Password = os.ExpandEnv(config.Server.Postgres.Password)
+	config.Server.MySQL.Password = os.ExpandEnv(config.Server.MySQL.Password)
+	
 	// Expand logging file path
 	config.Logging.File = os.ExpandEnv(config.Logging.File)
 	
 	return nil
 }
 
+// validateAuth checks that an API's auth settings carry what their type needs
+func validateAuth(auth AuthConfig) error {
+	// Types are matched case-insensitively, as auth.New does
+	authType := strings.ToLower(auth.Type)
+	validAuthTypes := []string{"none", "bearer", "apikey", "basic", "oauth2", "custom"}
+	if authType != "" && !contains(validAuthTypes, authType) {
+		return fmt.Errorf("has invalid auth type: %s", auth.Type)
+	}
+	
+	switch authType {
+	case "bearer", "apikey":
+		if auth.Token == "" {
+			return fmt.Errorf("with auth type '%s' missing token", authType)
+		}
+		if authType == "apikey" && auth.Header != "" && auth.Query != "" {
+			return fmt.Errorf("with auth type 'apikey' sets both header and query")
+		}
+	case "basic":
+		if auth.Username == "" && !strings.Contains(auth.Token, ":") {
+			return fmt.Errorf("with auth type 'basic' missing username (or a \"username:password\" token)")
+		}
+	case "oauth2":
+		// Without a token_url, the one of the spec's oauth2 scheme is used
+		if auth.TokenURL != "" {
+			if u, err := url.Parse(auth.TokenURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("with auth type 'oauth2' has invalid token_url: %s", auth.TokenURL)
+			}
+		}
+		if auth.ClientID == "" {
+			return fmt.Errorf("with auth type 'oauth2' missing client_id")
+		}
+		// Only the refresh token grant works for a public client
+		if auth.ClientSecret == "" && auth.RefreshToken == "" {
+			return fmt.Errorf("with auth type 'oauth2' missing client_secret (or refresh_token)")
+		}
+	case "custom":
+		if len(auth.Headers) == 0 {
+			return fmt.Errorf("with auth type 'custom' missing headers")
+		}
+	}
+	return nil
+}
+
 // validateConfig validates the configuration for common errors
 func validateConfig(config *Config) error {
 	// Validate server port
 	if config.Server.Port <= 0 || config.Server.Port > 65535 {
 		return fmt.Errorf("invalid server port: %d", config.Server.Port)
 	}
+	ports := map[int]string{config.Server.Port: "HTTP server"}
+	for _, listener := range []struct {
+		name string
+		port int
+	}{{"postgres", config.Server.Postgres.Port}, {"mysql", config.Server.MySQL.Port}} {
+		if listener.port < 0 || listener.port > 65535 {
+			return fmt.Errorf("invalid %s port: %d", listener.name, listener.port)
+		}
+		if listener.port == 0 {
+			continue
+		}
+		if other, taken := ports[listener.port]; taken {
+			return fmt.Errorf("%s port %d is already the %s port", listener.name, listener.port, other)
+		}
+		ports[listener.port] = listener.name
+	}
 	
 	// Validate APIs
 	apiNames := make(map[string]bool)
@@ -165,20 +242,13 @@ func validateConfig(config *Config) error {
 		if api.SpecURL == "" {
 			return fmt.Errorf("API '%s' missing spec_url", api.Name)
 		}
-		if api.BaseURL == "" {
-			return fmt.Errorf("API '%s' missing base_url", api.Name)
-		}
 		
-		// Validate auth type
-		validAuthTypes := []string{"none", "bearer", "apikey", "basic"}
-		if api.Auth.Type != "" && !contains(validAuthTypes, api.Auth.Type) {
-			return fmt.Errorf("API '%s' has invalid auth type: %s", api.Name, api.Auth.Type)
+		if err := validateAuth(api.Auth); err != nil {
+			return fmt.Errorf("API '%s' %v", api.Name, err)
 		}
-		
-		// Validate auth requirements
-		if api.Auth.Type == "bearer" || api.Auth.Type == "apikey" || api.Auth.Type == "basic" {
-			if api.Auth.Token == "" {
-				return fmt.Errorf("API '%s' with auth type '%s' missing token", api.Name, api.Auth.Type)
+		if api.Timeout != "" {
+			if _, err := time.ParseDuration(api.Timeout); err != nil {
+				return fmt.Errorf("API '%s' has invalid timeout: %s", api.Name, api.Timeout)
 			}
 		}
 	}
@@ -194,6 +264,13 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("default_limit (%d) cannot exceed max_limit (%d)", 
 			config.Defaults.DefaultLimit, config.Defaults.MaxLimit)
 	}
+	if config.Defaults.MaxRequests <= 0 {
+		return fmt.Errorf("max_requests must be positive, got: %d", config.Defaults.MaxRequests)
+	}
+	validCacheBackends := []string{"memory", "disk"}
+	if !contains(validCacheBackends, config.Defaults.CacheBackend) {
+		return fmt.Errorf("invalid cache_backend: %s", config.Defaults.CacheBackend)
+	}
 	
 	// Validate logging level
 	validLogLevels := []string{"debug", "info", "warn", "error"}
@@ -235,6 +312,42 @@ func CreateConfigDir() error {
 	return os.MkdirAll(configDir, 0755)
 }
 
+// GetSpecCacheDir returns the directory downloaded specs are cached in
+func GetSpecCacheDir() string {
+	return filepath.Join(getXDGCacheDir(), "specs")
+}
+
+// GetResponseCacheDir returns the directory the disk response cache keeps
+// its entries in
+func GetResponseCacheDir() string {
+	return filepath.Join(getXDGCacheDir(), "responses")
+}
+
+// GetHistoryPath returns the file the shell keeps its history in, under the
+// XDG state directory
+func GetHistoryPath() string {
+	xdgStateHome := os.Getenv("XDG_STATE_HOME")
+	if xdgStateHome == "" {
+		homeDir := os.Getenv("HOME")
+		if homeDir != "" {
+			xdgStateHome = filepath.Join(homeDir, ".local", "state")
+		}
+	}
+	return filepath.Join(xdgStateHome, "qRest", "history")
+}
+
+// getXDGCacheDir returns the XDG cache directory for qRest
+func getXDGCacheDir() string {
+	xdgCacheHome := os.Getenv("XDG_CACHE_HOME")
+	if xdgCacheHome == "" {
+		homeDir := os.Getenv("HOME")
+		if homeDir != "" {
+			xdgCacheHome = filepath.Join(homeDir, ".cache")
+		}
+	}
+	return filepath.Join(xdgCacheHome, "qRest")
+}
+
 // contains checks if a slice contains a string
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
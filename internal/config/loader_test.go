@@ -0,0 +1,35 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    AuthConfig
+		wantErr string
+	}{
+		{"none", AuthConfig{}, ""},
+		{"bearer", AuthConfig{Type: "bearer", Token: "secret"}, ""},
+		{"type in capitals", AuthConfig{Type: "Bearer", Token: "secret"}, ""},
+		{"capitals checked too", AuthConfig{Type: "APIKEY"}, "auth type 'apikey' missing token"},
+		{"apikey in header and query", AuthConfig{Type: "ApiKey", Token: "secret", Header: "X-Key", Query: "key"}, "sets both header and query"},
+		{"basic token", AuthConfig{Type: "BASIC", Token: "user:pass"}, ""},
+		{"basic missing username", AuthConfig{Type: "basic", Token: "pass"}, "missing username"},
+		{"oauth2 missing client", AuthConfig{Type: "OAuth2"}, "missing client_id"},
+		{"unknown", AuthConfig{Type: "kerberos"}, "invalid auth type: kerberos"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAuth(tt.auth)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("got error %v, want none", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -12,9 +12,17 @@ type Config struct {
 
 // ServerConfig holds HTTP server configuration
 type ServerConfig struct {
-	Host string `mapstructure:"host" toml:"host"`
-	Port int    `mapstructure:"port" toml:"port"`
-	CORS CORSConfig `mapstructure:"cors" toml:"cors"`
+	Host     string         `mapstructure:"host" toml:"host"`
+	Port     int            `mapstructure:"port" toml:"port"`
+	CORS     CORSConfig     `mapstructure:"cors" toml:"cors"`
+	Postgres ListenerConfig `mapstructure:"postgres" toml:"postgres"`
+	MySQL    ListenerConfig `mapstructure:"mysql" toml:"mysql"`
+}
+
+// ListenerConfig holds the settings of a database wire protocol listener
+type ListenerConfig struct {
+	Port     int    `mapstructure:"port" toml:"port"`         // 0 leaves the listener off
+	Password string `mapstructure:"password" toml:"password"` // required of clients when set; any user name is accepted
 }
 
 // CORSConfig holds CORS configuration
@@ -29,25 +37,36 @@ type APIConfig struct {
 	Name        string     `mapstructure:"name" toml:"name"`
 	Description string     `mapstructure:"description" toml:"description"`
 	SpecURL     string     `mapstructure:"spec_url" toml:"spec_url"`
-	BaseURL     string     `mapstructure:"base_url" toml:"base_url"`
+	BaseURL     string     `mapstructure:"base_url" toml:"base_url"` // defaults to the spec's servers
 	Auth        AuthConfig `mapstructure:"auth" toml:"auth"`
 	Timeout     string     `mapstructure:"timeout" toml:"timeout"`
 	Retry       RetryConfig `mapstructure:"retry" toml:"retry"`
 	Cache       CacheConfig `mapstructure:"cache" toml:"cache"`
+	FlattenNested bool      `mapstructure:"flatten_nested" toml:"flatten_nested"` // expose nested objects as parent_child columns
 }
 
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
-	Type   string            `mapstructure:"type" toml:"type"` // none, bearer, apikey, basic
-	Token  string            `mapstructure:"token" toml:"token"`
-	Header string            `mapstructure:"header" toml:"header"` // For apikey auth
-	Params map[string]string `mapstructure:"params" toml:"params"` // For custom auth
+	Type         string            `mapstructure:"type" toml:"type"` // none, bearer, apikey, basic, oauth2, custom
+	Token        string            `mapstructure:"token" toml:"token"` // bearer token, API key, or "username:password" for basic
+	Header       string            `mapstructure:"header" toml:"header"` // For apikey auth, X-API-Key by default
+	Query        string            `mapstructure:"query" toml:"query"` // For apikey auth sent as a query parameter instead
+	Username     string            `mapstructure:"username" toml:"username"` // For basic auth
+	Password     string            `mapstructure:"password" toml:"password"`
+	TokenURL     string            `mapstructure:"token_url" toml:"token_url"` // For oauth2 auth
+	ClientID     string            `mapstructure:"client_id" toml:"client_id"`
+	ClientSecret string            `mapstructure:"client_secret" toml:"client_secret"`
+	RefreshToken string            `mapstructure:"refresh_token" toml:"refresh_token"` // refresh token grant instead of client credentials
+	Scopes       []string          `mapstructure:"scopes" toml:"scopes"`
+	Params       map[string]string `mapstructure:"params" toml:"params"` // extra oauth2 token request parameters, such as audience
+	Headers      map[string]string `mapstructure:"headers" toml:"headers"` // For custom auth, sent with every request
 }
 
 // RetryConfig holds retry configuration
 type RetryConfig struct {
-	Attempts int    `mapstructure:"attempts" toml:"attempts"`
-	Delay    string `mapstructure:"delay" toml:"delay"`
+	Attempts  int    `mapstructure:"attempts" toml:"attempts"`     // total tries per request, the first included
+	Delay     string `mapstructure:"delay" toml:"delay"`           // backoff before the first retry, doubled after each
+	RetryPost bool   `mapstructure:"retry_post" toml:"retry_post"` // also retry the POSTs of INSERT, which are not idempotent
 }
 
 // CacheConfig holds caching configuration
@@ -60,8 +79,11 @@ type CacheConfig struct {
 type DefaultConfig struct {
 	MaxLimit    int    `mapstructure:"max_limit" toml:"max_limit"`
 	DefaultLimit int   `mapstructure:"default_limit" toml:"default_limit"`
+	MaxRequests int    `mapstructure:"max_requests" toml:"max_requests"` // API requests allowed per query
 	Timeout     string `mapstructure:"timeout" toml:"timeout"`
 	CacheTTL    string `mapstructure:"cache_ttl" toml:"cache_ttl"`
+	Offline     bool   `mapstructure:"offline" toml:"offline"` // load specs from the spec cache only
+	CacheBackend string `mapstructure:"cache_backend" toml:"cache_backend"` // response cache: memory or disk
 }
 
 // LoggingConfig holds logging configuration
@@ -87,8 +109,10 @@ func GetDefaultConfig() *Config {
 		Defaults: DefaultConfig{
 			MaxLimit:     1000,
 			DefaultLimit: 100,
+			MaxRequests:  20,
 			Timeout:      "30s",
 			CacheTTL:     "5m",
+			CacheBackend: "memory",
 		},
 		Logging: LoggingConfig{
 			Level:  "info",
@@ -131,6 +155,27 @@ func (c *CacheConfig) GetCacheTTL() time.Duration {
 	return 5 * time.Minute
 }
 
+// GetResponseCacheTTL returns how long the API's responses are cached: its
+// own ttl, else the default one, or zero when its cache is not enabled
+func (a *APIConfig) GetResponseCacheTTL(defaults *DefaultConfig) time.Duration {
+	if !a.Cache.Enabled {
+		return 0
+	}
+	if a.Cache.TTL == "" {
+		return defaults.GetDefaultCacheTTL()
+	}
+	return a.Cache.GetCacheTTL()
+}
+
+// GetRequestTimeout returns how long a request to the API may take: its own
+// timeout, else the default one
+func (a *APIConfig) GetRequestTimeout(defaults *DefaultConfig) time.Duration {
+	if a.Timeout == "" {
+		return defaults.GetDefaultTimeout()
+	}
+	return a.GetTimeout()
+}
+
 // GetDefaultTimeout returns the default timeout as a time.Duration
 func (d *DefaultConfig) GetDefaultTimeout() time.Duration {
 	if d.Timeout == "" {
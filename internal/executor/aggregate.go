@@ -0,0 +1,152 @@
+package executor
+
+import (
+	"encoding/json"
+
+	"github.com/simonm/qRest/internal/translator"
+)
+
+// accumulator computes one aggregate over the records of a group
+type accumulator struct {
+	aggregate translator.Aggregate
+	count     int
+	sum       float64
+	numeric   int // values that contributed to sum
+	extreme   interface{}
+	seen      map[string]bool // values already counted, for DISTINCT
+}
+
+func (a *accumulator) add(record map[string]interface{}) {
+	if a.aggregate.Column == "" {
+		// COUNT(*) counts rows, nulls included
+		a.count++
+		return
+	}
+
+	value, exists := columnValue(record, a.aggregate.Column)
+	if !exists || value == nil {
+		return
+	}
+	if a.aggregate.Distinct {
+		key, _ := json.Marshal(value)
+		if a.seen[string(key)] {
+			return
+		}
+		a.seen[string(key)] = true
+	}
+	a.count++
+
+	switch a.aggregate.Function {
+	case "SUM", "AVG":
+		if f, ok := toFloat(value); ok {
+			a.sum += f
+			a.numeric++
+		}
+	case "MIN", "MAX":
+		if a.extreme == nil {
+			a.extreme = value
+			return
+		}
+		cmp, ok := compareValues(value, a.extreme)
+		if ok && ((a.aggregate.Function == "MIN" && cmp < 0) || (a.aggregate.Function == "MAX" && cmp > 0)) {
+			a.extreme = value
+		}
+	}
+}
+
+// result returns the aggregate's value; SUM, AVG, MIN and MAX of no values are null
+func (a *accumulator) result() interface{} {
+	switch a.aggregate.Function {
+	case "COUNT":
+		return a.count
+	case "SUM":
+		if a.numeric == 0 {
+			return nil
+		}
+		return a.sum
+	case "AVG":
+		if a.numeric == 0 {
+			return nil
+		}
+		return a.sum / float64(a.numeric)
+	}
+	return a.extreme
+}
+
+// aggregateRecords groups records by the GROUP BY columns and computes the
+// query's aggregates for each group, keeping groups that satisfy HAVING.
+// Each output row holds the grouped columns and every computed aggregate.
+// Groups appear in the order their first record was fetched.
+func aggregateRecords(records []map[string]interface{}, query *translator.ParsedQuery) []map[string]interface{} {
+	type group struct {
+		values       map[string]interface{}
+		accumulators []*accumulator
+	}
+
+	newGroup := func(record map[string]interface{}) *group {
+		g := &group{values: make(map[string]interface{})}
+		for _, column := range query.GroupBy {
+			g.values[column], _ = columnValue(record, column)
+		}
+		for _, aggregate := range query.Aggregates {
+			g.accumulators = append(g.accumulators, &accumulator{
+				aggregate: aggregate,
+				seen:      make(map[string]bool),
+			})
+		}
+		return g
+	}
+
+	var groups []*group
+	byKey := make(map[string]*group)
+	for _, record := range records {
+		key := groupKey(record, query.GroupBy)
+		g, exists := byKey[key]
+		if !exists {
+			g = newGroup(record)
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		for _, acc := range g.accumulators {
+			acc.add(record)
+		}
+	}
+
+	// Without GROUP BY an aggregate query returns one row even for no records
+	if len(query.GroupBy) == 0 && len(groups) == 0 {
+		groups = append(groups, newGroup(nil))
+	}
+
+	var rows []map[string]interface{}
+	for _, g := range groups {
+		row := g.values
+		for _, acc := range g.accumulators {
+			row[acc.aggregate.Name] = acc.result()
+		}
+		if len(query.Having) > 0 && !matchesAnyGroup(row, query.Having) {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// matchesAnyGroup reports whether a record satisfies any of the OR-ed condition groups
+func matchesAnyGroup(record map[string]interface{}, groups [][]translator.Condition) bool {
+	for _, conditions := range groups {
+		if matchesConditions(record, conditions) {
+			return true
+		}
+	}
+	return false
+}
+
+// groupKey identifies a record's group; nulls and missing values group together
+func groupKey(record map[string]interface{}, columns []string) string {
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		values[i], _ = columnValue(record, column)
+	}
+	key, _ := json.Marshal(values)
+	return string(key)
+}
@@ -0,0 +1,83 @@
+package executor
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/simonm/qRest/internal/auth"
+	"github.com/simonm/qRest/internal/cache"
+	"github.com/simonm/qRest/internal/parser"
+)
+
+// cachedHeaders are the response headers a cache entry keeps: validators for
+// revalidation and the Link header paging follows
+var cachedHeaders = []string{"ETag", "Last-Modified", "Link", "Content-Type"}
+
+// get returns the body and headers of a GET, through the response cache when
+// the API has one. A stale entry with a validator is revalidated with a
+// conditional request and served again on 304 Not Modified.
+func (e *RESTExecutor) get(apiURL string, authenticator auth.Authenticator) ([]byte, http.Header, error) {
+	var key string
+	var entry *cache.Entry
+	var cached bool
+	conditional := make(http.Header)
+	if e.cache != nil && e.cacheTTL > 0 {
+		key = cache.Key("GET", apiURL, authenticator.Identity())
+		entry, cached = e.cache.Get(key)
+		if cached && entry.Fresh(time.Now()) {
+			return entry.Body, entry.Header, nil
+		}
+		if cached {
+			if etag := entry.Header.Get("ETag"); etag != "" {
+				conditional.Set("If-None-Match", etag)
+			}
+			if modified := entry.Header.Get("Last-Modified"); modified != "" {
+				conditional.Set("If-Modified-Since", modified)
+			}
+		}
+	}
+
+	resp, err := e.sendRequest("GET", apiURL, nil, conditional, authenticator)
+	if err != nil {
+		return nil, nil, fmt.Errorf("API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached {
+		e.cache.Refresh(entry, e.cacheTTL)
+		return entry.Body, entry.Header, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to parse API response: %v", err)
+	}
+	if key != "" {
+		header := make(http.Header)
+		for _, name := range cachedHeaders {
+			if values := resp.Header.Values(name); len(values) > 0 {
+				header[http.CanonicalHeaderKey(name)] = values
+			}
+		}
+		// A cache that cannot be written only costs a request next time
+		e.cache.Put(key, "GET", apiURL, header, body, e.cacheTTL)
+	}
+	return body, resp.Header, nil
+}
+
+// InvalidateCache drops the cached responses of the resource behind a table:
+// every URL under its path up to the first parameter, e.g. /pet for
+// /pet/{petId}. It returns the number of entries dropped.
+func (e *RESTExecutor) InvalidateCache(capability parser.APICapability) int {
+	if e.cache == nil {
+		return 0
+	}
+	path := capability.Path
+	if i := strings.Index(path, "{"); i >= 0 {
+		path = path[:i]
+	}
+	return e.cache.Invalidate(capability.BaseURL + strings.TrimSuffix(path, "/"))
+}
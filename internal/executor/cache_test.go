@@ -0,0 +1,129 @@
+package executor
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/simonm/qRest/internal/apitest"
+	"github.com/simonm/qRest/internal/auth"
+	"github.com/simonm/qRest/internal/cache"
+	"github.com/simonm/qRest/internal/grammar"
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/translator"
+)
+
+// versionedAPI serves a list of pets from /pets with an ETag that changes
+// when a pet is added by POST. It records each request as its method, path
+// and If-None-Match header.
+type versionedAPI struct {
+	*apitest.Server
+
+	mu   sync.Mutex
+	pets []map[string]interface{}
+}
+
+func newVersionedAPI(t *testing.T) *versionedAPI {
+	api := &versionedAPI{pets: []map[string]interface{}{{"id": 1, "name": "Rex"}}}
+	api.Server = apitest.New(t, func(r *http.Request) string {
+		return apitest.MethodPath(r) + " " + r.Header.Get("If-None-Match")
+	}, api.serve)
+	return api
+}
+
+func (a *versionedAPI) serve(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if r.Method == "POST" {
+		var pet map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&pet)
+		a.pets = append(a.pets, pet)
+		apitest.WriteJSON(w, http.StatusCreated, pet)
+		return
+	}
+	etag := `"v` + strconv.Itoa(len(a.pets)) + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	apitest.WriteJSON(w, http.StatusOK, a.pets)
+}
+
+func TestExecuteCachedSelect(t *testing.T) {
+	tests := []struct {
+		name     string
+		ttl      time.Duration
+		sql      []string
+		ids      []int // of the last SELECT
+		requests []string
+	}{
+		{
+			name:     "fresh entry",
+			ttl:      time.Hour,
+			sql:      []string{"SELECT * FROM pets", "SELECT * FROM pets"},
+			ids:      []int{1},
+			requests: []string{"GET /pets "},
+		},
+		{
+			name:     "stale entry revalidated",
+			ttl:      time.Nanosecond,
+			sql:      []string{"SELECT * FROM pets", "SELECT * FROM pets"},
+			ids:      []int{1},
+			requests: []string{"GET /pets ", `GET /pets "v1"`},
+		},
+		{
+			name:     "invalidated by a write",
+			ttl:      time.Hour,
+			sql:      []string{"SELECT * FROM pets", "INSERT INTO pets (id, name) VALUES (2, 'Bo')", "SELECT * FROM pets"},
+			ids:      []int{1, 2},
+			requests: []string{"GET /pets ", "POST /pets ", "GET /pets "},
+		},
+		{
+			name:     "uncached",
+			sql:      []string{"SELECT * FROM pets", "SELECT * FROM pets"},
+			ids:      []int{1},
+			requests: []string{"GET /pets ", "GET /pets "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newVersionedAPI(t)
+			capability := parser.APICapability{
+				Path:            "/pets",
+				Method:          "GET",
+				TableName:       "pets",
+				BaseURL:         api.URL,
+				ResponseColumns: []string{"id", "name"},
+			}
+			executor := NewRESTExecutor(auth.None{})
+			executor.SetCache(cache.New(cache.NewMemoryStore()), tt.ttl)
+
+			var result *QueryResult
+			for _, sql := range tt.sql {
+				query, err := translator.NewSimpleSQLTranslator(grammar.NewGrammarGenerator().GenerateGrammar(capability)).ParseSQL(sql)
+				if err != nil {
+					t.Fatalf("%s: %v", sql, err)
+				}
+				if result, err = executor.ExecuteQuery(capability, query); err != nil {
+					t.Fatalf("%s: %v", sql, err)
+				}
+				if result.Error != "" {
+					t.Fatalf("%s: %s", sql, result.Error)
+				}
+			}
+			if ids := recordIDs(result.Data); !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("got ids %v, want %v", ids, tt.ids)
+			}
+			if requests := api.Requests(); !reflect.DeepEqual(requests, tt.requests) {
+				t.Errorf("got requests %q, want %q", requests, tt.requests)
+			}
+		})
+	}
+}
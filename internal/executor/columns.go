@@ -0,0 +1,50 @@
+package executor
+
+import (
+	"strings"
+
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/translator"
+)
+
+// ColumnTypes gives the schema type of each output column of a statement.
+// Aggregates are typed by their function; other columns as ColumnType finds
+// them.
+func ColumnTypes(tables []JoinTable, query *translator.ParsedQuery) map[string]parser.ColumnType {
+	types := make(map[string]parser.ColumnType)
+	for _, column := range query.Columns {
+		types[column] = ColumnType(tables, query, column)
+	}
+	for _, aggregate := range query.Aggregates {
+		switch aggregate.Function {
+		case "COUNT":
+			types[aggregate.Name] = parser.ColumnType{Type: "integer"}
+		case "SUM", "AVG":
+			types[aggregate.Name] = parser.ColumnType{Type: "number"}
+		default:
+			types[aggregate.Name] = ColumnType(tables, query, aggregate.Column)
+		}
+	}
+	return types
+}
+
+// ColumnType looks a column up in the response schema of its table, the
+// qualified columns of a join by the table's alias. Fields of array elements,
+// which hold one value per element, are arrays; a field of one element, e.g.
+// "tags[0].name", has the type the schema gives "tags[].name".
+func ColumnType(tables []JoinTable, query *translator.ParsedQuery, column string) parser.ColumnType {
+	if strings.Contains(column, "[]") {
+		return parser.ColumnType{Type: "array"}
+	}
+	column = translator.ElementPath(column)
+	if !query.IsJoin() {
+		return tables[0].Capability.ColumnTypes[column]
+	}
+	alias, field, _ := strings.Cut(column, ".")
+	for i, source := range query.Sources {
+		if source.Alias == alias && i < len(tables) {
+			return tables[i].Capability.ColumnTypes[field]
+		}
+	}
+	return parser.ColumnType{}
+}
@@ -0,0 +1,49 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/translator"
+)
+
+func TestColumnType(t *testing.T) {
+	pets := parser.APICapability{
+		TableName: "pets",
+		ColumnTypes: map[string]parser.ColumnType{
+			"id":          {Type: "integer"},
+			"tags[].name": {Type: "string"},
+		},
+	}
+	orders := parser.APICapability{
+		TableName:   "orders",
+		ColumnTypes: map[string]parser.ColumnType{"petId": {Type: "integer"}},
+	}
+	single := []JoinTable{{Capability: pets}}
+	joined := []JoinTable{{Capability: orders}, {Capability: pets}}
+	join := &translator.ParsedQuery{Sources: []translator.JoinSource{{Alias: "o"}, {Alias: "p"}}}
+
+	tests := []struct {
+		name   string
+		tables []JoinTable
+		query  *translator.ParsedQuery
+		column string
+		want   parser.ColumnType
+	}{
+		{"column", single, &translator.ParsedQuery{}, "id", parser.ColumnType{Type: "integer"}},
+		{"all elements", single, &translator.ParsedQuery{}, "tags[].name", parser.ColumnType{Type: "array"}},
+		{"one element", single, &translator.ParsedQuery{}, "tags[0].name", parser.ColumnType{Type: "string"}},
+		{"unknown", single, &translator.ParsedQuery{}, "age", parser.ColumnType{}},
+		{"joined column", joined, join, "o.petId", parser.ColumnType{Type: "integer"}},
+		{"joined element", joined, join, "p.tags[2].name", parser.ColumnType{Type: "string"}},
+		{"unknown alias", joined, join, "x.id", parser.ColumnType{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ColumnType(tt.tables, tt.query, tt.column); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ColumnType(%q) = %+v, want %+v", tt.column, got, tt.want)
+			}
+		})
+	}
+}
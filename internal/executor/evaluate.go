@@ -0,0 +1,223 @@
+package executor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/simonm/qRest/internal/translator"
+)
+
+// matchesConditions reports whether a record satisfies every condition
+func matchesConditions(record map[string]interface{}, conditions []translator.Condition) bool {
+	for _, condition := range conditions {
+		if !evaluateCondition(record, condition) {
+			return false
+		}
+	}
+	return true
+}
+
+// evaluateCondition applies a single condition to a record in memory
+func evaluateCondition(record map[string]interface{}, condition translator.Condition) bool {
+	value, exists := columnValue(record, condition.Column)
+	switch condition.Operator {
+	case "IS NULL":
+		// A field the record leaves out is null
+		return !exists || value == nil
+	case "IS NOT NULL":
+		return exists && value != nil
+	}
+	if !exists || value == nil {
+		return false
+	}
+	if condition.Value = resolveColumnRefs(record, condition.Value); condition.Value == nil {
+		return false
+	}
+
+	switch condition.Operator {
+	case "=":
+		cmp, ok := compareValues(value, condition.Value)
+		return ok && cmp == 0
+	case "!=", "<>":
+		cmp, ok := compareValues(value, condition.Value)
+		return ok && cmp != 0
+	case "<":
+		cmp, ok := compareValues(value, condition.Value)
+		return ok && cmp < 0
+	case "<=":
+		cmp, ok := compareValues(value, condition.Value)
+		return ok && cmp <= 0
+	case ">":
+		cmp, ok := compareValues(value, condition.Value)
+		return ok && cmp > 0
+	case ">=":
+		cmp, ok := compareValues(value, condition.Value)
+		return ok && cmp >= 0
+	case "BETWEEN":
+		bounds, ok := condition.Value.([]interface{})
+		if !ok || len(bounds) != 2 {
+			return false
+		}
+		low, okLow := compareValues(value, bounds[0])
+		high, okHigh := compareValues(value, bounds[1])
+		return okLow && okHigh && low >= 0 && high <= 0
+	case "IN", "NOT IN":
+		values, ok := condition.Value.([]interface{})
+		if !ok {
+			return false
+		}
+		found := false
+		for _, candidate := range values {
+			if cmp, ok := compareValues(value, candidate); ok && cmp == 0 {
+				found = true
+				break
+			}
+		}
+		return found == (condition.Operator == "IN")
+	case "LIKE", "NOT LIKE", "ILIKE", "NOT ILIKE":
+		pattern, err := likePattern(fmt.Sprintf("%v", condition.Value), strings.HasSuffix(condition.Operator, "ILIKE"))
+		if err != nil {
+			return false
+		}
+		matched := pattern.MatchString(fmt.Sprintf("%v", value))
+		return matched == !strings.HasPrefix(condition.Operator, "NOT ")
+	}
+
+	return false
+}
+
+// resolveColumnRefs replaces columns compared against, as in a join's ON
+// clause, with the record's values. It returns nil when a referenced column
+// is null, which no comparison matches.
+func resolveColumnRefs(record map[string]interface{}, value interface{}) interface{} {
+	switch v := value.(type) {
+	case translator.ColumnRef:
+		value, _ := columnValue(record, string(v))
+		return value
+	case []interface{}:
+		resolved := make([]interface{}, len(v))
+		for i, item := range v {
+			if resolved[i] = resolveColumnRefs(record, item); resolved[i] == nil {
+				return nil
+			}
+		}
+		return resolved
+	}
+	return value
+}
+
+// likePattern compiles a SQL LIKE pattern: % matches any run of characters,
+// _ matches exactly one and a backslash escapes the next character
+func likePattern(pattern string, caseInsensitive bool) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	if caseInsensitive {
+		expr.WriteString("(?i)")
+	}
+	expr.WriteString("(?s)^")
+
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			expr.WriteString(".*")
+		case r == '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+
+	return regexp.Compile(expr.String())
+}
+
+// compareValues orders two values: numerically when both are numbers,
+// chronologically when both are RFC3339 timestamps, otherwise as strings.
+// ok is false when the values cannot be compared.
+func compareValues(a, b interface{}) (int, bool) {
+	if af, ok := toFloat(a); ok {
+		if bf, ok := toFloat(b); ok {
+			switch {
+			case af < bf:
+				return -1, true
+			case af > bf:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+
+	if ab, ok := a.(bool); ok {
+		if bb, ok := b.(bool); ok {
+			if ab == bb {
+				return 0, true
+			}
+			if !ab {
+				return -1, true
+			}
+			return 1, true
+		}
+		return 0, false
+	}
+
+	as, aIsString := a.(string)
+	bs, bIsString := b.(string)
+	if aIsString && bIsString {
+		if at, ok := parseTime(as); ok {
+			if bt, ok := parseTime(bs); ok {
+				return at.Compare(bt), true
+			}
+		}
+		return strings.Compare(as, bs), true
+	}
+
+	// Mixed types, e.g. a numeric string against a number
+	as, bs = fmt.Sprintf("%v", a), fmt.Sprintf("%v", b)
+	if af, err := strconv.ParseFloat(as, 64); err == nil {
+		if bf, err := strconv.ParseFloat(bs, 64); err == nil {
+			return compareValues(af, bf)
+		}
+	}
+	return strings.Compare(as, bs), true
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case string:
+		return 0, false
+	}
+	if s, ok := value.(fmt.Stringer); ok {
+		f, err := strconv.ParseFloat(s.String(), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// parseTime accepts RFC3339 timestamps and plain dates
+func parseTime(s string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
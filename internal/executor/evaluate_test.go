@@ -0,0 +1,90 @@
+package executor
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/simonm/qRest/internal/translator"
+)
+
+func TestEvaluateCondition(t *testing.T) {
+	record := map[string]interface{}{
+		"id":      3.0,
+		"name":    "Rex_1",
+		"status":  "sold",
+		"born":    "2020-05-01",
+		"updated": "2024-01-02T10:00:00Z",
+		"active":  true,
+		"price":   nil,
+		"weight":  json.Number("4.5"),
+		"owner":   map[string]interface{}{"id": 7.0},
+		"minimum": 2.0,
+	}
+	tests := []struct {
+		column   string
+		operator string
+		value    interface{}
+		want     bool
+	}{
+		{"id", "=", 3, true},
+		{"id", "=", "3", true},
+		{"id", "!=", 3, false},
+		{"id", "<>", 4, true},
+		{"id", "<", 3.5, true},
+		{"id", "<=", 3, true},
+		{"id", ">", 3, false},
+		{"id", ">=", 2, true},
+		{"weight", ">", 4, true},
+		{"name", "=", "Rex_1", true},
+		{"name", "<", "Sam", true},
+		{"born", "<", "2021-01-01", true},
+		{"updated", ">", "2024-01-02T09:00:00+00:00", true},
+		{"updated", "BETWEEN", []interface{}{"2024-01-01", "2024-01-03"}, true},
+		{"id", "BETWEEN", []interface{}{1, 3}, true},
+		{"id", "BETWEEN", []interface{}{4, 9}, false},
+		{"active", "=", true, true},
+		{"active", "=", false, false},
+		{"active", "=", 1, false},
+		{"status", "IN", []interface{}{"sold", "pending"}, true},
+		{"status", "IN", []interface{}{"available"}, false},
+		{"status", "NOT IN", []interface{}{"available"}, true},
+		{"id", "IN", []interface{}{1, 3}, true},
+		{"name", "LIKE", "R%", true},
+		{"name", "LIKE", "r%", false},
+		{"name", "ILIKE", "r%", true},
+		{"name", "LIKE", "Re_\\_1", true},
+		{"name", "LIKE", "Re_\\_", false},
+		{"name", "NOT LIKE", "%z%", true},
+		{"name", "NOT ILIKE", "%REX%", false},
+		{"owner.id", "=", 7, true},
+		{"id", ">", translator.ColumnRef("minimum"), true},
+		{"id", "=", translator.ColumnRef("missing"), false},
+		{"price", "IS NULL", nil, true},
+		{"missing", "IS NULL", nil, true},
+		{"status", "IS NOT NULL", nil, true},
+		{"price", "=", 1, false},
+		{"price", "!=", 1, false},
+		{"missing", "!=", 1, false},
+	}
+	for _, tt := range tests {
+		condition := translator.Condition{Column: tt.column, Operator: tt.operator, Value: tt.value}
+		if got := evaluateCondition(record, condition); got != tt.want {
+			t.Errorf("%s: got %v, want %v", condition, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesConditions(t *testing.T) {
+	record := map[string]interface{}{"id": 1.0, "status": "sold"}
+	conditions := []translator.Condition{
+		{Column: "id", Operator: "=", Value: 1},
+		{Column: "status", Operator: "=", Value: "sold"},
+	}
+	if !matchesConditions(record, conditions) {
+		t.Errorf("record %v does not match %v", record, conditions)
+	}
+	conditions = append(conditions, translator.Condition{Column: "id", Operator: ">", Value: 1})
+	if matchesConditions(record, conditions) {
+		t.Errorf("record %v matches %v", record, conditions)
+	}
+}
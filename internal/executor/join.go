@@ -0,0 +1,422 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/translator"
+)
+
+// maxLookupConcurrency bounds the per-key requests a lookup join has in flight
+const maxLookupConcurrency = 4
+
+// JoinTable is the endpoint behind one table of a SELECT with JOINs, along
+// with the executor that reaches its API. Tables of different APIs each use
+// their own credentials and request ceiling.
+type JoinTable struct {
+	Capability parser.APICapability
+	Executor   *RESTExecutor
+
+	// Records holds the rows of a virtual table such as
+	// information_schema.tables, which has no API and is queried in memory
+	Records []map[string]interface{}
+}
+
+// ExecuteJoin runs a SELECT with JOINs. tables follow query.Sources: the FROM
+// table first, then each joined table. Rows are joined left to right, each
+// joined table using one of three strategies:
+//
+//   - lookup: an endpoint such as /pet/{petId} whose path parameter is a join
+//     key gets one request per distinct key
+//   - hash: other endpoints are fetched once and matched on the join keys
+//   - nested loop: without join keys every pair of rows is compared
+//
+// WHERE conditions spanning tables, grouping, ORDER BY, OFFSET and LIMIT are
+// then applied to the joined rows.
+func (e *RESTExecutor) ExecuteJoin(tables []JoinTable, query *translator.ParsedQuery) (*QueryResult, error) {
+	if len(tables) != len(query.Sources) {
+		return nil, fmt.Errorf("join reads %d tables but %d were given", len(query.Sources), len(tables))
+	}
+	for i, table := range tables {
+		if err := table.checkReadable(query.Sources[i], i == 0); err != nil {
+			return nil, err
+		}
+	}
+	if query.NoRows {
+		return &QueryResult{Columns: query.Columns}, nil
+	}
+	result := &QueryResult{}
+
+	rows, err := tables[0].scan(query.Sources[0], result)
+	if err != nil {
+		return &QueryResult{Error: err.Error()}, nil
+	}
+	for i := 1; i < len(tables); i++ {
+		rows, err = tables[i].join(rows, query.Sources[i], result)
+		if err != nil {
+			return &QueryResult{Error: err.Error()}, nil
+		}
+	}
+
+	evaluateRows(rows, query, result)
+	return result, nil
+}
+
+// Execute runs a translated statement: a SELECT with JOINs across tables,
+// which follow query.Sources, or any other statement on tables[0]
+func Execute(tables []JoinTable, query *translator.ParsedQuery) (*QueryResult, error) {
+	switch {
+	case query.Params > 0:
+		return nil, fmt.Errorf("statement refers to parameter $%d; parameters are only bound by prepared statements", query.Params)
+	case query.IsJoin():
+		return tables[0].Executor.ExecuteJoin(tables, query)
+	case tables[0].Records == nil:
+		return tables[0].Executor.ExecuteQuery(tables[0].Capability, query)
+	case query.QueryType != "SELECT":
+		return nil, fmt.Errorf("table '%s' is read-only", query.TableName)
+	}
+	return QueryRecords(tables[0].Records, query), nil
+}
+
+// QueryRecords runs a SELECT over records held in memory, such as a catalog
+// of the tables, evaluating every clause locally
+func QueryRecords(records []map[string]interface{}, query *translator.ParsedQuery) *QueryResult {
+	result := &QueryResult{}
+	if query.NoRows {
+		result.Columns = query.Columns
+		return result
+	}
+	evaluateRows(records, query, result)
+	return result
+}
+
+// evaluateRows applies the WHERE clause, grouping, ORDER BY, OFFSET, LIMIT and
+// the SELECT list to rows
+func evaluateRows(rows []map[string]interface{}, query *translator.ParsedQuery, result *QueryResult) {
+	var matched []map[string]interface{}
+	for _, row := range rows {
+		if matchesAnyGroup(row, query.ConditionGroups()) {
+			matched = append(matched, row)
+		}
+	}
+	if query.IsAggregate() {
+		matched = aggregateRecords(matched, query)
+	}
+	if len(query.OrderBy) > 0 {
+		sortRecords(matched, query.OrderBy)
+	}
+	matched = applyOffsetLimit(matched, query.Offset, query.Limit)
+
+	result.Data = filterColumns(matched, query.Columns)
+	result.Columns = query.Columns
+	result.Total = len(result.Data)
+}
+
+// checkReadable rejects tables whose endpoint cannot be reached: a path
+// parameter has to be filled from a join key, so such a table can be neither
+// the FROM table nor joined on anything else
+func (t JoinTable) checkReadable(source translator.JoinSource, isFrom bool) error {
+	params := pathParameters(t.Capability.Path)
+	switch {
+	case len(params) == 0:
+		return nil
+	case len(params) > 1:
+		return fmt.Errorf("table '%s' has several path parameters; only one can be filled from a join key", source.Query.TableName)
+	case isFrom:
+		return fmt.Errorf("table '%s' needs a value for path parameter {%s}; join it to another table on that column", source.Query.TableName, params[0])
+	case t.lookupKey(source.Keys) < 0:
+		return fmt.Errorf("table '%s' can only be joined on its path parameter {%s}", source.Query.TableName, params[0])
+	}
+	return nil
+}
+
+// scan fetches every record of the table that matches its own conditions
+func (t JoinTable) scan(source translator.JoinSource, result *QueryResult) ([]map[string]interface{}, error) {
+	if t.Records != nil {
+		var kept []map[string]interface{}
+		for _, record := range t.Records {
+			if matchesAnyGroup(record, source.Query.ConditionGroups()) {
+				kept = append(kept, record)
+			}
+		}
+		return qualifyRecords(source.Alias, kept), nil
+	}
+	scanned, err := t.Executor.executeSelect(t.Capability, source.Query)
+	if err != nil {
+		return nil, err
+	}
+	if scanned.Error != "" {
+		return nil, errors.New(scanned.Error)
+	}
+	for _, warning := range scanned.Warnings {
+		result.Warnings = append(result.Warnings, source.Alias+": "+warning)
+	}
+	return qualifyRecords(source.Alias, scanned.Data), nil
+}
+
+// join combines rows with the records of this table
+func (t JoinTable) join(rows []map[string]interface{}, source translator.JoinSource, result *QueryResult) ([]map[string]interface{}, error) {
+	var inner []map[string]interface{}
+	var err error
+	if key := t.lookupKey(source.Keys); key >= 0 {
+		inner, err = t.lookup(rows, source, source.Keys[key], result)
+	} else {
+		inner, err = t.scan(source, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmatched rows of a LEFT JOIN carry nulls for this table's columns
+	nulls := make(map[string]interface{})
+	for _, column := range t.Capability.ResponseColumns {
+		nulls[source.Alias+"."+column] = nil
+	}
+	return combineRows(rows, inner, source, nulls), nil
+}
+
+// combineRows pairs each outer row with the inner records whose join keys
+// match, through a hash table on the inner keys, and keeps the pairs that
+// satisfy the remaining ON conditions. Without keys every pair is compared.
+func combineRows(outer, inner []map[string]interface{}, source translator.JoinSource, nulls map[string]interface{}) []map[string]interface{} {
+	outerColumns := make([]string, len(source.Keys))
+	innerColumns := make([]string, len(source.Keys))
+	for i, key := range source.Keys {
+		outerColumns[i] = key.Outer
+		innerColumns[i] = source.Alias + "." + key.Inner
+	}
+
+	index := make(map[string][]map[string]interface{})
+	for _, record := range inner {
+		if key, ok := joinKeyValue(record, innerColumns); ok {
+			index[key] = append(index[key], record)
+		}
+	}
+
+	var joined []map[string]interface{}
+	for _, row := range outer {
+		candidates := inner
+		if len(source.Keys) > 0 {
+			candidates = nil
+			if key, ok := joinKeyValue(row, outerColumns); ok {
+				candidates = index[key]
+			}
+		}
+
+		matched := false
+		for _, candidate := range candidates {
+			combined := mergeRecords(row, candidate)
+			if matchesConditions(combined, source.On) {
+				joined = append(joined, combined)
+				matched = true
+			}
+		}
+		if !matched && source.Kind == "LEFT" {
+			joined = append(joined, mergeRecords(row, nulls))
+		}
+	}
+	return joined
+}
+
+// lookup fetches the records matching the distinct values of key in rows with
+// one request per value against the endpoint's path, running at most
+// maxLookupConcurrency requests at a time. A 404 means there is no record.
+func (t JoinTable) lookup(rows []map[string]interface{}, source translator.JoinSource, key translator.JoinKey, result *QueryResult) ([]map[string]interface{}, error) {
+	param := pathParameters(t.Capability.Path)[0]
+
+	var values []string
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		if value, _ := columnValue(row, key.Outer); value != nil && !seen[keyString(value)] {
+			seen[keyString(value)] = true
+			values = append(values, keyString(value))
+		}
+	}
+	if limit := t.Executor.maxRequests; len(values) > limit {
+		result.Warnings = append(result.Warnings,
+			fmt.Sprintf("%s: stopped after %d lookup requests (max_requests); results may be incomplete", source.Alias, limit))
+		values = values[:limit]
+	}
+	authenticator := t.Executor.authenticator(t.Capability)
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		records  []map[string]interface{}
+		firstErr error
+	)
+	slots := make(chan struct{}, maxLookupConcurrency)
+	for _, value := range values {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			apiURL := t.Capability.BaseURL + strings.Replace(t.Capability.Path, "{"+param+"}", url.PathEscape(value), 1)
+			found, err := t.Executor.fetchPage(apiURL, authenticator)
+
+			mu.Lock()
+			defer mu.Unlock()
+			var status *statusError
+			switch {
+			case errors.As(err, &status) && status.code == http.StatusNotFound:
+			case err != nil:
+				if firstErr == nil {
+					firstErr = err
+				}
+			default:
+				records = append(records, found.records...)
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	// A lookup endpoint takes no filters, so the table's own conditions are
+	// checked here
+	for _, condition := range source.Query.Conditions {
+		result.Warnings = append(result.Warnings,
+			fmt.Sprintf("%s: condition %s evaluated locally: looked up by {%s}", source.Alias, condition, param))
+	}
+	var kept []map[string]interface{}
+	for _, record := range records {
+		if matchesConditions(record, source.Query.Conditions) {
+			kept = append(kept, record)
+		}
+	}
+	return qualifyRecords(source.Alias, kept), nil
+}
+
+// lookupKey returns the index of the join key that fills the endpoint's path
+// parameter, e.g. "id" for /pet/{petId}, or -1 when there is none
+func (t JoinTable) lookupKey(keys []translator.JoinKey) int {
+	params := pathParameters(t.Capability.Path)
+	if len(params) != 1 {
+		return -1
+	}
+	for i, key := range keys {
+		if matchesPathParameter(params[0], key.Inner) {
+			return i
+		}
+	}
+	return -1
+}
+
+// matchesPathParameter reports whether a path parameter names column, either
+// exactly or as a suffix such as "petId" or "pet_id" for "id"
+func matchesPathParameter(param, column string) bool {
+	if strings.EqualFold(param, column) {
+		return true
+	}
+	if len(param) <= len(column) || !strings.EqualFold(param[len(param)-len(column):], column) {
+		return false
+	}
+	boundary := param[len(param)-len(column)-1]
+	first := param[len(param)-len(column)]
+	return boundary == '_' || (first >= 'A' && first <= 'Z')
+}
+
+// pathParameters returns the names of the placeholders in an endpoint path
+func pathParameters(path string) []string {
+	var params []string
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			params = append(params, strings.Trim(part, "{}"))
+		}
+	}
+	return params
+}
+
+// joinKeyValue renders the values of columns as a hash key; a null never matches
+func joinKeyValue(record map[string]interface{}, columns []string) (string, bool) {
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		value, _ := columnValue(record, column)
+		if value == nil {
+			return "", false
+		}
+		parts[i] = keyString(value)
+	}
+	return strings.Join(parts, "\x00"), true
+}
+
+// keyString renders a join key value. Integers share a form whether they
+// are numbers or numeric strings, so that 10 matches "10" and 10.0. Integer
+// strings and json.Numbers are read exactly, so large IDs stay whole; only
+// floats that hold integers are rewritten as one.
+func keyString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return numericKey(v)
+	case json.Number:
+		return numericKey(v.String())
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case float64:
+		return floatKey(v)
+	case float32:
+		return floatKey(float64(v))
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// numericKey renders a string holding an integer or a decimal as keyString
+// renders the number, and any other string as it is
+func numericKey(s string) string {
+	if n, ok := new(big.Int).SetString(s, 10); ok {
+		return n.String()
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return floatKey(f)
+	}
+	return s
+}
+
+func floatKey(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < math.MaxInt64 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// qualifyRecords prefixes every field of the records with the table alias,
+// e.g. "p.name"
+func qualifyRecords(alias string, records []map[string]interface{}) []map[string]interface{} {
+	qualified := make([]map[string]interface{}, len(records))
+	for i, record := range records {
+		row := make(map[string]interface{}, len(record))
+		for column, value := range record {
+			row[alias+"."+column] = value
+		}
+		qualified[i] = row
+	}
+	return qualified
+}
+
+func mergeRecords(a, b map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(a)+len(b))
+	for column, value := range a {
+		merged[column] = value
+	}
+	for column, value := range b {
+		merged[column] = value
+	}
+	return merged
+}
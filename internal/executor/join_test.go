@@ -0,0 +1,183 @@
+package executor
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/simonm/qRest/internal/apitest"
+	"github.com/simonm/qRest/internal/grammar"
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/translator"
+)
+
+var storeOrders = []map[string]interface{}{
+	{"id": 1, "petId": 2},
+	{"id": 2, "petId": 5},
+	{"id": 3, "petId": 9},
+}
+
+// newStoreAPI serves orders from /orders, pets 1 to 5 from /pets and single
+// pets from /pet/{petId}, answering 404 for pets it does not have. It
+// records the path and query string of every request.
+func newStoreAPI(t *testing.T) *apitest.Server {
+	pet := func(id int) map[string]interface{} {
+		return map[string]interface{}{"id": id, "name": "pet" + strconv.Itoa(id)}
+	}
+	return apitest.New(t, apitest.RequestURI, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/orders":
+			apitest.WriteJSON(w, http.StatusOK, storeOrders)
+		case r.URL.Path == "/pets":
+			pets := []map[string]interface{}{}
+			for id := 1; id <= 5; id++ {
+				if name := r.URL.Query().Get("name"); name == "" || name == pet(id)["name"] {
+					pets = append(pets, pet(id))
+				}
+			}
+			apitest.WriteJSON(w, http.StatusOK, pets)
+		case strings.HasPrefix(r.URL.Path, "/pet/"):
+			id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/pet/"))
+			if err != nil || id < 1 || id > 5 {
+				http.NotFound(w, r)
+				return
+			}
+			apitest.WriteJSON(w, http.StatusOK, pet(id))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func TestExecuteJoin(t *testing.T) {
+	tests := []struct {
+		sql      string
+		rows     [][]interface{}
+		requests []string
+	}{
+		{
+			sql:      "SELECT o.id, p.name FROM orders o JOIN pets p ON o.petId = p.id",
+			rows:     [][]interface{}{{1.0, "pet2"}, {2.0, "pet5"}},
+			requests: []string{"/orders", "/pets"},
+		},
+		{
+			sql:      "SELECT o.id, p.name FROM orders o JOIN pet p ON o.petId = p.id",
+			rows:     [][]interface{}{{1.0, "pet2"}, {2.0, "pet5"}},
+			requests: []string{"/orders", "/pet/2", "/pet/5", "/pet/9"},
+		},
+		{
+			sql:      "SELECT o.id, p.name FROM orders o LEFT JOIN pets p ON o.petId = p.id",
+			rows:     [][]interface{}{{1.0, "pet2"}, {2.0, "pet5"}, {3.0, nil}},
+			requests: []string{"/orders", "/pets"},
+		},
+		{
+			sql:      "SELECT o.id, p.name FROM orders o JOIN pets p ON o.petId = p.id WHERE p.name = 'pet5'",
+			rows:     [][]interface{}{{2.0, "pet5"}},
+			requests: []string{"/orders", "/pets?name=pet5"},
+		},
+		{
+			sql:      "SELECT o.id, p.name FROM orders o LEFT JOIN pets p ON o.petId = p.id WHERE p.name = 'pet5'",
+			rows:     [][]interface{}{{2.0, "pet5"}},
+			requests: []string{"/orders", "/pets"},
+		},
+		{
+			sql:      "SELECT o.id, p.id FROM orders o JOIN pets p ON p.id > o.petId",
+			rows:     [][]interface{}{{1.0, 3.0}, {1.0, 4.0}, {1.0, 5.0}},
+			requests: []string{"/orders", "/pets"},
+		},
+	}
+
+	for _, tt := range tests {
+		api := newStoreAPI(t)
+		capabilities := map[string]parser.APICapability{
+			"orders": {Path: "/orders", Method: "GET", TableName: "orders", BaseURL: api.URL, ResponseColumns: []string{"id", "petId"}},
+			"pets": {
+				Path: "/pets", Method: "GET", TableName: "pets", BaseURL: api.URL, ResponseColumns: []string{"id", "name"},
+				Parameters: []parser.Parameter{{Name: "name", Type: "string", Location: "query", Operators: []string{"="}}},
+			},
+			"pet": {
+				Path: "/pet/{petId}", Method: "GET", TableName: "pet", BaseURL: api.URL, ResponseColumns: []string{"id", "name"},
+				Parameters: []parser.Parameter{{Name: "petId", Type: "integer", Location: "path", Required: true}},
+			},
+		}
+		grammars := make(map[string]grammar.SQLGrammar)
+		for name, capability := range capabilities {
+			grammars[name] = grammar.NewGrammarGenerator().GenerateGrammar(capability)
+		}
+
+		names, err := translator.ExtractTableNames(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		sqlTranslator := translator.NewSimpleSQLTranslator(grammars[names[0]])
+		sqlTranslator.SetJoinTables(grammars)
+		query, err := sqlTranslator.ParseSQL(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		executor := NewRESTExecutor(nil)
+		tables := make([]JoinTable, len(names))
+		for i, name := range names {
+			tables[i] = JoinTable{Capability: capabilities[name], Executor: executor}
+		}
+
+		result, err := Execute(tables, query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		if result.Error != "" {
+			t.Fatalf("%s: %s", tt.sql, result.Error)
+		}
+		rows := make([][]interface{}, len(result.Data))
+		for i, record := range result.Data {
+			for _, column := range query.Columns {
+				rows[i] = append(rows[i], record[column])
+			}
+		}
+		if !reflect.DeepEqual(rows, tt.rows) {
+			t.Errorf("%s: got rows %v, want %v", tt.sql, rows, tt.rows)
+		}
+		// Lookups run concurrently, so requests are compared in path order
+		requests := api.Requests()
+		sort.Strings(requests)
+		if !reflect.DeepEqual(requests, tt.requests) {
+			t.Errorf("%s: got requests %q, want %q", tt.sql, requests, tt.requests)
+		}
+	}
+}
+
+func TestKeyString(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{10.0, "10"},
+		{10, "10"},
+		{int64(10), "10"},
+		{"10", "10"},
+		{"10.0", "10"},
+		{json.Number("10"), "10"},
+		{2.5, "2.5"},
+		{"2.50", "2.5"},
+		{1e21, "1000000000000000000000"},
+		{"9007199254740993", "9007199254740993"},
+		{json.Number("9007199254740993"), "9007199254740993"},
+		{int64(9007199254740993), "9007199254740993"},
+		{"18446744073709551617", "18446744073709551617"},
+		{"abc", "abc"},
+		{"NaN", "NaN"},
+		{true, "true"},
+	}
+	for _, tt := range tests {
+		if got := keyString(tt.value); got != tt.want {
+			t.Errorf("keyString(%#v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+	if keyString("9007199254740993") == keyString(json.Number("9007199254740992")) {
+		t.Errorf("IDs beyond 2^53 share a key")
+	}
+}
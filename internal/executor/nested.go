@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"strconv"
+	"strings"
+)
+
+// columnValue returns a column of a record. The column may be a path into
+// nested objects and arrays, such as "category.name" or "tags[0].name", and
+// in joined rows starts with the table alias, as in "p.category.name".
+func columnValue(record map[string]interface{}, column string) (interface{}, bool) {
+	if value, exists := record[column]; exists {
+		return value, true
+	}
+	// The longest field name the path starts with holds the nested value
+	for i := len(column) - 1; i > 0; i-- {
+		if column[i] != '.' && column[i] != '[' {
+			continue
+		}
+		if value, exists := record[column[:i]]; exists {
+			return descend(value, column[i:])
+		}
+	}
+	return nil, false
+}
+
+// descend follows a path of ".field" and "[index]" steps into a JSON value
+func descend(value interface{}, path string) (interface{}, bool) {
+	for path != "" {
+		switch path[0] {
+		case '.':
+			end := strings.IndexAny(path[1:], ".[") + 1
+			if end == 0 {
+				end = len(path)
+			}
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if value, ok = object[path[1:end]]; !ok {
+				return nil, false
+			}
+			path = path[end:]
+
+		case '[':
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return nil, false
+			}
+			index, err := strconv.Atoi(path[1:end])
+			array, ok := value.([]interface{})
+			if err != nil || !ok || index < 0 || index >= len(array) {
+				return nil, false
+			}
+			value = array[index]
+			path = path[end+1:]
+
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// flattenRecord replaces nested objects with "parent_child" fields, e.g.
+// {"category": {"name": "Dogs"}} becomes {"category_name": "Dogs"}. Arrays
+// are kept as they are.
+func flattenRecord(record map[string]interface{}) map[string]interface{} {
+	flat := make(map[string]interface{}, len(record))
+	flattenInto(flat, "", record)
+	return flat
+}
+
+func flattenInto(flat map[string]interface{}, prefix string, object map[string]interface{}) {
+	for field, value := range object {
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			flattenInto(flat, prefix+field+"_", nested)
+			continue
+		}
+		flat[prefix+field] = value
+	}
+}
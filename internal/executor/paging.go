@@ -0,0 +1,373 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/simonm/qRest/internal/auth"
+	"github.com/simonm/qRest/internal/parser"
+)
+
+// defaultMaxRequests is the request ceiling used when none is configured
+const defaultMaxRequests = 20
+
+// pagination describes the query parameters an endpoint pages with
+type pagination struct {
+	limitParam  string // page size: limit, per_page, page_size, size
+	offsetParam string // number of rows to skip: offset, skip, start
+	pageParam   string // 1-based page number: page
+	cursorParam string // opaque cursor taken from the previous response
+	maxPageSize int
+}
+
+// detectPagination classifies the endpoint's paging parameters by their
+// well-known names; filters that only contain one, such as shoe_size or
+// homepage, are left alone. Parameters carrying credentials, such as an
+// api_token key, are never paging parameters.
+func detectPagination(capability parser.APICapability, authenticator auth.Authenticator) pagination {
+	p := pagination{maxPageSize: capability.MaxResults}
+	credentials := authParams(capability, authenticator)
+	for _, param := range capability.Parameters {
+		name := strings.ToLower(param.Name)
+		if credentials[name] {
+			continue
+		}
+		switch {
+		case isCursorParam(name):
+			p.cursorParam = param.Name
+		case isPageSizeParam(name):
+			p.limitParam = param.Name
+		case name == "offset" || name == "skip" || name == "start":
+			p.offsetParam = param.Name
+		case name == "page" || name == "page_number" || name == "pagenumber":
+			p.pageParam = param.Name
+		}
+	}
+	return p
+}
+
+// isPageSizeParam reports whether a lower-case parameter name is a page
+// size: limit, size, page_size or per_page
+func isPageSizeParam(name string) bool {
+	switch strings.NewReplacer("_", "", "-", "").Replace(name) {
+	case "limit", "size", "pagesize", "perpage":
+		return true
+	}
+	return false
+}
+
+// isCursorParam reports whether a lower-case parameter name is a page
+// cursor: cursor, continuation, after, or a page or next token
+func isCursorParam(name string) bool {
+	name = strings.NewReplacer("_", "", "-", "").Replace(name)
+	switch name {
+	case "after", "pagetoken", "nexttoken", "nextpagetoken":
+		return true
+	}
+	return strings.Contains(name, "cursor") || strings.Contains(name, "continuation")
+}
+
+// authParams returns the lower-case names of the query parameters that
+// carry an endpoint's credentials: API keys its security schemes send in
+// the query, and the configured one
+func authParams(capability parser.APICapability, authenticator auth.Authenticator) map[string]bool {
+	names := make(map[string]bool)
+	for _, alternative := range capability.Security {
+		for _, scheme := range alternative {
+			if strings.EqualFold(scheme.Type, "apiKey") && strings.EqualFold(scheme.In, "query") {
+				names[strings.ToLower(scheme.ParamName)] = true
+			}
+		}
+	}
+	var collect func(auth.Authenticator)
+	collect = func(a auth.Authenticator) {
+		switch a := a.(type) {
+		case auth.APIKey:
+			if a.InQuery {
+				names[strings.ToLower(a.Name)] = true
+			}
+		case auth.Chain:
+			for _, link := range a {
+				collect(link)
+			}
+		}
+	}
+	collect(authenticator)
+	return names
+}
+
+// page is one response of a paged endpoint
+type page struct {
+	records []map[string]interface{}
+	isList  bool   // the response held an array of records
+	nextURL string // from a Link rel="next" header or a URL in the body
+	cursor  string // opaque cursor from the body
+}
+
+// pager walks the pages of an endpoint while keeping count of the requests a
+// query has issued
+type pager struct {
+	executor   *RESTExecutor
+	auth       auth.Authenticator // credentials of the endpoint's operation
+	pagination pagination
+	requests   int
+	truncated  bool // the request ceiling stopped a scan early
+}
+
+func (e *RESTExecutor) newPager(capability parser.APICapability) *pager {
+	authenticator := e.authenticator(capability)
+	return &pager{executor: e, auth: authenticator, pagination: detectPagination(capability, authenticator)}
+}
+
+// fetch GETs baseURL with params, following pages until want records past the
+// first skip have been kept, or every record when want is 0. It stops when
+// the API runs out of data or the request ceiling is reached. keep, when not
+// nil, filters records before they are counted.
+func (p *pager) fetch(baseURL string, params url.Values, skip, want int, keep func(map[string]interface{}) bool) ([]map[string]interface{}, error) {
+	pg := p.pagination
+	params = cloneValues(params)
+
+	// Ask for what the query still needs, capped by the API maximum. Without
+	// a limit parameter the API's own page size applies.
+	pageSize := want + skip
+	if skip > 0 && (pg.offsetParam != "" || pg.pageParam != "") {
+		pageSize = want
+	}
+	if want == 0 {
+		pageSize = 0
+	}
+	if pg.maxPageSize > 0 && (pageSize == 0 || pageSize > pg.maxPageSize) {
+		pageSize = pg.maxPageSize
+	}
+	if pg.limitParam == "" {
+		pageSize = 0
+	}
+
+	// Push OFFSET down when the API can skip rows itself. A page number
+	// reaches the page holding the first row; the rest is skipped here.
+	offset, pageNumber, localSkip := 0, 1, skip
+	switch {
+	case skip == 0:
+	case pg.offsetParam != "":
+		offset, localSkip = skip, 0
+	case pg.pageParam != "" && pageSize > 0:
+		pageNumber, localSkip = skip/pageSize+1, skip%pageSize
+	}
+
+	if pageSize > 0 {
+		params.Set(pg.limitParam, strconv.Itoa(pageSize))
+	}
+	if offset > 0 {
+		params.Set(pg.offsetParam, strconv.Itoa(offset))
+	}
+	if pageNumber > 1 {
+		params.Set(pg.pageParam, strconv.Itoa(pageNumber))
+	}
+
+	var records []map[string]interface{}
+	nextURL := withQuery(baseURL, params)
+	for nextURL != "" {
+		if p.requests >= p.executor.maxRequests {
+			p.truncated = true
+			break
+		}
+		p.requests++
+
+		current := nextURL
+		result, err := p.executor.fetchPage(current, p.auth)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range result.records {
+			if keep != nil && !keep(record) {
+				continue
+			}
+			if localSkip > 0 {
+				localSkip--
+				continue
+			}
+			records = append(records, record)
+		}
+		if want > 0 && len(records) >= want {
+			break
+		}
+		if len(result.records) == 0 {
+			break
+		}
+
+		// Work out the next page: links and cursors from the response win
+		// over counting rows
+		nextURL = ""
+		switch {
+		case result.nextURL != "":
+			nextURL = resolveURL(current, result.nextURL)
+			if nextURL == current {
+				nextURL = ""
+			}
+		case result.cursor != "" && pg.cursorParam != "":
+			params.Set(pg.cursorParam, result.cursor)
+			nextURL = withQuery(baseURL, params)
+		case !result.isList || (pg.offsetParam == "" && pg.pageParam == ""):
+		default:
+			if pageSize == 0 {
+				// Learn the API's default page size from the first page
+				pageSize = len(result.records)
+			}
+			if len(result.records) < pageSize {
+				break
+			}
+			if pg.offsetParam != "" {
+				offset += len(result.records)
+				params.Set(pg.offsetParam, strconv.Itoa(offset))
+			} else {
+				pageNumber++
+				params.Set(pg.pageParam, strconv.Itoa(pageNumber))
+			}
+			nextURL = withQuery(baseURL, params)
+		}
+	}
+
+	if want > 0 && len(records) > want {
+		records = records[:want]
+	}
+	return records, nil
+}
+
+// fetchPage GETs a URL and returns its records along with any pointer to the
+// next page
+func (e *RESTExecutor) fetchPage(apiURL string, authenticator auth.Authenticator) (*page, error) {
+	body, header, err := e.get(apiURL, authenticator)
+	if err != nil {
+		return nil, err
+	}
+
+	var jsonData interface{}
+	if err := json.Unmarshal(body, &jsonData); err != nil {
+		return nil, fmt.Errorf("Failed to parse API response: response is not valid JSON: %v", err)
+	}
+
+	records, err := e.extractDataArray(jsonData)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse API response: %v", err)
+	}
+
+	result := &page{records: records, nextURL: linkNext(header)}
+	switch data := jsonData.(type) {
+	case []interface{}:
+		result.isList = true
+	case map[string]interface{}:
+		result.isList = len(records) != 1 || !isSameRecord(records[0], data)
+		if result.nextURL == "" {
+			result.nextURL, result.cursor = nextFromBody(data)
+		}
+	}
+	if e.flatten {
+		for i, record := range result.records {
+			result.records[i] = flattenRecord(record)
+		}
+	}
+	return result, nil
+}
+
+// linkNext returns the target of an RFC 5988 Link header with rel="next".
+// Targets are split on their angle brackets since URLs may contain commas.
+func linkNext(header http.Header) string {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, "<")[1:] {
+			target, attributes, found := strings.Cut(link, ">")
+			if !found {
+				continue
+			}
+			for _, attribute := range strings.Split(attributes, ";") {
+				key, val, found := strings.Cut(strings.TrimSpace(attribute), "=")
+				if !found || !strings.EqualFold(strings.TrimSpace(key), "rel") {
+					continue
+				}
+				val = strings.TrimRight(strings.TrimSpace(val), ", ")
+				for _, rel := range strings.Fields(strings.Trim(val, `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return target
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// nextFromBody looks for a next page in a wrapped response: a "next" field
+// holding a URL or cursor, common cursor field names, or a "next" link nested
+// in a links/paging object
+func nextFromBody(data map[string]interface{}) (string, string) {
+	if next, ok := data["next"].(string); ok && next != "" {
+		if looksLikeURL(next) {
+			return next, ""
+		}
+		return "", next
+	}
+	for _, field := range []string{"next_cursor", "nextCursor", "next_page_token", "nextPageToken"} {
+		if cursor, ok := data[field].(string); ok && cursor != "" {
+			return "", cursor
+		}
+	}
+	for _, field := range []string{"links", "_links", "paging", "pagination", "meta"} {
+		nested, ok := data[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch next := nested["next"].(type) {
+		case string:
+			if looksLikeURL(next) {
+				return next, ""
+			}
+			if next != "" {
+				return "", next
+			}
+		case map[string]interface{}:
+			if href, ok := next["href"].(string); ok && href != "" {
+				return href, ""
+			}
+		}
+		if cursor, ok := nested["next_cursor"].(string); ok && cursor != "" {
+			return "", cursor
+		}
+	}
+	return "", ""
+}
+
+func looksLikeURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") ||
+		strings.HasPrefix(s, "/") || strings.Contains(s, "?")
+}
+
+// isSameRecord reports whether extractDataArray fell back to treating the
+// whole response object as a single record
+func isSameRecord(record, data map[string]interface{}) bool {
+	return len(record) == len(data) && recordKey(record) == recordKey(data)
+}
+
+// resolveURL resolves a possibly relative next-page link against the URL it
+// was returned from
+func resolveURL(base, ref string) string {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return baseURL.ResolveReference(refURL).String()
+}
+
+func withQuery(baseURL string, params url.Values) string {
+	if len(params) == 0 {
+		return baseURL
+	}
+	return baseURL + "?" + params.Encode()
+}
@@ -0,0 +1,335 @@
+package executor
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/simonm/qRest/internal/apitest"
+	"github.com/simonm/qRest/internal/auth"
+	"github.com/simonm/qRest/internal/grammar"
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/translator"
+)
+
+// newPetsAPI serves pets 1 to total from /pets, paging with limit/offset or
+// page/per_page, from /linked three at a time with Link headers and from
+// /cursor three at a time with a next_page_token in the body. It records
+// the query string of every request.
+func newPetsAPI(t *testing.T, total int) *apitest.Server {
+	return apitest.New(t, apitest.Query, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		number := func(name string, fallback int) int {
+			if n, err := strconv.Atoi(query.Get(name)); err == nil {
+				return n
+			}
+			return fallback
+		}
+
+		start, size := 0, total
+		var next string
+		switch {
+		case r.URL.Path == "/cursor":
+			start, size = number("page_token", 0), 3
+			if start+size < total {
+				next = strconv.Itoa(start + size)
+			}
+		case r.URL.Path == "/linked":
+			start, size = number("after", 0), 3
+			if start+size < total {
+				w.Header().Set("Link", fmt.Sprintf(`</linked?after=%d>; rel="next"`, start+size))
+			}
+		case query.Has("per_page"):
+			size = number("per_page", 3)
+			start = (number("page", 1) - 1) * size
+		default:
+			start, size = number("offset", 0), number("limit", total)
+		}
+
+		pets := []map[string]interface{}{}
+		for id := start + 1; id <= total && id <= start+size; id++ {
+			pets = append(pets, map[string]interface{}{"id": id, "name": fmt.Sprintf("pet%d", id)})
+		}
+		if r.URL.Path == "/cursor" {
+			apitest.WriteJSON(w, http.StatusOK, map[string]interface{}{"data": pets, "next_page_token": next})
+			return
+		}
+		apitest.WriteJSON(w, http.StatusOK, pets)
+	})
+}
+
+func recordIDs(records []map[string]interface{}) []int {
+	ids := make([]int, len(records))
+	for i, record := range records {
+		id, _ := record["id"].(float64)
+		ids[i] = int(id)
+	}
+	return ids
+}
+
+func TestDetectPagination(t *testing.T) {
+	params := func(names ...string) []parser.Parameter {
+		parameters := make([]parser.Parameter, len(names))
+		for i, name := range names {
+			parameters[i] = parser.Parameter{Name: name, Location: "query"}
+		}
+		return parameters
+	}
+	queryKey := [][]parser.SecurityScheme{{{Name: "key", Type: "apiKey", In: "query", ParamName: "api_token"}}}
+
+	tests := []struct {
+		name          string
+		capability    parser.APICapability
+		authenticator auth.Authenticator
+		want          pagination
+	}{
+		{
+			name:       "limit and offset",
+			capability: parser.APICapability{Parameters: params("limit", "offset", "status")},
+			want:       pagination{limitParam: "limit", offsetParam: "offset"},
+		},
+		{
+			name:       "page numbers",
+			capability: parser.APICapability{Parameters: params("page", "per_page"), MaxResults: 50},
+			want:       pagination{limitParam: "per_page", pageParam: "page", maxPageSize: 50},
+		},
+		{
+			name:       "cursor names",
+			capability: parser.APICapability{Parameters: params("pageSize", "pageToken")},
+			want:       pagination{limitParam: "pageSize", cursorParam: "pageToken"},
+		},
+		{
+			name:       "filters named like paging parameters",
+			capability: parser.APICapability{Parameters: params("shoe_size", "rate_limit", "homepage", "offset_days", "page_size")},
+			want:       pagination{limitParam: "page_size"},
+		},
+		{
+			name:       "page number and skip",
+			capability: parser.APICapability{Parameters: params("size", "pageNumber", "skip")},
+			want:       pagination{limitParam: "size", offsetParam: "skip", pageParam: "pageNumber"},
+		},
+		{
+			name:       "tokens that are not cursors",
+			capability: parser.APICapability{Parameters: params("access_token", "id_token", "next_cursor")},
+			want:       pagination{cursorParam: "next_cursor"},
+		},
+		{
+			name:       "key of a security scheme",
+			capability: parser.APICapability{Parameters: params("api_token", "page_token"), Security: queryKey},
+			want:       pagination{cursorParam: "page_token"},
+		},
+		{
+			name:          "configured key",
+			capability:    parser.APICapability{Parameters: params("continuation", "after")},
+			authenticator: auth.APIKey{Key: "secret", Name: "after", InQuery: true},
+			want:          pagination{cursorParam: "continuation"},
+		},
+	}
+
+	for _, tt := range tests {
+		authenticator := tt.authenticator
+		if authenticator == nil {
+			authenticator = auth.None{}
+		}
+		if got := detectPagination(tt.capability, authenticator); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPagerFetch(t *testing.T) {
+	limitOffset := pagination{limitParam: "limit", offsetParam: "offset", maxPageSize: 3}
+	pageNumbers := pagination{limitParam: "per_page", pageParam: "page", maxPageSize: 3}
+
+	tests := []struct {
+		name        string
+		path        string
+		pagination  pagination
+		maxRequests int
+		skip, want  int
+		keep        func(map[string]interface{}) bool
+		ids         []int
+		requests    []string
+		truncated   bool
+	}{
+		{
+			name:       "limit within one page",
+			pagination: limitOffset,
+			want:       2,
+			ids:        []int{1, 2},
+			requests:   []string{"limit=2"},
+		},
+		{
+			name:       "limit across pages",
+			pagination: limitOffset,
+			want:       5,
+			ids:        []int{1, 2, 3, 4, 5},
+			requests:   []string{"limit=3", "limit=3&offset=3"},
+		},
+		{
+			name:       "offset pushed down",
+			pagination: limitOffset,
+			skip:       2,
+			want:       2,
+			ids:        []int{3, 4},
+			requests:   []string{"limit=2&offset=2"},
+		},
+		{
+			name:       "every record",
+			pagination: limitOffset,
+			ids:        []int{1, 2, 3, 4, 5, 6, 7},
+			requests:   []string{"limit=3", "limit=3&offset=3", "limit=3&offset=6"},
+		},
+		{
+			name:       "offset as a page number",
+			pagination: pageNumbers,
+			skip:       4,
+			want:       2,
+			ids:        []int{5, 6},
+			requests:   []string{"page=3&per_page=2"},
+		},
+		{
+			name:       "filtered records are not counted",
+			pagination: limitOffset,
+			want:       2,
+			keep:       func(record map[string]interface{}) bool { return record["id"].(float64) > 4 },
+			ids:        []int{5, 6},
+			requests:   []string{"limit=2", "limit=2&offset=2", "limit=2&offset=4"},
+		},
+		{
+			name:     "link headers",
+			path:     "/linked",
+			want:     5,
+			ids:      []int{1, 2, 3, 4, 5},
+			requests: []string{"", "after=3"},
+		},
+		{
+			name:       "cursor from the body",
+			path:       "/cursor",
+			pagination: pagination{cursorParam: "page_token"},
+			want:       5,
+			ids:        []int{1, 2, 3, 4, 5},
+			requests:   []string{"", "page_token=3"},
+		},
+		{
+			name:        "request ceiling",
+			pagination:  limitOffset,
+			maxRequests: 2,
+			ids:         []int{1, 2, 3, 4, 5, 6},
+			requests:    []string{"limit=3", "limit=3&offset=3"},
+			truncated:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newPetsAPI(t, 7)
+			executor := NewRESTExecutor(nil)
+			executor.SetMaxRequests(tt.maxRequests)
+			pages := &pager{executor: executor, auth: auth.None{}, pagination: tt.pagination}
+
+			path := tt.path
+			if path == "" {
+				path = "/pets"
+			}
+			records, err := pages.fetch(api.URL+path, url.Values{}, tt.skip, tt.want, tt.keep)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := recordIDs(records); !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("got ids %v, want %v", ids, tt.ids)
+			}
+			if requests := api.Requests(); !reflect.DeepEqual(requests, tt.requests) {
+				t.Errorf("got requests %q, want %q", requests, tt.requests)
+			}
+			if pages.truncated != tt.truncated {
+				t.Errorf("got truncated %v, want %v", pages.truncated, tt.truncated)
+			}
+		})
+	}
+}
+
+func TestExecuteSelectLimit(t *testing.T) {
+	tests := []struct {
+		sql      string
+		ids      []int
+		requests int
+	}{
+		{sql: "SELECT * FROM pets LIMIT 2", ids: []int{1, 2}, requests: 1},
+		{sql: "SELECT * FROM pets LIMIT 2 OFFSET 3", ids: []int{4, 5}, requests: 1},
+		{sql: "SELECT * FROM pets LIMIT 0", ids: []int{}, requests: 0},
+		{sql: "SELECT COUNT(*) FROM pets LIMIT 0", ids: []int{}, requests: 0},
+		{sql: "SELECT * FROM pets WHERE name = 'pet3' LIMIT 0", ids: []int{}, requests: 0},
+	}
+
+	for _, tt := range tests {
+		api := newPetsAPI(t, 7)
+		capability := parser.APICapability{
+			Path:            "/pets",
+			Method:          "GET",
+			TableName:       "pets",
+			BaseURL:         api.URL,
+			ResponseColumns: []string{"id", "name"},
+			Parameters: []parser.Parameter{
+				{Name: "limit", Type: "integer", Location: "query"},
+				{Name: "offset", Type: "integer", Location: "query"},
+			},
+			HasPaging: true,
+		}
+		query, err := translator.NewSimpleSQLTranslator(grammar.NewGrammarGenerator().GenerateGrammar(capability)).ParseSQL(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+
+		result, err := NewRESTExecutor(nil).ExecuteQuery(capability, query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		if result.Error != "" {
+			t.Fatalf("%s: %s", tt.sql, result.Error)
+		}
+		if ids := recordIDs(result.Data); !reflect.DeepEqual(ids, tt.ids) {
+			t.Errorf("%s: got ids %v, want %v", tt.sql, ids, tt.ids)
+		}
+		if requests := len(api.Requests()); requests != tt.requests {
+			t.Errorf("%s: sent %d requests, want %d", tt.sql, requests, tt.requests)
+		}
+	}
+}
+
+func TestExecuteCursorKeepsQueryKey(t *testing.T) {
+	api := newPetsAPI(t, 5)
+	capability := parser.APICapability{
+		Path:            "/cursor",
+		Method:          "GET",
+		TableName:       "pets",
+		BaseURL:         api.URL,
+		ResponseColumns: []string{"id", "name"},
+		Parameters: []parser.Parameter{
+			{Name: "page_token", Type: "string", Location: "query"},
+			{Name: "api_token", Type: "string", Location: "query"},
+		},
+	}
+	query, err := translator.NewSimpleSQLTranslator(grammar.NewGrammarGenerator().GenerateGrammar(capability)).ParseSQL("SELECT * FROM pets")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewRESTExecutor(auth.APIKey{Key: "secret", Name: "api_token", InQuery: true}).ExecuteQuery(capability, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Error != "" {
+		t.Fatal(result.Error)
+	}
+	if ids := recordIDs(result.Data); !reflect.DeepEqual(ids, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("got ids %v, want 1 to 5", ids)
+	}
+	want := []string{"api_token=secret", "api_token=secret&page_token=3"}
+	if requests := api.Requests(); !reflect.DeepEqual(requests, want) {
+		t.Errorf("got requests %q, want %q", requests, want)
+	}
+}
@@ -0,0 +1,197 @@
+package executor
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/translator"
+)
+
+// selectRequest is one GET issued for a SELECT: the query parameters sent to
+// the API plus the residual predicates checked on the returned records. A
+// record is kept when it satisfies any of the residual groups; a request
+// without residual groups keeps every record.
+type selectRequest struct {
+	params   url.Values
+	residual [][]translator.Condition
+}
+
+// selectPlan splits a WHERE clause into the requests sent to the API and the
+// predicates evaluated in-process because no parameter can express them
+type selectPlan struct {
+	requests []*selectRequest
+	residual []translator.Condition
+}
+
+// filtersLocally reports whether any request drops records after fetching them
+func (p *selectPlan) filtersLocally() bool {
+	for _, request := range p.requests {
+		if len(request.residual) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// keep applies a request's residual predicates to a record
+func (r *selectRequest) keep(record map[string]interface{}) bool {
+	if len(r.residual) == 0 {
+		return true
+	}
+	for _, conditions := range r.residual {
+		if matchesConditions(record, conditions) {
+			return true
+		}
+	}
+	return false
+}
+
+// planSelect pushes each condition down to an API parameter where one exists
+// and keeps the rest as residual predicates. OR groups that end up sending
+// the same parameters share a single request.
+func (e *RESTExecutor) planSelect(capability parser.APICapability, query *translator.ParsedQuery) (*selectPlan, error) {
+	plan := &selectPlan{}
+	byParams := make(map[string]*selectRequest)
+	seenResidual := make(map[string]bool)
+
+	for _, conditions := range e.expandConditionGroups(capability, query.ConditionGroups()) {
+		params, residual, err := e.buildFilterParams(capability, conditions)
+		if err != nil {
+			return nil, err
+		}
+		if missing := missingRequiredParameter(capability, params); len(conditions) > 0 && missing != "" {
+			return nil, fmt.Errorf("cannot evaluate %s locally: the API requires parameter '%s'",
+				joinConditions(conditions), missing)
+		}
+
+		for _, condition := range residual {
+			if !seenResidual[condition.String()] {
+				seenResidual[condition.String()] = true
+				plan.residual = append(plan.residual, condition)
+			}
+		}
+
+		key := params.Encode()
+		request, exists := byParams[key]
+		switch {
+		case !exists:
+			request = &selectRequest{params: params}
+			if len(residual) > 0 {
+				request.residual = [][]translator.Condition{residual}
+			}
+			byParams[key] = request
+			plan.requests = append(plan.requests, request)
+		case len(request.residual) == 0:
+			// An earlier group already keeps every record of this request
+		case len(residual) == 0:
+			request.residual = nil
+		default:
+			request.residual = append(request.residual, residual)
+		}
+	}
+
+	return plan, nil
+}
+
+// expandConditionGroups splits IN conditions into one group per value when the
+// API filters the column by a single value only. IN lists the API cannot
+// express at all stay as they are and are evaluated locally.
+func (e *RESTExecutor) expandConditionGroups(capability parser.APICapability, groups [][]translator.Condition) [][]translator.Condition {
+	var expanded [][]translator.Condition
+	for _, group := range groups {
+		partial := [][]translator.Condition{{}}
+		for _, condition := range group {
+			values, isList := condition.Value.([]interface{})
+			if condition.Operator != "IN" || !isList || !e.expandsToEqualities(capability, condition.Column) {
+				for i := range partial {
+					partial[i] = append(partial[i], condition)
+				}
+				continue
+			}
+
+			var next [][]translator.Condition
+			for _, conditions := range partial {
+				for _, value := range values {
+					alternative := append(append([]translator.Condition{}, conditions...), translator.Condition{
+						Column:   condition.Column,
+						Operator: "=",
+						Value:    value,
+					})
+					next = append(next, alternative)
+				}
+			}
+			partial = next
+		}
+		expanded = append(expanded, partial...)
+	}
+	return expanded
+}
+
+// expandsToEqualities reports whether an IN on column is best sent as one
+// request per value: the API has an equality parameter but no list parameter
+func (e *RESTExecutor) expandsToEqualities(capability parser.APICapability, column string) bool {
+	if e.findMultiValueParameter(capability, column) != "" {
+		return false
+	}
+	for _, param := range capability.Parameters {
+		if strings.EqualFold(param.Name, column) && contains(param.Operators, "=") {
+			return true
+		}
+	}
+	return false
+}
+
+// buildFilterParams converts AND-ed conditions into query parameters.
+// Conditions, or parts of them, the API cannot express are returned as
+// residual predicates to evaluate locally.
+func (e *RESTExecutor) buildFilterParams(capability parser.APICapability, conditions []translator.Condition) (url.Values, []translator.Condition, error) {
+	params := url.Values{}
+	var residual []translator.Condition
+
+	for _, condition := range conditions {
+		if condition.Operator == "BETWEEN" {
+			between, betweenResidual, err := e.convertBetweenToParams(capability, condition)
+			if err != nil {
+				return nil, nil, err
+			}
+			for name, values := range between {
+				params[name] = append(params[name], values...)
+			}
+			residual = append(residual, betweenResidual...)
+			continue
+		}
+
+		paramName, paramValues, err := e.convertConditionToParam(capability, condition)
+		if err != nil {
+			residual = append(residual, condition)
+			continue
+		}
+
+		for _, paramValue := range paramValues {
+			params.Add(paramName, paramValue)
+		}
+	}
+
+	return params, residual, nil
+}
+
+// missingRequiredParameter returns the first required query parameter that
+// params does not set
+func missingRequiredParameter(capability parser.APICapability, params url.Values) string {
+	for _, param := range capability.Parameters {
+		if param.Required && param.Location == "query" && params.Get(param.Name) == "" {
+			return param.Name
+		}
+	}
+	return ""
+}
+
+func joinConditions(conditions []translator.Condition) string {
+	parts := make([]string, len(conditions))
+	for i, condition := range conditions {
+		parts[i] = condition.String()
+	}
+	return strings.Join(parts, " AND ")
+}
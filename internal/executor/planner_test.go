@@ -0,0 +1,212 @@
+package executor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/simonm/qRest/internal/auth"
+	"github.com/simonm/qRest/internal/grammar"
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/translator"
+)
+
+// planPets is a pets endpoint filtering by status and name, with age and
+// tags only filtered locally
+var planPets = parser.APICapability{
+	Path:            "/pets",
+	Method:          "GET",
+	TableName:       "pets",
+	ResponseColumns: []string{"id", "name", "status", "age", "tags"},
+	Parameters: []parser.Parameter{
+		{Name: "status", Type: "string", Location: "query", Operators: []string{"="}},
+		{Name: "name", Type: "string", Location: "query", Operators: []string{"="}},
+	},
+}
+
+// describePlan writes each request of a plan as its query string and the
+// residual groups that filter its records, e.g. "status=sold | age > 3"
+func describePlan(plan *selectPlan) []string {
+	requests := make([]string, len(plan.requests))
+	for i, request := range plan.requests {
+		groups := make([]string, len(request.residual))
+		for j, conditions := range request.residual {
+			groups[j] = joinConditions(conditions)
+		}
+		requests[i] = request.params.Encode()
+		if len(groups) > 0 {
+			requests[i] += " | " + strings.Join(groups, " OR ")
+		}
+	}
+	return requests
+}
+
+func planWhere(t *testing.T, capability parser.APICapability, where string) (*selectPlan, error) {
+	t.Helper()
+	sql := "SELECT * FROM " + capability.TableName + " WHERE " + where
+	query, err := translator.NewSimpleSQLTranslator(grammar.NewGrammarGenerator().GenerateGrammar(capability)).ParseSQL(sql)
+	if err != nil {
+		t.Fatalf("%s: %v", sql, err)
+	}
+	return NewRESTExecutor(auth.None{}).planSelect(capability, query)
+}
+
+func TestPlanSelect(t *testing.T) {
+	tests := []struct {
+		where    string
+		requests []string
+	}{
+		{
+			where:    "status = 'sold' AND name = 'Rex'",
+			requests: []string{"name=Rex&status=sold"},
+		},
+		{
+			where:    "status = 'sold' OR name = 'Rex'",
+			requests: []string{"status=sold", "name=Rex"},
+		},
+		{
+			where:    "status = 'sold' AND (name = 'Rex' OR age > 3)",
+			requests: []string{"name=Rex&status=sold", "status=sold | age > 3"},
+		},
+		{
+			// Groups sending the same parameters share one request
+			where:    "(status = 'sold' AND age > 3) OR (status = 'sold' AND age < 1)",
+			requests: []string{"status=sold | age > 3 OR age < 1"},
+		},
+		{
+			// A group without residual predicates keeps every record
+			where:    "status = 'sold' OR (status = 'sold' AND age > 3)",
+			requests: []string{"status=sold"},
+		},
+		{
+			where:    "NOT (status = 'sold' OR age > 3)",
+			requests: []string{" | status != 'sold' AND age <= 3"},
+		},
+		{
+			where:    "NOT (status = 'sold' AND name = 'Rex')",
+			requests: []string{" | status != 'sold' OR name != 'Rex'"},
+		},
+	}
+
+	for _, tt := range tests {
+		plan, err := planWhere(t, planPets, tt.where)
+		if err != nil {
+			t.Errorf("%s: %v", tt.where, err)
+			continue
+		}
+		if requests := describePlan(plan); !reflect.DeepEqual(requests, tt.requests) {
+			t.Errorf("%s: got requests %q, want %q", tt.where, requests, tt.requests)
+		}
+	}
+}
+
+func TestPlanSelectRequiredParameter(t *testing.T) {
+	capability := planPets
+	capability.Parameters = []parser.Parameter{
+		{Name: "status", Type: "string", Location: "query", Required: true, Operators: []string{"="}},
+	}
+
+	if _, err := planWhere(t, capability, "status = 'sold' OR status = 'pending'"); err != nil {
+		t.Errorf("got error %v, want none", err)
+	}
+	_, err := planWhere(t, capability, "status = 'sold' OR age > 3")
+	if want := "cannot evaluate age > 3 locally: the API requires parameter 'status'"; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
+
+func TestPlanSelectIn(t *testing.T) {
+	capability := planPets
+	capability.Parameters = []parser.Parameter{
+		{Name: "status", Type: "string", Location: "query", Operators: []string{"="}},
+		{Name: "tags", Type: "array", Location: "query", Operators: []string{"IN"}, CollectionFormat: "pipes"},
+		{Name: "id_nin", Type: "array", Location: "query", CollectionFormat: "multi"},
+	}
+
+	tests := []struct {
+		where    string
+		requests []string
+	}{
+		{
+			where:    "tags IN ('cat', 'dog')",
+			requests: []string{"tags=cat%7Cdog"},
+		},
+		{
+			// Without a list parameter each value is one request
+			where:    "status IN ('sold', 'pending')",
+			requests: []string{"status=sold", "status=pending"},
+		},
+		{
+			where:    "id NOT IN (1, 2)",
+			requests: []string{"id_nin=1&id_nin=2"},
+		},
+		{
+			where:    "age IN (1, 2)",
+			requests: []string{" | age IN (1, 2)"},
+		},
+		{
+			where:    "status NOT IN ('sold')",
+			requests: []string{" | status NOT IN ('sold')"},
+		},
+	}
+
+	for _, tt := range tests {
+		plan, err := planWhere(t, capability, tt.where)
+		if err != nil {
+			t.Errorf("%s: %v", tt.where, err)
+			continue
+		}
+		if requests := describePlan(plan); !reflect.DeepEqual(requests, tt.requests) {
+			t.Errorf("%s: got requests %q, want %q", tt.where, requests, tt.requests)
+		}
+	}
+}
+
+func TestPlanSelectBetween(t *testing.T) {
+	capability := planPets
+	capability.ResponseColumns = []string{"id", "price", "age", "weight", "height"}
+	capability.Parameters = []parser.Parameter{
+		{Name: "price_between", Type: "string", Location: "query"},
+		{Name: "age_gte", Type: "integer", Location: "query"},
+		{Name: "age_lte", Type: "integer", Location: "query"},
+		{Name: "weight_min", Type: "number", Location: "query"},
+	}
+
+	tests := []struct {
+		where    string
+		requests []string
+	}{
+		{
+			where:    "price BETWEEN 10 AND 20",
+			requests: []string{"price_between=10%2C20"},
+		},
+		{
+			where:    "age BETWEEN 1 AND 3",
+			requests: []string{"age_gte=1&age_lte=3"},
+		},
+		{
+			// The bound without a parameter is checked locally
+			where:    "weight BETWEEN 2.5 AND 4",
+			requests: []string{"weight_min=2.5 | weight <= 4"},
+		},
+		{
+			where:    "height BETWEEN 1 AND 2",
+			requests: []string{" | height BETWEEN (1, 2)"},
+		},
+		{
+			where:    "age NOT BETWEEN 1 AND 3",
+			requests: []string{" | age < 1 OR age > 3"},
+		},
+	}
+
+	for _, tt := range tests {
+		plan, err := planWhere(t, capability, tt.where)
+		if err != nil {
+			t.Errorf("%s: %v", tt.where, err)
+			continue
+		}
+		if requests := describePlan(plan); !reflect.DeepEqual(requests, tt.requests) {
+			t.Errorf("%s: got requests %q, want %q", tt.where, requests, tt.requests)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package executor
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,47 +12,89 @@ import (
 	"strings"
 	"time"
 
+	"github.com/simonm/qRest/internal/auth"
+	"github.com/simonm/qRest/internal/cache"
 	"github.com/simonm/qRest/internal/parser"
 	"github.com/simonm/qRest/internal/translator"
 )
 
 type RESTExecutor struct {
 	client      *http.Client
-	authType    string
-	authToken   string
+	auth        auth.Authenticator
+	maxRequests int  // ceiling on API requests per query
+	flatten     bool // nested objects are read as "parent_child" fields
+	retry       retryPolicy
+	cache       *cache.Cache  // shared response cache, nil when off
+	cacheTTL    time.Duration // how long this API's responses are cached
 }
 
 type QueryResult struct {
 	Data     []map[string]interface{} `json:"data"`
+	Columns  []string                 `json:"columns,omitempty"` // output columns in SELECT order, computed ones included
 	Total    int                      `json:"total"`
 	Error    string                   `json:"error,omitempty"`
 	Warnings []string                 `json:"warnings,omitempty"`
 }
 
-func NewRESTExecutor(authType, authToken string) *RESTExecutor {
+// NewRESTExecutor returns an executor that sends each request through
+// authenticator, or without credentials when it is nil
+func NewRESTExecutor(authenticator auth.Authenticator) *RESTExecutor {
+	if authenticator == nil {
+		authenticator = auth.None{}
+	}
 	return &RESTExecutor{
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		authType:  authType,
-		authToken: authToken,
+		auth:        authenticator,
+		maxRequests: defaultMaxRequests,
 	}
 }
 
+// authenticator returns the credentials of capability's operation, which the
+// spec's security requirements decide when the executor has a resolver
+func (e *RESTExecutor) authenticator(capability parser.APICapability) auth.Authenticator {
+	if resolver, ok := e.auth.(auth.Resolver); ok {
+		return resolver.Resolve(capability.Security)
+	}
+	return e.auth
+}
+
+// SetTimeout bounds each attempt of an API request, 30s by default
+func (e *RESTExecutor) SetTimeout(d time.Duration) {
+	if d > 0 {
+		e.client.Timeout = d
+	}
+}
+
+// SetMaxRequests bounds how many API requests a single query may issue across
+// all pages and OR alternatives
+func (e *RESTExecutor) SetMaxRequests(n int) {
+	if n > 0 {
+		e.maxRequests = n
+	}
+}
+
+// SetFlattenNested flattens the nested objects of fetched records into
+// "parent_child" fields, matching a parser set to flatten them
+func (e *RESTExecutor) SetFlattenNested(flatten bool) {
+	e.flatten = flatten
+}
+
+// SetCache serves GET responses from c for ttl, then revalidates them with
+// their ETag or Last-Modified. A zero ttl leaves this API uncached.
+func (e *RESTExecutor) SetCache(c *cache.Cache, ttl time.Duration) {
+	e.cache = c
+	e.cacheTTL = ttl
+}
+
 func (e *RESTExecutor) ExecuteQuery(capability parser.APICapability, query *translator.ParsedQuery) (*QueryResult, error) {
 	var resp *http.Response
 	var err error
 	
 	switch query.QueryType {
 	case "SELECT":
-		// Build REST API URL with query parameters
-		apiURL, err := e.buildAPIURL(capability, query)
-		if err != nil {
-			return nil, fmt.Errorf("failed to build API URL: %w", err)
-		}
-		
-		// Make GET request
-		resp, err = e.makeRequest("GET", apiURL, nil)
+		return e.executeSelect(capability, query)
 		
 	case "INSERT":
 		// Build base URL without query parameters
@@ -63,7 +107,7 @@ func (e *RESTExecutor) ExecuteQuery(capability parser.APICapability, query *tran
 		}
 		
 		// Make POST request
-		resp, err = e.makeRequest("POST", apiURL, body)
+		resp, err = e.makeRequest("POST", apiURL, body, e.authenticator(capability))
 		
 	case "UPDATE":
 		// Build URL with ID from WHERE clause
@@ -74,7 +118,7 @@ func (e *RESTExecutor) ExecuteQuery(capability parser.APICapability, query *tran
 		
 		// Make PUT/PATCH request
 		method := capability.Method // Use the method from capability (PUT or PATCH)
-		resp, err = e.makeRequest(method, apiURL, query.Updates)
+		resp, err = e.makeRequest(method, apiURL, query.Updates, e.authenticator(capability))
 		
 	case "DELETE":
 		// Build URL with ID from WHERE clause
@@ -84,12 +128,15 @@ func (e *RESTExecutor) ExecuteQuery(capability parser.APICapability, query *tran
 		}
 		
 		// Make DELETE request
-		resp, err = e.makeRequest("DELETE", apiURL, nil)
+		resp, err = e.makeRequest("DELETE", apiURL, nil, e.authenticator(capability))
 		
 	default:
 		return nil, fmt.Errorf("unsupported query type: %s", query.QueryType)
 	}
 	
+	// Even a failed mutation may have changed the data
+	e.InvalidateCache(capability)
+	
 	if err != nil {
 		return &QueryResult{
 			Error: fmt.Sprintf("API request failed: %v", err),
@@ -114,24 +161,106 @@ func (e *RESTExecutor) ExecuteQuery(capability parser.APICapability, query *tran
 	return result, nil
 }
 
-func (e *RESTExecutor) buildAPIURL(capability parser.APICapability, query *translator.ParsedQuery) (string, error) {
-	baseURL := capability.BaseURL + capability.Path
-	params := url.Values{}
-
-	// Convert SQL conditions to REST API query parameters
-	for _, condition := range query.Conditions {
-		paramName, paramValue, err := e.convertConditionToParam(capability, condition)
+// executeSelect issues the requests planned for the WHERE clause, applies the
+// residual predicates to the returned records and merges the results
+func (e *RESTExecutor) executeSelect(capability parser.APICapability, query *translator.ParsedQuery) (*QueryResult, error) {
+	if query.NoRows {
+		return &QueryResult{Columns: query.Columns}, nil
+	}
+	
+	plan, err := e.planSelect(capability, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build API URL: %w", err)
+	}
+	result := &QueryResult{}
+	
+	for _, condition := range plan.residual {
+		result.Warnings = append(result.Warnings, 
+			fmt.Sprintf("condition %s evaluated locally: the API has no matching parameter", condition))
+	}
+	
+	// ORDER BY runs here when the API cannot sort or when the results of
+	// several requests are merged. Grouped queries always sort their output here.
+	aggregate := query.IsAggregate()
+	sortLocally := aggregate && len(query.OrderBy) > 0
+	if len(query.OrderBy) > 0 && !aggregate {
+		switch {
+		case e.findSortParameter(capability) == "":
+			sortLocally = true
+			result.Warnings = append(result.Warnings, 
+				fmt.Sprintf("ORDER BY %s sorted locally: the API has no sort parameter", orderByString(query.OrderBy)))
+		case len(plan.requests) > 1:
+			sortLocally = true
+			result.Warnings = append(result.Warnings, 
+				fmt.Sprintf("ORDER BY %s sorted locally: results of several API requests were merged", orderByString(query.OrderBy)))
+		}
+	}
+	
+	// A single request whose filters and sort all went to the API can pass
+	// OFFSET straight through and stop paging after LIMIT rows. Merged or
+	// filtered requests stop once each has OFFSET+LIMIT rows, and a local
+	// sort or aggregation needs every record before LIMIT/OFFSET can be applied.
+	pushLimit := len(plan.requests) == 1 && !plan.filtersLocally() && !sortLocally && !aggregate
+	skip, want := query.Offset, query.Limit
+	if !pushLimit {
+		skip, want = 0, query.Offset+query.Limit
+		if sortLocally || aggregate {
+			want = 0
+		}
+	}
+	
+	pages := e.newPager(capability)
+	var merged []map[string]interface{}
+	seen := make(map[string]bool)
+	for _, request := range plan.requests {
+		params := e.buildSelectParams(capability, query, request.params)
+		data, err := pages.fetch(capability.BaseURL+capability.Path, params, skip, want, request.keep)
 		if err != nil {
-			return "", err
+			return &QueryResult{Error: err.Error()}, nil
 		}
 		
-		if paramName != "" {
-			params.Add(paramName, paramValue)
+		for _, record := range data {
+			if len(plan.requests) > 1 {
+				key := recordKey(record)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+			}
+			merged = append(merged, record)
 		}
 	}
+	
+	if pages.truncated {
+		result.Warnings = append(result.Warnings, 
+			fmt.Sprintf("stopped after %d API requests (max_requests); results may be incomplete", pages.requests))
+	}
+	if len(plan.requests) > 1 {
+		result.Warnings = append(result.Warnings, 
+			fmt.Sprintf("OR condition executed as %d separate API requests", len(plan.requests)))
+	}
+	if aggregate {
+		merged = aggregateRecords(merged, query)
+	}
+	if sortLocally {
+		sortRecords(merged, query.OrderBy)
+	}
+	if !pushLimit {
+		merged = applyOffsetLimit(merged, query.Offset, query.Limit)
+	}
+	
+	result.Data = filterColumns(merged, query.Columns)
+	result.Columns = query.Columns
+	result.Total = len(result.Data)
+	return result, nil
+}
 
-	// Add ORDER BY
-	if len(query.OrderBy) > 0 {
+// buildSelectParams adds the ORDER BY parameter to a request's filters
+func (e *RESTExecutor) buildSelectParams(capability parser.APICapability, query *translator.ParsedQuery, params url.Values) url.Values {
+	params = cloneValues(params)
+
+	// Add ORDER BY; grouped queries sort their computed rows instead
+	if len(query.OrderBy) > 0 && !query.IsAggregate() {
 		// Try to find a sort parameter in the API
 		sortParam := e.findSortParameter(capability)
 		if sortParam != "" {
@@ -140,31 +269,52 @@ func (e *RESTExecutor) buildAPIURL(capability parser.APICapability, query *trans
 		}
 	}
 
-	// Add LIMIT (pagination)
-	if query.Limit > 0 {
-		limitParam := e.findLimitParameter(capability)
-		if limitParam != "" {
-			params.Add(limitParam, strconv.Itoa(query.Limit))
+	return params
+}
+
+// convertBetweenToParams maps BETWEEN onto a "_between" parameter or a pair of
+// lower/upper bound parameters ("_gte"/"_lte", "_min"/"_max"). A bound without
+// a parameter is returned as a condition to apply locally.
+func (e *RESTExecutor) convertBetweenToParams(capability parser.APICapability, condition translator.Condition) (url.Values, []translator.Condition, error) {
+	bounds, ok := condition.Value.([]interface{})
+	if !ok || len(bounds) != 2 {
+		return nil, nil, fmt.Errorf("invalid BETWEEN condition: %s", condition)
+	}
+	params := url.Values{}
+	
+	// Prefer a single range parameter, e.g. "price_between=10,20"
+	for _, param := range capability.Parameters {
+		if e.matchesOperatorPattern(param.Name, condition.Column, "BETWEEN") {
+			params.Set(param.Name, fmt.Sprintf("%v,%v", bounds[0], bounds[1]))
+			return params, nil, nil
 		}
 	}
-
-	// Add OFFSET (pagination)
-	if query.Offset > 0 {
-		offsetParam := e.findOffsetParameter(capability)
-		if offsetParam != "" {
-			params.Add(offsetParam, strconv.Itoa(query.Offset))
+	
+	lower := translator.Condition{Column: condition.Column, Operator: ">=", Value: bounds[0]}
+	upper := translator.Condition{Column: condition.Column, Operator: "<=", Value: bounds[1]}
+	
+	var local []translator.Condition
+	for _, bound := range []translator.Condition{lower, upper} {
+		paramName, paramValues, err := e.convertConditionToParam(capability, bound)
+		if err != nil {
+			local = append(local, bound)
+			continue
 		}
+		params[paramName] = append(params[paramName], paramValues...)
 	}
-
-	// Build final URL
-	if len(params) > 0 {
-		baseURL += "?" + params.Encode()
+	
+	if len(local) == 2 {
+		// Neither bound can be pushed down: evaluate the whole range locally
+		return nil, []translator.Condition{condition}, nil
 	}
-
-	return baseURL, nil
+	return params, local, nil
 }
 
-func (e *RESTExecutor) convertConditionToParam(capability parser.APICapability, condition translator.Condition) (string, string, error) {
+func (e *RESTExecutor) convertConditionToParam(capability parser.APICapability, condition translator.Condition) (string, []string, error) {
+	if condition.Operator == "IS NULL" || condition.Operator == "IS NOT NULL" {
+		return e.convertNullCheckToParam(capability, condition)
+	}
+	
 	// Find the parameter that matches this condition
 	for _, param := range capability.Parameters {
 		columnName := strings.ToLower(param.Name)
@@ -173,20 +323,36 @@ func (e *RESTExecutor) convertConditionToParam(capability parser.APICapability,
 		// Direct match
 		if columnName == conditionColumn {
 			if contains(param.Operators, condition.Operator) {
-				return param.Name, fmt.Sprintf("%v", condition.Value), nil
+				return param.Name, serializeParamValue(param, condition.Value), nil
 			}
 		}
 		
 		// Pattern-based match (e.g., "age_gt" for "age > 25")
 		if e.matchesOperatorPattern(param.Name, condition.Column, condition.Operator) {
-			return param.Name, fmt.Sprintf("%v", condition.Value), nil
+			return param.Name, serializeParamValue(param, condition.Value), nil
 		}
 	}
 
-	return "", "", fmt.Errorf("no API parameter found for condition: %s %s %v", 
+	return "", nil, fmt.Errorf("no API parameter found for condition: %s %s %v", 
 		condition.Column, condition.Operator, condition.Value)
 }
 
+// convertNullCheckToParam maps IS [NOT] NULL onto a flag parameter:
+// "_null", "_isnull" or "_is_null" set to true or false, or "_notnull" /
+// "_not_null" set to true for IS NOT NULL
+func (e *RESTExecutor) convertNullCheckToParam(capability parser.APICapability, condition translator.Condition) (string, []string, error) {
+	isNull := condition.Operator == "IS NULL"
+	for _, param := range capability.Parameters {
+		if e.matchesOperatorPattern(param.Name, condition.Column, "IS NULL") {
+			return param.Name, []string{strconv.FormatBool(isNull)}, nil
+		}
+		if !isNull && e.matchesOperatorPattern(param.Name, condition.Column, "IS NOT NULL") {
+			return param.Name, []string{"true"}, nil
+		}
+	}
+	return "", nil, fmt.Errorf("no API parameter found for condition: %s", condition)
+}
+
 func (e *RESTExecutor) matchesOperatorPattern(paramName, column, operator string) bool {
 	paramLower := strings.ToLower(paramName)
 	columnLower := strings.ToLower(column)
@@ -213,36 +379,67 @@ func (e *RESTExecutor) matchesOperatorPattern(paramName, column, operator string
 		return suffix == "like" || suffix == "search" || suffix == "contains"
 	case "IN":
 		return suffix == "in"
+	case "NOT IN":
+		return suffix == "nin" || suffix == "not_in"
+	case "BETWEEN":
+		return suffix == "between"
+	case "IS NULL":
+		return suffix == "null" || suffix == "isnull" || suffix == "is_null"
+	case "IS NOT NULL":
+		return suffix == "notnull" || suffix == "not_null"
 	}
 	
 	return false
 }
 
-func (e *RESTExecutor) findSortParameter(capability parser.APICapability) string {
+// findMultiValueParameter returns the parameter that filters column by several values at once
+func (e *RESTExecutor) findMultiValueParameter(capability parser.APICapability, column string) string {
 	for _, param := range capability.Parameters {
-		name := strings.ToLower(param.Name)
-		if strings.Contains(name, "sort") || strings.Contains(name, "order") {
+		if strings.EqualFold(param.Name, column) && isMultiValueParameter(param) {
+			return param.Name
+		}
+		if e.matchesOperatorPattern(param.Name, column, "IN") {
 			return param.Name
 		}
 	}
 	return ""
 }
 
-func (e *RESTExecutor) findLimitParameter(capability parser.APICapability) string {
-	for _, param := range capability.Parameters {
-		name := strings.ToLower(param.Name)
-		if strings.Contains(name, "limit") || strings.Contains(name, "size") || 
-		   strings.Contains(name, "per_page") {
-			return param.Name
-		}
+func isMultiValueParameter(param parser.Parameter) bool {
+	return param.Type == "array" || contains(param.Operators, "IN")
+}
+
+// serializeParamValue renders a condition value as query parameter values.
+// Lists follow the parameter's collectionFormat; "multi" repeats the parameter.
+func serializeParamValue(param parser.Parameter, value interface{}) []string {
+	values, ok := value.([]interface{})
+	if !ok {
+		return []string{fmt.Sprintf("%v", value)}
+	}
+	
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%v", v)
+	}
+	
+	switch param.CollectionFormat {
+	case "multi":
+		return parts
+	case "ssv":
+		return []string{strings.Join(parts, " ")}
+	case "tsv":
+		return []string{strings.Join(parts, "\t")}
+	case "pipes":
+		return []string{strings.Join(parts, "|")}
+	default:
+		return []string{strings.Join(parts, ",")}
 	}
-	return ""
 }
 
-func (e *RESTExecutor) findOffsetParameter(capability parser.APICapability) string {
+func (e *RESTExecutor) findSortParameter(capability parser.APICapability) string {
 	for _, param := range capability.Parameters {
 		name := strings.ToLower(param.Name)
-		if strings.Contains(name, "offset") || strings.Contains(name, "page") {
+		if strings.Contains(name, "sort") || strings.Contains(name, "order") {
 			return param.Name
 		}
 	}
@@ -261,17 +458,68 @@ func (e *RESTExecutor) buildSortValue(orderBy []translator.OrderByField) string
 	return strings.Join(parts, ",")
 }
 
-func (e *RESTExecutor) makeRequest(method string, url string, body interface{}) (*http.Response, error) {
-	var req *http.Request
-	var err error
-	
-	// Create request with body if provided
+// orderByString renders ORDER BY fields for messages, e.g. "name ASC, age DESC"
+func orderByString(orderBy []translator.OrderByField) string {
+	parts := make([]string, len(orderBy))
+	for i, field := range orderBy {
+		parts[i] = field.Column + " " + field.Order
+	}
+	return strings.Join(parts, ", ")
+}
+
+// makeRequest sends a request, retrying it as the executor's retry policy
+// allows. Error statuses are returned as a *statusError.
+func (e *RESTExecutor) makeRequest(method string, url string, body interface{}, authenticator auth.Authenticator) (*http.Response, error) {
+	var jsonBody []byte
 	if body != nil {
-		jsonBody, err := json.Marshal(body)
+		var err error
+		jsonBody, err = json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
-		req, err = http.NewRequest(method, url, strings.NewReader(string(jsonBody)))
+	}
+	return e.sendRequest(method, url, jsonBody, nil, authenticator)
+}
+
+// sendRequest sends a request with any extra headers, retrying it as the
+// executor's retry policy allows
+func (e *RESTExecutor) sendRequest(method string, url string, jsonBody []byte, header http.Header, authenticator auth.Authenticator) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := e.doRequest(method, url, jsonBody, header, authenticator)
+		if attempt >= e.retry.attempts || !e.retry.retries(method) {
+			return resp, err
+		}
+
+		// Network errors, 429 and 5xx are retried after the backoff, or
+		// after the server's Retry-After when it sends one
+		wait := e.retry.backoff(attempt)
+		var status *statusError
+		switch {
+		case err == nil:
+			return resp, nil
+		case errors.As(err, &status):
+			if !retryableStatus(status.code) {
+				return nil, err
+			}
+			if after, ok := retryAfter(status.header); ok {
+				if after > maxRetryAfter {
+					return nil, err
+				}
+				wait = after
+			}
+		}
+		time.Sleep(wait)
+	}
+}
+
+// doRequest sends a single request with the given authentication
+func (e *RESTExecutor) doRequest(method string, url string, jsonBody []byte, header http.Header, authenticator auth.Authenticator) (*http.Response, error) {
+	var req *http.Request
+	var err error
+	
+	// Create request with body if provided
+	if jsonBody != nil {
+		req, err = http.NewRequest(method, url, bytes.NewReader(jsonBody))
 		if err != nil {
 			return nil, err
 		}
@@ -283,19 +531,17 @@ func (e *RESTExecutor) makeRequest(method string, url string, body interface{})
 		}
 	}
 
-	// Add authentication
-	switch strings.ToLower(e.authType) {
-	case "bearer":
-		req.Header.Set("Authorization", "Bearer "+e.authToken)
-	case "apikey":
-		req.Header.Set("X-API-Key", e.authToken)
-	case "basic":
-		req.Header.Set("Authorization", "Basic "+e.authToken)
-	}
-
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("User-Agent", "qRest/1.0")
 
+	// Add authentication
+	if err := authenticator.Authenticate(req); err != nil {
+		return nil, fmt.Errorf("authentication failed: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
 	resp, err := e.client.Do(req)
 	if err != nil {
 		return nil, err
@@ -304,12 +550,23 @@ func (e *RESTExecutor) makeRequest(method string, url string, body interface{})
 	if resp.StatusCode >= 400 {
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+		return nil, &statusError{code: resp.StatusCode, body: string(body), header: resp.Header}
 	}
 
 	return resp, nil
 }
 
+// statusError is an error status returned by the API
+type statusError struct {
+	code   int
+	body   string
+	header http.Header
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("API returned status %d: %s", e.code, e.body)
+}
+
 func (e *RESTExecutor) buildMutationURL(capability parser.APICapability, query *translator.ParsedQuery) (string, error) {
 	baseURL := capability.BaseURL + capability.Path
 	
@@ -353,7 +610,7 @@ func (e *RESTExecutor) parseResponse(resp *http.Response, query *translator.Pars
 	}
 
 	// Filter columns based on SELECT clause
-	filteredData := e.filterColumns(data, query.Columns)
+	filteredData := filterColumns(data, query.Columns)
 
 	result := &QueryResult{
 		Data:  filteredData,
@@ -400,7 +657,7 @@ func (e *RESTExecutor) extractDataArray(jsonData interface{}) ([]map[string]inte
 	}
 }
 
-func (e *RESTExecutor) filterColumns(data []map[string]interface{}, columns []string) []map[string]interface{} {
+func filterColumns(data []map[string]interface{}, columns []string) []map[string]interface{} {
 	if len(columns) == 0 {
 		return data
 	}
@@ -410,7 +667,7 @@ func (e *RESTExecutor) filterColumns(data []map[string]interface{}, columns []st
 		filteredRecord := make(map[string]interface{})
 		
 		for _, column := range columns {
-			if value, exists := record[column]; exists {
+			if value, exists := columnValue(record, column); exists {
 				filteredRecord[column] = value
 			}
 		}
@@ -428,4 +685,32 @@ func contains(slice []string, item string) bool {
 		}
 	}
 	return false
-}
\ No newline at end of file
+}
+
+// recordKey identifies a record for de-duplication; encoding/json sorts map keys
+func recordKey(record map[string]interface{}) string {
+	key, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Sprintf("%v", record)
+	}
+	return string(key)
+}
+
+func applyOffsetLimit(data []map[string]interface{}, offset, limit int) []map[string]interface{} {
+	if offset >= len(data) {
+		return nil
+	}
+	data = data[offset:]
+	if limit > 0 && limit < len(data) {
+		data = data[:limit]
+	}
+	return data
+}
+
+func cloneValues(values url.Values) url.Values {
+	clone := url.Values{}
+	for key, list := range values {
+		clone[key] = append([]string(nil), list...)
+	}
+	return clone
+}
@@ -0,0 +1,66 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/translator"
+)
+
+func TestSerializeParamValue(t *testing.T) {
+	list := []interface{}{"a", 2, 3.5}
+	tests := []struct {
+		format string
+		value  interface{}
+		want   []string
+	}{
+		{"", list, []string{"a,2,3.5"}},
+		{"csv", list, []string{"a,2,3.5"}},
+		{"ssv", list, []string{"a 2 3.5"}},
+		{"tsv", list, []string{"a\t2\t3.5"}},
+		{"pipes", list, []string{"a|2|3.5"}},
+		{"multi", list, []string{"a", "2", "3.5"}},
+		{"pipes", "a", []string{"a"}},
+		{"multi", 7, []string{"7"}},
+	}
+	for _, tt := range tests {
+		param := parser.Parameter{Name: "tags", Type: "array", CollectionFormat: tt.format}
+		if got := serializeParamValue(param, tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("serializeParamValue(%q, %v) = %q, want %q", tt.format, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestConvertNullCheckToParam(t *testing.T) {
+	capability := parser.APICapability{Parameters: []parser.Parameter{
+		{Name: "born_isnull", Type: "boolean"},
+		{Name: "price_not_null", Type: "boolean"},
+	}}
+	tests := []struct {
+		condition translator.Condition
+		param     string
+		values    []string
+		err       string
+	}{
+		{condition: translator.Condition{Column: "born", Operator: "IS NULL"}, param: "born_isnull", values: []string{"true"}},
+		{condition: translator.Condition{Column: "born", Operator: "IS NOT NULL"}, param: "born_isnull", values: []string{"false"}},
+		{condition: translator.Condition{Column: "price", Operator: "IS NOT NULL"}, param: "price_not_null", values: []string{"true"}},
+		{condition: translator.Condition{Column: "price", Operator: "IS NULL"}, err: "no API parameter found for condition: price IS NULL"},
+	}
+
+	executor := &RESTExecutor{}
+	for _, tt := range tests {
+		param, values, err := executor.convertConditionToParam(capability, tt.condition)
+		switch {
+		case tt.err != "":
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: got error %v, want %q", tt.condition, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", tt.condition, err)
+		case param != tt.param || !reflect.DeepEqual(values, tt.values):
+			t.Errorf("%s: got %s=%q, want %s=%q", tt.condition, param, values, tt.param, tt.values)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// maxRetryDelay caps the exponential backoff between attempts
+	maxRetryDelay = 30 * time.Second
+	// maxRetryAfter is the longest Retry-After a request waits for; a longer
+	// one fails the request instead
+	maxRetryAfter = 2 * time.Minute
+)
+
+// retryPolicy decides whether and when a failed request is sent again
+type retryPolicy struct {
+	attempts  int           // total attempts per request; 1 or less never retries
+	delay     time.Duration // backoff before the first retry, doubled after each
+	retryPost bool          // POST is not idempotent, so it is only retried on request
+}
+
+// SetRetryPolicy retries requests that fail with a network error, 429 or a 5xx
+// status, up to attempts tries in all. GET, PUT and DELETE are retried; POST
+// only when retryPost is set, since an INSERT may have been applied already.
+func (e *RESTExecutor) SetRetryPolicy(attempts int, delay time.Duration, retryPost bool) {
+	e.retry = retryPolicy{attempts: attempts, delay: delay, retryPost: retryPost}
+}
+
+// retries reports whether a request with this method may be sent again
+func (r retryPolicy) retries(method string) bool {
+	switch method {
+	case "GET", "HEAD", "PUT", "DELETE", "OPTIONS":
+		return r.attempts > 1
+	case "POST":
+		return r.attempts > 1 && r.retryPost
+	}
+	return false
+}
+
+// retryableStatus reports whether a response status is worth retrying
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// backoff returns how long to wait before the given retry (1 for the first),
+// doubling the base delay each time and drawing from its upper half so that
+// concurrent clients spread out
+func (r retryPolicy) backoff(retry int) time.Duration {
+	wait := r.delay
+	for i := 1; i < retry && wait < maxRetryDelay; i++ {
+		wait *= 2
+	}
+	if wait > maxRetryDelay {
+		wait = maxRetryDelay
+	}
+	if wait <= 0 {
+		return 0
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// retryAfter reads a Retry-After header given in seconds or as an HTTP date
+func retryAfter(header http.Header) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		wait := time.Until(at)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
@@ -0,0 +1,223 @@
+package executor
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/simonm/qRest/internal/apitest"
+	"github.com/simonm/qRest/internal/auth"
+	"github.com/simonm/qRest/internal/grammar"
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/translator"
+)
+
+// flakyAPI answers the first failures requests to /pets with status, sending
+// retryAfter as a Retry-After header when set, and then succeeds. It records
+// each request as its method and path.
+type flakyAPI struct {
+	*apitest.Server
+
+	status     int
+	retryAfter string
+
+	mu       sync.Mutex
+	failures int
+}
+
+func newFlakyAPI(t *testing.T, failures, status int, retryAfter string) *flakyAPI {
+	api := &flakyAPI{failures: failures, status: status, retryAfter: retryAfter}
+	api.Server = apitest.New(t, apitest.MethodPath, api.serve)
+	return api
+}
+
+func (a *flakyAPI) serve(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.failures > 0 {
+		a.failures--
+		if a.retryAfter != "" {
+			w.Header().Set("Retry-After", a.retryAfter)
+		}
+		http.Error(w, http.StatusText(a.status), a.status)
+		return
+	}
+	if r.Method == "POST" {
+		apitest.WriteJSON(w, http.StatusCreated, map[string]interface{}{"id": 2, "name": "Bo"})
+		return
+	}
+	apitest.WriteJSON(w, http.StatusOK, []map[string]interface{}{{"id": 1, "name": "Rex"}})
+}
+
+func TestExecuteRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		sql        string
+		failures   int
+		status     int
+		retryAfter string
+		attempts   int
+		retryPost  bool
+		wantErr    string
+		requests   []string
+	}{
+		{
+			name:     "server error retried",
+			sql:      "SELECT * FROM pets",
+			failures: 2,
+			status:   http.StatusServiceUnavailable,
+			attempts: 3,
+			requests: []string{"GET /pets", "GET /pets", "GET /pets"},
+		},
+		{
+			name:       "Retry-After honoured",
+			sql:        "SELECT * FROM pets",
+			failures:   1,
+			status:     http.StatusTooManyRequests,
+			retryAfter: "0",
+			attempts:   2,
+			requests:   []string{"GET /pets", "GET /pets"},
+		},
+		{
+			name:       "Retry-After too long",
+			sql:        "SELECT * FROM pets",
+			failures:   1,
+			status:     http.StatusTooManyRequests,
+			retryAfter: "3600",
+			attempts:   2,
+			wantErr:    "429",
+			requests:   []string{"GET /pets"},
+		},
+		{
+			name:     "attempts exhausted",
+			sql:      "SELECT * FROM pets",
+			failures: 3,
+			status:   http.StatusBadGateway,
+			attempts: 2,
+			wantErr:  "502",
+			requests: []string{"GET /pets", "GET /pets"},
+		},
+		{
+			name:     "client error not retried",
+			sql:      "SELECT * FROM pets",
+			failures: 1,
+			status:   http.StatusNotFound,
+			attempts: 3,
+			wantErr:  "404",
+			requests: []string{"GET /pets"},
+		},
+		{
+			name:     "retries disabled",
+			sql:      "SELECT * FROM pets",
+			failures: 1,
+			status:   http.StatusServiceUnavailable,
+			attempts: 1,
+			wantErr:  "503",
+			requests: []string{"GET /pets"},
+		},
+		{
+			name:     "POST not retried",
+			sql:      "INSERT INTO pets (id, name) VALUES (2, 'Bo')",
+			failures: 1,
+			status:   http.StatusServiceUnavailable,
+			attempts: 3,
+			wantErr:  "503",
+			requests: []string{"POST /pets"},
+		},
+		{
+			name:      "POST retried on request",
+			sql:       "INSERT INTO pets (id, name) VALUES (2, 'Bo')",
+			failures:  1,
+			status:    http.StatusServiceUnavailable,
+			attempts:  3,
+			retryPost: true,
+			requests:  []string{"POST /pets", "POST /pets"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFlakyAPI(t, tt.failures, tt.status, tt.retryAfter)
+			capability := parser.APICapability{
+				Path:            "/pets",
+				Method:          "GET",
+				TableName:       "pets",
+				BaseURL:         api.URL,
+				ResponseColumns: []string{"id", "name"},
+			}
+			if strings.HasPrefix(tt.sql, "INSERT") {
+				capability.Method = "POST"
+			}
+			executor := NewRESTExecutor(auth.None{})
+			executor.SetRetryPolicy(tt.attempts, time.Millisecond, tt.retryPost)
+
+			query, err := translator.NewSimpleSQLTranslator(grammar.NewGrammarGenerator().GenerateGrammar(capability)).ParseSQL(tt.sql)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.sql, err)
+			}
+			result, err := executor.ExecuteQuery(capability, query)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.sql, err)
+			}
+			switch {
+			case tt.wantErr == "" && result.Error != "":
+				t.Errorf("got error %q, want none", result.Error)
+			case tt.wantErr != "" && !strings.Contains(result.Error, tt.wantErr):
+				t.Errorf("got error %q, want one containing %q", result.Error, tt.wantErr)
+			}
+			if requests := api.Requests(); !reflect.DeepEqual(requests, tt.requests) {
+				t.Errorf("got requests %q, want %q", requests, tt.requests)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := retryPolicy{attempts: 10, delay: time.Second}
+	tests := []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{1, 500 * time.Millisecond, time.Second},
+		{2, time.Second, 2 * time.Second},
+		{3, 2 * time.Second, 4 * time.Second},
+		{10, maxRetryDelay / 2, maxRetryDelay},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if wait := policy.backoff(tt.retry); wait < tt.min || wait > tt.max {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", tt.retry, wait, tt.min, tt.max)
+			}
+		}
+	}
+	if wait := (retryPolicy{}).backoff(1); wait != 0 {
+		t.Errorf("backoff with no delay = %v, want 0", wait)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"5", 5 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.value != "" {
+			header.Set("Retry-After", tt.value)
+		}
+		if got, ok := retryAfter(header); got != tt.want || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
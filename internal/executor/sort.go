@@ -0,0 +1,55 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/simonm/qRest/internal/translator"
+)
+
+// sortRecords orders records in memory by the ORDER BY fields. Values are
+// compared by type: numbers numerically, RFC3339 timestamps chronologically
+// and everything else as strings. Missing and null values go first or last
+// as each field's Nulls setting says. The sort is stable.
+func sortRecords(records []map[string]interface{}, orderBy []translator.OrderByField) {
+	sort.SliceStable(records, func(i, j int) bool {
+		for _, field := range orderBy {
+			a, _ := columnValue(records[i], field.Column)
+			b, _ := columnValue(records[j], field.Column)
+			cmp := compareForSort(a, b, field)
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+}
+
+// compareForSort compares two values of one ORDER BY field, applying the
+// direction and null placement
+func compareForSort(a, b interface{}, field translator.OrderByField) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil || b == nil:
+		// Null placement does not flip with DESC
+		cmp := 1
+		if field.Nulls == "FIRST" {
+			cmp = -1
+		}
+		if b == nil {
+			cmp = -cmp
+		}
+		return cmp
+	}
+
+	cmp, ok := compareValues(a, b)
+	if !ok {
+		// Values of unrelated types, e.g. a bool and a number
+		cmp, _ = compareValues(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+	}
+	if field.Order == "DESC" {
+		cmp = -cmp
+	}
+	return cmp
+}
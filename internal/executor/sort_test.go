@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/simonm/qRest/internal/translator"
+)
+
+func TestSortRecords(t *testing.T) {
+	pets := func() []map[string]interface{} {
+		return []map[string]interface{}{
+			{"id": 1.0, "name": "Rex", "age": 10.0, "born": "2014-03-01T00:00:00Z", "owner": map[string]interface{}{"name": "Sam"}},
+			{"id": 2.0, "name": "Bo", "age": 9.0, "born": "2015-01-01T00:00:00+02:00"},
+			{"id": 3.0, "name": "Tom", "age": nil, "born": "2014-12-31T23:00:00-03:00", "owner": map[string]interface{}{"name": "Al"}},
+			{"id": 4.0, "name": "Bo", "age": 2.0, "born": "2022-06-01T00:00:00Z", "owner": map[string]interface{}{"name": "Sam"}},
+		}
+	}
+	tests := []struct {
+		name    string
+		orderBy []translator.OrderByField
+		ids     []int
+	}{
+		{"numbers", []translator.OrderByField{{Column: "age", Order: "ASC"}}, []int{4, 2, 1, 3}},
+		{"numbers descending", []translator.OrderByField{{Column: "age", Order: "DESC"}}, []int{1, 2, 4, 3}},
+		{"nulls first", []translator.OrderByField{{Column: "age", Order: "ASC", Nulls: "FIRST"}}, []int{3, 4, 2, 1}},
+		{"nulls first descending", []translator.OrderByField{{Column: "age", Order: "DESC", Nulls: "FIRST"}}, []int{3, 1, 2, 4}},
+		{"timestamps", []translator.OrderByField{{Column: "born", Order: "ASC"}}, []int{1, 2, 3, 4}},
+		{"stable on ties", []translator.OrderByField{{Column: "name", Order: "ASC"}}, []int{2, 4, 1, 3}},
+		{"second field", []translator.OrderByField{{Column: "name", Order: "ASC"}, {Column: "id", Order: "DESC"}}, []int{4, 2, 1, 3}},
+		{"nested missing last", []translator.OrderByField{{Column: "owner.name", Order: "ASC"}, {Column: "id", Order: "ASC"}}, []int{3, 1, 4, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := pets()
+			sortRecords(records, tt.orderBy)
+			if ids := recordIDs(records); !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("got ids %v, want %v", ids, tt.ids)
+			}
+		})
+	}
+}
+
+func TestCompareForSort(t *testing.T) {
+	asc := translator.OrderByField{Column: "v", Order: "ASC"}
+	desc := translator.OrderByField{Column: "v", Order: "DESC"}
+	tests := []struct {
+		a, b  interface{}
+		field translator.OrderByField
+		want  int
+	}{
+		{2.0, 10.0, asc, -1},
+		{"2", "10", asc, 1},
+		{2.0, "10", asc, -1},
+		{false, true, asc, -1},
+		{false, true, desc, 1},
+		{true, 1.0, asc, 1},
+		{nil, 1.0, asc, 1},
+		{nil, 1.0, desc, 1},
+		{nil, nil, asc, 0},
+	}
+	for _, tt := range tests {
+		if got := compareForSort(tt.a, tt.b, tt.field); got != tt.want {
+			t.Errorf("compareForSort(%v, %v, %s) = %d, want %d", tt.a, tt.b, tt.field.Order, got, tt.want)
+		}
+	}
+}
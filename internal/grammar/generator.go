@@ -10,6 +10,7 @@ import (
 type SQLGrammar struct {
 	TableName      string
 	AllowedColumns []string
+	ColumnTypes    map[string]parser.ColumnType // what values compared with or assigned to a column are coerced to
 	WhereClause    WhereGrammar
 	OrderBy        OrderByGrammar
 	Limit          LimitGrammar
@@ -17,6 +18,7 @@ type SQLGrammar struct {
 
 type WhereGrammar struct {
 	AllowedColumns map[string][]string // column -> allowed operators
+	LocalColumns   []string            // columns that can be filtered on the returned records
 	Suggestions    []string
 }
 
@@ -39,7 +41,8 @@ func NewGrammarGenerator() *GrammarGenerator {
 
 func (g *GrammarGenerator) GenerateGrammar(capability parser.APICapability) SQLGrammar {
 	grammar := SQLGrammar{
-		TableName: capability.TableName,
+		TableName:   capability.TableName,
+		ColumnTypes: make(map[string]parser.ColumnType),
 		WhereClause: WhereGrammar{
 			AllowedColumns: make(map[string][]string),
 		},
@@ -57,29 +60,52 @@ func (g *GrammarGenerator) GenerateGrammar(capability parser.APICapability) SQLG
 	if len(capability.ResponseColumns) > 0 {
 		grammar.AllowedColumns = capability.ResponseColumns
 		grammar.OrderBy.AllowedColumns = capability.ResponseColumns
+		// Any response column can be filtered in-process when the API
+		// has no parameter for the condition
+		grammar.WhereClause.LocalColumns = capability.ResponseColumns
 	} else {
 		// Fallback to parameter-based columns if no response schema
 		grammar.AllowedColumns = []string{}
 		grammar.OrderBy.AllowedColumns = []string{}
 	}
 
+	for column, columnType := range capability.ColumnTypes {
+		grammar.ColumnTypes[column] = columnType
+	}
+
 	// Process parameters to build WHERE clause operations (what can be filtered)
 	for _, param := range capability.Parameters {
-		// Skip pagination parameters from WHERE clause
-		if isPaginationParam(param.Name) {
+		// Pagination parameters are skipped
+		columnName := g.FilterColumn(param.Name)
+		if columnName == "" {
 			continue
 		}
 
-		columnName := g.extractColumnName(param.Name)
-		if columnName == "" {
-			continue
+		// Add to WHERE clause operators, merging parameters that filter the
+		// same column (e.g. "price_min" and "price_max")
+		operators := param.Operators
+		if len(operators) == 0 {
+			operators = []string{"="}
+		}
+		for _, op := range operators {
+			if !contains(grammar.WhereClause.AllowedColumns[columnName], op) {
+				grammar.WhereClause.AllowedColumns[columnName] = append(grammar.WhereClause.AllowedColumns[columnName], op)
+			}
 		}
 
-		// Add to WHERE clause operators
-		if len(param.Operators) > 0 {
-			grammar.WhereClause.AllowedColumns[columnName] = param.Operators
-		} else {
-			grammar.WhereClause.AllowedColumns[columnName] = []string{"="}
+		// Values sent as the parameter take its declared type and enum.
+		// Array parameters keep the column's type, which their elements
+		// have, as do parameters declaring the column's type without a format
+		// and null-check flags, which take no value of the column.
+		if !contains(operators, "IS NOT NULL") {
+			columnType := grammar.ColumnTypes[columnName]
+			if param.Type != "" && param.Type != "array" && (param.Type != columnType.Type || param.Format != "") {
+				columnType.Type, columnType.Format = param.Type, param.Format
+			}
+			if len(param.Enum) > 0 {
+				columnType.Enum = param.Enum
+			}
+			grammar.ColumnTypes[columnName] = columnType
 		}
 
 		// If no response columns available, add parameter columns to allowed columns
@@ -92,22 +118,35 @@ func (g *GrammarGenerator) GenerateGrammar(capability parser.APICapability) SQLG
 	// Generate suggestions for API improvements
 	grammar.WhereClause.Suggestions = g.generateSuggestions(capability)
 
-	// Set default limit if not specified
-	if grammar.Limit.MaxLimit == 0 {
+	// Set default limit if not specified. The executor pages through
+	// endpoints with an offset or page parameter, so their LIMIT takes the
+	// default too rather than the largest page the API returns.
+	if grammar.Limit.MaxLimit == 0 || capability.PageParam != "" {
 		grammar.Limit.MaxLimit = 1000
 	}
 
 	return grammar
 }
 
+// FilterColumn returns the column a parameter filters, e.g. "age" for
+// "age_gt", or "" for pagination parameters, which filter none
+func (g *GrammarGenerator) FilterColumn(paramName string) string {
+	if isPaginationParam(paramName) {
+		return ""
+	}
+	return g.extractColumnName(paramName)
+}
+
 func (g *GrammarGenerator) extractColumnName(paramName string) string {
 	// Remove common suffixes that indicate operators
-	suffixes := []string{"_gt", "_gte", "_lt", "_lte", "_ne", "_not", "_in", "_like", "_between", "_min", "_max"}
+	suffixes := []string{"_gt", "_gte", "_lt", "_lte", "_ne", "_not_in", "_nin", "_not", "_in", "_like", "_between", "_min", "_max",
+		"_is_null", "_not_null", "_isnull", "_notnull", "_null"}
 	
 	name := strings.ToLower(paramName)
 	for _, suffix := range suffixes {
 		if strings.HasSuffix(name, suffix) {
-			return strings.TrimSuffix(name, suffix)
+			// Keep the original casing so "shipDate_between" maps to "shipDate"
+			return paramName[:len(paramName)-len(suffix)]
 		}
 	}
 	
@@ -192,6 +231,7 @@ func (g *GrammarGenerator) GetAllowedOperations(grammar SQLGrammar) map[string]i
 		"table":   grammar.TableName,
 		"columns": grammar.AllowedColumns,
 		"where":   grammar.WhereClause.AllowedColumns,
+		"where_local": grammar.WhereClause.LocalColumns,
 		"order_by": grammar.OrderBy.AllowedColumns,
 		"limit": map[string]interface{}{
 			"max":     grammar.Limit.MaxLimit,
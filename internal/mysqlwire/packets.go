@@ -0,0 +1,366 @@
+package mysqlwire
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxPacketSize bounds the commands a client may send; it is reported as
+// max_allowed_packet
+const maxPacketSize = 64 << 20
+
+// maxPayload is the largest payload of one packet; longer ones are split
+const maxPayload = 1<<24 - 1
+
+// Capability flags exchanged in the handshake
+const (
+	clientLongPassword         = 0x00000001
+	clientFoundRows            = 0x00000002
+	clientLongFlag             = 0x00000004
+	clientConnectWithDB        = 0x00000008
+	clientProtocol41           = 0x00000200
+	clientSSL                  = 0x00000800
+	clientTransactions         = 0x00002000
+	clientSecureConnection     = 0x00008000
+	clientMultiStatements      = 0x00010000
+	clientMultiResults         = 0x00020000
+	clientPSMultiResults       = 0x00040000
+	clientPluginAuth           = 0x00080000
+	clientConnectAttrs         = 0x00100000
+	clientPluginAuthLenencData = 0x00200000
+)
+
+// serverCapabilities are those the listener offers. It has no TLS, and ends
+// result sets with EOF packets rather than OK packets.
+const serverCapabilities = clientLongPassword | clientFoundRows | clientLongFlag | clientConnectWithDB |
+	clientProtocol41 | clientTransactions | clientSecureConnection | clientMultiStatements |
+	clientMultiResults | clientPSMultiResults | clientPluginAuth | clientConnectAttrs |
+	clientPluginAuthLenencData
+
+// Server status flags, sent in OK and EOF packets
+const (
+	statusInTrans            = 0x0001
+	statusAutocommit         = 0x0002
+	statusMoreResultsExist   = 0x0008
+	statusNoBackslashEscapes = 0x0200
+)
+
+// Commands a client sends
+const (
+	comQuit             = 0x01
+	comInitDB           = 0x02
+	comQuery            = 0x03
+	comFieldList        = 0x04
+	comPing             = 0x0e
+	comStmtPrepare      = 0x16
+	comStmtExecute      = 0x17
+	comStmtSendLongData = 0x18
+	comStmtClose        = 0x19
+	comStmtReset        = 0x1a
+	comSetOption        = 0x1b
+	comResetConnection  = 0x1f
+)
+
+// errorCode is a MySQL error number with its SQLSTATE
+type errorCode struct {
+	number uint16
+	state  string
+}
+
+// Error codes of the errors the listener reports
+var (
+	codeAccessDenied      = errorCode{1045, "28000"}
+	codeUnknownCommand    = errorCode{1047, "08S01"}
+	codeUnknownDatabase   = errorCode{1049, "42000"}
+	codeParseError        = errorCode{1064, "42000"}
+	codeEmptyQuery        = errorCode{1065, "42000"}
+	codeUnknownError      = errorCode{1105, "HY000"}
+	codeNoSuchTable       = errorCode{1146, "42S02"}
+	codeNetPacketTooLarge = errorCode{1153, "08S01"}
+	codeWrongArguments    = errorCode{1210, "HY000"}
+	codeNotSupported      = errorCode{1235, "42000"}
+	codeUnknownStatement  = errorCode{1243, "HY000"}
+	codeWrongValue        = errorCode{1366, "HY000"}
+	codeMalformedPacket   = errorCode{1835, "HY000"}
+)
+
+// mysqlError is an error reported to the client with its code
+type mysqlError struct {
+	code    errorCode
+	message string
+}
+
+func (e *mysqlError) Error() string { return e.message }
+
+func newError(code errorCode, format string, args ...interface{}) *mysqlError {
+	return &mysqlError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// errorCodeOf returns the code of an error, ER_UNKNOWN_ERROR for those not
+// raised as a mysqlError
+func errorCodeOf(err error) errorCode {
+	var myErr *mysqlError
+	if errors.As(err, &myErr) {
+		return myErr.code
+	}
+	return codeUnknownError
+}
+
+// conn reads and writes the packets of a connection. Each packet carries a
+// sequence number, which restarts with every command the client sends. The
+// first write error sticks and is returned by flush.
+type conn struct {
+	r   *bufio.Reader
+	w   *bufio.Writer
+	seq byte
+	err error
+}
+
+// readPacket reads a payload, joining the packets one of 16MB or more is
+// split into
+func (c *conn) readPacket() ([]byte, error) {
+	var payload []byte
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(c.r, header[:]); err != nil {
+			return nil, err
+		}
+		length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+		c.seq = header[3] + 1
+		if len(payload)+length > maxPacketSize {
+			return nil, newError(codeNetPacketTooLarge, "Got a packet bigger than 'max_allowed_packet' bytes")
+		}
+		start := len(payload)
+		payload = append(payload, make([]byte, length)...)
+		if _, err := io.ReadFull(c.r, payload[start:]); err != nil {
+			return nil, err
+		}
+		if length < maxPayload {
+			return payload, nil
+		}
+	}
+}
+
+// writePacket writes a payload, split into packets of at most 16MB
+func (c *conn) writePacket(payload []byte) {
+	for {
+		n := len(payload)
+		if n > maxPayload {
+			n = maxPayload
+		}
+		header := []byte{byte(n), byte(n >> 8), byte(n >> 16), c.seq}
+		c.seq++
+		c.write(header)
+		c.write(payload[:n])
+		payload = payload[n:]
+		if n < maxPayload {
+			return
+		}
+	}
+}
+
+func (c *conn) write(b []byte) {
+	if c.err == nil {
+		_, c.err = c.w.Write(b)
+	}
+}
+
+func (c *conn) flush() error {
+	if c.err == nil {
+		c.err = c.w.Flush()
+	}
+	return c.err
+}
+
+// ok acknowledges a command that returns no rows
+func (c *conn) ok(affected uint64, status uint16, warnings int) {
+	payload := []byte{0x00}
+	payload = appendLenencInt(payload, affected)
+	payload = appendLenencInt(payload, 0) // last insert id
+	payload = binary.LittleEndian.AppendUint16(payload, status)
+	payload = binary.LittleEndian.AppendUint16(payload, uint16(warnings))
+	c.writePacket(payload)
+}
+
+// eof ends the column definitions and the rows of a result set
+func (c *conn) eof(status uint16, warnings int) {
+	payload := []byte{0xfe}
+	payload = binary.LittleEndian.AppendUint16(payload, uint16(warnings))
+	payload = binary.LittleEndian.AppendUint16(payload, status)
+	c.writePacket(payload)
+}
+
+// errorPacket reports an error, which ends the command
+func (c *conn) errorPacket(err error) {
+	code := errorCodeOf(err)
+	payload := []byte{0xff}
+	payload = binary.LittleEndian.AppendUint16(payload, code.number)
+	payload = append(payload, '#')
+	payload = append(payload, code.state...)
+	payload = append(payload, err.Error()...)
+	c.writePacket(payload)
+}
+
+// columnDefinition describes a column of the rows to come, or a parameter
+// of a prepared statement
+func (c *conn) columnDefinition(f field) {
+	length, charset, flags, decimals := columnInfo(f.typ)
+	schema := ""
+	if f.table != "" {
+		schema = databaseName
+	}
+	payload := appendLenencString(nil, "def")
+	payload = appendLenencString(payload, schema)
+	payload = appendLenencString(payload, f.table)
+	payload = appendLenencString(payload, f.table)
+	payload = appendLenencString(payload, f.name)
+	payload = appendLenencString(payload, f.name)
+	payload = append(payload, 0x0c) // length of the fixed fields
+	payload = binary.LittleEndian.AppendUint16(payload, charset)
+	payload = binary.LittleEndian.AppendUint32(payload, length)
+	payload = append(payload, f.typ)
+	payload = binary.LittleEndian.AppendUint16(payload, flags)
+	payload = append(payload, decimals, 0, 0)
+	c.writePacket(payload)
+}
+
+// textRow sends a row of the text protocol; nil is NULL
+func (c *conn) textRow(values [][]byte) {
+	var payload []byte
+	for _, value := range values {
+		if value == nil {
+			payload = append(payload, 0xfb)
+			continue
+		}
+		payload = appendLenencInt(payload, uint64(len(value)))
+		payload = append(payload, value...)
+	}
+	c.writePacket(payload)
+}
+
+// binaryRow sends a row of the binary protocol used by prepared statements:
+// a bitmap of the NULL values, offset by two bits, then the others
+func (c *conn) binaryRow(row []interface{}, fields []field) {
+	nulls := make([]byte, (len(fields)+7+2)/8)
+	payload := []byte{0x00}
+	var values []byte
+	for i, f := range fields {
+		var ok bool
+		values, ok = appendBinary(values, row[i], f.typ)
+		if !ok {
+			nulls[(i+2)/8] |= 1 << ((i + 2) % 8)
+		}
+	}
+	payload = append(payload, nulls...)
+	c.writePacket(append(payload, values...))
+}
+
+func appendLenencInt(b []byte, n uint64) []byte {
+	switch {
+	case n < 0xfb:
+		return append(b, byte(n))
+	case n < 1<<16:
+		return binary.LittleEndian.AppendUint16(append(b, 0xfc), uint16(n))
+	case n < 1<<24:
+		return append(b, 0xfd, byte(n), byte(n>>8), byte(n>>16))
+	}
+	return binary.LittleEndian.AppendUint64(append(b, 0xfe), n)
+}
+
+func appendLenencString(b []byte, s string) []byte {
+	return append(appendLenencInt(b, uint64(len(s))), s...)
+}
+
+// message reads the fields of a payload in order; a read past its end sets
+// err and returns zero values
+type message struct {
+	data []byte
+	err  error
+}
+
+func (m *message) take(n int) []byte {
+	if m.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(m.data) {
+		m.err = newError(codeMalformedPacket, "Malformed communication packet")
+		return nil
+	}
+	field := m.data[:n]
+	m.data = m.data[n:]
+	return field
+}
+
+func (m *message) byte() byte {
+	if field := m.take(1); field != nil {
+		return field[0]
+	}
+	return 0
+}
+
+func (m *message) uint16() uint16 {
+	if field := m.take(2); field != nil {
+		return binary.LittleEndian.Uint16(field)
+	}
+	return 0
+}
+
+func (m *message) uint32() uint32 {
+	if field := m.take(4); field != nil {
+		return binary.LittleEndian.Uint32(field)
+	}
+	return 0
+}
+
+func (m *message) uint64() uint64 {
+	if field := m.take(8); field != nil {
+		return binary.LittleEndian.Uint64(field)
+	}
+	return 0
+}
+
+func (m *message) lenencInt() uint64 {
+	switch first := m.byte(); first {
+	case 0xfc:
+		return uint64(m.uint16())
+	case 0xfd:
+		if field := m.take(3); field != nil {
+			return uint64(field[0]) | uint64(field[1])<<8 | uint64(field[2])<<16
+		}
+		return 0
+	case 0xfe:
+		return m.uint64()
+	default:
+		return uint64(first)
+	}
+}
+
+func (m *message) lenencBytes() []byte {
+	n := m.lenencInt()
+	if n > uint64(len(m.data)) {
+		m.take(len(m.data) + 1)
+		return nil
+	}
+	return m.take(int(n))
+}
+
+// nulString reads a string ending in a NUL byte, or at the end of the
+// payload
+func (m *message) nulString() string {
+	if m.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(m.data, 0)
+	if end < 0 {
+		s := string(m.data)
+		m.data = nil
+		return s
+	}
+	s := string(m.data[:end])
+	m.data = m.data[end+1:]
+	return s
+}
@@ -0,0 +1,226 @@
+// Package mysqlwire serves the gateway's tables over the MySQL client/server
+// protocol, so that the mysql client, JDBC and BI tools can query them. It
+// speaks text queries and prepared statements, types columns from the
+// response schemas, and answers SHOW TABLES, SHOW COLUMNS and DESCRIBE from
+// the tables' grammars.
+package mysqlwire
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync/atomic"
+
+	"github.com/simonm/qRest/internal/executor"
+	"github.com/simonm/qRest/internal/grammar"
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/translator"
+)
+
+// serverVersion is the MySQL version reported to clients, which some use to
+// pick the statements they send
+const serverVersion = "8.0.36-qRest"
+
+// databaseName is the database the gateway's tables belong to
+const databaseName = "qrest"
+
+// nativePassword is the authentication method the listener uses
+const nativePassword = "mysql_native_password"
+
+// Backend is the gateway whose tables the listener serves
+type Backend interface {
+	// Tables returns the capability of each table statements can name
+	Tables() map[string]parser.APICapability
+	// Grammars returns the grammar each table's statements are checked
+	// against, which tells the columns they can filter on
+	Grammars() map[string]grammar.SQLGrammar
+	// Translate resolves the tables a statement names and parses it against
+	// their grammars. The tables follow the sources of a join.
+	Translate(sql string) (*translator.ParsedQuery, []executor.JoinTable, error)
+}
+
+// Server accepts MySQL connections for a backend
+type Server struct {
+	backend  Backend
+	password string
+	nextID   atomic.Uint32
+}
+
+// NewServer returns a server for backend that, when password is set, requires
+// clients to prove they know it with mysql_native_password
+func NewServer(backend Backend, password string) *Server {
+	return &Server{backend: backend, password: password}
+}
+
+// ListenAndServe accepts connections on addr until the listener fails
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on listener, serving each on its own goroutine
+func (s *Server) Serve(listener net.Listener) error {
+	defer listener.Close()
+	for {
+		netConn, err := listener.Accept()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+		go s.serveConn(netConn)
+	}
+}
+
+func (s *Server) serveConn(netConn net.Conn) {
+	defer netConn.Close()
+	host, _, _ := net.SplitHostPort(netConn.RemoteAddr().String())
+	sess := &session{
+		server: s,
+		c:      &conn{r: bufio.NewReader(netConn), w: bufio.NewWriter(netConn)},
+		id:     s.nextID.Add(1),
+		host:   host,
+	}
+	sess.reset()
+	if err := sess.handshake(); err != nil {
+		if !errors.Is(err, io.EOF) && !errors.Is(err, errClosed) {
+			log.Printf("mysql: %s: %v", netConn.RemoteAddr(), err)
+		}
+		return
+	}
+	if err := sess.serve(); err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, errClosed) {
+		log.Printf("mysql: %s: %v", netConn.RemoteAddr(), err)
+	}
+}
+
+// errClosed ends a connection the client or the handshake closed cleanly
+var errClosed = errors.New("connection closed")
+
+// handshake greets the client, reads its credentials and checks the
+// password, then selects the database it names
+func (s *session) handshake() error {
+	salt := make([]byte, 20)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	for i := range salt {
+		// Clients read the salt as a NUL-terminated string
+		salt[i] = salt[i]&0x7f | 1
+	}
+
+	greeting := []byte{10} // protocol version
+	greeting = append(append(greeting, serverVersion...), 0)
+	greeting = binary.LittleEndian.AppendUint32(greeting, s.id)
+	greeting = append(append(greeting, salt[:8]...), 0)
+	greeting = binary.LittleEndian.AppendUint16(greeting, uint16(serverCapabilities&0xffff))
+	greeting = append(greeting, charsetUTF8MB4)
+	greeting = binary.LittleEndian.AppendUint16(greeting, s.status)
+	greeting = binary.LittleEndian.AppendUint16(greeting, uint16(serverCapabilities>>16))
+	greeting = append(greeting, byte(len(salt)+1))
+	greeting = append(greeting, make([]byte, 10)...)
+	greeting = append(append(greeting, salt[8:]...), 0)
+	greeting = append(append(greeting, nativePassword...), 0)
+	s.c.writePacket(greeting)
+	if err := s.c.flush(); err != nil {
+		return err
+	}
+
+	packet, err := s.c.readPacket()
+	if err != nil {
+		return err
+	}
+	m := &message{data: packet}
+	s.capabilities = m.uint32() & (serverCapabilities | clientSSL)
+	m.uint32() // max packet size
+	m.byte()   // character set; the listener speaks UTF-8 whatever the client asks
+	m.take(23)
+	if m.err != nil || s.capabilities&clientProtocol41 == 0 {
+		return s.refuse(newError(codeNotSupported, "clients must support the 4.1 protocol"))
+	}
+	if s.capabilities&clientSSL != 0 {
+		return s.refuse(newError(codeNotSupported, "SSL connections are not supported"))
+	}
+	s.user = m.nulString()
+	var response []byte
+	switch {
+	case s.capabilities&clientPluginAuthLenencData != 0:
+		response = m.lenencBytes()
+	case s.capabilities&clientSecureConnection != 0:
+		response = m.take(int(m.byte()))
+	default:
+		response = []byte(m.nulString())
+	}
+	var database, plugin string
+	if s.capabilities&clientConnectWithDB != 0 && len(m.data) > 0 {
+		database = m.nulString()
+	}
+	if s.capabilities&clientPluginAuth != 0 && len(m.data) > 0 {
+		plugin = m.nulString()
+	}
+	if m.err != nil {
+		return s.refuse(m.err)
+	}
+	s.multiStatements = s.capabilities&clientMultiStatements != 0
+
+	if s.server.password != "" {
+		if plugin != "" && plugin != nativePassword {
+			// The client picked another method; ask for the one the salt is for
+			request := append([]byte{0xfe}, nativePassword...)
+			request = append(append(append(request, 0), salt...), 0)
+			s.c.writePacket(request)
+			if err := s.c.flush(); err != nil {
+				return err
+			}
+			if response, err = s.c.readPacket(); err != nil {
+				return err
+			}
+		}
+		if !checkScramble(response, salt, s.server.password) {
+			using := "YES"
+			if len(response) == 0 {
+				using = "NO"
+			}
+			return s.refuse(newError(codeAccessDenied, "Access denied for user '%s'@'%s' (using password: %s)", s.user, s.host, using))
+		}
+	}
+
+	if database != "" {
+		if err := s.useDatabase(database); err != nil {
+			return s.refuse(err)
+		}
+	}
+	s.c.ok(0, s.status, 0)
+	return s.c.flush()
+}
+
+// refuse reports why the handshake failed and closes the connection
+func (s *session) refuse(err error) error {
+	s.c.errorPacket(err)
+	s.c.flush()
+	return errClosed
+}
+
+// checkScramble checks a mysql_native_password response, which is
+// SHA1(password) XOR SHA1(salt + SHA1(SHA1(password)))
+func checkScramble(response, salt []byte, password string) bool {
+	stage1 := sha1.Sum([]byte(password))
+	stage2 := sha1.Sum(stage1[:])
+	mix := sha1.Sum(append(bytes.Clone(salt), stage2[:]...))
+	expected := make([]byte, len(mix))
+	for i := range mix {
+		expected[i] = stage1[i] ^ mix[i]
+	}
+	return subtle.ConstantTimeCompare(response, expected) == 1
+}
@@ -0,0 +1,461 @@
+package mysqlwire
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simonm/qRest/internal/apitest"
+	"github.com/simonm/qRest/internal/wiretest"
+)
+
+// client speaks the client side of the protocol, summarising the packets
+// the server sends as lines of text, e.g. "row 1,Rex" for a row
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+	seq  byte
+}
+
+// dial serves a pets API on a new listener and connects to it
+func dial(t *testing.T, password string) (*client, *apitest.Server) {
+	api := apitest.NewPets(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go NewServer(wiretest.NewBackend(api), password).Serve(listener)
+	t.Cleanup(func() { listener.Close() })
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	t.Cleanup(func() { conn.Close() })
+	return &client{t: t, conn: conn, r: bufio.NewReader(conn)}, api
+}
+
+// connect dials and logs in without a password
+func connect(t *testing.T) (*client, *apitest.Server) {
+	c, api := dial(t, "")
+	if reply := c.login("alice", "", ""); reply != "OK 0" {
+		t.Fatalf("got %q logging in, want OK", reply)
+	}
+	return c, api
+}
+
+// login reads the greeting and answers it with the user, the password
+// scrambled with the greeting's salt and the database, asking for
+// multiple statements per query
+func (c *client) login(user, password, database string) string {
+	m := &message{data: c.readPacket()}
+	m.byte()
+	m.nulString() // server version
+	m.uint32()    // connection id
+	salt := bytes.Clone(m.take(8))
+	m.take(1 + 2 + 1 + 2 + 2 + 1 + 10)
+	salt = append(salt, m.take(12)...)
+
+	var scramble []byte
+	if password != "" {
+		stage1 := sha1.Sum([]byte(password))
+		stage2 := sha1.Sum(stage1[:])
+		mix := sha1.Sum(append(bytes.Clone(salt), stage2[:]...))
+		for i := range mix {
+			scramble = append(scramble, stage1[i]^mix[i])
+		}
+	}
+	capabilities := uint32(clientProtocol41 | clientSecureConnection | clientPluginAuth | clientMultiStatements | clientMultiResults)
+	if database != "" {
+		capabilities |= clientConnectWithDB
+	}
+	response := binary.LittleEndian.AppendUint32(nil, capabilities)
+	response = binary.LittleEndian.AppendUint32(response, maxPacketSize)
+	response = append(response, charsetUTF8MB4)
+	response = append(response, make([]byte, 23)...)
+	response = append(append(response, user...), 0)
+	response = append(append(response, byte(len(scramble))), scramble...)
+	if database != "" {
+		response = append(append(response, database...), 0)
+	}
+	response = append(append(response, nativePassword...), 0)
+	c.writePacket(response)
+	return c.reply(c.readPacket())
+}
+
+func (c *client) readPacket() []byte {
+	var header [4]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		c.t.Fatalf("reading reply: %v", err)
+	}
+	payload := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		c.t.Fatalf("reading reply: %v", err)
+	}
+	c.seq = header[3] + 1
+	return payload
+}
+
+func (c *client) writePacket(payload []byte) {
+	header := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), c.seq}
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// command sends a command, which starts a new sequence
+func (c *client) command(command byte, body []byte) {
+	c.seq = 0
+	c.writePacket(append([]byte{command}, body...))
+}
+
+// reply summarises an OK or error packet
+func (c *client) reply(packet []byte) string {
+	m := &message{data: packet[1:]}
+	switch packet[0] {
+	case 0x00:
+		summary := fmt.Sprintf("OK %d", m.lenencInt())
+		m.lenencInt()
+		m.uint16()
+		if warnings := m.uint16(); warnings > 0 {
+			summary += fmt.Sprintf(" %d warnings", warnings)
+		}
+		return summary
+	case 0xff:
+		number := m.uint16()
+		m.take(1)
+		return fmt.Sprintf("ERR %d %s %s", number, m.take(5), m.data)
+	}
+	return fmt.Sprintf("unexpected packet % x", packet)
+}
+
+// results reads the replies to a query: an OK or error packet or a result
+// set, and those of the statements that follow while the server says more
+// results exist. binaryRows reads rows as prepared statements send them.
+func (c *client) results(binaryRows bool) []string {
+	var replies []string
+	for {
+		packet := c.readPacket()
+		if packet[0] == 0x00 || packet[0] == 0xff {
+			replies = append(replies, c.reply(packet))
+			if packet[0] == 0x00 && moreResults(packet) {
+				continue
+			}
+			return replies
+		}
+
+		m := &message{data: packet}
+		columns, types := c.columns(int(m.lenencInt()))
+		replies = append(replies, "columns "+columns)
+		for {
+			packet := c.readPacket()
+			if packet[0] == 0xfe && len(packet) < 9 {
+				m := &message{data: packet[1:]}
+				end := "end"
+				if warnings := m.uint16(); warnings > 0 {
+					end += fmt.Sprintf(" %d warnings", warnings)
+				}
+				replies = append(replies, end)
+				if m.uint16()&statusMoreResultsExist != 0 {
+					break
+				}
+				return replies
+			}
+			if binaryRows {
+				replies = append(replies, "row "+binaryValues(packet, types))
+			} else {
+				replies = append(replies, "row "+textValues(packet))
+			}
+		}
+	}
+}
+
+// columns reads n column definitions and the EOF after them, returning them
+// as name:type and the types
+func (c *client) columns(n int) (string, []byte) {
+	names := make([]string, n)
+	types := make([]byte, n)
+	for i := range names {
+		m := &message{data: c.readPacket()}
+		for j := 0; j < 4; j++ {
+			m.lenencBytes()
+		}
+		name := m.lenencBytes()
+		m.lenencBytes()
+		m.take(1 + 2 + 4)
+		types[i] = m.byte()
+		names[i] = fmt.Sprintf("%s:%d", name, types[i])
+	}
+	c.readPacket()
+	return strings.Join(names, " "), types
+}
+
+func moreResults(ok []byte) bool {
+	m := &message{data: ok[1:]}
+	m.lenencInt()
+	m.lenencInt()
+	return m.uint16()&statusMoreResultsExist != 0
+}
+
+func textValues(packet []byte) string {
+	m := &message{data: packet}
+	var values []string
+	for len(m.data) > 0 {
+		if m.data[0] == 0xfb {
+			m.byte()
+			values = append(values, "NULL")
+			continue
+		}
+		values = append(values, string(m.lenencBytes()))
+	}
+	return strings.Join(values, ",")
+}
+
+func binaryValues(packet []byte, types []byte) string {
+	m := &message{data: packet[1:]}
+	nulls := m.take((len(types) + 9) / 8)
+	values := make([]string, len(types))
+	for i, typ := range types {
+		switch {
+		case nulls[(i+2)/8]&(1<<((i+2)%8)) != 0:
+			values[i] = "NULL"
+		case typ == typeLongLong:
+			values[i] = fmt.Sprint(int64(m.uint64()))
+		case typ == typeDouble:
+			values[i] = fmt.Sprint(math.Float64frombits(m.uint64()))
+		default:
+			values[i] = string(m.lenencBytes())
+		}
+	}
+	return strings.Join(values, ",")
+}
+
+// prepare sends COM_STMT_PREPARE, summarising the statement id and the types
+// of its parameters and columns
+func (c *client) prepare(sql string) []string {
+	c.command(comStmtPrepare, []byte(sql))
+	packet := c.readPacket()
+	if packet[0] != 0x00 {
+		return []string{c.reply(packet)}
+	}
+	m := &message{data: packet[1:]}
+	id, columns, params := m.uint32(), m.uint16(), m.uint16()
+	replies := []string{fmt.Sprintf("statement %d", id)}
+	if params > 0 {
+		_, types := c.columns(int(params))
+		replies = append(replies, fmt.Sprintf("params %v", types))
+	}
+	if columns > 0 {
+		names, _ := c.columns(int(columns))
+		replies = append(replies, "columns "+names)
+	}
+	return replies
+}
+
+// longData stands for a parameter sent with COM_STMT_SEND_LONG_DATA, whose
+// value COM_STMT_EXECUTE leaves out
+type longData struct{}
+
+// execute sends COM_STMT_EXECUTE with the parameters given as a string, an
+// int64, nil or longData, sending their types when bindTypes is set
+func (c *client) execute(id uint32, bindTypes bool, params ...interface{}) []string {
+	body := binary.LittleEndian.AppendUint32(nil, id)
+	body = append(body, 0)
+	body = binary.LittleEndian.AppendUint32(body, 1)
+	if len(params) > 0 {
+		nulls := make([]byte, (len(params)+7)/8)
+		var types, values []byte
+		for i, param := range params {
+			switch param := param.(type) {
+			case nil:
+				nulls[i/8] |= 1 << (i % 8)
+				types = append(types, typeNull, 0)
+			case int64:
+				types = append(types, typeLongLong, 0)
+				values = binary.LittleEndian.AppendUint64(values, uint64(param))
+			case string:
+				types = append(types, typeVarString, 0)
+				values = appendLenencString(values, param)
+			case longData:
+				types = append(types, typeBlob, 0)
+			}
+		}
+		body = append(body, nulls...)
+		if bindTypes {
+			body = append(append(body, 1), types...)
+		} else {
+			body = append(body, 0)
+		}
+		body = append(body, values...)
+	}
+	c.command(comStmtExecute, body)
+	return c.results(true)
+}
+
+func TestHandshake(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string // the server's
+		login    string // the client's
+		database string
+		reply    string
+	}{
+		{name: "no password", reply: "OK 0"},
+		{name: "database", database: "qrest", reply: "OK 0"},
+		{name: "unknown database", database: "shop", reply: "ERR 1049 42000 Unknown database 'shop'"},
+		{name: "password", password: "secret", login: "secret", reply: "OK 0"},
+		{name: "wrong password", password: "secret", login: "guess", reply: "ERR 1045 28000 Access denied for user 'alice'@'127.0.0.1' (using password: YES)"},
+		{name: "missing password", password: "secret", reply: "ERR 1045 28000 Access denied for user 'alice'@'127.0.0.1' (using password: NO)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := dial(t, tt.password)
+			if reply := c.login("alice", tt.login, tt.database); reply != tt.reply {
+				t.Errorf("got %q, want %q", reply, tt.reply)
+			}
+		})
+	}
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		sql      string
+		replies  []string
+		requests []string
+	}{
+		{
+			sql:      "SELECT id, name FROM pets WHERE status = 'sold'",
+			replies:  []string{"columns id:8 name:253", "row 1,Rex", "row 3,Tom", "end"},
+			requests: []string{"/pets?status=sold"},
+		},
+		{
+			sql: "SELECT * FROM qrest.pets WHERE id > 1 ORDER BY name; SHOW WARNINGS",
+			replies: []string{
+				"columns id:8 name:253 status:253", "row 2,Bo,available", "row 3,Tom,sold", "end 2 warnings",
+				"columns Level:253 Code:3 Message:253",
+				"row Warning,1105,condition id > 1 evaluated locally: the API has no matching parameter",
+				"row Warning,1105,ORDER BY name ASC sorted locally: the API has no sort parameter",
+				"end 2 warnings",
+			},
+			requests: []string{"/pets"},
+		},
+		{
+			sql:     "SELECT 1",
+			replies: []string{"columns 1:8", "row 1", "end"},
+		},
+		{
+			sql:     "SHOW TABLES",
+			replies: []string{"columns Tables_in_qrest:253", "row pets", "end"},
+		},
+		{
+			sql:     "BEGIN; COMMIT",
+			replies: []string{"OK 0", "OK 0"},
+		},
+		{
+			sql:     "",
+			replies: []string{"ERR 1065 42000 Query was empty"},
+		},
+		{
+			sql:     "SELECT nope FROM pets",
+			replies: []string{"ERR 1064 42000 column 'nope' not available. Available columns: [id name status] (line 1, column 8)"},
+		},
+		{
+			sql:     "SELECT id FROM pets WHERE status = ?",
+			replies: []string{"ERR 1064 42000 syntax error at line 1, column 36: unexpected character '?'"},
+		},
+		{
+			sql:     "OPTIMIZE TABLE pets",
+			replies: []string{"ERR 1235 42000 OPTIMIZE statements are not supported"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			c, api := connect(t)
+			c.command(comQuery, []byte(tt.sql))
+			if replies := c.results(false); !reflect.DeepEqual(replies, tt.replies) {
+				t.Errorf("got replies %q, want %q", replies, tt.replies)
+			}
+			if requests := api.Requests(); !reflect.DeepEqual(requests, tt.requests) {
+				t.Errorf("got requests %q, want %q", requests, tt.requests)
+			}
+		})
+	}
+}
+
+func TestPreparedStatement(t *testing.T) {
+	c, api := connect(t)
+
+	want := []string{"statement 1", fmt.Sprintf("params %v", []byte{typeVarString, typeLongLong}), "columns id:8 name:253"}
+	if replies := c.prepare("SELECT id, name FROM pets WHERE status = ? LIMIT ?"); !reflect.DeepEqual(replies, want) {
+		t.Fatalf("prepare: got replies %q, want %q", replies, want)
+	}
+
+	steps := []struct {
+		name    string
+		run     func() []string
+		replies []string
+	}{
+		{
+			name:    "bound and run",
+			run:     func() []string { return c.execute(1, true, "sold", int64(1)) },
+			replies: []string{"columns id:8 name:253", "row 1,Rex", "end"},
+		},
+		{
+			name:    "types of the last execution kept",
+			run:     func() []string { return c.execute(1, false, "available", int64(5)) },
+			replies: []string{"columns id:8 name:253", "row 2,Bo", "end"},
+		},
+		{
+			name:    "number sent as text",
+			run:     func() []string { return c.execute(1, true, "sold", "5") },
+			replies: []string{"columns id:8 name:253", "row 1,Rex", "row 3,Tom", "end"},
+		},
+		{
+			name:    "invalid number",
+			run:     func() []string { return c.execute(1, true, "sold", "many") },
+			replies: []string{"ERR 1366 HY000 Incorrect integer value: 'many' for parameter 2"},
+		},
+		{
+			name: "long data",
+			run: func() []string {
+				for _, piece := range []string{"avail", "able"} {
+					body := binary.LittleEndian.AppendUint32(nil, 1)
+					body = binary.LittleEndian.AppendUint16(body, 0)
+					c.command(comStmtSendLongData, append(body, piece...))
+				}
+				return c.execute(1, true, longData{}, int64(5))
+			},
+			replies: []string{"columns id:8 name:253", "row 2,Bo", "end"},
+		},
+		{
+			name: "closed",
+			run: func() []string {
+				c.command(comStmtClose, binary.LittleEndian.AppendUint32(nil, 1))
+				return c.execute(1, true, "sold", int64(1))
+			},
+			replies: []string{"ERR 1243 HY000 Unknown prepared statement handler (1) given to mysqld_stmt_execute"},
+		},
+	}
+	for _, step := range steps {
+		if replies := step.run(); !reflect.DeepEqual(replies, step.replies) {
+			t.Errorf("%s: got replies %q, want %q", step.name, replies, step.replies)
+		}
+	}
+
+	want = []string{"/pets?status=sold", "/pets?status=available", "/pets?status=sold", "/pets?status=available"}
+	if requests := api.Requests(); !reflect.DeepEqual(requests, want) {
+		t.Errorf("got requests %q, want %q", requests, want)
+	}
+}
@@ -0,0 +1,391 @@
+package mysqlwire
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+
+	"github.com/simonm/qRest/internal/executor"
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/translator"
+	"github.com/simonm/qRest/internal/wiresql"
+)
+
+// variableDefaults are the system variables a connection starts with, as
+// @@name and SHOW VARIABLES read them
+var variableDefaults = map[string]string{
+	"version":                  serverVersion,
+	"version_comment":          "qRest gateway",
+	"autocommit":               "1",
+	"character_set_client":     "utf8mb4",
+	"character_set_connection": "utf8mb4",
+	"character_set_results":    "utf8mb4",
+	"character_set_server":     "utf8mb4",
+	"character_set_database":   "utf8mb4",
+	"collation_connection":     "utf8mb4_general_ci",
+	"collation_server":         "utf8mb4_general_ci",
+	"collation_database":       "utf8mb4_general_ci",
+	"sql_mode":                 "ANSI_QUOTES,NO_BACKSLASH_ESCAPES",
+	"time_zone":                "+00:00",
+	"system_time_zone":         "UTC",
+	"transaction_isolation":    "READ-COMMITTED",
+	"transaction_read_only":    "0",
+	"max_allowed_packet":       strconv.Itoa(maxPacketSize),
+	"auto_increment_increment": "1",
+	"lower_case_table_names":   "0",
+	"net_write_timeout":        "60",
+	"wait_timeout":             "28800",
+	"interactive_timeout":      "28800",
+	"init_connect":             "",
+	"performance_schema":       "0",
+}
+
+// session is the state of one client connection
+type session struct {
+	server *Server
+	c      *conn
+	id     uint32
+	host   string
+
+	capabilities    uint32
+	multiStatements bool
+	user            string
+	database        string
+	status          uint16
+	variables       map[string]string
+	userVariables   map[string]interface{}
+	warnings        []string // of the last statement, for SHOW WARNINGS
+
+	statements    map[uint32]*statement
+	nextStatement uint32
+}
+
+// statement is a statement prepared with COM_STMT_PREPARE
+type statement struct {
+	sql        string
+	params     []parser.ColumnType // the types of the columns parameters meet
+	paramTypes []byte              // as last bound, kept for executions that send none
+	longData   map[int][]byte      // values sent with COM_STMT_SEND_LONG_DATA
+	fields     []field
+}
+
+// field is a column of a result. Columns of the gateway's tables name their
+// table.
+type field struct {
+	name  string
+	typ   byte
+	table string
+}
+
+// plan is how the listener answers a statement; run carries it out
+type plan struct {
+	fields       []field // nil for statements that return no rows
+	run          func() (*outcome, error)
+	keepWarnings bool // SHOW WARNINGS reads those of the statement before
+
+	// The translated statement, from which COM_STMT_PREPARE infers parameter
+	// types
+	query  *translator.ParsedQuery
+	tables []executor.JoinTable
+}
+
+// outcome is what a statement returned: its rows, in field order, or the
+// number of rows it changed
+type outcome struct {
+	rows     [][]interface{}
+	affected uint64
+}
+
+// reset returns the session to its state after the handshake, as
+// COM_RESET_CONNECTION asks
+func (s *session) reset() {
+	s.status = statusAutocommit | statusNoBackslashEscapes
+	s.variables = make(map[string]string, len(variableDefaults))
+	for name, value := range variableDefaults {
+		s.variables[name] = value
+	}
+	s.userVariables = make(map[string]interface{})
+	s.warnings = nil
+	s.statements = make(map[uint32]*statement)
+}
+
+// serve reads commands until the client quits
+func (s *session) serve() error {
+	for {
+		packet, err := s.c.readPacket()
+		if err != nil {
+			var myErr *mysqlError
+			if errors.As(err, &myErr) {
+				s.c.errorPacket(err)
+				s.c.flush()
+			}
+			return err
+		}
+		if len(packet) == 0 {
+			return newError(codeMalformedPacket, "empty command packet")
+		}
+
+		command, body := packet[0], packet[1:]
+		switch command {
+		case comQuit:
+			return errClosed
+		case comInitDB:
+			if err = s.useDatabase(string(body)); err == nil {
+				s.c.ok(0, s.status, 0)
+			}
+		case comQuery:
+			s.query(string(body))
+		case comFieldList:
+			err = s.fieldList(body)
+		case comPing:
+			s.c.ok(0, s.status, 0)
+		case comStmtPrepare:
+			err = s.prepare(string(body))
+		case comStmtExecute:
+			err = s.execute(body)
+		case comStmtSendLongData:
+			// Long data is not acknowledged, even when it fails
+			s.sendLongData(body)
+			continue
+		case comStmtClose:
+			m := &message{data: body}
+			delete(s.statements, m.uint32())
+			continue
+		case comStmtReset:
+			err = s.resetStatement(body)
+		case comSetOption:
+			err = s.setOption(body)
+		case comResetConnection:
+			s.reset()
+			s.c.ok(0, s.status, 0)
+		default:
+			err = newError(codeUnknownCommand, "command %#x is not supported", command)
+		}
+		if err != nil {
+			s.c.errorPacket(err)
+		}
+		if err := s.c.flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// query runs the statements of COM_QUERY in turn, stopping at the first that
+// fails. Each result but the last tells the client more follow.
+func (s *session) query(sql string) {
+	statements := dialect.SplitStatements(sql)
+	switch {
+	case len(statements) == 0:
+		s.c.errorPacket(newError(codeEmptyQuery, "Query was empty"))
+		return
+	case len(statements) > 1 && !s.multiStatements:
+		s.c.errorPacket(newError(codeParseError, "multiple statements in one query require the multi-statements option"))
+		return
+	}
+	for i, text := range statements {
+		if err := s.runStatement(text, i < len(statements)-1, false); err != nil {
+			s.c.errorPacket(err)
+			return
+		}
+	}
+}
+
+// runStatement plans and runs a statement, sending its rows in the text
+// protocol or, for prepared statements, the binary protocol
+func (s *session) runStatement(sql string, more, binaryRows bool) error {
+	p, err := s.plan(sql)
+	if err != nil {
+		return err
+	}
+	if !p.keepWarnings {
+		s.warnings = nil
+	}
+	out, err := p.run()
+	if err != nil {
+		return err
+	}
+
+	status := s.status
+	if more {
+		status |= statusMoreResultsExist
+	}
+	if p.fields == nil {
+		s.c.ok(out.affected, status, len(s.warnings))
+		return nil
+	}
+	s.c.writePacket(appendLenencInt(nil, uint64(len(p.fields))))
+	for _, f := range p.fields {
+		s.c.columnDefinition(f)
+	}
+	s.c.eof(s.status, len(s.warnings))
+	for _, row := range out.rows {
+		if binaryRows {
+			s.c.binaryRow(row, p.fields)
+			continue
+		}
+		values := make([][]byte, len(row))
+		for i, value := range row {
+			values[i] = encodeText(value, p.fields[i].typ)
+		}
+		s.c.textRow(values)
+	}
+	s.c.eof(status, len(s.warnings))
+	return nil
+}
+
+// useDatabase selects the database, as USE and COM_INIT_DB do. The
+// gateway's tables all belong to one.
+func (s *session) useDatabase(name string) error {
+	if name != databaseName {
+		return newError(codeUnknownDatabase, "Unknown database '%s'", name)
+	}
+	s.database = name
+	return nil
+}
+
+// fieldList answers COM_FIELD_LIST, which the mysql client sends to complete
+// column names, with the definitions of a table's columns
+func (s *session) fieldList(body []byte) error {
+	m := &message{data: body}
+	table := m.nulString()
+	columns, err := s.describeTable(table)
+	if err != nil {
+		return err
+	}
+	for _, column := range columns {
+		s.c.columnDefinition(field{column.name, columnType(column.columnType), table})
+	}
+	s.c.eof(s.status, 0)
+	return nil
+}
+
+// prepare prepares a statement, typing its ? parameters by the columns they
+// are compared with or assigned to
+func (s *session) prepare(sql string) error {
+	statements := dialect.SplitStatements(sql)
+	if len(statements) != 1 {
+		return newError(codeParseError, "a prepared statement must hold exactly one statement")
+	}
+	tokens := dialect.Tokenize(sql)
+	p, params, err := s.planParams(sql, tokens, wiresql.CountParams(tokens))
+	if err != nil {
+		return err
+	}
+
+	s.nextStatement++
+	id := s.nextStatement
+	s.statements[id] = &statement{sql: sql, params: params, longData: make(map[int][]byte), fields: p.fields}
+
+	reply := []byte{0x00}
+	reply = binary.LittleEndian.AppendUint32(reply, id)
+	reply = binary.LittleEndian.AppendUint16(reply, uint16(len(p.fields)))
+	reply = binary.LittleEndian.AppendUint16(reply, uint16(len(params)))
+	reply = append(reply, 0)
+	reply = binary.LittleEndian.AppendUint16(reply, 0) // warnings
+	s.c.writePacket(reply)
+	if len(params) > 0 {
+		for _, param := range params {
+			s.c.columnDefinition(field{name: "?", typ: columnType(param)})
+		}
+		s.c.eof(s.status, 0)
+	}
+	if len(p.fields) > 0 {
+		for _, f := range p.fields {
+			s.c.columnDefinition(f)
+		}
+		s.c.eof(s.status, 0)
+	}
+	return nil
+}
+
+// execute binds the parameters of a prepared statement and runs it, sending
+// its rows in the binary protocol
+func (s *session) execute(body []byte) error {
+	m := &message{data: body}
+	id := m.uint32()
+	m.byte()   // cursor flags; rows are always sent at once
+	m.uint32() // iteration count, always 1
+	if m.err != nil {
+		return m.err
+	}
+	stmt, ok := s.statements[id]
+	if !ok {
+		return newError(codeUnknownStatement, "Unknown prepared statement handler (%d) given to mysqld_stmt_execute", id)
+	}
+	defer func() { stmt.longData = make(map[int][]byte) }()
+
+	values := make([]*paramValue, len(stmt.params))
+	if len(values) > 0 {
+		nulls := m.take((len(values) + 7) / 8)
+		if m.byte() == 1 {
+			stmt.paramTypes = m.take(2 * len(values))
+		}
+		if m.err != nil {
+			return m.err
+		}
+		if len(stmt.paramTypes) != 2*len(values) {
+			return newError(codeWrongArguments, "Incorrect arguments to mysqld_stmt_execute")
+		}
+		for i := range values {
+			if data, ok := stmt.longData[i]; ok {
+				values[i] = &paramValue{text: string(data)}
+				continue
+			}
+			typ, unsigned := stmt.paramTypes[2*i], stmt.paramTypes[2*i+1]&0x80 != 0
+			if nulls[i/8]&(1<<(i%8)) != 0 || typ == typeNull {
+				continue
+			}
+			value, err := decodeParam(m, typ, unsigned)
+			if err != nil {
+				return err
+			}
+			values[i] = &value
+		}
+	}
+
+	sql, err := bindParams(stmt.sql, values, stmt.params)
+	if err != nil {
+		return err
+	}
+	return s.runStatement(sql, false, true)
+}
+
+// sendLongData appends to a parameter sent in pieces before execution
+func (s *session) sendLongData(body []byte) {
+	m := &message{data: body}
+	id := m.uint32()
+	param := int(m.uint16())
+	if stmt, ok := s.statements[id]; ok && m.err == nil && param < len(stmt.params) {
+		stmt.longData[param] = append(stmt.longData[param], m.data...)
+	}
+}
+
+// resetStatement discards the long data sent for a prepared statement
+func (s *session) resetStatement(body []byte) error {
+	m := &message{data: body}
+	id := m.uint32()
+	stmt, ok := s.statements[id]
+	if m.err != nil || !ok {
+		return newError(codeUnknownStatement, "Unknown prepared statement handler (%d) given to mysqld_stmt_reset", id)
+	}
+	stmt.longData = make(map[int][]byte)
+	s.c.ok(0, s.status, 0)
+	return nil
+}
+
+// setOption turns multiple statements per COM_QUERY on or off
+func (s *session) setOption(body []byte) error {
+	m := &message{data: body}
+	switch option := m.uint16(); {
+	case m.err != nil:
+		return m.err
+	case option == 0:
+		s.multiStatements = true
+	case option == 1:
+		s.multiStatements = false
+	default:
+		return newError(codeUnknownCommand, "unknown option %d", option)
+	}
+	s.c.eof(s.status, 0)
+	return nil
+}
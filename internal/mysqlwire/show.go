@@ -0,0 +1,323 @@
+package mysqlwire
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/simonm/qRest/internal/executor"
+	"github.com/simonm/qRest/internal/grammar"
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/translator"
+	"github.com/simonm/qRest/internal/wiresql"
+)
+
+// tableColumn is a column as SHOW COLUMNS and DESCRIBE list it: those the
+// table returns, then those its API filters on but does not return
+type tableColumn struct {
+	name       string
+	columnType parser.ColumnType
+	operators  []string // the API's filter operators
+	filterOnly bool
+}
+
+// describeTable lists the columns of a table from its grammar
+func (s *session) describeTable(name string) ([]tableColumn, error) {
+	tableGrammar, ok := s.server.backend.Grammars()[name]
+	if !ok {
+		return nil, newError(codeNoSuchTable, "Table '%s.%s' doesn't exist", databaseName, name)
+	}
+	capability := s.server.backend.Tables()[name]
+
+	var columns []tableColumn
+	returned := make(map[string]bool)
+	for _, column := range tableGrammar.AllowedColumns {
+		returned[column] = true
+		columns = append(columns, tableColumn{
+			name:       column,
+			columnType: capability.ColumnTypes[column],
+			operators:  tableGrammar.WhereClause.AllowedColumns[column],
+		})
+	}
+
+	var filterOnly []string
+	for column := range tableGrammar.WhereClause.AllowedColumns {
+		if !returned[column] {
+			filterOnly = append(filterOnly, column)
+		}
+	}
+	sort.Strings(filterOnly)
+	for _, column := range filterOnly {
+		var columnType parser.ColumnType
+		for _, param := range capability.Parameters {
+			if param.Name == column {
+				columnType = parser.ColumnType{Type: param.Type, Format: param.Format}
+			}
+		}
+		columns = append(columns, tableColumn{
+			name:       column,
+			columnType: columnType,
+			operators:  tableGrammar.WhereClause.AllowedColumns[column],
+			filterOnly: true,
+		})
+	}
+	return columns, nil
+}
+
+// extra is what the Extra column of SHOW COLUMNS says of a column: the
+// operators the API filters it with, where the gateway does not filter the
+// returned rows itself
+func (c tableColumn) extra() string {
+	if len(c.operators) == 0 {
+		return ""
+	}
+	extra := "api filter: " + strings.Join(c.operators, ", ")
+	if c.filterOnly {
+		extra = "filter only; " + extra
+	}
+	return extra
+}
+
+// showPlan answers the SHOW statements clients send to browse the tables
+// and the session
+func (s *session) showPlan(sql string, tokens []wiresql.Token) (*plan, error) {
+	full := len(tokens) > 0 && tokens[0].Is("FULL")
+	if full {
+		tokens = tokens[1:]
+	}
+	if len(tokens) > 0 && (tokens[0].Is("SESSION") || tokens[0].Is("LOCAL") || tokens[0].Is("GLOBAL")) {
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 0 {
+		return nil, newError(codeParseError, "syntax error in SHOW")
+	}
+
+	what := strings.ToUpper(tokens[0].Text)
+	rest := tokens[1:]
+	switch what {
+	case "TABLES":
+		return s.showTables(sql, full, rest)
+	case "COLUMNS", "FIELDS":
+		if len(rest) == 0 || !rest[0].Is("FROM") && !rest[0].Is("IN") {
+			return nil, newError(codeParseError, "syntax error in SHOW %s", wiresql.Describe(tokens))
+		}
+		table, rest, err := tableName(rest[1:])
+		if err != nil {
+			return nil, err
+		}
+		return s.showColumns(sql, table, full, rest)
+	case "DATABASES", "SCHEMAS":
+		fields := []field{{name: "Database", typ: typeVarString}}
+		return filterPlan(sql, fields, rest, func() [][]interface{} {
+			return [][]interface{}{{databaseName}}
+		})
+	case "VARIABLES":
+		fields := []field{{name: "Variable_name", typ: typeVarString}, {name: "Value", typ: typeVarString}}
+		return filterPlan(sql, fields, rest, func() [][]interface{} {
+			names := make([]string, 0, len(s.variables))
+			for name := range s.variables {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			rows := make([][]interface{}, len(names))
+			for i, name := range names {
+				rows[i] = []interface{}{name, s.variables[name]}
+			}
+			return rows
+		})
+	case "STATUS":
+		fields := []field{{name: "Variable_name", typ: typeVarString}, {name: "Value", typ: typeVarString}}
+		return filterPlan(sql, fields, rest, func() [][]interface{} { return nil })
+	case "WARNINGS":
+		warnings := s.warnings
+		fields := []field{{name: "Level", typ: typeVarString}, {name: "Code", typ: typeLong}, {name: "Message", typ: typeVarString}}
+		p := &plan{fields: fields, keepWarnings: true, run: func() (*outcome, error) {
+			out := &outcome{}
+			for _, warning := range warnings {
+				out.rows = append(out.rows, []interface{}{"Warning", int64(codeUnknownError.number), warning})
+			}
+			return out, nil
+		}}
+		return p, nil
+	}
+	return nil, newError(codeNotSupported, "SHOW %s is not supported", wiresql.Describe(tokens))
+}
+
+// tableName reads a table name, which may be qualified by the database
+func tableName(tokens []wiresql.Token) (string, []wiresql.Token, error) {
+	if len(tokens) == 0 || !tokens[0].IsName() {
+		return "", nil, newError(codeParseError, "a table name is expected")
+	}
+	if len(tokens) >= 3 && tokens[1].Text == "." && tokens[2].IsName() {
+		if database := tokens[0].Value(); database != databaseName {
+			return "", nil, newError(codeUnknownDatabase, "Unknown database '%s'", database)
+		}
+		tokens = tokens[2:]
+	}
+	return tokens[0].Value(), tokens[1:], nil
+}
+
+// skipDatabase reads the FROM or IN clause naming the database of SHOW
+// TABLES and SHOW COLUMNS
+func skipDatabase(tokens []wiresql.Token) ([]wiresql.Token, error) {
+	if len(tokens) < 2 || !tokens[0].Is("FROM") && !tokens[0].Is("IN") {
+		return tokens, nil
+	}
+	if database := tokens[1].Value(); database != databaseName {
+		return nil, newError(codeUnknownDatabase, "Unknown database '%s'", database)
+	}
+	return tokens[2:], nil
+}
+
+// showTables lists the gateway's tables, with their type when FULL
+func (s *session) showTables(sql string, full bool, tokens []wiresql.Token) (*plan, error) {
+	tokens, err := skipDatabase(tokens)
+	if err != nil {
+		return nil, err
+	}
+	fields := []field{{name: "Tables_in_" + databaseName, typ: typeVarString}}
+	if full {
+		fields = append(fields, field{name: "Table_type", typ: typeVarString})
+	}
+	return filterPlan(sql, fields, tokens, func() [][]interface{} {
+		names := make([]string, 0)
+		for name := range s.server.backend.Grammars() {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		rows := make([][]interface{}, len(names))
+		for i, name := range names {
+			rows[i] = []interface{}{name}
+			if full {
+				rows[i] = append(rows[i], "BASE TABLE")
+			}
+		}
+		return rows
+	})
+}
+
+// showColumns lists the columns of a table, typed from its response schema.
+// Extra tells which the API filters on, and with which operators.
+func (s *session) showColumns(sql, table string, full bool, tokens []wiresql.Token) (*plan, error) {
+	tokens, err := skipDatabase(tokens)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := s.describeTable(table)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{"Field", "Type", "Null", "Key", "Default", "Extra"}
+	if full {
+		names = []string{"Field", "Type", "Collation", "Null", "Key", "Default", "Extra", "Privileges", "Comment"}
+	}
+	fields := make([]field, len(names))
+	for i, name := range names {
+		fields[i] = field{name: name, typ: typeVarString}
+	}
+	return filterPlan(sql, fields, tokens, func() [][]interface{} {
+		rows := make([][]interface{}, len(columns))
+		for i, column := range columns {
+			typeName := columnTypeName(column.columnType)
+			var collation interface{}
+			if typeName == "text" {
+				collation = "utf8mb4_general_ci"
+			}
+			nullable := "YES"
+			if !column.filterOnly && column.columnType.Type != "" && !column.columnType.Nullable {
+				nullable = "NO"
+			}
+			privileges := "select"
+			if column.filterOnly {
+				privileges = ""
+			}
+			values := map[string]interface{}{
+				"Field": column.name, "Type": typeName, "Collation": collation, "Null": nullable, "Key": "",
+				"Default": nil, "Extra": column.extra(), "Privileges": privileges, "Comment": "",
+			}
+			rows[i] = make([]interface{}, len(names))
+			for j, name := range names {
+				rows[i][j] = values[name]
+			}
+		}
+		return rows
+	})
+}
+
+// describePlan answers DESCRIBE table, optionally naming a column or a
+// pattern, as SHOW COLUMNS does
+func (s *session) describePlan(sql string, tokens []wiresql.Token) (*plan, error) {
+	if len(tokens) > 0 && tokens[0].Kind == wiresql.Word {
+		switch strings.ToUpper(tokens[0].Text) {
+		case "SELECT", "INSERT", "UPDATE", "DELETE", "FORMAT", "ANALYZE", "EXTENDED", "PARTITIONS":
+			return nil, newError(codeNotSupported, "EXPLAIN of statements is not supported")
+		}
+	}
+	table, rest, err := tableName(tokens)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(rest) == 0:
+		return s.showColumns(sql, table, false, nil)
+	case len(rest) == 1 && (rest[0].Kind == wiresql.String || rest[0].IsName()):
+		// A column name is matched as a pattern, as MySQL does
+		pattern := wiresql.Token{Kind: wiresql.String, Text: wiresql.QuoteString(rest[0].Value())}
+		return s.showColumns(sql, table, false, []wiresql.Token{{Kind: wiresql.Word, Text: "LIKE"}, pattern})
+	}
+	return nil, newError(codeParseError, "syntax error in DESCRIBE %s", wiresql.Describe(tokens))
+}
+
+// filterPlan answers a SHOW statement with rows built when it runs, keeping
+// those the LIKE pattern or WHERE condition ending it matches. LIKE matches
+// the first column. The condition is evaluated in memory like a join.
+func filterPlan(sql string, fields []field, filter []wiresql.Token, rows func() [][]interface{}) (*plan, error) {
+	p := &plan{fields: fields, run: func() (*outcome, error) {
+		return &outcome{rows: rows()}, nil
+	}}
+	var condition string
+	switch {
+	case len(filter) == 0:
+		return p, nil
+	case filter[0].Is("LIKE") && len(filter) == 2 && filter[1].Kind == wiresql.String:
+		condition = quoteIdentifier(fields[0].name) + " LIKE " + filter[1].Text
+	case filter[0].Is("WHERE") && len(filter) > 1:
+		condition = sql[filter[1].Start:]
+	default:
+		return nil, newError(codeParseError, "syntax error at %s", wiresql.Describe(filter))
+	}
+
+	capability := parser.APICapability{TableName: "result", ColumnTypes: make(map[string]parser.ColumnType)}
+	for _, f := range fields {
+		capability.ResponseColumns = append(capability.ResponseColumns, f.name)
+		capability.ColumnTypes[f.name] = parser.ColumnType{Type: "string"}
+		if f.typ == typeLong {
+			capability.ColumnTypes[f.name] = parser.ColumnType{Type: "integer"}
+		}
+	}
+	resultGrammar := grammar.NewGrammarGenerator().GenerateGrammar(capability)
+	resultGrammar.Limit = grammar.LimitGrammar{MaxLimit: math.MaxInt32}
+	query, err := translator.NewSimpleSQLTranslator(resultGrammar).ParseSQL("SELECT * FROM result WHERE " + condition)
+	if err != nil {
+		return nil, newError(codeParseError, "%s", err.Error())
+	}
+
+	p.run = func() (*outcome, error) {
+		var records []map[string]interface{}
+		for _, row := range rows() {
+			record := make(map[string]interface{}, len(fields))
+			for i, f := range fields {
+				record[f.name] = row[i]
+			}
+			records = append(records, record)
+		}
+		result := executor.QueryRecords(records, query)
+		return &outcome{rows: wiresql.RecordRows(result.Data, query.Columns)}, nil
+	}
+	return p, nil
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
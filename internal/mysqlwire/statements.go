@@ -0,0 +1,516 @@
+package mysqlwire
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/simonm/qRest/internal/executor"
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/translator"
+	"github.com/simonm/qRest/internal/wiresql"
+)
+
+// dialect is how MySQL clients write statements: ? parameters, # comments,
+// backquoted identifiers, @variables and := assignments. Backslashes do not
+// escape: sessions run with NO_BACKSLASH_ESCAPES.
+var dialect = wiresql.Dialect{Placeholder: '?', Operator: ":=", HashComments: true, Backticks: true, Variables: true}
+
+// plan decides how a statement is answered without running it
+func (s *session) plan(sql string) (*plan, error) {
+	tokens := dialect.Tokenize(sql)
+	if len(tokens) == 0 {
+		return nil, newError(codeEmptyQuery, "Query was empty")
+	}
+
+	command := strings.ToUpper(tokens[0].Text)
+	switch command {
+	case "BEGIN":
+		return s.transactionPlan(true), nil
+	case "START":
+		if len(tokens) < 2 || !tokens[1].Is("TRANSACTION") {
+			return nil, newError(codeParseError, "syntax error in START %s", wiresql.Describe(tokens[1:]))
+		}
+		return s.transactionPlan(true), nil
+	case "COMMIT", "ROLLBACK":
+		return s.transactionPlan(false), nil
+	case "SET":
+		return s.setPlan(tokens[1:])
+	case "USE":
+		if len(tokens) != 2 || !tokens[1].IsName() {
+			return nil, newError(codeParseError, "syntax error in USE %s", wiresql.Describe(tokens[1:]))
+		}
+		name := tokens[1].Value()
+		return commandPlan(func() error { return s.useDatabase(name) }), nil
+	case "SHOW":
+		return s.showPlan(sql, tokens[1:])
+	case "DESCRIBE", "DESC", "EXPLAIN":
+		return s.describePlan(sql, tokens[1:])
+	case "SELECT":
+		from := fromIndex(tokens)
+		if from < 0 || from+2 <= len(tokens) && tokens[from+1].Is("DUAL") {
+			return s.scalarPlan(tokens[1:])
+		}
+		return s.gatewayPlan(stripDatabase(sql, tokens))
+	case "INSERT", "UPDATE", "DELETE":
+		return s.gatewayPlan(stripDatabase(sql, tokens))
+	}
+	return nil, newError(codeNotSupported, "%s statements are not supported", command)
+}
+
+// fromIndex returns the position of the FROM of a SELECT, or -1
+func fromIndex(tokens []wiresql.Token) int {
+	for i, t := range tokens {
+		if t.Depth == 0 && t.Is("FROM") {
+			return i
+		}
+	}
+	return -1
+}
+
+// stripDatabase drops the database from qualified table names: the
+// gateway's tables all belong to it. Virtual tables such as qrest.operators
+// keep their names.
+func stripDatabase(sql string, tokens []wiresql.Token) string {
+	var stripped strings.Builder
+	last := 0
+	for i := 0; i+2 < len(tokens); i++ {
+		t := tokens[i]
+		if t.IsName() && t.Value() == databaseName && tokens[i+1].Text == "." && tokens[i+2].IsName() &&
+			!slices.Contains(translator.MetadataTableNames(), databaseName+"."+tokens[i+2].Value()) {
+			stripped.WriteString(sql[last:t.Start])
+			last = tokens[i+1].End
+		}
+	}
+	stripped.WriteString(sql[last:])
+	return stripped.String()
+}
+
+// commandPlan answers a statement that returns no rows, after applying its
+// effect on the session
+func commandPlan(effect func() error) *plan {
+	return &plan{run: func() (*outcome, error) {
+		if effect != nil {
+			if err := effect(); err != nil {
+				return nil, err
+			}
+		}
+		return &outcome{}, nil
+	}}
+}
+
+// transactionPlan tracks transactions for the status clients see.
+// Statements are not transactional: each is applied when it runs.
+func (s *session) transactionPlan(begin bool) *plan {
+	return commandPlan(func() error {
+		if begin {
+			s.status |= statusInTrans
+		} else {
+			s.status &^= statusInTrans
+		}
+		return nil
+	})
+}
+
+// setPlan handles SET with its comma-separated assignments to system and
+// user variables, SET NAMES and SET TRANSACTION
+func (s *session) setPlan(tokens []wiresql.Token) (*plan, error) {
+	if len(tokens) == 0 {
+		return nil, newError(codeParseError, "syntax error in SET")
+	}
+	var effects []func() error
+	for _, item := range wiresql.SplitList(tokens) {
+		effect, err := s.assignment(item)
+		if err != nil {
+			return nil, err
+		}
+		if effect != nil {
+			effects = append(effects, effect)
+		}
+	}
+	return commandPlan(func() error {
+		for _, effect := range effects {
+			if err := effect(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}), nil
+}
+
+// assignment plans one assignment of a SET statement
+func (s *session) assignment(item []wiresql.Token) (func() error, error) {
+	if len(item) > 0 && (item[0].Is("SESSION") || item[0].Is("LOCAL")) {
+		item = item[1:]
+	}
+	if len(item) == 0 {
+		return nil, newError(codeParseError, "syntax error in SET")
+	}
+	switch {
+	case item[0].Is("GLOBAL") || item[0].Is("PERSIST") || strings.HasPrefix(strings.ToLower(item[0].Text), "@@global."):
+		return nil, newError(codeNotSupported, "global variables cannot be set")
+	case item[0].Is("TRANSACTION"):
+		// Transaction characteristics have no effect on the gateway
+		return nil, nil
+	case item[0].Is("NAMES") || item[0].Is("CHARSET") || len(item) > 1 && item[0].Is("CHARACTER") && item[1].Is("SET"):
+		if item[0].Is("CHARACTER") {
+			item = item[1:]
+		}
+		if len(item) < 2 {
+			return nil, newError(codeParseError, "syntax error in SET %s", wiresql.Describe(item))
+		}
+		charset := strings.ToLower(item[1].Value())
+		switch charset {
+		case "utf8mb4", "utf8", "utf8mb3", "default":
+		default:
+			return nil, newError(codeNotSupported, "character set %s is not supported; use utf8mb4", charset)
+		}
+		return nil, nil
+	}
+
+	if len(item) < 3 || item[1].Text != "=" && item[1].Text != ":=" {
+		return nil, newError(codeParseError, "syntax error in SET %s", wiresql.Describe(item))
+	}
+	target, expr := item[0], item[2:]
+	if target.Kind == wiresql.Variable && !strings.HasPrefix(target.Text, "@@") {
+		value, _, err := s.scalar(expr)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.ToLower(target.Text[1:])
+		return func() error {
+			s.userVariables[name] = value()
+			return nil
+		}, nil
+	}
+
+	name := variableName(target)
+	if len(expr) == 1 && expr[0].Is("DEFAULT") {
+		return func() error { return s.setVariable(name, variableDefaults[name]) }, nil
+	}
+	if len(expr) == 1 && expr[0].Kind == wiresql.Word {
+		// ON, OFF and enumerated values are written as words
+		word := expr[0].Text
+		return func() error { return s.setVariable(name, word) }, nil
+	}
+	value, _, err := s.scalar(expr)
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		v := value()
+		if v == nil {
+			return s.setVariable(name, "")
+		}
+		return s.setVariable(name, textOf(v))
+	}, nil
+}
+
+// variableName returns the name of a system variable, without its @@ and
+// scope
+func variableName(t wiresql.Token) string {
+	name := strings.ToLower(strings.TrimPrefix(t.Value(), "@@"))
+	for _, scope := range []string{"session.", "local.", "global."} {
+		name = strings.TrimPrefix(name, scope)
+	}
+	return name
+}
+
+// setVariable changes a system variable. autocommit also changes the status
+// clients see; the character set variables only take UTF-8.
+func (s *session) setVariable(name, value string) error {
+	switch {
+	case name == "autocommit":
+		on, err := wiresql.ParseBool(value)
+		if err != nil {
+			return newError(codeWrongArguments, "Variable 'autocommit' can't be set to the value of '%s'", value)
+		}
+		if on {
+			value = "1"
+			s.status |= statusAutocommit
+		} else {
+			value = "0"
+			s.status &^= statusAutocommit
+		}
+	case strings.HasPrefix(name, "character_set_") && name != "character_set_server" && name != "character_set_database":
+		if v := strings.ToLower(value); v != "utf8mb4" && v != "utf8" && v != "utf8mb3" && v != "" {
+			return newError(codeNotSupported, "character set %s is not supported; use utf8mb4", value)
+		}
+	}
+	s.variables[name] = value
+	return nil
+}
+
+// scalarPlan answers a SELECT without FROM, which clients send to check the
+// connection and to learn about the server: literals, variables and the
+// functions that report the session. A LIMIT is allowed, as in the mysql
+// client's SELECT @@version_comment LIMIT 1.
+func (s *session) scalarPlan(tokens []wiresql.Token) (*plan, error) {
+	limit := -1
+	for i := len(tokens) - 1; i >= 0; i-- {
+		t := tokens[i]
+		switch {
+		case t.Depth == 0 && t.Is("LIMIT"):
+			n, err := strconv.Atoi(wiresql.Describe(tokens[i+1:]))
+			if err != nil {
+				return nil, newError(codeParseError, "syntax error in LIMIT %s", wiresql.Describe(tokens[i+1:]))
+			}
+			limit, tokens = n, tokens[:i]
+		case t.Depth == 0 && t.Is("FROM"):
+			tokens = tokens[:i]
+		}
+	}
+
+	p := &plan{fields: []field{}}
+	var values []func() interface{}
+	for _, item := range wiresql.SplitList(tokens) {
+		expr, alias := selectAlias(item)
+		value, typ, err := s.scalar(expr)
+		if err != nil {
+			return nil, err
+		}
+		name := alias
+		if name == "" && len(expr) > 0 {
+			name = expressionText(expr)
+		}
+		p.fields = append(p.fields, field{name: name, typ: typ})
+		values = append(values, value)
+	}
+	p.run = func() (*outcome, error) {
+		if limit == 0 {
+			return &outcome{}, nil
+		}
+		row := make([]interface{}, len(values))
+		for i, value := range values {
+			row[i] = value()
+		}
+		return &outcome{rows: [][]interface{}{row}}, nil
+	}
+	return p, nil
+}
+
+// selectAlias separates a SELECT item from its alias, with or without AS
+func selectAlias(item []wiresql.Token) ([]wiresql.Token, string) {
+	n := len(item)
+	switch {
+	case n >= 3 && item[n-2].Is("AS"):
+		return item[:n-2], item[n-1].Value()
+	case n >= 2 && (item[n-1].IsName() || item[n-1].Kind == wiresql.String) && item[n-2].Text != "." && item[n-2].Text != "(":
+		return item[:n-1], item[n-1].Value()
+	}
+	return item, ""
+}
+
+// expressionText writes an expression back as MySQL names its column: as
+// written, with the spaces between its tokens
+func expressionText(expr []wiresql.Token) string {
+	var text strings.Builder
+	for i, t := range expr {
+		if i > 0 && t.Start > expr[i-1].End {
+			text.WriteByte(' ')
+		}
+		text.WriteString(t.Text)
+	}
+	return text.String()
+}
+
+// scalar evaluates a SELECT item without FROM, returning its value and type
+func (s *session) scalar(expr []wiresql.Token) (func() interface{}, byte, error) {
+	constant := func(value interface{}, typ byte) (func() interface{}, byte, error) {
+		return func() interface{} { return value }, typ, nil
+	}
+	unsupported := newError(codeNotSupported, "expression %s is not supported without FROM", wiresql.Describe(expr))
+
+	switch {
+	case len(expr) == 2 && expr[0].Text == "-" && expr[1].Kind == wiresql.Number:
+		value, typ, err := s.scalar(expr[1:])
+		if err != nil {
+			return nil, 0, err
+		}
+		switch v := value().(type) {
+		case int64:
+			return constant(-v, typ)
+		case float64:
+			return constant(-v, typ)
+		}
+		return nil, 0, unsupported
+	case len(expr) == 1:
+		switch t := expr[0]; {
+		case t.Kind == wiresql.Number:
+			if n, err := strconv.ParseInt(t.Text, 10, 64); err == nil {
+				return constant(n, typeLongLong)
+			}
+			f, err := strconv.ParseFloat(t.Text, 64)
+			if err != nil {
+				return nil, 0, unsupported
+			}
+			return constant(f, typeDouble)
+		case t.Kind == wiresql.String:
+			return constant(t.Value(), typeVarString)
+		case t.Kind == wiresql.Param:
+			return nil, 0, newError(codeParseError, "parameters are not allowed here")
+		case t.Is("TRUE"):
+			return constant(int64(1), typeLongLong)
+		case t.Is("FALSE"):
+			return constant(int64(0), typeLongLong)
+		case t.Is("NULL"):
+			return constant(nil, typeNull)
+		case t.Kind == wiresql.Variable && strings.HasPrefix(t.Text, "@@"):
+			name := variableName(t)
+			typ := typeVarString
+			if _, err := strconv.ParseInt(s.variables[name], 10, 64); err == nil {
+				typ = typeLongLong
+			}
+			return func() interface{} {
+				value, ok := s.variables[name]
+				if !ok {
+					// Clients read variables the gateway does not have while
+					// connecting; they are NULL rather than an error
+					return nil
+				}
+				if typ == typeLongLong {
+					n, _ := strconv.ParseInt(value, 10, 64)
+					return n
+				}
+				return value
+			}, typ, nil
+		case t.Kind == wiresql.Variable:
+			name := strings.ToLower(t.Text[1:])
+			return func() interface{} { return s.userVariables[name] }, typeVarString, nil
+		}
+	case len(expr) < 3 || expr[1].Text != "(" || expr[len(expr)-1].Text != ")":
+		return nil, 0, unsupported
+	}
+
+	word := strings.ToLower(expr[0].Text)
+	if len(expr) == 1 {
+		switch word {
+		case "current_user", "current_timestamp", "localtime", "localtimestamp":
+		default:
+			return nil, 0, newError(codeNotSupported, "column '%s' requires a FROM clause", expr[0].Value())
+		}
+	} else if len(expr) > 3 {
+		return nil, 0, unsupported
+	}
+
+	switch word {
+	case "version":
+		return constant(serverVersion, typeVarString)
+	case "database", "schema":
+		return func() interface{} {
+			if s.database == "" {
+				return nil
+			}
+			return s.database
+		}, typeVarString, nil
+	case "user", "session_user", "system_user":
+		return constant(fmt.Sprintf("%s@%s", s.user, s.host), typeVarString)
+	case "current_user":
+		return constant(s.user+"@%", typeVarString)
+	case "connection_id":
+		return constant(int64(s.id), typeLongLong)
+	case "last_insert_id":
+		return constant(int64(0), typeLongLong)
+	case "now", "current_timestamp", "localtime", "localtimestamp", "sysdate", "utc_timestamp":
+		return func() interface{} { return time.Now() }, typeDateTime, nil
+	}
+	return nil, 0, unsupported
+}
+
+// gatewayPlan runs a statement on the gateway's tables, typing the columns
+// from their response schemas
+func (s *session) gatewayPlan(sql string) (*plan, error) {
+	query, tables, err := s.server.backend.Translate(sql)
+	if err != nil {
+		return nil, newError(codeParseError, "%s", err.Error())
+	}
+
+	p := &plan{query: query, tables: tables}
+	if query.QueryType == "SELECT" {
+		table := ""
+		if !query.IsJoin() {
+			table = tables[0].Capability.TableName
+		}
+		types := executor.ColumnTypes(tables, query)
+		p.fields = make([]field, len(query.Columns))
+		for i, column := range query.Columns {
+			p.fields[i] = field{column, columnType(types[column]), table}
+		}
+	}
+	p.run = func() (*outcome, error) {
+		result, err := executor.Execute(tables, query)
+		if err != nil {
+			return nil, newError(codeUnknownError, "query execution failed: %v", err)
+		}
+		if result.Error != "" {
+			return nil, newError(codeUnknownError, "API error: %s", result.Error)
+		}
+		s.warnings = result.Warnings
+
+		switch query.QueryType {
+		case "INSERT", "UPDATE", "DELETE":
+			return &outcome{affected: 1}, nil
+		}
+		return &outcome{rows: wiresql.RecordRows(result.Data, query.Columns)}, nil
+	}
+	return p, nil
+}
+
+// planParams plans a statement before its parameters are bound, inferring
+// their types from the columns they are compared with or assigned to. Those
+// it cannot infer are left untyped.
+func (s *session) planParams(sql string, tokens []wiresql.Token, count int) (*plan, []parser.ColumnType, error) {
+	marked, types := wiresql.MarkParams(sql, tokens, count)
+	p, err := s.plan(marked)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.query != nil {
+		wiresql.InferParams(p.query, p.tables, types)
+	}
+	return p, types, nil
+}
+
+// bindParams replaces each ? with its bound value as a literal
+func bindParams(sql string, values []*paramValue, types []parser.ColumnType) (string, error) {
+	return wiresql.SubstituteParams(sql, dialect.Tokenize(sql), func(n int, _ wiresql.Token) (string, error) {
+		if n > len(values) {
+			return "", newError(codeWrongArguments, "Incorrect arguments to mysqld_stmt_execute")
+		}
+		return bindLiteral(values[n-1], types[n-1], n)
+	})
+}
+
+// bindLiteral writes a parameter value as a SQL literal of the type of the
+// column it meets: NULL, booleans and numbers as they are and everything else
+// quoted
+func bindLiteral(value *paramValue, columnType parser.ColumnType, n int) (string, error) {
+	if value == nil {
+		return "NULL", nil
+	}
+	switch columnType.Type {
+	case "integer", "number":
+		number, ok := wiresql.DecimalLiteral(value.text)
+		if !ok {
+			return "", newError(codeWrongValue, "Incorrect %s value: '%s' for parameter %d", columnType.Type, value.text, n)
+		}
+		return number, nil
+	case "boolean":
+		b, err := wiresql.ParseBool(value.text)
+		if err != nil {
+			return "", newError(codeWrongValue, "Incorrect boolean value: '%s' for parameter %d", value.text, n)
+		}
+		return strings.ToUpper(strconv.FormatBool(b)), nil
+	case "":
+		if value.numeric {
+			number, ok := wiresql.DecimalLiteral(value.text)
+			if !ok {
+				return "", newError(codeWrongValue, "Incorrect number value: '%s' for parameter %d", value.text, n)
+			}
+			return number, nil
+		}
+	}
+	return wiresql.QuoteString(value.text), nil
+}
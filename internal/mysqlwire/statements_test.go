@@ -0,0 +1,53 @@
+package mysqlwire
+
+import (
+	"testing"
+
+	"github.com/simonm/qRest/internal/parser"
+)
+
+func TestBindLiteral(t *testing.T) {
+	integer := parser.ColumnType{Type: "integer"}
+	number := parser.ColumnType{Type: "number"}
+	tests := []struct {
+		value      *paramValue
+		columnType parser.ColumnType
+		want       string
+		err        string
+	}{
+		{value: nil, columnType: integer, want: "NULL"},
+		{value: &paramValue{text: "42"}, columnType: integer, want: "42"},
+		{value: &paramValue{text: "+2.50"}, columnType: number, want: "2.50"},
+		{value: &paramValue{text: "1e-3", numeric: true}, columnType: number, want: "1e-3"},
+		{value: &paramValue{text: "NaN", numeric: true}, columnType: number, err: "Incorrect number value: 'NaN' for parameter 1"},
+		{value: &paramValue{text: "+Inf", numeric: true}, columnType: number, err: "Incorrect number value: '+Inf' for parameter 1"},
+		{value: &paramValue{text: "Infinity"}, columnType: integer, err: "Incorrect integer value: 'Infinity' for parameter 1"},
+		{value: &paramValue{text: "0x10"}, columnType: integer, err: "Incorrect integer value: '0x10' for parameter 1"},
+		{value: &paramValue{text: "1 OR 1=1"}, columnType: integer, err: "Incorrect integer value: '1 OR 1=1' for parameter 1"},
+		{value: &paramValue{text: "7", numeric: true}, want: "7"},
+		{value: &paramValue{text: "NaN", numeric: true}, err: "Incorrect number value: 'NaN' for parameter 1"},
+		{value: &paramValue{text: "1 OR 1=1", numeric: true}, err: "Incorrect number value: '1 OR 1=1' for parameter 1"},
+		{value: &paramValue{text: "NaN"}, want: "'NaN'"},
+		{value: &paramValue{text: "on"}, columnType: parser.ColumnType{Type: "boolean"}, want: "TRUE"},
+		{value: &paramValue{text: "maybe"}, columnType: parser.ColumnType{Type: "boolean"}, err: "Incorrect boolean value: 'maybe' for parameter 1"},
+		{value: &paramValue{text: "it's"}, columnType: parser.ColumnType{Type: "string"}, want: "'it''s'"},
+	}
+
+	for _, tt := range tests {
+		text := "NULL"
+		if tt.value != nil {
+			text = tt.value.text
+		}
+		got, err := bindLiteral(tt.value, tt.columnType, 1)
+		switch {
+		case tt.err != "":
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%q as %q: got error %v, want %q", text, tt.columnType.Type, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("%q as %q: %v", text, tt.columnType.Type, err)
+		case got != tt.want:
+			t.Errorf("%q as %q: got %s, want %s", text, tt.columnType.Type, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package mysqlwire
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/simonm/qRest/internal/parser"
+)
+
+// Column type codes
+const (
+	typeDecimal    byte = 0x00
+	typeTiny       byte = 0x01
+	typeShort      byte = 0x02
+	typeLong       byte = 0x03
+	typeFloat      byte = 0x04
+	typeDouble     byte = 0x05
+	typeNull       byte = 0x06
+	typeTimestamp  byte = 0x07
+	typeLongLong   byte = 0x08
+	typeInt24      byte = 0x09
+	typeDate       byte = 0x0a
+	typeTime       byte = 0x0b
+	typeDateTime   byte = 0x0c
+	typeYear       byte = 0x0d
+	typeVarchar    byte = 0x0f
+	typeBit        byte = 0x10
+	typeJSON       byte = 0xf5
+	typeNewDecimal byte = 0xf6
+	typeEnum       byte = 0xf7
+	typeSet        byte = 0xf8
+	typeTinyBlob   byte = 0xf9
+	typeMediumBlob byte = 0xfa
+	typeLongBlob   byte = 0xfb
+	typeBlob       byte = 0xfc
+	typeVarString  byte = 0xfd
+	typeString     byte = 0xfe
+)
+
+// Character sets of the columns
+const (
+	charsetUTF8MB4 = 45 // utf8mb4_general_ci
+	charsetBinary  = 63
+)
+
+// Column definition flags
+const (
+	flagBlob   = 0x0010
+	flagBinary = 0x0080
+	flagNum    = 0x8000
+)
+
+// dateTimeFormat is how DATETIME values are written in the text protocol,
+// with the six fractional digits the columns declare
+const dateTimeFormat = "2006-01-02 15:04:05.000000"
+
+// columnType maps a response schema type to the MySQL type it is sent as
+func columnType(t parser.ColumnType) byte {
+	switch t.Type {
+	case "integer":
+		if t.Format == "int32" {
+			return typeLong
+		}
+		return typeLongLong
+	case "number":
+		return typeDouble
+	case "boolean":
+		return typeTiny
+	case "array", "object":
+		return typeJSON
+	case "string":
+		switch t.Format {
+		case "date-time":
+			return typeDateTime
+		case "date":
+			return typeDate
+		}
+	}
+	return typeVarString
+}
+
+// columnTypeName is the SQL name DESCRIBE gives a response schema type
+func columnTypeName(t parser.ColumnType) string {
+	switch columnType(t) {
+	case typeLong:
+		return "int"
+	case typeLongLong:
+		return "bigint"
+	case typeDouble:
+		return "double"
+	case typeTiny:
+		return "tinyint(1)"
+	case typeJSON:
+		return "json"
+	case typeDateTime:
+		return "datetime(6)"
+	case typeDate:
+		return "date"
+	}
+	return "text"
+}
+
+// columnInfo gives the display length, character set, flags and decimals of
+// a column type's definition
+func columnInfo(typ byte) (length uint32, charset uint16, flags uint16, decimals byte) {
+	switch typ {
+	case typeTiny:
+		return 1, charsetBinary, flagBinary | flagNum, 0
+	case typeLong:
+		return 11, charsetBinary, flagBinary | flagNum, 0
+	case typeLongLong:
+		return 20, charsetBinary, flagBinary | flagNum, 0
+	case typeDouble:
+		return 22, charsetBinary, flagBinary | flagNum, 31
+	case typeDate:
+		return 10, charsetBinary, flagBinary, 0
+	case typeDateTime:
+		return 26, charsetBinary, flagBinary, 6
+	case typeJSON:
+		return math.MaxUint32, charsetBinary, flagBlob | flagBinary, 0
+	case typeNull:
+		return 0, charsetBinary, flagBinary, 0
+	}
+	return 4 * math.MaxUint16, charsetUTF8MB4, 0, 0
+}
+
+// typedValue converts a decoded JSON value to the Go value of a column's
+// type, reporting false when it does not fit, e.g. an API sending text in a
+// column its schema declares numeric
+func typedValue(value interface{}, typ byte) (interface{}, bool) {
+	switch typ {
+	case typeTiny:
+		switch v := value.(type) {
+		case bool:
+			return v, true
+		case int64:
+			return v != 0, true
+		}
+	case typeLong, typeLongLong:
+		switch v := value.(type) {
+		case float64:
+			if v == math.Trunc(v) {
+				return int64(v), true
+			}
+		case int:
+			return int64(v), true
+		case int64:
+			return v, true
+		case string:
+			n, err := strconv.ParseInt(v, 10, 64)
+			return n, err == nil
+		}
+	case typeDouble:
+		switch v := value.(type) {
+		case float64:
+			return v, true
+		case int:
+			return float64(v), true
+		case int64:
+			return float64(v), true
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			return f, err == nil
+		}
+	case typeDateTime:
+		switch v := value.(type) {
+		case time.Time:
+			return v.UTC(), true
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, v)
+			return t.UTC(), err == nil
+		}
+	case typeDate:
+		switch v := value.(type) {
+		case time.Time:
+			return v, true
+		case string:
+			t, err := time.Parse(time.DateOnly, v)
+			return t, err == nil
+		}
+	case typeJSON:
+		data, err := json.Marshal(value)
+		return data, err == nil
+	default:
+		return textOf(value), true
+	}
+	return nil, false
+}
+
+// textOf formats a value for a text column: objects and arrays as JSON and
+// numbers without exponents
+func textOf(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(dateTimeFormat)
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+// encodeText writes a value as the text protocol sends it; nil is NULL.
+// Values that do not fit the column's type are sent as their text.
+func encodeText(value interface{}, typ byte) []byte {
+	if value == nil {
+		return nil
+	}
+	typed, ok := typedValue(value, typ)
+	if !ok {
+		return []byte(textOf(value))
+	}
+	switch v := typed.(type) {
+	case int64:
+		return strconv.AppendInt(nil, v, 10)
+	case float64:
+		return strconv.AppendFloat(nil, v, 'f', -1, 64)
+	case bool:
+		if v {
+			return []byte("1")
+		}
+		return []byte("0")
+	case time.Time:
+		if typ == typeDate {
+			return []byte(v.Format(time.DateOnly))
+		}
+		return []byte(v.Format(dateTimeFormat))
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	}
+	return []byte(textOf(typed))
+}
+
+// appendBinary appends a value as the binary protocol sends it, reporting
+// false for NULL, which values that do not fit the column's type are sent as
+func appendBinary(b []byte, value interface{}, typ byte) ([]byte, bool) {
+	if value == nil {
+		return b, false
+	}
+	typed, ok := typedValue(value, typ)
+	if !ok {
+		return b, false
+	}
+	switch typ {
+	case typeTiny:
+		if typed.(bool) {
+			return append(b, 1), true
+		}
+		return append(b, 0), true
+	case typeLong:
+		return binary.LittleEndian.AppendUint32(b, uint32(typed.(int64))), true
+	case typeLongLong:
+		return binary.LittleEndian.AppendUint64(b, uint64(typed.(int64))), true
+	case typeDouble:
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(typed.(float64))), true
+	case typeDate:
+		t := typed.(time.Time)
+		b = append(b, 4)
+		b = binary.LittleEndian.AppendUint16(b, uint16(t.Year()))
+		return append(b, byte(t.Month()), byte(t.Day())), true
+	case typeDateTime:
+		t := typed.(time.Time)
+		b = append(b, 11)
+		b = binary.LittleEndian.AppendUint16(b, uint16(t.Year()))
+		b = append(b, byte(t.Month()), byte(t.Day()), byte(t.Hour()), byte(t.Minute()), byte(t.Second()))
+		return binary.LittleEndian.AppendUint32(b, uint32(t.Nanosecond()/1000)), true
+	case typeJSON:
+		data := typed.([]byte)
+		return append(appendLenencInt(b, uint64(len(data))), data...), true
+	}
+	return appendLenencString(b, typed.(string)), true
+}
+
+// paramValue is a parameter of a prepared statement as the client bound it
+type paramValue struct {
+	text    string
+	numeric bool // sent as a number rather than a string
+}
+
+// decodeParam reads a parameter value of the type the client gave it
+func decodeParam(m *message, typ byte, unsigned bool) (paramValue, error) {
+	switch typ {
+	case typeTiny:
+		if unsigned {
+			return number(strconv.FormatUint(uint64(m.byte()), 10)), m.err
+		}
+		return number(strconv.Itoa(int(int8(m.byte())))), m.err
+	case typeShort, typeYear:
+		if unsigned {
+			return number(strconv.FormatUint(uint64(m.uint16()), 10)), m.err
+		}
+		return number(strconv.Itoa(int(int16(m.uint16())))), m.err
+	case typeLong, typeInt24:
+		if unsigned {
+			return number(strconv.FormatUint(uint64(m.uint32()), 10)), m.err
+		}
+		return number(strconv.Itoa(int(int32(m.uint32())))), m.err
+	case typeLongLong:
+		if unsigned {
+			return number(strconv.FormatUint(m.uint64(), 10)), m.err
+		}
+		return number(strconv.FormatInt(int64(m.uint64()), 10)), m.err
+	case typeFloat:
+		return number(strconv.FormatFloat(float64(math.Float32frombits(m.uint32())), 'f', -1, 32)), m.err
+	case typeDouble:
+		return number(strconv.FormatFloat(math.Float64frombits(m.uint64()), 'f', -1, 64)), m.err
+	case typeDate, typeDateTime, typeTimestamp:
+		return decodeDateTime(m, typ)
+	case typeTime:
+		return decodeTime(m)
+	case typeDecimal, typeNewDecimal:
+		text := string(m.lenencBytes())
+		return paramValue{text: text, numeric: true}, m.err
+	case typeVarchar, typeVarString, typeString, typeBlob, typeTinyBlob, typeMediumBlob,
+		typeLongBlob, typeJSON, typeEnum, typeSet, typeBit:
+		return paramValue{text: string(m.lenencBytes())}, m.err
+	}
+	return paramValue{}, newError(codeNotSupported, "parameters of type %d are not supported", typ)
+}
+
+func number(text string) paramValue {
+	return paramValue{text: text, numeric: true}
+}
+
+// decodeDateTime reads a DATE, DATETIME or TIMESTAMP, whose length gives
+// the fields present
+func decodeDateTime(m *message, typ byte) (paramValue, error) {
+	length := m.byte()
+	var year, month, day, hour, minute, second, micro int
+	if length >= 4 {
+		year, month, day = int(m.uint16()), int(m.byte()), int(m.byte())
+	}
+	if length >= 7 {
+		hour, minute, second = int(m.byte()), int(m.byte()), int(m.byte())
+	}
+	if length >= 11 {
+		micro = int(m.uint32())
+	}
+	if m.err != nil {
+		return paramValue{}, m.err
+	}
+	t := time.Date(year, time.Month(month), day, hour, minute, second, micro*1000, time.UTC)
+	if typ == typeDate {
+		return paramValue{text: t.Format(time.DateOnly)}, nil
+	}
+	return paramValue{text: t.Format(time.RFC3339Nano)}, nil
+}
+
+// decodeTime reads a TIME, which may be negative and span days
+func decodeTime(m *message) (paramValue, error) {
+	length := m.byte()
+	var negative bool
+	var days, hours, minutes, seconds, micro int
+	if length >= 8 {
+		negative = m.byte() == 1
+		days, hours, minutes, seconds = int(m.uint32()), int(m.byte()), int(m.byte()), int(m.byte())
+	}
+	if length >= 12 {
+		micro = int(m.uint32())
+	}
+	if m.err != nil {
+		return paramValue{}, m.err
+	}
+	text := fmt.Sprintf("%02d:%02d:%02d", days*24+hours, minutes, seconds)
+	if micro > 0 {
+		text += fmt.Sprintf(".%06d", micro)
+	}
+	if negative {
+		text = "-" + text
+	}
+	return paramValue{text: text}, nil
+}
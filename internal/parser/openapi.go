@@ -1,12 +1,19 @@
 package parser
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/go-openapi/loads"
 	"github.com/go-openapi/spec"
+	"github.com/go-openapi/swag"
 )
 
 type APICapability struct {
@@ -14,22 +21,33 @@ type APICapability struct {
 	Method          string
 	Parameters      []Parameter
 	ResponseColumns []string // Available columns from response schema
+	ColumnTypes     map[string]ColumnType // schema types of the response columns
 	TableName       string
 	BaseURL         string
 	MaxResults      int
 	HasPaging       bool
 	PageParam       string
 	LimitParam      string
+	Security        [][]SecurityScheme // alternatives the operation accepts; none when the spec declares no security
+}
+
+// ColumnType is the type and format the response schema gives a column
+type ColumnType struct {
+	Type     string   // string, integer, number, boolean, array or object; empty when the schema gives none
+	Format   string   // e.g. int64, double or date-time
+	Nullable bool     // the schema allows null, or does not require the property
+	Enum     []string // the values the schema allows, if it lists them
 }
 
 type Parameter struct {
-	Name        string
-	Type        string
-	Location    string // query, path, header
-	Required    bool
-	Format      string
-	Enum        []string
-	Operators   []string // derived from name patterns like "age_gt", "created_at_gte"
+	Name             string
+	Type             string
+	Location         string // query, path, header
+	Required         bool
+	Format           string
+	Enum             []string
+	Operators        []string // derived from name patterns like "age_gt", "created_at_gte"
+	CollectionFormat string   // array serialisation: csv, ssv, tsv, pipes or multi
 }
 
 type OpenAPIParser struct {
@@ -37,19 +55,64 @@ type OpenAPIParser struct {
 	baseURL  string
 	authType string
 	authToken string
+	flattenNested bool
+	securitySchemes map[string]SecurityScheme
 }
 
-func NewOpenAPIParser(specURL, baseURL, authType, authToken string) (*OpenAPIParser, error) {
-	doc, err := loads.Spec(specURL)
+// maxColumnDepth bounds how many levels of nested objects become column paths
+const maxColumnDepth = 4
+
+// NewOpenAPIParser loads a Swagger 2.0 or OpenAPI 3.0/3.1 spec, as JSON or
+// YAML, from an http(s) URL, a file:// URL or a local path. Remote specs go
+// through cache when one is given. Without a baseURL, requests go to the
+// spec's first server (or its host and basePath for Swagger 2.0).
+func NewOpenAPIParser(specURL, baseURL, authType, authToken string, cache *SpecCache) (*OpenAPIParser, error) {
+	raw, err := loadDocument(specURL, cache)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load OpenAPI spec: %w", err)
 	}
+	swaggerVersion, openAPIVersion, err := documentVersion(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load OpenAPI spec: %w", err)
+	}
+
+	var swagger *spec.Swagger
+	var schemes map[string]SecurityScheme
+	switch {
+	case strings.HasPrefix(openAPIVersion, "3."):
+		doc, err := parseOpenAPI3(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load OpenAPI spec: %w", err)
+		}
+		swagger = doc.toSwagger()
+		schemes = doc.securitySchemes()
+		if baseURL == "" {
+			baseURL = doc.serverURL(specURL)
+		}
+	case openAPIVersion != "":
+		return nil, fmt.Errorf("failed to load OpenAPI spec: OpenAPI version %s is not supported", openAPIVersion)
+	default:
+		doc, err := loads.Analyzed(raw, swaggerVersion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load OpenAPI spec: %w", err)
+		}
+		swagger = doc.Spec()
+		schemes = swaggerSecuritySchemes(swagger.SecurityDefinitions)
+		if baseURL == "" {
+			baseURL = swaggerBaseURL(swagger, specURL)
+		}
+	}
+	if baseURL == "" {
+		return nil, fmt.Errorf("no base URL: set base_url or declare servers in the spec")
+	}
+	resolveTokenURLs(schemes, baseURL)
 
 	return &OpenAPIParser{
-		spec:      doc.Spec(),
+		spec:      swagger,
 		baseURL:   baseURL,
 		authType:  authType,
 		authToken: authToken,
+		securitySchemes: schemes,
 	}, nil
 }
 
@@ -134,18 +197,22 @@ func (p *OpenAPIParser) parseOperation(path string, method string, operation *sp
 				Required: param.Required,
 				Format:   param.Format,
 			}
+			if param.Type == "array" {
+				parameter.CollectionFormat = param.CollectionFormat
+				if parameter.CollectionFormat == "" {
+					parameter.CollectionFormat = "csv"
+				}
+			}
 
 			// Detect operators from parameter naming patterns
 			parameter.Operators = p.detectOperators(param.Name, param.Type)
 
-			// Handle enum values
-			if param.Enum != nil {
-				for _, v := range param.Enum {
-					if s, ok := v.(string); ok {
-						parameter.Enum = append(parameter.Enum, s)
-					}
-				}
+			// Handle enum values (for arrays they sit on the item schema)
+			enum := param.Enum
+			if enum == nil && param.Items != nil {
+				enum = param.Items.Enum
 			}
+			parameter.Enum = enumValues(enum)
 
 			capability.Parameters = append(capability.Parameters, parameter)
 
@@ -169,7 +236,8 @@ func (p *OpenAPIParser) parseOperation(path string, method string, operation *sp
 	}
 
 	// Parse response schema to extract available columns
-	capability.ResponseColumns = p.extractResponseColumns(operation)
+	capability.ResponseColumns, capability.ColumnTypes = p.extractResponseColumns(operation)
+	capability.Security = p.operationSecurity(operation)
 
 	return capability
 }
@@ -235,10 +303,28 @@ func (p *OpenAPIParser) detectOperators(paramName, paramType string) []string {
 		   strings.Contains(name, "filter") || strings.Contains(name, "name") {
 			operators = append(operators, "LIKE", "ILIKE")
 		}
-		if strings.HasSuffix(name, "_in") {
-			operators = append(operators, "IN")
+	}
+	
+	// List operators: array parameters and "_in"/"_nin" suffixes take several values
+	if paramType == "array" || strings.HasSuffix(name, "_in") {
+		operators = append(operators, "IN")
+	}
+	if strings.HasSuffix(name, "_nin") || strings.HasSuffix(name, "_not_in") {
+		operators = append(operators, "NOT IN")
+	}
+	
+	// Null checks: "_null", "_isnull" or "_is_null" flags answer both IS NULL
+	// and IS NOT NULL; "_notnull" only IS NOT NULL
+	if strings.HasSuffix(name, "_null") || strings.HasSuffix(name, "_isnull") {
+		if strings.HasSuffix(name, "_not_null") {
+			operators = append(operators, "IS NOT NULL")
+		} else {
+			operators = append(operators, "IS NULL", "IS NOT NULL")
 		}
 	}
+	if strings.HasSuffix(name, "_notnull") {
+		operators = append(operators, "IS NOT NULL")
+	}
 	
 	// NOT operator (common pattern)
 	if strings.HasSuffix(name, "_not") || strings.HasSuffix(name, "_ne") {
@@ -267,26 +353,27 @@ func (p *OpenAPIParser) BuildURL(capability APICapability, filters map[string]in
 	return baseURL, nil
 }
 
-func (p *OpenAPIParser) extractResponseColumns(operation *spec.Operation) []string {
+func (p *OpenAPIParser) extractResponseColumns(operation *spec.Operation) ([]string, map[string]ColumnType) {
 	var columns []string
+	types := make(map[string]ColumnType)
 	
 	// Look for 200 response
 	if operation.Responses == nil || operation.Responses.StatusCodeResponses == nil {
-		return columns
+		return columns, types
 	}
 	
 	response, exists := operation.Responses.StatusCodeResponses[200]
 	if !exists || response.Schema == nil {
-		return columns
+		return columns, types
 	}
 	
 	// Extract columns from response schema
-	columns = p.extractColumnsFromSchema(response.Schema)
+	columns = p.extractColumnsFromSchema(response.Schema, types)
 	
-	return columns
+	return columns, types
 }
 
-func (p *OpenAPIParser) extractColumnsFromSchema(schema *spec.Schema) []string {
+func (p *OpenAPIParser) extractColumnsFromSchema(schema *spec.Schema, types map[string]ColumnType) []string {
 	var columns []string
 	
 	if schema == nil {
@@ -296,28 +383,190 @@ func (p *OpenAPIParser) extractColumnsFromSchema(schema *spec.Schema) []string {
 	// Handle array responses (most common for list endpoints)
 	if schema.Type.Contains("array") && schema.Items != nil && schema.Items.Schema != nil {
 		// Extract from array item schema
-		return p.extractColumnsFromSchema(schema.Items.Schema)
+		return p.extractColumnsFromSchema(schema.Items.Schema, types)
 	}
 	
 	// Handle object responses
 	if schema.Type.Contains("object") && schema.Properties != nil {
-		// Extract property names
-		for propName := range schema.Properties {
-			columns = append(columns, propName)
+		// Wrapped list responses, e.g. {"data": [...], "next": "..."}; the
+		// executor reads the records from the same fields
+		for _, field := range []string{"data", "results", "items", "records", "list"} {
+			if prop, exists := schema.Properties[field]; exists && prop.Type.Contains("array") {
+				return p.extractColumnsFromSchema(&prop, types)
+			}
 		}
+		
+		// Extract property names, along with the paths to nested fields
+		columns = p.propertyColumns(schema, "", p.flattenNested, 0, map[string]bool{}, types)
 	}
 	
 	// Handle referenced schemas
 	if schema.Ref.String() != "" {
 		// Try to resolve the reference
 		if resolved := p.resolveSchemaRef(schema.Ref.String()); resolved != nil {
-			return p.extractColumnsFromSchema(resolved)
+			return p.extractColumnsFromSchema(resolved, types)
 		}
 	}
 	
 	return columns
 }
 
+// loadDocument reads a spec from a file or URL, converting YAML to JSON
+func loadDocument(specURL string, cache *SpecCache) (json.RawMessage, error) {
+	var data []byte
+	var err error
+	switch {
+	case !isRemoteURL(specURL):
+		data, err = os.ReadFile(localPath(specURL))
+	case cache != nil:
+		data, err = cache.Load(specURL)
+	default:
+		data, err = swag.LoadFromFileOrHTTP(specURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return trimmed, nil
+	}
+	doc, err := swag.BytesToYAMLDoc(data)
+	if err != nil {
+		return nil, err
+	}
+	return swag.YAMLToJSON(doc)
+}
+
+func isRemoteURL(specURL string) bool {
+	return strings.HasPrefix(specURL, "http://") || strings.HasPrefix(specURL, "https://")
+}
+
+// localPath turns a file:// URL into a path; other specs are paths already
+func localPath(specURL string) string {
+	location, err := url.Parse(specURL)
+	if err != nil || location.Scheme != "file" {
+		return specURL
+	}
+	if location.Host != "" && location.Host != "localhost" {
+		// file://relative/path
+		return filepath.FromSlash(location.Host + location.Path)
+	}
+	return filepath.FromSlash(location.Path)
+}
+
+// SetFlattenNested names the fields of nested objects "parent_child" instead
+// of "parent.child", matching records flattened by the executor. Arrays keep
+// their element paths, e.g. "tags[].name".
+func (p *OpenAPIParser) SetFlattenNested(flatten bool) {
+	p.flattenNested = flatten
+}
+
+// propertyColumns lists the properties of an object schema as columns under
+// prefix. Nested objects add "parent.child" paths (or "parent_child" ones when
+// flattened) and arrays of objects add "parent[].child" paths. refs holds the
+// schemas being expanded, so recursive definitions stop at their first repeat.
+// The type of each column is recorded in types.
+func (p *OpenAPIParser) propertyColumns(schema *spec.Schema, prefix string, flatten bool, depth int, refs map[string]bool, types map[string]ColumnType) []string {
+	var columns []string
+	// Properties come in name order, or as ordered by x-order, so that
+	// SELECT * returns the same columns in the same order every time
+	for _, item := range schema.Properties.ToOrderedSchemaItems() {
+		propName, prop := item.Name, item.Schema
+		column := prefix + propName
+		nested, ref := p.derefSchema(&prop)
+		columnType := propertyType(nested)
+		columnType.Nullable = columnType.Nullable || isNullable(&prop) || !slices.Contains(schema.Required, propName)
+		types[column] = columnType
+		if nested == nil || refs[ref] || depth >= maxColumnDepth {
+			columns = append(columns, column)
+			continue
+		}
+		refs[ref] = ref != ""
+
+		var children []string
+		switch {
+		case nested.Type.Contains("array") && nested.Items != nil && nested.Items.Schema != nil:
+			columns = append(columns, column)
+			if items, itemRef := p.derefSchema(nested.Items.Schema); items != nil && len(items.Properties) > 0 && !refs[itemRef] {
+				refs[itemRef] = itemRef != ""
+				children = p.propertyColumns(items, column+"[].", false, depth+1, refs, types)
+				delete(refs, itemRef)
+			}
+		case len(nested.Properties) > 0 && flatten:
+			children = p.propertyColumns(nested, column+"_", true, depth+1, refs, types)
+		case len(nested.Properties) > 0:
+			columns = append(columns, column)
+			children = p.propertyColumns(nested, column+".", false, depth+1, refs, types)
+		default:
+			columns = append(columns, column)
+		}
+		delete(refs, ref)
+
+		// The fields of an object that may be missing may be missing too
+		if columnType.Nullable {
+			for _, child := range children {
+				childType := types[child]
+				childType.Nullable = true
+				types[child] = childType
+			}
+		}
+		columns = append(columns, children...)
+	}
+	return columns
+}
+
+// propertyType returns a property's type, taking an object for a schema with
+// properties but no type
+func propertyType(schema *spec.Schema) ColumnType {
+	if schema == nil {
+		return ColumnType{Nullable: true}
+	}
+	columnType := ColumnType{
+		Type:     schemaType(schema),
+		Format:   schema.Format,
+		Nullable: isNullable(schema),
+		Enum:     enumValues(schema.Enum),
+	}
+	if columnType.Type == "" && len(schema.Properties) > 0 {
+		columnType.Type = "object"
+	}
+	return columnType
+}
+
+// isNullable reports whether a schema allows null: with nullable (OpenAPI
+// 3.0), a "null" type (3.1) or x-nullable (Swagger 2.0)
+func isNullable(schema *spec.Schema) bool {
+	nullable, _ := schema.Extensions.GetBool("x-nullable")
+	return schema.Nullable || schema.Type.Contains("null") || nullable
+}
+
+// enumValues renders the values of an enum as text, numbers without an
+// exponent; null is left out
+func enumValues(enum []interface{}) []string {
+	var values []string
+	for _, v := range enum {
+		switch v := v.(type) {
+		case nil:
+		case string:
+			values = append(values, v)
+		case float64:
+			values = append(values, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			values = append(values, fmt.Sprint(v))
+		}
+	}
+	return values
+}
+
+// derefSchema follows a $ref, returning the schema and the reference it came
+// from, if any
+func (p *OpenAPIParser) derefSchema(schema *spec.Schema) (*spec.Schema, string) {
+	ref := schema.Ref.String()
+	if ref == "" {
+		return schema, ""
+	}
+	return p.resolveSchemaRef(ref), ref
+}
+
 func (p *OpenAPIParser) resolveSchemaRef(ref string) *spec.Schema {
 	// Simple reference resolution - look in definitions
 	if p.spec.Definitions != nil {
@@ -0,0 +1,398 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// openAPI3Document holds the parts of an OpenAPI 3.0 or 3.1 document that
+// capability discovery reads. It is converted to the Swagger 2.0 model, so
+// the rest of the parser handles both versions alike.
+type openAPI3Document struct {
+	OpenAPI    string                      `json:"openapi"`
+	Servers    []openAPI3Server            `json:"servers"`
+	Paths      map[string]openAPI3PathItem `json:"paths"`
+	Components openAPI3Components          `json:"components"`
+	Security   []map[string][]string       `json:"security"`
+}
+
+type openAPI3Server struct {
+	URL       string `json:"url"`
+	Variables map[string]struct {
+		Default string `json:"default"`
+	} `json:"variables"`
+}
+
+type openAPI3Components struct {
+	Schemas         spec.Definitions                  `json:"schemas"`
+	Parameters      map[string]openAPI3Parameter      `json:"parameters"`
+	RequestBodies   map[string]openAPI3RequestBody    `json:"requestBodies"`
+	Responses       map[string]openAPI3Response       `json:"responses"`
+	SecuritySchemes map[string]openAPI3SecurityScheme `json:"securitySchemes"`
+}
+
+type openAPI3PathItem struct {
+	Parameters []openAPI3Parameter `json:"parameters"`
+	Get        *openAPI3Operation  `json:"get"`
+	Post       *openAPI3Operation  `json:"post"`
+	Put        *openAPI3Operation  `json:"put"`
+	Patch      *openAPI3Operation  `json:"patch"`
+	Delete     *openAPI3Operation  `json:"delete"`
+}
+
+type openAPI3Operation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description"`
+	Tags        []string                    `json:"tags"`
+	Parameters  []openAPI3Parameter         `json:"parameters"`
+	RequestBody *openAPI3RequestBody        `json:"requestBody"`
+	Responses   map[string]openAPI3Response `json:"responses"`
+	Security    []map[string][]string       `json:"security"` // nil inherits the document's
+}
+
+type openAPI3Parameter struct {
+	Ref         string                       `json:"$ref"`
+	Name        string                       `json:"name"`
+	In          string                       `json:"in"`
+	Description string                       `json:"description"`
+	Required    bool                         `json:"required"`
+	Style       string                       `json:"style"`
+	Explode     *bool                        `json:"explode"`
+	Schema      *spec.Schema                 `json:"schema"`
+	Content     map[string]openAPI3MediaType `json:"content"`
+}
+
+type openAPI3RequestBody struct {
+	Ref         string                       `json:"$ref"`
+	Description string                       `json:"description"`
+	Required    bool                         `json:"required"`
+	Content     map[string]openAPI3MediaType `json:"content"`
+}
+
+type openAPI3Response struct {
+	Ref         string                       `json:"$ref"`
+	Description string                       `json:"description"`
+	Content     map[string]openAPI3MediaType `json:"content"`
+}
+
+type openAPI3MediaType struct {
+	Schema *spec.Schema `json:"schema"`
+}
+
+type openAPI3SecurityScheme struct {
+	Ref    string `json:"$ref"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	In     string `json:"in"`
+	Scheme string `json:"scheme"`
+	Flows  map[string]struct {
+		TokenURL string `json:"tokenUrl"`
+	} `json:"flows"`
+}
+
+// parseOpenAPI3 decodes an OpenAPI 3.x document given as JSON
+func parseOpenAPI3(raw json.RawMessage) (*openAPI3Document, error) {
+	var data interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	normalized, err := json.Marshal(normalizeOpenAPI3(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var doc openAPI3Document
+	if err := json.Unmarshal(normalized, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// normalizeOpenAPI3 rewrites the constructs the Swagger 2.0 schema model does
+// not read: schema references move from components to definitions, and the
+// numeric exclusiveMinimum/exclusiveMaximum of 3.1 become a bound plus flag
+func normalizeOpenAPI3(data interface{}) interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = normalizeOpenAPI3(value)
+		}
+		if ref, ok := v["$ref"].(string); ok {
+			v["$ref"] = strings.Replace(ref, "#/components/schemas/", "#/definitions/", 1)
+		}
+		for bound, exclusive := range map[string]string{"minimum": "exclusiveMinimum", "maximum": "exclusiveMaximum"} {
+			if limit, ok := v[exclusive].(float64); ok {
+				v[bound] = limit
+				v[exclusive] = true
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = normalizeOpenAPI3(value)
+		}
+	}
+	return data
+}
+
+// toSwagger converts the document's paths and schemas to the Swagger 2.0 model
+func (d *openAPI3Document) toSwagger() *spec.Swagger {
+	swagger := &spec.Swagger{SwaggerProps: spec.SwaggerProps{
+		Swagger:     "2.0",
+		Definitions: d.Components.Schemas,
+		Paths:       &spec.Paths{Paths: make(map[string]spec.PathItem)},
+		Security:    d.Security,
+	}}
+
+	for path, item := range d.Paths {
+		var pathItem spec.PathItem
+		pathItem.Get = d.operation(item.Get, item.Parameters)
+		pathItem.Post = d.operation(item.Post, item.Parameters)
+		pathItem.Put = d.operation(item.Put, item.Parameters)
+		pathItem.Patch = d.operation(item.Patch, item.Parameters)
+		pathItem.Delete = d.operation(item.Delete, item.Parameters)
+		swagger.Paths.Paths[path] = pathItem
+	}
+	return swagger
+}
+
+// operation converts an operation. Path-level parameters apply unless the
+// operation redefines them, and a JSON request body becomes a body parameter.
+func (d *openAPI3Document) operation(op *openAPI3Operation, shared []openAPI3Parameter) *spec.Operation {
+	if op == nil {
+		return nil
+	}
+
+	operation := &spec.Operation{OperationProps: spec.OperationProps{
+		ID:          op.OperationID,
+		Summary:     op.Summary,
+		Description: op.Description,
+		Tags:        op.Tags,
+		Security:    op.Security,
+		Responses: &spec.Responses{ResponsesProps: spec.ResponsesProps{
+			StatusCodeResponses: make(map[int]spec.Response),
+		}},
+	}}
+
+	defined := make(map[string]bool)
+	for _, param := range op.Parameters {
+		param = d.parameter(param)
+		defined[param.In+":"+param.Name] = true
+		operation.Parameters = append(operation.Parameters, d.swaggerParameter(param))
+	}
+	for _, param := range shared {
+		if param = d.parameter(param); !defined[param.In+":"+param.Name] {
+			operation.Parameters = append(operation.Parameters, d.swaggerParameter(param))
+		}
+	}
+
+	if op.RequestBody != nil {
+		body := *op.RequestBody
+		if body.Ref != "" {
+			body = d.Components.RequestBodies[refName(body.Ref)]
+		}
+		if schema := mediaSchema(body.Content); schema != nil {
+			operation.Parameters = append(operation.Parameters, spec.Parameter{ParamProps: spec.ParamProps{
+				Name:        "body",
+				In:          "body",
+				Description: body.Description,
+				Required:    body.Required,
+				Schema:      schema,
+			}})
+		}
+	}
+
+	for code, response := range op.Responses {
+		if response.Ref != "" {
+			response = d.Components.Responses[refName(response.Ref)]
+		}
+		converted := spec.Response{ResponseProps: spec.ResponseProps{
+			Description: response.Description,
+			Schema:      mediaSchema(response.Content),
+		}}
+		if code == "default" {
+			operation.Responses.Default = &converted
+		} else if status, err := strconv.Atoi(code); err == nil {
+			operation.Responses.StatusCodeResponses[status] = converted
+		}
+	}
+	return operation
+}
+
+// parameter resolves a reference to a parameter in components
+func (d *openAPI3Document) parameter(param openAPI3Parameter) openAPI3Parameter {
+	if param.Ref != "" {
+		return d.Components.Parameters[refName(param.Ref)]
+	}
+	return param
+}
+
+// swaggerParameter moves a parameter's type, format, enum and bounds out of
+// its schema, as Swagger 2.0 declares them on the parameter itself
+func (d *openAPI3Document) swaggerParameter(param openAPI3Parameter) spec.Parameter {
+	converted := spec.Parameter{ParamProps: spec.ParamProps{
+		Name:        param.Name,
+		In:          param.In,
+		Description: param.Description,
+		Required:    param.Required,
+	}}
+
+	schema := param.Schema
+	if schema == nil {
+		schema = mediaSchema(param.Content)
+	}
+	schema = d.schema(schema)
+	if schema == nil {
+		return converted
+	}
+
+	converted.Type = schemaType(schema)
+	converted.Format = schema.Format
+	converted.Default = schema.Default
+	converted.Enum = schema.Enum
+	converted.Minimum = schema.Minimum
+	converted.Maximum = schema.Maximum
+	if converted.Type == "array" {
+		converted.CollectionFormat = collectionFormat(param.Style, param.Explode)
+		if schema.Items != nil {
+			if items := d.schema(schema.Items.Schema); items != nil {
+				converted.Items = spec.NewItems().Typed(schemaType(items), items.Format)
+				converted.Items.Enum = items.Enum
+			}
+		}
+	}
+	return converted
+}
+
+// schema resolves a reference to a schema in components
+func (d *openAPI3Document) schema(schema *spec.Schema) *spec.Schema {
+	if schema == nil || schema.Ref.String() == "" {
+		return schema
+	}
+	if resolved, exists := d.Components.Schemas[refName(schema.Ref.String())]; exists {
+		return &resolved
+	}
+	return nil
+}
+
+// serverURL returns the first server's URL with its variables set to their
+// defaults, resolved against the spec's own URL when relative. A relative URL
+// in a local spec gives no base URL.
+func (d *openAPI3Document) serverURL(specURL string) string {
+	if len(d.Servers) == 0 {
+		return ""
+	}
+	server := d.Servers[0].URL
+	for name, variable := range d.Servers[0].Variables {
+		server = strings.ReplaceAll(server, "{"+name+"}", variable.Default)
+	}
+	if isRemoteURL(specURL) {
+		base, _ := url.Parse(specURL)
+		if ref, err := url.Parse(server); err == nil {
+			server = base.ResolveReference(ref).String()
+		}
+	}
+	if !isRemoteURL(server) {
+		return ""
+	}
+	return strings.TrimSuffix(server, "/")
+}
+
+// swaggerBaseURL builds the base URL of a Swagger 2.0 document from its
+// schemes, host and basePath. A missing host or scheme is taken from the
+// spec's own URL.
+func swaggerBaseURL(swagger *spec.Swagger, specURL string) string {
+	host, scheme := swagger.Host, "https"
+	if len(swagger.Schemes) > 0 {
+		scheme = swagger.Schemes[0]
+	}
+	if isRemoteURL(specURL) {
+		specLocation, _ := url.Parse(specURL)
+		if host == "" {
+			host = specLocation.Host
+		}
+		if len(swagger.Schemes) == 0 {
+			scheme = specLocation.Scheme
+		}
+	}
+	if host == "" {
+		return ""
+	}
+	return strings.TrimSuffix(scheme+"://"+host+swagger.BasePath, "/")
+}
+
+// mediaSchema picks the schema of a JSON media type from a content map,
+// including vendor types such as application/vnd.github+json
+func mediaSchema(content map[string]openAPI3MediaType) *spec.Schema {
+	if media, exists := content["application/json"]; exists && media.Schema != nil {
+		return media.Schema
+	}
+	types := make([]string, 0, len(content))
+	for mediaType := range content {
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+	for _, mediaType := range types {
+		if strings.Contains(mediaType, "json") && content[mediaType].Schema != nil {
+			return content[mediaType].Schema
+		}
+	}
+	if media, exists := content["*/*"]; exists {
+		return media.Schema
+	}
+	return nil
+}
+
+// schemaType returns a schema's type. OpenAPI 3.1 may list several, such as
+// ["string", "null"]; the first that is not null is used.
+func schemaType(schema *spec.Schema) string {
+	for _, t := range schema.Type {
+		if t != "null" {
+			return t
+		}
+	}
+	return ""
+}
+
+// collectionFormat maps an array parameter's style and explode settings to
+// the Swagger 2.0 collection format. Query parameters default to form style,
+// which repeats the parameter unless explode is false.
+func collectionFormat(style string, explode *bool) string {
+	switch style {
+	case "", "form":
+		if explode == nil || *explode {
+			return "multi"
+		}
+		return "csv"
+	case "spaceDelimited":
+		return "ssv"
+	case "pipeDelimited":
+		return "pipes"
+	}
+	return "csv"
+}
+
+// refName returns the component a local reference points to, e.g. "Pet"
+// for "#/components/schemas/Pet"
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+// documentVersion reads the version fields that tell Swagger 2.0 and
+// OpenAPI 3.x documents apart
+func documentVersion(raw json.RawMessage) (string, string, error) {
+	var version struct {
+		Swagger string `json:"swagger"`
+		OpenAPI string `json:"openapi"`
+	}
+	if err := json.Unmarshal(raw, &version); err != nil {
+		return "", "", fmt.Errorf("spec is not a valid JSON or YAML document: %w", err)
+	}
+	return version.Swagger, version.OpenAPI, nil
+}
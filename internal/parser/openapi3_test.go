@@ -0,0 +1,237 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-openapi/spec"
+)
+
+const petsSpec31 = `openapi: 3.1.0
+servers:
+  - url: https://{region}.example.com/v1/
+    variables:
+      region: {default: eu}
+paths:
+  /pets:
+    parameters:
+      - $ref: '#/components/parameters/Limit'
+      - {name: status, in: query, schema: {type: string}}
+    get:
+      parameters:
+        - name: status
+          in: query
+          required: true
+          schema: {type: string, enum: [available, sold]}
+        - name: tags
+          in: query
+          style: pipeDelimited
+          schema: {type: array, items: {type: string}}
+        - name: ids
+          in: query
+          explode: false
+          schema: {type: array, items: {type: integer}}
+      responses:
+        '200':
+          $ref: '#/components/responses/Pets'
+    post:
+      requestBody:
+        $ref: '#/components/requestBodies/Pet'
+      responses:
+        '200':
+          description: created
+          content:
+            application/vnd.pets+json:
+              schema: {$ref: '#/components/schemas/Pet'}
+components:
+  parameters:
+    Limit: {name: limit, in: query, schema: {type: integer, maximum: 100}}
+  schemas:
+    Pet:
+      type: object
+      required: [id]
+      properties:
+        id: {type: integer, format: int64}
+        name: {type: [string, 'null']}
+        price: {type: number, exclusiveMinimum: 0}
+  responses:
+    Pets:
+      description: pets
+      content:
+        application/json:
+          schema: {type: array, items: {$ref: '#/components/schemas/Pet'}}
+  requestBodies:
+    Pet:
+      required: true
+      content:
+        application/json:
+          schema: {$ref: '#/components/schemas/Pet'}
+`
+
+func writeSpec(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func parseSpec(t *testing.T, path string) map[string]APICapability {
+	t.Helper()
+	p, err := NewOpenAPIParser(path, "", "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	capabilities, err := p.ParseCapabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	byOperation := make(map[string]APICapability)
+	for _, capability := range capabilities {
+		byOperation[capability.Method+" "+capability.Path] = capability
+	}
+	return byOperation
+}
+
+func TestOpenAPI3Capabilities(t *testing.T) {
+	capabilities := parseSpec(t, writeSpec(t, "pets.yaml", petsSpec31))
+
+	get, ok := capabilities["GET /pets"]
+	if !ok {
+		t.Fatalf("no GET /pets in %v", capabilities)
+	}
+	if get.BaseURL != "https://eu.example.com/v1" {
+		t.Errorf("got base URL %q, want https://eu.example.com/v1", get.BaseURL)
+	}
+
+	// The operation's status replaces the path's, and the path's limit is
+	// resolved from components
+	type param struct {
+		name, typ, format string
+		required          bool
+		enum              []string
+	}
+	var params []param
+	for _, p := range get.Parameters {
+		params = append(params, param{p.Name, p.Type, p.CollectionFormat, p.Required, p.Enum})
+	}
+	wantParams := []param{
+		{"status", "string", "", true, []string{"available", "sold"}},
+		{"tags", "array", "pipes", false, nil},
+		{"ids", "array", "csv", false, nil},
+		{"limit", "integer", "", false, nil},
+	}
+	if !reflect.DeepEqual(params, wantParams) {
+		t.Errorf("got parameters %+v, want %+v", params, wantParams)
+	}
+	if get.MaxResults != 100 {
+		t.Errorf("got max results %d, want 100", get.MaxResults)
+	}
+
+	wantTypes := map[string]ColumnType{
+		"id":    {Type: "integer", Format: "int64"},
+		"name":  {Type: "string", Nullable: true},
+		"price": {Type: "number", Nullable: true},
+	}
+	if !reflect.DeepEqual(get.ResponseColumns, []string{"id", "name", "price"}) {
+		t.Errorf("got columns %v, want id, name and price", get.ResponseColumns)
+	}
+	if !reflect.DeepEqual(get.ColumnTypes, wantTypes) {
+		t.Errorf("got column types %+v, want %+v", get.ColumnTypes, wantTypes)
+	}
+
+	// The response comes from a vendor JSON media type
+	post, ok := capabilities["POST /pets"]
+	if !ok {
+		t.Fatalf("no POST /pets in %v", capabilities)
+	}
+	if !reflect.DeepEqual(post.ResponseColumns, []string{"id", "name", "price"}) {
+		t.Errorf("got POST columns %v, want id, name and price", post.ResponseColumns)
+	}
+}
+
+func TestOpenAPI3RequestBody(t *testing.T) {
+	raw, err := loadDocument(writeSpec(t, "pets.yaml", petsSpec31), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := parseOpenAPI3(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := doc.toSwagger().Paths.Paths["/pets"].Post
+	if post == nil {
+		t.Fatal("no POST /pets")
+	}
+	var body *spec.Parameter
+	for i, param := range post.Parameters {
+		if param.In == "body" {
+			body = &post.Parameters[i]
+		}
+	}
+	if body == nil || !body.Required || body.Schema == nil || body.Schema.Ref.String() != "#/definitions/Pet" {
+		t.Errorf("got body parameter %+v, want a required reference to #/definitions/Pet", body)
+	}
+}
+
+func TestOpenAPI3Versions(t *testing.T) {
+	tests := []struct {
+		name, spec, err string
+	}{
+		{"3.0 as JSON", `{"openapi": "3.0.0", "servers": [{"url": "https://api.example.com"}], "paths": {}}`, ""},
+		{"unsupported version", `{"openapi": "4.0.0", "paths": {}}`, "OpenAPI version 4.0.0 is not supported"},
+		{"no servers", `{"openapi": "3.0.0", "paths": {}}`, "no base URL"},
+		{"relative server in a local spec", `{"openapi": "3.0.0", "servers": [{"url": "/v1"}], "paths": {}}`, "no base URL"},
+	}
+	for _, tt := range tests {
+		_, err := NewOpenAPIParser(writeSpec(t, "spec.json", tt.spec), "", "", "", nil)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: got error %v, want none", tt.name, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestServerURL(t *testing.T) {
+	tests := []struct {
+		server, specURL, want string
+	}{
+		{"https://api.example.com/", "pets.yaml", "https://api.example.com"},
+		{"/v2", "https://example.com/specs/openapi.json", "https://example.com/v2"},
+		{"v2", "https://example.com/specs/openapi.json", "https://example.com/specs/v2"},
+		{"/v2", "pets.yaml", ""},
+	}
+	for _, tt := range tests {
+		doc := &openAPI3Document{Servers: []openAPI3Server{{URL: tt.server}}}
+		if got := doc.serverURL(tt.specURL); got != tt.want {
+			t.Errorf("serverURL(%q) for %s = %q, want %q", tt.server, tt.specURL, got, tt.want)
+		}
+	}
+}
+
+func TestCollectionFormat(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		style   string
+		explode *bool
+		want    string
+	}{
+		{"", nil, "multi"},
+		{"form", &yes, "multi"},
+		{"form", &no, "csv"},
+		{"spaceDelimited", nil, "ssv"},
+		{"pipeDelimited", nil, "pipes"},
+		{"simple", nil, "csv"},
+	}
+	for _, tt := range tests {
+		if got := collectionFormat(tt.style, tt.explode); got != tt.want {
+			t.Errorf("collectionFormat(%q, %v) = %q, want %q", tt.style, tt.explode, got, tt.want)
+		}
+	}
+}
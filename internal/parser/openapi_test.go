@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const petsSpec = `{
+  "openapi": "3.0.3",
+  "servers": [{"url": "https://api.example.com"}],
+  "paths": {
+    "/pets": {
+      "get": {
+        "responses": {
+          "200": {
+            "description": "pets",
+            "content": {"application/json": {"schema": {"type": "array", "items": {
+              "type": "object",
+              "properties": {
+                "status": {"type": "string"},
+                "name": {"type": "string"},
+                "id": {"type": "integer"},
+                "category": {"type": "object", "properties": {"title": {"type": "string"}, "code": {"type": "string"}}},
+                "tags": {"type": "array", "items": {"type": "object", "properties": {"label": {"type": "string"}, "id": {"type": "integer"}}}}
+              }
+            }}}}
+          }
+        }
+      }
+    }
+  }
+}`
+
+func TestResponseColumnOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pets.json")
+	if err := os.WriteFile(path, []byte(petsSpec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"category", "category.code", "category.title", "id", "name", "status",
+		"tags", "tags[].id", "tags[].label",
+	}
+
+	// Map iteration differs from run to run, so the order is checked on
+	// several parses
+	for i := 0; i < 10; i++ {
+		p, err := NewOpenAPIParser(path, "", "", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		capabilities, err := p.ParseCapabilities()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(capabilities) != 1 {
+			t.Fatalf("got %d capabilities, want 1", len(capabilities))
+		}
+		if columns := capabilities[0].ResponseColumns; !reflect.DeepEqual(columns, want) {
+			t.Fatalf("got columns %v, want %v", columns, want)
+		}
+	}
+}
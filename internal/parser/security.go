@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// SecurityScheme is a way of authenticating that the spec declares, as an
+// operation requires it
+type SecurityScheme struct {
+	Name      string   // key in securityDefinitions or components.securitySchemes
+	Type      string   // apiKey, http, oauth2 or openIdConnect
+	Scheme    string   // basic or bearer, for http
+	In        string   // header, query or cookie, for apiKey
+	ParamName string   // header or query parameter carrying the key, for apiKey
+	TokenURL  string   // token endpoint, for oauth2
+	Scopes    []string // oauth2 scopes the operation requires
+}
+
+// swaggerSecuritySchemes reads the securityDefinitions of a Swagger 2.0
+// document, whose basic type is an http scheme in OpenAPI 3
+func swaggerSecuritySchemes(definitions spec.SecurityDefinitions) map[string]SecurityScheme {
+	schemes := make(map[string]SecurityScheme)
+	for name, definition := range definitions {
+		if definition == nil {
+			continue
+		}
+		scheme := SecurityScheme{
+			Name:      name,
+			Type:      definition.Type,
+			In:        definition.In,
+			ParamName: definition.Name,
+			TokenURL:  definition.TokenURL,
+		}
+		if definition.Type == "basic" {
+			scheme.Type, scheme.Scheme = "http", "basic"
+		}
+		schemes[name] = scheme
+	}
+	return schemes
+}
+
+// securitySchemes reads the document's components.securitySchemes. Of the
+// OAuth2 flows, client credentials is preferred for its token URL, since it
+// needs no user; the refresh token grant works at any of them.
+func (d *openAPI3Document) securitySchemes() map[string]SecurityScheme {
+	schemes := make(map[string]SecurityScheme)
+	for name, definition := range d.Components.SecuritySchemes {
+		if definition.Ref != "" {
+			definition = d.Components.SecuritySchemes[refName(definition.Ref)]
+		}
+		scheme := SecurityScheme{
+			Name:      name,
+			Type:      definition.Type,
+			Scheme:    strings.ToLower(definition.Scheme),
+			In:        definition.In,
+			ParamName: definition.Name,
+		}
+		for _, flow := range []string{"clientCredentials", "authorizationCode", "password"} {
+			if tokenURL := definition.Flows[flow].TokenURL; tokenURL != "" {
+				scheme.TokenURL = tokenURL
+				break
+			}
+		}
+		schemes[name] = scheme
+	}
+	return schemes
+}
+
+// operationSecurity returns the alternatives an operation accepts, each a set
+// of schemes used together. An operation without its own requirements has the
+// document's; an empty alternative means it can be called anonymously.
+func (p *OpenAPIParser) operationSecurity(operation *spec.Operation) [][]SecurityScheme {
+	requirements := operation.Security
+	if requirements == nil {
+		requirements = p.spec.Security
+	}
+
+	var security [][]SecurityScheme
+	for _, requirement := range requirements {
+		names := make([]string, 0, len(requirement))
+		for name := range requirement {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		alternative := make([]SecurityScheme, 0, len(names))
+		for _, name := range names {
+			scheme, exists := p.securitySchemes[name]
+			if !exists {
+				// An undeclared scheme cannot be satisfied, but is shown as required
+				scheme = SecurityScheme{Name: name}
+			}
+			scheme.Scopes = requirement[name]
+			alternative = append(alternative, scheme)
+		}
+		security = append(security, alternative)
+	}
+	return security
+}
+
+// resolveTokenURLs resolves relative OAuth2 token URLs against the base URL
+func resolveTokenURLs(schemes map[string]SecurityScheme, baseURL string) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return
+	}
+	for name, scheme := range schemes {
+		if scheme.TokenURL == "" {
+			continue
+		}
+		if ref, err := url.Parse(scheme.TokenURL); err == nil && !ref.IsAbs() {
+			scheme.TokenURL = base.ResolveReference(ref).String()
+			schemes[name] = scheme
+		}
+	}
+}
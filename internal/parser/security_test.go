@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+const securedSpec = `{
+  "swagger": "2.0",
+  "host": "api.example.com",
+  "basePath": "/v1",
+  "securityDefinitions": {
+    "key": {"type": "apiKey", "in": "query", "name": "api_key"},
+    "basic": {"type": "basic"},
+    "oauth": {"type": "oauth2", "flow": "application", "tokenUrl": "/oauth/token", "scopes": {"read": "", "write": ""}}
+  },
+  "security": [{"key": []}],
+  "paths": {
+    "/pets": {
+      "get": {"responses": {"200": {"description": "pets"}}},
+      "post": {
+        "security": [{"oauth": ["write"], "key": []}, {"basic": []}],
+        "responses": {"200": {"description": "pet"}}
+      }
+    },
+    "/health": {
+      "get": {"security": [], "responses": {"200": {"description": "ok"}}}
+    }
+  }
+}`
+
+func TestOperationSecurity(t *testing.T) {
+	capabilities := parseSpec(t, writeSpec(t, "secured.json", securedSpec))
+
+	key := SecurityScheme{Name: "key", Type: "apiKey", In: "query", ParamName: "api_key", Scopes: []string{}}
+	oauth := SecurityScheme{Name: "oauth", Type: "oauth2", TokenURL: "https://api.example.com/oauth/token", Scopes: []string{"write"}}
+	basic := SecurityScheme{Name: "basic", Type: "http", Scheme: "basic", Scopes: []string{}}
+	tests := []struct {
+		operation string
+		want      [][]SecurityScheme
+	}{
+		{"GET /pets", [][]SecurityScheme{{key}}},
+		{"POST /pets", [][]SecurityScheme{{key, oauth}, {basic}}},
+		{"GET /health", nil},
+	}
+	for _, tt := range tests {
+		capability, ok := capabilities[tt.operation]
+		if !ok {
+			t.Fatalf("no %s in %v", tt.operation, capabilities)
+		}
+		if !reflect.DeepEqual(capability.Security, tt.want) {
+			t.Errorf("%s: got security %+v, want %+v", tt.operation, capability.Security, tt.want)
+		}
+	}
+}
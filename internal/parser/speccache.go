@@ -0,0 +1,181 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SpecCache keeps downloaded specs on disk so that they load without
+// reaching the spec host. Documents are stored under the SHA-256 of their
+// content, and an index maps each spec URL to its document along with the
+// ETag and Last-Modified validators it was served with.
+type SpecCache struct {
+	dir     string
+	offline bool
+	client  *http.Client
+}
+
+// specCacheEntry is the index record of one spec URL
+type specCacheEntry struct {
+	URL          string    `json:"url"`
+	Digest       string    `json:"digest"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+func NewSpecCache(dir string) *SpecCache {
+	return &SpecCache{
+		dir: dir,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// SetOffline makes the cache serve specs from disk only, never revalidating
+func (c *SpecCache) SetOffline(offline bool) {
+	c.offline = offline
+}
+
+// Load returns the spec at specURL. A cached copy is revalidated with a
+// conditional request; offline, or when the spec host cannot be reached, it
+// is used as it is.
+func (c *SpecCache) Load(specURL string) ([]byte, error) {
+	entry, cached := c.lookup(specURL)
+	if c.offline {
+		if !cached {
+			return nil, fmt.Errorf("spec %s is not cached; load it once without --offline", specURL)
+		}
+		return c.read(entry)
+	}
+
+	req, err := http.NewRequest("GET", specURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cached {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if cached {
+			return c.read(entry)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached:
+		return c.read(entry)
+	case resp.StatusCode >= 400:
+		if cached {
+			return c.read(entry)
+		}
+		return nil, fmt.Errorf("could not access document at %q [%s]", specURL, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	// A cache that cannot be written only means the spec is fetched again
+	// next time
+	c.store(specCacheEntry{
+		URL:          specURL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now().UTC(),
+	}, data)
+	return data, nil
+}
+
+// lookup reads the index record of a spec URL
+func (c *SpecCache) lookup(specURL string) (specCacheEntry, bool) {
+	var entry specCacheEntry
+	data, err := os.ReadFile(c.indexPath(specURL))
+	if err != nil || json.Unmarshal(data, &entry) != nil || entry.URL != specURL {
+		return entry, false
+	}
+	if _, err := os.Stat(c.documentPath(entry.Digest)); err != nil {
+		return entry, false
+	}
+	return entry, true
+}
+
+// read returns a cached document, checking it against its digest
+func (c *SpecCache) read(entry specCacheEntry) ([]byte, error) {
+	data, err := os.ReadFile(c.documentPath(entry.Digest))
+	if err != nil {
+		return nil, err
+	}
+	if digest(data) != entry.Digest {
+		return nil, fmt.Errorf("cached spec for %s is corrupt; load it again without --offline", entry.URL)
+	}
+	return data, nil
+}
+
+// store saves a document and points the URL's index record at it
+func (c *SpecCache) store(entry specCacheEntry, data []byte) error {
+	entry.Digest = digest(data)
+	if _, err := os.Stat(c.documentPath(entry.Digest)); errors.Is(err, fs.ErrNotExist) {
+		if err := writeFileAtomic(c.documentPath(entry.Digest), data); err != nil {
+			return err
+		}
+	}
+	index, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.indexPath(entry.URL), index)
+}
+
+func (c *SpecCache) documentPath(digest string) string {
+	return filepath.Join(c.dir, "documents", digest)
+}
+
+func (c *SpecCache) indexPath(specURL string) string {
+	return filepath.Join(c.dir, "index", digest([]byte(specURL))+".json")
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeFileAtomic writes through a temporary file so that concurrent runs
+// never read a partial document
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/simonm/qRest/internal/apitest"
+)
+
+// specHost serves a spec from /spec.json with an ETag naming its version,
+// answering 304 to a request that already has it and status instead when
+// set. It records each request as its path and If-None-Match header.
+type specHost struct {
+	*apitest.Server
+
+	mu      sync.Mutex
+	version string
+	status  int
+}
+
+func newSpecHost(t *testing.T) *specHost {
+	host := &specHost{version: "1"}
+	host.Server = apitest.New(t, func(r *http.Request) string {
+		return r.URL.Path + " " + r.Header.Get("If-None-Match")
+	}, host.serve)
+	return host
+}
+
+func (h *specHost) serve(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.status != 0 {
+		w.WriteHeader(h.status)
+		return
+	}
+	etag := `"v` + h.version + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Write([]byte(`{"swagger": "2.0", "info": {"version": "` + h.version + `"}}`))
+}
+
+func (h *specHost) set(version string, status int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.version, h.status = version, status
+}
+
+func TestSpecCacheLoad(t *testing.T) {
+	host := newSpecHost(t)
+	specURL := host.URL + "/spec.json"
+	cache := NewSpecCache(t.TempDir())
+
+	load := func(want string) {
+		t.Helper()
+		data, err := cache.Load(specURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), `"version": "`+want+`"`) {
+			t.Fatalf("got spec %s, want version %s", data, want)
+		}
+	}
+
+	load("1")
+	load("1") // revalidated
+	host.set("2", 0)
+	load("2")
+	host.set("3", http.StatusInternalServerError)
+	load("2") // the host fails, so the cached copy is used
+
+	cache.SetOffline(true)
+	load("2")
+	cache.SetOffline(false)
+
+	want := []string{"/spec.json ", `/spec.json "v1"`, `/spec.json "v1"`, `/spec.json "v2"`}
+	if requests := host.Requests(); !reflect.DeepEqual(requests, want) {
+		t.Errorf("got requests %q, want %q", requests, want)
+	}
+
+	host.Close()
+	load("2") // the host is down
+}
+
+func TestSpecCacheErrors(t *testing.T) {
+	host := newSpecHost(t)
+	specURL := host.URL + "/spec.json"
+
+	offline := NewSpecCache(t.TempDir())
+	offline.SetOffline(true)
+	if _, err := offline.Load(specURL); err == nil || !strings.Contains(err.Error(), "is not cached") {
+		t.Errorf("offline without a cached copy: got error %v", err)
+	}
+
+	host.set("1", http.StatusNotFound)
+	if _, err := NewSpecCache(t.TempDir()).Load(specURL); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("missing spec: got error %v", err)
+	}
+
+	// A document that no longer matches its digest is not served
+	host.set("1", 0)
+	cache := NewSpecCache(t.TempDir())
+	if _, err := cache.Load(specURL); err != nil {
+		t.Fatal(err)
+	}
+	entry, cached := cache.lookup(specURL)
+	if !cached {
+		t.Fatal("spec was not cached")
+	}
+	if err := os.WriteFile(cache.documentPath(entry.Digest), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cache.SetOffline(true)
+	if _, err := cache.Load(specURL); err == nil || !strings.Contains(err.Error(), "is corrupt") {
+		t.Errorf("corrupt spec: got error %v", err)
+	}
+}
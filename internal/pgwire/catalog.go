@@ -0,0 +1,325 @@
+package pgwire
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/simonm/qRest/internal/executor"
+	"github.com/simonm/qRest/internal/grammar"
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/translator"
+	"github.com/simonm/qRest/internal/wiresql"
+)
+
+// OIDs of the catalog's objects. The gateway's tables are numbered from
+// firstTableOID in name order.
+const (
+	ownerOID             = 10
+	pgCatalogOID         = 11
+	publicOID            = 2200
+	informationSchemaOID = 13000
+	databaseOID          = 16383
+	firstTableOID        = 16384
+)
+
+var namespaces = []struct {
+	oid  int64
+	name string
+}{
+	{pgCatalogOID, "pg_catalog"},
+	{publicOID, "public"},
+	{informationSchemaOID, "information_schema"},
+}
+
+// catalogColumn is a column of an emulated system table
+type catalogColumn struct {
+	name string
+	oid  uint32
+}
+
+// catalogTables are the system tables clients read to list the tables and
+// their columns, by qualified name. Each has the columns clients commonly
+// select; rows are built from the gateway's tables when queried.
+var catalogTables = map[string][]catalogColumn{
+	"information_schema.schemata": {
+		{"catalog_name", oidText}, {"schema_name", oidText}, {"schema_owner", oidText},
+	},
+	"information_schema.tables": {
+		{"table_catalog", oidText}, {"table_schema", oidText}, {"table_name", oidText}, {"table_type", oidText},
+	},
+	"information_schema.columns": {
+		{"table_catalog", oidText}, {"table_schema", oidText}, {"table_name", oidText}, {"column_name", oidText},
+		{"ordinal_position", oidInt4}, {"column_default", oidText}, {"is_nullable", oidText},
+		{"data_type", oidText}, {"udt_name", oidText},
+	},
+	"pg_catalog.pg_namespace": {
+		{"oid", oidOID}, {"nspname", oidText}, {"nspowner", oidOID},
+	},
+	"pg_catalog.pg_database": {
+		{"oid", oidOID}, {"datname", oidText}, {"datdba", oidOID}, {"encoding", oidInt4},
+	},
+	"pg_catalog.pg_tables": {
+		{"schemaname", oidText}, {"tablename", oidText}, {"tableowner", oidText}, {"tablespace", oidText},
+		{"hasindexes", oidBool}, {"hasrules", oidBool}, {"hastriggers", oidBool}, {"rowsecurity", oidBool},
+	},
+	"pg_catalog.pg_class": {
+		{"oid", oidOID}, {"relname", oidText}, {"relnamespace", oidOID}, {"relkind", oidText},
+		{"relowner", oidOID}, {"relnatts", oidInt2}, {"relhasindex", oidBool}, {"relpersistence", oidText},
+	},
+	"pg_catalog.pg_attribute": {
+		{"attrelid", oidOID}, {"attname", oidText}, {"atttypid", oidOID}, {"attlen", oidInt2},
+		{"attnum", oidInt2}, {"atttypmod", oidInt4}, {"attnotnull", oidBool}, {"atthasdef", oidBool},
+		{"attisdropped", oidBool},
+	},
+	"pg_catalog.pg_type": {
+		{"oid", oidOID}, {"typname", oidText}, {"typnamespace", oidOID}, {"typlen", oidInt2},
+		{"typtype", oidText}, {"typbasetype", oidOID},
+	},
+}
+
+// catalogName returns the qualified name of the system table a statement
+// names; pg_catalog's tables may be named without their schema. The
+// gateway's own tables take precedence.
+func catalogName(name string, tables map[string]parser.APICapability) (string, bool) {
+	if _, ok := tables[name]; ok {
+		return "", false
+	}
+	name = strings.ToLower(name)
+	if _, ok := catalogTables[name]; ok {
+		return name, true
+	}
+	if _, ok := catalogTables["pg_catalog."+name]; ok {
+		return "pg_catalog." + name, true
+	}
+	return "", false
+}
+
+// catalogPlan answers a SELECT on a system table, evaluated in memory like
+// a join. It returns no plan for statements on the gateway's tables.
+func (s *session) catalogPlan(sql string) (*plan, error) {
+	names, err := translator.ExtractTableNames(sql)
+	if err != nil {
+		return nil, nil
+	}
+	tables := s.server.backend.Tables()
+	var written, name string
+	for _, n := range names {
+		if qualified, ok := catalogName(n, tables); ok {
+			written, name = n, qualified
+			break
+		}
+	}
+	if name == "" {
+		return nil, nil
+	}
+	if len(names) > 1 {
+		return nil, newError(codeFeatureNotSupported, "joins with catalog table %s are not supported", written)
+	}
+
+	// The table is validated against a grammar of its columns, with no limit
+	// on the rows returned
+	capability := parser.APICapability{TableName: written, ColumnTypes: make(map[string]parser.ColumnType)}
+	oids := make(map[string]uint32)
+	for _, column := range catalogTables[name] {
+		capability.ResponseColumns = append(capability.ResponseColumns, column.name)
+		capability.ColumnTypes[column.name] = schemaType(column.oid)
+		oids[column.name] = column.oid
+	}
+	tableGrammar := grammar.NewGrammarGenerator().GenerateGrammar(capability)
+	tableGrammar.Limit = grammar.LimitGrammar{MaxLimit: math.MaxInt32}
+	query, err := translator.NewSimpleSQLTranslator(tableGrammar).ParseSQL(sql)
+	if err != nil {
+		return nil, newError(codeSyntaxError, "%s", err.Error())
+	}
+	if query.QueryType != "SELECT" {
+		return nil, newError(codeFeatureNotSupported, "catalog table %s is read-only", written)
+	}
+
+	joinTables := []executor.JoinTable{{Capability: capability}}
+	types := executor.ColumnTypes(joinTables, query)
+	p := &plan{query: query, tables: joinTables, fields: make([]field, len(query.Columns))}
+	for i, column := range query.Columns {
+		oid, ok := oids[column]
+		if !ok {
+			oid = columnOID(types[column])
+		}
+		p.fields[i] = field{column, oid}
+	}
+	p.run = func() (*outcome, error) {
+		result := executor.QueryRecords(s.catalogRecords(name), query)
+		return &outcome{rows: wiresql.RecordRows(result.Data, query.Columns), tag: "SELECT " + strconv.Itoa(len(result.Data))}, nil
+	}
+	return p, nil
+}
+
+// schemaType is the response schema type of a catalog column, which types
+// the aggregates computed over it
+func schemaType(oid uint32) parser.ColumnType {
+	switch oid {
+	case oidInt2, oidInt4, oidOID:
+		return parser.ColumnType{Type: "integer", Format: "int32"}
+	case oidBool:
+		return parser.ColumnType{Type: "boolean"}
+	}
+	return parser.ColumnType{Type: "string"}
+}
+
+// catalogRecords builds the rows of a system table from the gateway's tables
+func (s *session) catalogRecords(name string) []map[string]interface{} {
+	tables := s.server.backend.Tables()
+	tableNames := make([]string, 0, len(tables))
+	for table := range tables {
+		tableNames = append(tableNames, table)
+	}
+	sort.Strings(tableNames)
+	database, owner := s.params["database"], s.params["user"]
+
+	columns := catalogTables[name]
+	var records []map[string]interface{}
+	add := func(values ...interface{}) {
+		record := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			record[column.name] = values[i]
+		}
+		records = append(records, record)
+	}
+
+	switch name {
+	case "information_schema.schemata":
+		for _, namespace := range namespaces {
+			add(database, namespace.name, owner)
+		}
+	case "pg_catalog.pg_namespace":
+		for _, namespace := range namespaces {
+			add(namespace.oid, namespace.name, int64(ownerOID))
+		}
+	case "pg_catalog.pg_database":
+		add(int64(databaseOID), database, int64(ownerOID), int64(6)) // 6 is UTF8
+	case "pg_catalog.pg_type":
+		oids := make([]uint32, 0, len(pgTypes))
+		for oid := range pgTypes {
+			oids = append(oids, oid)
+		}
+		sort.Slice(oids, func(i, j int) bool { return oids[i] < oids[j] })
+		for _, oid := range oids {
+			t := pgTypes[oid]
+			add(int64(oid), t.name, int64(pgCatalogOID), int64(t.size), "b", int64(0))
+		}
+	}
+
+	for i, table := range tableNames {
+		capability := tables[table]
+		tableOID := int64(firstTableOID + i)
+		switch name {
+		case "information_schema.tables":
+			add(database, "public", table, "BASE TABLE")
+		case "pg_catalog.pg_tables":
+			add("public", table, owner, nil, false, false, false, false)
+		case "pg_catalog.pg_class":
+			add(tableOID, table, int64(publicOID), "r", int64(ownerOID), int64(len(capability.ResponseColumns)), false, "p")
+		}
+		for j, column := range capability.ResponseColumns {
+			columnType := capability.ColumnTypes[column]
+			oid := columnOID(columnType)
+			t := pgTypes[oid]
+			notNull := columnType.Type != "" && !columnType.Nullable
+			switch name {
+			case "information_schema.columns":
+				isNullable := "YES"
+				if notNull {
+					isNullable = "NO"
+				}
+				add(database, "public", table, column, int64(j+1), nil, isNullable, t.dataType, t.name)
+			case "pg_catalog.pg_attribute":
+				add(tableOID, column, int64(oid), int64(t.size), int64(j+1), int64(-1), notNull, false, false)
+			}
+		}
+	}
+	return records
+}
+
+// isListRelations recognizes the query psql sends for \d and \dt, which
+// labels its columns "Schema", "Name", "Type" and "Owner"
+func isListRelations(sql string) bool {
+	return strings.Contains(sql, `"Schema"`) && strings.Contains(sql, `"Name"`) && strings.Contains(sql, "pg_catalog.pg_class")
+}
+
+// listRelationsPlan answers psql's \d and \dt with the gateway's tables. It
+// returns the columns the query labels, applying the name patterns it
+// matches with OPERATOR(pg_catalog.~) and the relation kinds it lists.
+func (s *session) listRelationsPlan(tokens []wiresql.Token) *plan {
+	p := &plan{fields: []field{}}
+	var patterns []*regexp.Regexp
+	listsTables := true
+	for i, t := range tokens {
+		switch {
+		case i > 0 && t.Depth == 0 && t.Kind == wiresql.Ident && tokens[i-1].Is("AS"):
+			p.fields = append(p.fields, field{t.Value(), oidText})
+		case t.Is("OPERATOR") && i > 0 && tokens[i-1].Is("relname"):
+			for _, next := range tokens[i+1:] {
+				if next.Kind == wiresql.String {
+					if pattern, err := regexp.Compile(next.Value()); err == nil {
+						patterns = append(patterns, pattern)
+					}
+					break
+				}
+			}
+		case t.Is("relkind") && i+2 < len(tokens) && tokens[i+1].Is("IN"):
+			listsTables = false
+			for _, kind := range tokens[i+3:] {
+				if kind.Text == ")" {
+					break
+				}
+				if kind.Kind == wiresql.String && kind.Value() == "r" {
+					listsTables = true
+				}
+			}
+		}
+	}
+
+	p.run = func() (*outcome, error) {
+		out := &outcome{}
+		if listsTables {
+			var names []string
+			for name := range s.server.backend.Tables() {
+				if matchesAll(patterns, name) {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				row := make([]interface{}, len(p.fields))
+				for i, f := range p.fields {
+					switch f.name {
+					case "Schema":
+						row[i] = "public"
+					case "Name":
+						row[i] = name
+					case "Type":
+						row[i] = "table"
+					case "Owner":
+						row[i] = s.params["user"]
+					case "Persistence":
+						row[i] = "permanent"
+					}
+				}
+				out.rows = append(out.rows, row)
+			}
+		}
+		out.tag = "SELECT " + strconv.Itoa(len(out.rows))
+		return out, nil
+	}
+	return p
+}
+
+func matchesAll(patterns []*regexp.Regexp, name string) bool {
+	for _, pattern := range patterns {
+		if !pattern.MatchString(name) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,311 @@
+package pgwire
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxMessageSize bounds the messages a client may send
+const maxMessageSize = 64 << 20
+
+// Startup request codes, sent in place of a protocol version
+const (
+	protocolVersion3 = 196608
+	cancelRequest    = 80877102
+	sslRequest       = 80877103
+	gssEncRequest    = 80877104
+)
+
+// Error codes of the errors the listener reports
+const (
+	codeProtocolViolation   = "08P01"
+	codeInvalidPassword     = "28P01"
+	codeSyntaxError         = "42601"
+	codeUndefinedObject     = "42704"
+	codeUndefinedStatement  = "26000"
+	codeUndefinedPortal     = "34000"
+	codeFeatureNotSupported = "0A000"
+	codeInvalidParameter    = "22023"
+	codeInvalidText         = "22P02"
+	codeFailedTransaction   = "25P02"
+	codeInternalError       = "XX000"
+)
+
+// pgError is an error reported to the client with its SQLSTATE code
+type pgError struct {
+	code    string
+	message string
+}
+
+func (e *pgError) Error() string { return e.message }
+
+func newError(code, format string, args ...interface{}) *pgError {
+	return &pgError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// errorCode returns the SQLSTATE of an error, internal_error for those not
+// raised as a pgError
+func errorCode(err error) string {
+	var pgErr *pgError
+	if errors.As(err, &pgErr) {
+		return pgErr.code
+	}
+	return codeInternalError
+}
+
+// readMessage reads a typed message: its type byte and its body
+func readMessage(r *bufio.Reader) (byte, []byte, error) {
+	msgType, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	body, err := readBody(r)
+	return msgType, body, err
+}
+
+// readStartup reads the untyped first message of a connection
+func readStartup(r *bufio.Reader) ([]byte, error) {
+	return readBody(r)
+}
+
+func readBody(r *bufio.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint32(header[:]))
+	if length < 4 || length > maxMessageSize {
+		return nil, newError(codeProtocolViolation, "invalid message length %d", length)
+	}
+	body := make([]byte, length-4)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// message reads the fields of a message body in order; a read past its end
+// sets err and returns zero values
+type message struct {
+	data []byte
+	err  error
+}
+
+func (m *message) take(n int) []byte {
+	if m.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(m.data) {
+		m.err = newError(codeProtocolViolation, "message is shorter than its fields")
+		return nil
+	}
+	field := m.data[:n]
+	m.data = m.data[n:]
+	return field
+}
+
+func (m *message) byte() byte {
+	if field := m.take(1); field != nil {
+		return field[0]
+	}
+	return 0
+}
+
+func (m *message) int16() int {
+	if field := m.take(2); field != nil {
+		return int(int16(binary.BigEndian.Uint16(field)))
+	}
+	return 0
+}
+
+func (m *message) int32() int {
+	if field := m.take(4); field != nil {
+		return int(int32(binary.BigEndian.Uint32(field)))
+	}
+	return 0
+}
+
+// count reads the int16 length of a list of fields
+func (m *message) count() int {
+	n := m.int16()
+	if n < 0 && m.err == nil {
+		m.err = newError(codeProtocolViolation, "invalid field count %d", n)
+	}
+	if m.err != nil {
+		return 0
+	}
+	return n
+}
+
+func (m *message) string() string {
+	if m.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(m.data, 0)
+	if end < 0 {
+		m.err = newError(codeProtocolViolation, "unterminated string in message")
+		return ""
+	}
+	s := string(m.data[:end])
+	m.data = m.data[end+1:]
+	return s
+}
+
+// writer buffers the messages sent to a client until flushed. The first
+// write error sticks and is returned by flush.
+type writer struct {
+	w   *bufio.Writer
+	buf []byte
+	err error
+}
+
+// begin starts a message of a type; end writes it out
+func (w *writer) begin(msgType byte) {
+	w.buf = append(w.buf[:0], msgType, 0, 0, 0, 0)
+}
+
+func (w *writer) int16(n int) {
+	w.buf = binary.BigEndian.AppendUint16(w.buf, uint16(n))
+}
+
+func (w *writer) int32(n int) {
+	w.buf = binary.BigEndian.AppendUint32(w.buf, uint32(n))
+}
+
+func (w *writer) string(s string) {
+	w.buf = append(append(w.buf, s...), 0)
+}
+
+func (w *writer) end() {
+	if w.err != nil {
+		return
+	}
+	binary.BigEndian.PutUint32(w.buf[1:5], uint32(len(w.buf)-1))
+	_, w.err = w.w.Write(w.buf)
+}
+
+// raw writes bytes outside any message, such as the answer to an SSL request
+func (w *writer) raw(b []byte) {
+	if w.err == nil {
+		_, w.err = w.w.Write(b)
+	}
+}
+
+func (w *writer) flush() error {
+	if w.err == nil {
+		w.err = w.w.Flush()
+	}
+	return w.err
+}
+
+// simple writes a message that carries no fields
+func (w *writer) simple(msgType byte) {
+	w.begin(msgType)
+	w.end()
+}
+
+func (w *writer) authentication(kind int) {
+	w.begin('R')
+	w.int32(kind)
+	w.end()
+}
+
+func (w *writer) parameterStatus(name, value string) {
+	w.begin('S')
+	w.string(name)
+	w.string(value)
+	w.end()
+}
+
+func (w *writer) backendKeyData(pid, secret int) {
+	w.begin('K')
+	w.int32(pid)
+	w.int32(secret)
+	w.end()
+}
+
+func (w *writer) readyForQuery(status byte) {
+	w.begin('Z')
+	w.buf = append(w.buf, status)
+	w.end()
+}
+
+func (w *writer) commandComplete(tag string) {
+	w.begin('C')
+	w.string(tag)
+	w.end()
+}
+
+// errorResponse reports an error; severity is ERROR, or FATAL when the
+// connection is closed after it
+func (w *writer) errorResponse(severity string, err error) {
+	w.notice('E', severity, errorCode(err), err.Error())
+}
+
+// noticeResponse passes a warning on to the client
+func (w *writer) noticeResponse(message string) {
+	w.notice('N', "WARNING", "01000", message)
+}
+
+func (w *writer) notice(msgType byte, severity, code, message string) {
+	w.begin(msgType)
+	w.buf = append(w.buf, 'S')
+	w.string(severity)
+	w.buf = append(w.buf, 'V')
+	w.string(severity)
+	w.buf = append(w.buf, 'C')
+	w.string(code)
+	w.buf = append(w.buf, 'M')
+	w.string(message)
+	w.buf = append(w.buf, 0)
+	w.end()
+}
+
+// rowDescription describes the fields of the rows to come, each sent in
+// the format its code gives
+func (w *writer) rowDescription(fields []field, formats []int16) {
+	w.begin('T')
+	w.int16(len(fields))
+	for i, f := range fields {
+		size := int16(-1)
+		if t, ok := pgTypes[f.oid]; ok {
+			size = t.size
+		}
+		w.string(f.name)
+		w.int32(0) // table OID
+		w.int16(0) // column number
+		w.int32(int(f.oid))
+		w.int16(int(size))
+		w.int32(-1) // type modifier
+		w.int16(int(formats[i]))
+	}
+	w.end()
+}
+
+func (w *writer) parameterDescription(oids []uint32) {
+	w.begin('t')
+	w.int16(len(oids))
+	for _, oid := range oids {
+		w.int32(int(oid))
+	}
+	w.end()
+}
+
+// dataRow sends a row of encoded values; nil is NULL
+func (w *writer) dataRow(values [][]byte) {
+	w.begin('D')
+	w.int16(len(values))
+	for _, value := range values {
+		if value == nil {
+			w.int32(-1)
+			continue
+		}
+		w.int32(len(value))
+		w.buf = append(w.buf, value...)
+	}
+	w.end()
+}
@@ -0,0 +1,179 @@
+// Package pgwire serves the gateway's tables over the PostgreSQL wire
+// protocol, so that psql, JDBC and BI tools can query them. It speaks the
+// simple and extended query protocols, types columns from the response
+// schemas, and emulates enough of pg_catalog and information_schema for
+// clients to list the tables and their columns.
+package pgwire
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"sync/atomic"
+
+	"github.com/simonm/qRest/internal/executor"
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/translator"
+)
+
+// serverVersion is the Postgres version reported to clients, which some use
+// to pick the catalog queries they send
+const serverVersion = "14.0"
+
+// Backend is the gateway whose tables the listener serves
+type Backend interface {
+	// Tables returns the capability of each table statements can name
+	Tables() map[string]parser.APICapability
+	// Translate resolves the tables a statement names and parses it against
+	// their grammars. The tables follow the sources of a join.
+	Translate(sql string) (*translator.ParsedQuery, []executor.JoinTable, error)
+}
+
+// Server accepts Postgres connections for a backend
+type Server struct {
+	backend  Backend
+	password string
+	nextPID  atomic.Int32
+}
+
+// NewServer returns a server for backend that, when password is set, requires
+// clients to send it in cleartext
+func NewServer(backend Backend, password string) *Server {
+	return &Server{backend: backend, password: password}
+}
+
+// ListenAndServe accepts connections on addr until the listener fails
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on listener, serving each on its own goroutine
+func (s *Server) Serve(listener net.Listener) error {
+	defer listener.Close()
+	for {
+		netConn, err := listener.Accept()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+		go s.serveConn(netConn)
+	}
+}
+
+func (s *Server) serveConn(netConn net.Conn) {
+	defer netConn.Close()
+	sess := &session{
+		server: s,
+		r:      bufio.NewReader(netConn),
+		w:      &writer{w: bufio.NewWriter(netConn)},
+		params: defaultParameters(),
+		status: 'I',
+	}
+	if err := sess.startup(); err != nil {
+		if !errors.Is(err, io.EOF) && !errors.Is(err, errClosed) {
+			log.Printf("postgres: %s: %v", netConn.RemoteAddr(), err)
+		}
+		return
+	}
+	if err := sess.serve(); err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, errClosed) {
+		log.Printf("postgres: %s: %v", netConn.RemoteAddr(), err)
+	}
+}
+
+// errClosed ends a connection the client or the handshake closed cleanly
+var errClosed = errors.New("connection closed")
+
+// startup answers SSL and GSS encryption requests with "no", checks the
+// password, and reports the session parameters
+func (s *session) startup() error {
+	for {
+		body, err := readStartup(s.r)
+		if err != nil {
+			return err
+		}
+		m := &message{data: body}
+		code := m.int32()
+		switch code {
+		case sslRequest, gssEncRequest:
+			s.w.raw([]byte{'N'})
+			if err := s.w.flush(); err != nil {
+				return err
+			}
+			continue
+		case cancelRequest:
+			// Statements run to completion; there is nothing to cancel
+			return errClosed
+		case protocolVersion3:
+		default:
+			s.w.errorResponse("FATAL", newError(codeFeatureNotSupported, "unsupported frontend protocol %d.%d", code>>16, code&0xffff))
+			s.w.flush()
+			return errClosed
+		}
+
+		for {
+			name := m.string()
+			if name == "" || m.err != nil {
+				break
+			}
+			value := m.string()
+			switch name {
+			case "user", "database", "application_name", "client_encoding", "DateStyle", "TimeZone", "search_path":
+				s.params[name] = value
+			}
+		}
+		if m.err != nil {
+			return m.err
+		}
+		break
+	}
+	if s.params["database"] == "" {
+		s.params["database"] = s.params["user"]
+	}
+	s.params["session_authorization"] = s.params["user"]
+
+	if s.server.password != "" {
+		if err := s.authenticate(); err != nil {
+			return err
+		}
+	}
+
+	s.pid = int(s.server.nextPID.Add(1))
+	s.w.authentication(0)
+	for _, name := range reportedParameters {
+		s.w.parameterStatus(name, s.params[name])
+	}
+	s.w.backendKeyData(s.pid, rand.Int())
+	s.w.readyForQuery(s.status)
+	return s.w.flush()
+}
+
+// authenticate asks for the password in cleartext and checks it
+func (s *session) authenticate() error {
+	s.w.authentication(3)
+	if err := s.w.flush(); err != nil {
+		return err
+	}
+	msgType, body, err := readMessage(s.r)
+	if err != nil {
+		return err
+	}
+	m := &message{data: body}
+	password := m.string()
+	if msgType != 'p' || m.err != nil || subtle.ConstantTimeCompare([]byte(password), []byte(s.server.password)) != 1 {
+		s.w.errorResponse("FATAL", newError(codeInvalidPassword, "password authentication failed for user \"%s\"", s.params["user"]))
+		s.w.flush()
+		return errClosed
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+package pgwire
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simonm/qRest/internal/apitest"
+	"github.com/simonm/qRest/internal/wiretest"
+)
+
+// client speaks the frontend side of the protocol, summarising each message
+// the server sends as a line of text, e.g. "D 1,Rex" for a data row
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dial serves a pets API on a new listener and connects to it
+func dial(t *testing.T, password string) (*client, *apitest.Server) {
+	api := apitest.NewPets(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go NewServer(wiretest.NewBackend(api), password).Serve(listener)
+	t.Cleanup(func() { listener.Close() })
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	t.Cleanup(func() { conn.Close() })
+	return &client{t: t, conn: conn, r: bufio.NewReader(conn)}, api
+}
+
+// connect dials and completes the startup of a session without a password
+func connect(t *testing.T) (*client, *apitest.Server) {
+	c, api := dial(t, "")
+	c.startup("user", "alice")
+	c.replies()
+	return c, api
+}
+
+func (c *client) write(b []byte) {
+	if _, err := c.conn.Write(b); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// startup sends the startup message with the given name and value pairs
+func (c *client) startup(params ...string) {
+	body := i32(protocolVersion3)
+	for _, param := range params {
+		body = append(body, cstring(param)...)
+	}
+	body = append(body, 0)
+	c.write(append(i32(len(body)+4), body...))
+}
+
+// receive reads a message and summarises it
+func (c *client) receive() string {
+	msgType, body, err := readMessage(c.r)
+	if err != nil {
+		c.t.Fatalf("reading reply: %v", err)
+	}
+	m := &message{data: body}
+	switch msgType {
+	case 'R':
+		return fmt.Sprintf("R %d", m.int32())
+	case 'S':
+		return "S " + m.string() + "=" + m.string()
+	case 'Z':
+		return "Z " + string(m.byte())
+	case 'C':
+		return "C " + m.string()
+	case 'E', 'N':
+		fields := make(map[byte]string)
+		for code := m.byte(); code != 0 && m.err == nil; code = m.byte() {
+			fields[code] = m.string()
+		}
+		return string(msgType) + " " + fields['C'] + " " + fields['M']
+	case 'T':
+		columns := make([]string, m.count())
+		for i := range columns {
+			name := m.string()
+			m.take(6)
+			oid := m.int32()
+			m.take(8)
+			columns[i] = fmt.Sprintf("%s:%d", name, oid)
+		}
+		return "T " + strings.Join(columns, " ")
+	case 't':
+		oids := make([]string, m.count())
+		for i := range oids {
+			oids[i] = fmt.Sprint(m.int32())
+		}
+		return "t " + strings.Join(oids, ",")
+	case 'D':
+		values := make([]string, m.count())
+		for i := range values {
+			if length := m.int32(); length < 0 {
+				values[i] = "NULL"
+			} else {
+				values[i] = string(m.take(length))
+			}
+		}
+		return "D " + strings.Join(values, ",")
+	}
+	return string(msgType)
+}
+
+// replies reads messages up to and including ReadyForQuery
+func (c *client) replies() []string {
+	var replies []string
+	for {
+		reply := c.receive()
+		replies = append(replies, reply)
+		if strings.HasPrefix(reply, "Z ") {
+			return replies
+		}
+	}
+}
+
+func i16(n int) []byte { return binary.BigEndian.AppendUint16(nil, uint16(n)) }
+
+func i32(n int) []byte { return binary.BigEndian.AppendUint32(nil, uint32(n)) }
+
+func cstring(s string) []byte { return append([]byte(s), 0) }
+
+// msg encodes a typed message from its fields
+func msg(msgType byte, fields ...[]byte) []byte {
+	var body []byte
+	for _, f := range fields {
+		body = append(body, f...)
+	}
+	return append(append([]byte{msgType}, i32(len(body)+4)...), body...)
+}
+
+func query(sql string) []byte { return msg('Q', cstring(sql)) }
+
+func parse(name, sql string) []byte { return msg('P', cstring(name), cstring(sql), i16(0)) }
+
+// bind binds the unnamed portal with text results; values are sent in
+// the given formats, nil as NULL
+func bind(statement string, formats []int, values ...[]byte) []byte {
+	fields := [][]byte{cstring(""), cstring(statement), i16(len(formats))}
+	for _, format := range formats {
+		fields = append(fields, i16(format))
+	}
+	fields = append(fields, i16(len(values)))
+	for _, value := range values {
+		if value == nil {
+			fields = append(fields, i32(-1))
+			continue
+		}
+		fields = append(fields, i32(len(value)), value)
+	}
+	return msg('B', append(fields, i16(0))...)
+}
+
+func describe(kind byte, name string) []byte { return msg('D', []byte{kind}, cstring(name)) }
+
+func execute(maxRows int) []byte { return msg('E', cstring(""), i32(maxRows)) }
+
+func syncMsg() []byte { return msg('S') }
+
+func TestStartup(t *testing.T) {
+	t.Run("no password", func(t *testing.T) {
+		c, _ := dial(t, "")
+		c.startup("user", "alice", "application_name", "psql")
+		replies := c.replies()
+		if replies[0] != "R 0" || replies[len(replies)-1] != "Z I" {
+			t.Errorf("got replies %q, want AuthenticationOk first and ReadyForQuery last", replies)
+		}
+		for _, want := range []string{"S server_version=14.0", "S application_name=psql", "S session_authorization=alice", "K"} {
+			if !contains(replies, want) {
+				t.Errorf("got replies %q, want %q among them", replies, want)
+			}
+		}
+	})
+
+	t.Run("SSL refused", func(t *testing.T) {
+		c, _ := dial(t, "")
+		c.write(append(i32(8), i32(sslRequest)...))
+		if answer, err := c.r.ReadByte(); err != nil || answer != 'N' {
+			t.Fatalf("got answer %q, %v, want 'N'", answer, err)
+		}
+		c.startup("user", "alice")
+		if replies := c.replies(); replies[0] != "R 0" {
+			t.Errorf("got replies %q, want AuthenticationOk", replies)
+		}
+	})
+
+	t.Run("password", func(t *testing.T) {
+		c, _ := dial(t, "secret")
+		c.startup("user", "alice")
+		if reply := c.receive(); reply != "R 3" {
+			t.Fatalf("got %q, want AuthenticationCleartextPassword", reply)
+		}
+		c.write(msg('p', cstring("secret")))
+		if reply := c.receive(); reply != "R 0" {
+			t.Errorf("got %q, want AuthenticationOk", reply)
+		}
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		c, _ := dial(t, "secret")
+		c.startup("user", "alice")
+		c.receive()
+		c.write(msg('p', cstring("guess")))
+		if reply, want := c.receive(), `E 28P01 password authentication failed for user "alice"`; reply != want {
+			t.Errorf("got %q, want %q", reply, want)
+		}
+		if _, err := c.r.ReadByte(); err != io.EOF {
+			t.Errorf("got %v after the failed password, want the connection closed", err)
+		}
+	})
+}
+
+func TestSimpleQuery(t *testing.T) {
+	tests := []struct {
+		sql      string
+		replies  []string
+		requests []string
+	}{
+		{
+			sql:      "SELECT id, name FROM pets WHERE status = 'sold'",
+			replies:  []string{"T id:20 name:25", "D 1,Rex", "D 3,Tom", "C SELECT 2", "Z I"},
+			requests: []string{"/pets?status=sold"},
+		},
+		{
+			sql: "SELECT * FROM public.pets WHERE id > 1 ORDER BY name",
+			replies: []string{
+				"T id:20 name:25 status:25",
+				"N 01000 condition id > 1 evaluated locally: the API has no matching parameter",
+				"N 01000 ORDER BY name ASC sorted locally: the API has no sort parameter",
+				"D 2,Bo,available", "D 3,Tom,sold", "C SELECT 2", "Z I",
+			},
+			requests: []string{"/pets"},
+		},
+		{
+			sql:     "SELECT 1",
+			replies: []string{"T ?column?:23", "D 1", "C SELECT 1", "Z I"},
+		},
+		{
+			sql:     "",
+			replies: []string{"I", "Z I"},
+		},
+		{
+			sql:     "SET application_name = 'report'; SHOW application_name",
+			replies: []string{"S application_name=report", "C SET", "T application_name:25", "D report", "C SHOW", "Z I"},
+		},
+		{
+			sql:     "BEGIN; SELECT nope FROM pets; COMMIT",
+			replies: []string{"C BEGIN", "E 42601 column 'nope' not available. Available columns: [id name status] (line 1, column 8)", "Z E"},
+		},
+		{
+			sql:     "SELECT id FROM pets WHERE id = $1",
+			replies: []string{"T id:20", "E XX000 query execution failed: statement refers to parameter $1; parameters are only bound by prepared statements", "Z I"},
+		},
+		{
+			sql:     "VACUUM pets",
+			replies: []string{"E 0A000 VACUUM statements are not supported", "Z I"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			c, api := connect(t)
+			c.write(query(tt.sql))
+			if replies := c.replies(); !reflect.DeepEqual(replies, tt.replies) {
+				t.Errorf("got replies %q, want %q", replies, tt.replies)
+			}
+			if requests := api.Requests(); !reflect.DeepEqual(requests, tt.requests) {
+				t.Errorf("got requests %q, want %q", requests, tt.requests)
+			}
+		})
+	}
+}
+
+func TestExtendedQuery(t *testing.T) {
+	c, api := connect(t)
+	steps := []struct {
+		name     string
+		messages [][]byte
+		replies  []string
+	}{
+		{
+			name:     "parameter types inferred",
+			messages: [][]byte{parse("pets", "SELECT id, name FROM pets WHERE status = $1 LIMIT $2"), describe('S', "pets"), syncMsg()},
+			replies:  []string{"1", "t 25,20", "T id:20 name:25", "Z I"},
+		},
+		{
+			name:     "bound and run",
+			messages: [][]byte{bind("pets", nil, []byte("sold"), []byte("1")), execute(0), syncMsg()},
+			replies:  []string{"2", "D 1,Rex", "C SELECT 1", "Z I"},
+		},
+		{
+			name:     "rows fetched in batches",
+			messages: [][]byte{bind("pets", nil, []byte("sold"), []byte("5")), execute(1), execute(1), syncMsg()},
+			replies:  []string{"2", "D 1,Rex", "s", "D 3,Tom", "C SELECT 2", "Z I"},
+		},
+		{
+			name:     "binary parameter",
+			messages: [][]byte{bind("pets", []int{0, 1}, []byte("available"), binary.BigEndian.AppendUint64(nil, 5)), execute(0), syncMsg()},
+			replies:  []string{"2", "D 2,Bo", "C SELECT 1", "Z I"},
+		},
+		{
+			name:     "invalid parameter skips to Sync",
+			messages: [][]byte{bind("pets", nil, []byte("sold"), []byte("many")), execute(0), syncMsg()},
+			replies:  []string{`E 22P02 invalid input syntax for type int8: "many"`, "Z I"},
+		},
+		{
+			name:     "unknown statement",
+			messages: [][]byte{bind("nope", nil), execute(0), syncMsg()},
+			replies:  []string{`E 26000 prepared statement "nope" does not exist`, "Z I"},
+		},
+		{
+			name:     "statement without rows",
+			messages: [][]byte{parse("", "BEGIN"), bind("", nil), describe('P', ""), execute(0), syncMsg()},
+			replies:  []string{"1", "2", "n", "C BEGIN", "Z T"},
+		},
+	}
+	for _, step := range steps {
+		for _, m := range step.messages {
+			c.write(m)
+		}
+		if replies := c.replies(); !reflect.DeepEqual(replies, step.replies) {
+			t.Errorf("%s: got replies %q, want %q", step.name, replies, step.replies)
+		}
+	}
+
+	want := []string{"/pets?status=sold", "/pets?status=sold", "/pets?status=available"}
+	if requests := api.Requests(); !reflect.DeepEqual(requests, want) {
+		t.Errorf("got requests %q, want %q", requests, want)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
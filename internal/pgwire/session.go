@@ -0,0 +1,440 @@
+package pgwire
+
+import (
+	"bufio"
+	"strings"
+
+	"github.com/simonm/qRest/internal/executor"
+	"github.com/simonm/qRest/internal/translator"
+	"github.com/simonm/qRest/internal/wiresql"
+)
+
+// parameterDefaults are the session parameters a connection starts with,
+// before those the client sends at startup
+var parameterDefaults = map[string]string{
+	"server_version":              serverVersion,
+	"server_encoding":             "UTF8",
+	"client_encoding":             "UTF8",
+	"DateStyle":                   "ISO, MDY",
+	"TimeZone":                    "UTC",
+	"IntervalStyle":               "postgres",
+	"integer_datetimes":           "on",
+	"standard_conforming_strings": "on",
+	"is_superuser":                "off",
+	"application_name":            "",
+	"search_path":                 `"$user", public`,
+	"transaction_isolation":       "read committed",
+	"transaction_read_only":       "off",
+	"max_identifier_length":       "63",
+}
+
+// reportedParameters are sent to the client at startup and whenever SET
+// changes them
+var reportedParameters = []string{
+	"server_version", "server_encoding", "client_encoding", "DateStyle", "TimeZone", "IntervalStyle",
+	"integer_datetimes", "standard_conforming_strings", "is_superuser", "application_name",
+	"session_authorization",
+}
+
+func defaultParameters() map[string]string {
+	params := make(map[string]string, len(parameterDefaults))
+	for name, value := range parameterDefaults {
+		params[name] = value
+	}
+	return params
+}
+
+// session is the state of one client connection
+type session struct {
+	server *Server
+	r      *bufio.Reader
+	w      *writer
+	params map[string]string
+	status byte // transaction status: I idle, T in a block, E in a failed block
+	pid    int
+
+	defaults   map[string]string // parameters as the startup left them, restored by RESET
+	statements map[string]*statement
+	portals    map[string]*portal
+	skipping   bool // an extended query failed; messages are ignored until Sync
+}
+
+// statement is a statement prepared with Parse
+type statement struct {
+	sql    string
+	params []uint32 // parameter types, given by the client or inferred
+	fields []field
+}
+
+// portal is a statement bound to its parameters. Rows left over by an
+// Execute limited to fewer rows wait here for the next.
+type portal struct {
+	plan    *plan
+	formats []int16 // result format of each field
+	outcome *outcome
+}
+
+// field is a column of a result
+type field struct {
+	name string
+	oid  uint32
+}
+
+// plan is how the listener answers a statement; run carries it out
+type plan struct {
+	fields []field // nil for statements that return no rows
+	empty  bool    // the statement is empty or only a comment
+	run    func() (*outcome, error)
+
+	// The translated statement, from which Parse infers parameter types
+	query  *translator.ParsedQuery
+	tables []executor.JoinTable
+}
+
+// outcome is what a statement returned: its rows, in field order, and the
+// command tag
+type outcome struct {
+	rows [][]interface{}
+	tag  string
+}
+
+// serve reads messages until the client terminates
+func (s *session) serve() error {
+	s.defaults = make(map[string]string, len(s.params))
+	for name, value := range s.params {
+		s.defaults[name] = value
+	}
+	s.statements = make(map[string]*statement)
+	s.portals = make(map[string]*portal)
+
+	for {
+		msgType, body, err := readMessage(s.r)
+		if err != nil {
+			return err
+		}
+		m := &message{data: body}
+		if s.skipping && msgType != 'S' && msgType != 'X' {
+			continue
+		}
+
+		switch msgType {
+		case 'Q':
+			s.simpleQuery(m.string())
+		case 'P':
+			err = s.parse(m)
+		case 'B':
+			err = s.bind(m)
+		case 'D':
+			err = s.describe(m)
+		case 'E':
+			err = s.execute(m)
+		case 'C':
+			err = s.close(m)
+		case 'S':
+			s.skipping = false
+			if s.status == 'I' {
+				s.portals = make(map[string]*portal)
+			}
+			s.w.readyForQuery(s.status)
+		case 'H':
+		case 'X':
+			return errClosed
+		default:
+			s.w.errorResponse("FATAL", newError(codeProtocolViolation, "invalid frontend message type %q", msgType))
+			s.w.flush()
+			return errClosed
+		}
+		if err == nil {
+			err = m.err
+		}
+		if err != nil {
+			s.fail(err)
+			s.skipping = true
+		}
+
+		// Replies to the extended protocol wait for Sync or Flush
+		switch msgType {
+		case 'Q', 'S', 'H':
+			if err := s.w.flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// fail reports an error, aborting the transaction block if one is open
+func (s *session) fail(err error) {
+	s.w.errorResponse("ERROR", err)
+	if s.status == 'T' {
+		s.status = 'E'
+	}
+}
+
+// simpleQuery runs the statements of a Query message in turn, stopping at
+// the first that fails
+func (s *session) simpleQuery(sql string) {
+	statements := dialect.SplitStatements(sql)
+	if len(statements) == 0 {
+		s.w.simple('I') // EmptyQueryResponse
+	}
+	for _, text := range statements {
+		if err := s.runStatement(text); err != nil {
+			s.fail(err)
+			break
+		}
+	}
+	s.w.readyForQuery(s.status)
+}
+
+// runStatement runs a statement of a simple query, describing its rows in
+// text format before sending them
+func (s *session) runStatement(sql string) error {
+	p, err := s.plan(sql)
+	if err != nil {
+		return err
+	}
+	formats := make([]int16, len(p.fields))
+	if p.fields != nil {
+		s.w.rowDescription(p.fields, formats)
+	}
+	out, err := p.run()
+	if err != nil {
+		return err
+	}
+	s.sendRows(out, p.fields, formats, 0)
+	return nil
+}
+
+// sendRows sends up to maxRows rows of an outcome, all of them when maxRows
+// is 0, and completes the command once none are left. It reports whether
+// rows remain.
+func (s *session) sendRows(out *outcome, fields []field, formats []int16, maxRows int) bool {
+	for n := 0; len(out.rows) > 0; n++ {
+		if maxRows > 0 && n == maxRows {
+			return true
+		}
+		row := out.rows[0]
+		out.rows = out.rows[1:]
+		values := make([][]byte, len(row))
+		for i, value := range row {
+			if formats[i] == 1 {
+				values[i] = encodeBinary(value, fields[i].oid)
+			} else {
+				values[i] = encodeText(value, fields[i].oid)
+			}
+		}
+		s.w.dataRow(values)
+	}
+	s.w.commandComplete(out.tag)
+	return false
+}
+
+// parse prepares a statement, inferring the types of the parameters the
+// client leaves unspecified from the columns they are compared with
+func (s *session) parse(m *message) error {
+	name := m.string()
+	sql := m.string()
+	params := make([]uint32, m.count())
+	for i := range params {
+		params[i] = uint32(m.int32())
+	}
+	if m.err != nil {
+		return m.err
+	}
+
+	tokens := dialect.Tokenize(sql)
+	if count := wiresql.CountParams(tokens); count > len(params) {
+		params = append(params, make([]uint32, count-len(params))...)
+	}
+	p, inferred, err := s.planParams(sql, tokens, len(params))
+	if err != nil {
+		return err
+	}
+	for i, oid := range params {
+		if oid == 0 {
+			params[i] = inferred[i]
+		}
+	}
+
+	s.statements[name] = &statement{sql: sql, params: params, fields: p.fields}
+	s.w.simple('1')
+	return nil
+}
+
+// bind binds a prepared statement's parameters, creating a portal
+func (s *session) bind(m *message) error {
+	portalName := m.string()
+	statementName := m.string()
+	paramFormats := make([]int16, m.count())
+	for i := range paramFormats {
+		paramFormats[i] = int16(m.int16())
+	}
+	values := make([][]byte, m.count())
+	for i := range values {
+		if length := m.int32(); length >= 0 {
+			values[i] = m.take(length)
+			if values[i] == nil {
+				values[i] = []byte{}
+			}
+		}
+	}
+	resultFormats := make([]int16, m.count())
+	for i := range resultFormats {
+		resultFormats[i] = int16(m.int16())
+	}
+	if m.err != nil {
+		return m.err
+	}
+
+	stmt, ok := s.statements[statementName]
+	if !ok {
+		return newError(codeUndefinedStatement, "prepared statement \"%s\" does not exist", statementName)
+	}
+	if len(values) != len(stmt.params) {
+		return newError(codeProtocolViolation, "bind message supplies %d parameters, but prepared statement \"%s\" requires %d", len(values), statementName, len(stmt.params))
+	}
+	formatOf, err := formatCodes(paramFormats, len(values))
+	if err != nil {
+		return err
+	}
+	sql, err := bindParams(stmt.sql, values, formatOf, stmt.params)
+	if err != nil {
+		return err
+	}
+	p, err := s.plan(sql)
+	if err != nil {
+		return err
+	}
+	formats, err := formatCodes(resultFormats, len(p.fields))
+	if err != nil {
+		return err
+	}
+
+	s.portals[portalName] = &portal{plan: p, formats: formats}
+	s.w.simple('2')
+	return nil
+}
+
+// formatCodes expands the format codes of a Bind message to one per value:
+// none means text, one applies to every value
+func formatCodes(codes []int16, n int) ([]int16, error) {
+	formats := make([]int16, n)
+	switch len(codes) {
+	case 0:
+	case 1:
+		for i := range formats {
+			formats[i] = codes[0]
+		}
+	case n:
+		copy(formats, codes)
+	default:
+		return nil, newError(codeProtocolViolation, "bind message has %d format codes for %d values", len(codes), n)
+	}
+	for _, format := range formats {
+		if format != 0 && format != 1 {
+			return nil, newError(codeProtocolViolation, "unsupported format code %d", format)
+		}
+	}
+	return formats, nil
+}
+
+func (s *session) describe(m *message) error {
+	kind := m.byte()
+	name := m.string()
+	if m.err != nil {
+		return m.err
+	}
+	switch kind {
+	case 'S':
+		stmt, ok := s.statements[name]
+		if !ok {
+			return newError(codeUndefinedStatement, "prepared statement \"%s\" does not exist", name)
+		}
+		s.w.parameterDescription(stmt.params)
+		s.describeFields(stmt.fields, make([]int16, len(stmt.fields)))
+	case 'P':
+		p, ok := s.portals[name]
+		if !ok {
+			return newError(codeUndefinedPortal, "portal \"%s\" does not exist", name)
+		}
+		s.describeFields(p.plan.fields, p.formats)
+	default:
+		return newError(codeProtocolViolation, "invalid DESCRIBE message subtype %q", kind)
+	}
+	return nil
+}
+
+func (s *session) describeFields(fields []field, formats []int16) {
+	if fields == nil {
+		s.w.simple('n') // NoData
+		return
+	}
+	s.w.rowDescription(fields, formats)
+}
+
+func (s *session) execute(m *message) error {
+	name := m.string()
+	maxRows := m.int32()
+	if m.err != nil {
+		return m.err
+	}
+	p, ok := s.portals[name]
+	if !ok {
+		return newError(codeUndefinedPortal, "portal \"%s\" does not exist", name)
+	}
+	if p.plan.empty {
+		s.w.simple('I')
+		return nil
+	}
+	if p.outcome == nil {
+		out, err := p.plan.run()
+		if err != nil {
+			return err
+		}
+		p.outcome = out
+	}
+	if s.sendRows(p.outcome, p.plan.fields, p.formats, maxRows) {
+		s.w.simple('s') // PortalSuspended
+	}
+	return nil
+}
+
+func (s *session) close(m *message) error {
+	kind := m.byte()
+	name := m.string()
+	if m.err != nil {
+		return m.err
+	}
+	switch kind {
+	case 'S':
+		delete(s.statements, name)
+	case 'P':
+		delete(s.portals, name)
+	default:
+		return newError(codeProtocolViolation, "invalid CLOSE message subtype %q", kind)
+	}
+	s.w.simple('3')
+	return nil
+}
+
+// setParameter changes a session parameter, reporting it to the client when
+// it is one of those reported
+func (s *session) setParameter(name, value string) {
+	s.params[name] = value
+	for _, reported := range reportedParameters {
+		if reported == name {
+			s.w.parameterStatus(name, value)
+		}
+	}
+}
+
+// parameterName returns the canonical spelling of a parameter name, which
+// SET and SHOW match case-insensitively
+func (s *session) parameterName(name string) string {
+	for known := range s.params {
+		if strings.EqualFold(known, name) {
+			return known
+		}
+	}
+	return strings.ToLower(name)
+}
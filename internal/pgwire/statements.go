@@ -0,0 +1,546 @@
+package pgwire
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/simonm/qRest/internal/executor"
+	"github.com/simonm/qRest/internal/wiresql"
+)
+
+// dialect is how Postgres clients write statements: $n parameters and ::
+// casts
+var dialect = wiresql.Dialect{Placeholder: '$', Operator: "::"}
+
+// plan decides how a statement is answered without running it
+func (s *session) plan(sql string) (*plan, error) {
+	tokens := dialect.Tokenize(sql)
+	if len(tokens) == 0 {
+		return &plan{empty: true}, nil
+	}
+	command := strings.ToUpper(tokens[0].Text)
+	if s.status == 'E' && command != "ROLLBACK" && command != "ABORT" && command != "COMMIT" && command != "END" {
+		return nil, newError(codeFailedTransaction, "current transaction is aborted, commands ignored until end of transaction block")
+	}
+
+	switch command {
+	case "BEGIN", "START":
+		return s.transactionPlan("BEGIN", 'T'), nil
+	case "COMMIT", "END":
+		return s.transactionPlan("COMMIT", 'I'), nil
+	case "ROLLBACK", "ABORT":
+		return s.transactionPlan("ROLLBACK", 'I'), nil
+	case "SET":
+		return s.setPlan(tokens[1:])
+	case "SHOW":
+		return s.showPlan(tokens[1:])
+	case "RESET":
+		return s.resetPlan(tokens[1:])
+	case "DISCARD":
+		return s.discardPlan(tokens[1:])
+	case "DEALLOCATE":
+		return s.deallocatePlan(tokens[1:])
+	case "SELECT":
+		if !hasFrom(tokens) {
+			return s.scalarPlan(tokens[1:])
+		}
+		if isListRelations(sql) {
+			return s.listRelationsPlan(tokens), nil
+		}
+		sql = stripSchema(sql, tokens)
+		if p, err := s.catalogPlan(sql); p != nil || err != nil {
+			return p, err
+		}
+		return s.gatewayPlan(sql)
+	case "INSERT", "UPDATE", "DELETE":
+		return s.gatewayPlan(stripSchema(sql, tokens))
+	}
+	return nil, newError(codeFeatureNotSupported, "%s statements are not supported", command)
+}
+
+func hasFrom(tokens []wiresql.Token) bool {
+	for _, t := range tokens {
+		if t.Depth == 0 && t.Is("FROM") {
+			return true
+		}
+	}
+	return false
+}
+
+// stripSchema drops the public schema from qualified names: the gateway's
+// tables all belong to it
+func stripSchema(sql string, tokens []wiresql.Token) string {
+	var stripped strings.Builder
+	last := 0
+	for i := 0; i+1 < len(tokens); i++ {
+		t := tokens[i]
+		if (t.Is("public") || t.Kind == wiresql.Ident && t.Value() == "public") && tokens[i+1].Text == "." {
+			stripped.WriteString(sql[last:t.Start])
+			last = tokens[i+1].End
+		}
+	}
+	stripped.WriteString(sql[last:])
+	return stripped.String()
+}
+
+// commandPlan answers a statement that returns no rows with its tag, after
+// applying its effect on the session
+func commandPlan(tag string, effect func()) *plan {
+	return &plan{run: func() (*outcome, error) {
+		if effect != nil {
+			effect()
+		}
+		return &outcome{tag: tag}, nil
+	}}
+}
+
+// transactionPlan tracks transaction blocks for the status clients see.
+// Statements are not transactional: each is applied when it runs.
+func (s *session) transactionPlan(tag string, status byte) *plan {
+	return &plan{run: func() (*outcome, error) {
+		out := &outcome{tag: tag}
+		if tag == "COMMIT" && s.status == 'E' {
+			out.tag = "ROLLBACK"
+		}
+		s.status = status
+		return out, nil
+	}}
+}
+
+// setPlan handles SET name TO value and its variants
+func (s *session) setPlan(tokens []wiresql.Token) (*plan, error) {
+	if len(tokens) > 0 && (tokens[0].Is("SESSION") || tokens[0].Is("LOCAL")) {
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 0 {
+		return nil, newError(codeSyntaxError, "syntax error in SET")
+	}
+
+	var name string
+	switch {
+	case tokens[0].Is("TRANSACTION") || tokens[0].Is("CHARACTERISTICS"):
+		// Transaction characteristics have no effect on the gateway
+		return commandPlan("SET", nil), nil
+	case len(tokens) > 1 && tokens[0].Is("TIME") && tokens[1].Is("ZONE"):
+		name, tokens = "TimeZone", tokens[2:]
+	default:
+		name = s.parameterName(tokens[0].Value())
+		if len(tokens) < 2 || !tokens[1].Is("TO") && tokens[1].Text != "=" {
+			return nil, newError(codeSyntaxError, "syntax error in SET %s", name)
+		}
+		tokens = tokens[2:]
+	}
+	if len(tokens) == 0 {
+		return nil, newError(codeSyntaxError, "SET %s requires a value", name)
+	}
+
+	value := listValue(tokens)
+	if len(tokens) == 1 && (tokens[0].Is("DEFAULT") || name == "TimeZone" && tokens[0].Is("LOCAL")) {
+		value = s.defaults[name]
+	}
+	if name == "client_encoding" && !strings.EqualFold(value, "UTF8") && !strings.EqualFold(value, "UNICODE") {
+		return nil, newError(codeFeatureNotSupported, "client encoding %s is not supported; use UTF8", value)
+	}
+	return commandPlan("SET", func() { s.setParameter(name, value) }), nil
+}
+
+// listValue joins the comma-separated values of a SET statement
+func listValue(tokens []wiresql.Token) string {
+	var items []string
+	var item []string
+	for _, t := range tokens {
+		if t.Text == "," && t.Depth == 0 {
+			items = append(items, strings.Join(item, " "))
+			item = nil
+			continue
+		}
+		item = append(item, t.Value())
+	}
+	return strings.Join(append(items, strings.Join(item, " ")), ", ")
+}
+
+func (s *session) showPlan(tokens []wiresql.Token) (*plan, error) {
+	var name string
+	switch {
+	case len(tokens) == 1 && tokens[0].Is("ALL"):
+		fields := []field{{"name", oidText}, {"setting", oidText}}
+		return &plan{fields: fields, run: func() (*outcome, error) {
+			names := make([]string, 0, len(s.params))
+			for name := range s.params {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			out := &outcome{tag: "SHOW"}
+			for _, name := range names {
+				out.rows = append(out.rows, []interface{}{name, s.params[name]})
+			}
+			return out, nil
+		}}, nil
+	case len(tokens) == 2 && tokens[0].Is("TIME") && tokens[1].Is("ZONE"):
+		name = "TimeZone"
+	case len(tokens) == 3 && tokens[0].Is("TRANSACTION") && tokens[1].Is("ISOLATION") && tokens[2].Is("LEVEL"):
+		name = "transaction_isolation"
+	case len(tokens) == 1:
+		name = s.parameterName(tokens[0].Value())
+	default:
+		return nil, newError(codeSyntaxError, "syntax error in SHOW %s", wiresql.Describe(tokens))
+	}
+	if _, ok := s.params[name]; !ok {
+		return nil, newError(codeUndefinedObject, "unrecognized configuration parameter \"%s\"", name)
+	}
+	return &plan{fields: []field{{name, oidText}}, run: func() (*outcome, error) {
+		return &outcome{rows: [][]interface{}{{s.params[name]}}, tag: "SHOW"}, nil
+	}}, nil
+}
+
+func (s *session) resetPlan(tokens []wiresql.Token) (*plan, error) {
+	if len(tokens) == 1 && tokens[0].Is("ALL") {
+		return commandPlan("RESET", s.resetParameters), nil
+	}
+	if len(tokens) != 1 {
+		return nil, newError(codeSyntaxError, "syntax error in RESET %s", wiresql.Describe(tokens))
+	}
+	name := s.parameterName(tokens[0].Value())
+	value, ok := s.defaults[name]
+	if !ok {
+		return nil, newError(codeUndefinedObject, "unrecognized configuration parameter \"%s\"", name)
+	}
+	return commandPlan("RESET", func() { s.setParameter(name, value) }), nil
+}
+
+// resetParameters restores every parameter to its value after startup
+func (s *session) resetParameters() {
+	for name := range s.params {
+		if _, ok := s.defaults[name]; !ok {
+			delete(s.params, name)
+		}
+	}
+	for name, value := range s.defaults {
+		if s.params[name] != value {
+			s.setParameter(name, value)
+		}
+	}
+}
+
+func (s *session) discardPlan(tokens []wiresql.Token) (*plan, error) {
+	if len(tokens) != 1 {
+		return nil, newError(codeSyntaxError, "syntax error in DISCARD %s", wiresql.Describe(tokens))
+	}
+	what := strings.ToUpper(tokens[0].Text)
+	if what != "ALL" {
+		// There are no plans, temporary tables or sequences to discard
+		return commandPlan("DISCARD "+what, nil), nil
+	}
+	return commandPlan("DISCARD ALL", func() {
+		s.resetParameters()
+		s.statements = make(map[string]*statement)
+		s.status = 'I'
+	}), nil
+}
+
+func (s *session) deallocatePlan(tokens []wiresql.Token) (*plan, error) {
+	if len(tokens) > 0 && tokens[0].Is("PREPARE") {
+		tokens = tokens[1:]
+	}
+	if len(tokens) != 1 {
+		return nil, newError(codeSyntaxError, "syntax error in DEALLOCATE %s", wiresql.Describe(tokens))
+	}
+	if tokens[0].Is("ALL") {
+		return commandPlan("DEALLOCATE ALL", func() { s.statements = make(map[string]*statement) }), nil
+	}
+	name := tokens[0].Value()
+	if _, ok := s.statements[name]; !ok {
+		return nil, newError(codeUndefinedStatement, "prepared statement \"%s\" does not exist", name)
+	}
+	return commandPlan("DEALLOCATE", func() { delete(s.statements, name) }), nil
+}
+
+// scalarPlan answers a SELECT without FROM, which clients send to check the
+// connection and to learn about the server: literals and the functions that
+// report the session, such as version() and current_setting()
+func (s *session) scalarPlan(tokens []wiresql.Token) (*plan, error) {
+	p := &plan{fields: []field{}}
+	var values []func() interface{}
+	for _, item := range wiresql.SplitList(tokens) {
+		expr, alias := selectAlias(item)
+		expr, oid := stripCast(expr)
+		value, exprOID, name, err := s.scalar(expr)
+		if err != nil {
+			return nil, err
+		}
+		if alias != "" {
+			name = alias
+		}
+		if oid == 0 {
+			oid = exprOID
+		}
+		p.fields = append(p.fields, field{name, oid})
+		values = append(values, value)
+	}
+	p.run = func() (*outcome, error) {
+		row := make([]interface{}, len(values))
+		for i, value := range values {
+			row[i] = value()
+		}
+		return &outcome{rows: [][]interface{}{row}, tag: "SELECT 1"}, nil
+	}
+	return p, nil
+}
+
+// selectAlias separates a SELECT item from its alias, with or without AS
+func selectAlias(item []wiresql.Token) ([]wiresql.Token, string) {
+	n := len(item)
+	switch {
+	case n >= 3 && item[n-2].Is("AS"):
+		return item[:n-2], item[n-1].Value()
+	case n >= 2 && (item[n-1].Kind == wiresql.Word || item[n-1].Kind == wiresql.Ident) && item[n-2].Text != "." && item[n-2].Text != "::":
+		return item[:n-1], item[n-1].Value()
+	}
+	return item, ""
+}
+
+// stripCast removes a trailing ::type cast, returning the type when it is
+// one the listener sends
+func stripCast(expr []wiresql.Token) ([]wiresql.Token, uint32) {
+	for i, t := range expr {
+		if t.Text == "::" && t.Depth == expr[0].Depth && i+1 < len(expr) {
+			name := strings.ToLower(expr[len(expr)-1].Value())
+			return expr[:i], typeOID(name)
+		}
+	}
+	return expr, 0
+}
+
+// typeOID finds a type by its name or SQL name
+func typeOID(name string) uint32 {
+	switch name {
+	case "int":
+		return oidInt4
+	case "varchar":
+		return oidVarchar
+	}
+	for oid, t := range pgTypes {
+		if t.name == name || t.dataType == name {
+			return oid
+		}
+	}
+	return 0
+}
+
+// scalar evaluates a SELECT item without FROM, returning its value, type
+// and the column name Postgres gives it
+func (s *session) scalar(expr []wiresql.Token) (func() interface{}, uint32, string, error) {
+	constant := func(value interface{}, oid uint32, name string) (func() interface{}, uint32, string, error) {
+		return func() interface{} { return value }, oid, name, nil
+	}
+	unsupported := newError(codeFeatureNotSupported, "expression %s is not supported without FROM", wiresql.Describe(expr))
+	if len(expr) > 2 && expr[0].Is("pg_catalog") && expr[1].Text == "." {
+		expr = expr[2:]
+	}
+
+	switch {
+	case len(expr) == 2 && expr[0].Text == "-" && expr[1].Kind == wiresql.Number:
+		value, oid, name, err := s.scalar(expr[1:])
+		if err != nil {
+			return nil, 0, "", err
+		}
+		switch v := value().(type) {
+		case int64:
+			return constant(-v, oid, name)
+		case float64:
+			return constant(-v, oid, name)
+		}
+		return nil, 0, "", unsupported
+	case len(expr) != 1 && (len(expr) < 3 || expr[1].Text != "(" || expr[len(expr)-1].Text != ")"):
+		return nil, 0, "", unsupported
+	}
+
+	word := strings.ToLower(expr[0].Text)
+	if len(expr) == 1 {
+		switch t := expr[0]; {
+		case t.Kind == wiresql.Number:
+			if n, err := strconv.ParseInt(t.Text, 10, 64); err == nil {
+				if n == int64(int32(n)) {
+					return constant(n, oidInt4, "?column?")
+				}
+				return constant(n, oidInt8, "?column?")
+			}
+			f, err := strconv.ParseFloat(t.Text, 64)
+			if err != nil {
+				return nil, 0, "", unsupported
+			}
+			return constant(f, oidFloat8, "?column?")
+		case t.Kind == wiresql.String:
+			return constant(t.Value(), oidText, "?column?")
+		case t.Kind == wiresql.Param:
+			return nil, 0, "", newError(codeSyntaxError, "there is no parameter %s", t.Text)
+		case t.Is("TRUE"), t.Is("FALSE"):
+			return constant(t.Is("TRUE"), oidBool, "bool")
+		case t.Is("NULL"):
+			return constant(nil, oidText, "?column?")
+		}
+	}
+
+	var args [][]wiresql.Token
+	if len(expr) > 1 {
+		args = wiresql.SplitList(expr[2 : len(expr)-1])
+	} else if expr[0].Kind != wiresql.Word {
+		return nil, 0, "", unsupported
+	} else {
+		switch word {
+		case "current_user", "session_user", "user", "current_role", "current_schema", "current_catalog", "current_timestamp":
+		default:
+			return nil, 0, "", newError(codeFeatureNotSupported, "column \"%s\" requires a FROM clause", expr[0].Value())
+		}
+	}
+	argument := func(i int) (string, error) {
+		if i >= len(args) || len(args[i]) != 1 || args[i][0].Kind != wiresql.String {
+			return "", newError(codeFeatureNotSupported, "%s() takes string literal arguments", word)
+		}
+		return args[i][0].Value(), nil
+	}
+
+	switch word {
+	case "version":
+		return constant(fmt.Sprintf("PostgreSQL %s (qRest gateway)", serverVersion), oidText, word)
+	case "current_schema":
+		return constant("public", oidText, word)
+	case "current_database", "current_catalog":
+		return func() interface{} { return s.params["database"] }, oidText, word, nil
+	case "current_user", "session_user", "user", "current_role":
+		return func() interface{} { return s.params["user"] }, oidText, word, nil
+	case "pg_backend_pid":
+		return constant(int64(s.pid), oidInt4, word)
+	case "pg_is_in_recovery":
+		return constant(false, oidBool, word)
+	case "now", "current_timestamp", "transaction_timestamp", "statement_timestamp":
+		return func() interface{} { return time.Now() }, oidTimestampTZ, word, nil
+	case "current_setting":
+		name, err := argument(0)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		name = s.parameterName(name)
+		if _, ok := s.params[name]; !ok {
+			return nil, 0, "", newError(codeUndefinedObject, "unrecognized configuration parameter \"%s\"", name)
+		}
+		return func() interface{} { return s.params[name] }, oidText, word, nil
+	case "set_config":
+		name, err := argument(0)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		value, err := argument(1)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		name = s.parameterName(name)
+		return func() interface{} {
+			s.setParameter(name, value)
+			return value
+		}, oidText, word, nil
+	}
+	return nil, 0, "", unsupported
+}
+
+// gatewayPlan runs a statement on the gateway's tables, typing the columns
+// from their response schemas
+func (s *session) gatewayPlan(sql string) (*plan, error) {
+	query, tables, err := s.server.backend.Translate(sql)
+	if err != nil {
+		return nil, newError(codeSyntaxError, "%s", err.Error())
+	}
+
+	p := &plan{query: query, tables: tables}
+	if query.QueryType == "SELECT" {
+		types := executor.ColumnTypes(tables, query)
+		p.fields = make([]field, len(query.Columns))
+		for i, column := range query.Columns {
+			p.fields[i] = field{column, columnOID(types[column])}
+		}
+	}
+	p.run = func() (*outcome, error) {
+		result, err := executor.Execute(tables, query)
+		if err != nil {
+			return nil, newError(codeInternalError, "query execution failed: %v", err)
+		}
+		if result.Error != "" {
+			return nil, newError(codeInternalError, "API error: %s", result.Error)
+		}
+		for _, warning := range result.Warnings {
+			s.w.noticeResponse(warning)
+		}
+
+		switch query.QueryType {
+		case "INSERT":
+			return &outcome{tag: "INSERT 0 1"}, nil
+		case "UPDATE", "DELETE":
+			return &outcome{tag: query.QueryType + " 1"}, nil
+		}
+		return &outcome{rows: wiresql.RecordRows(result.Data, query.Columns), tag: fmt.Sprintf("SELECT %d", len(result.Data))}, nil
+	}
+	return p, nil
+}
+
+// planParams plans a statement before its parameters are bound, inferring
+// their types from the columns they are compared with or assigned to. Those
+// it cannot infer are text.
+func (s *session) planParams(sql string, tokens []wiresql.Token, count int) (*plan, []uint32, error) {
+	marked, types := wiresql.MarkParams(sql, tokens, count)
+	p, err := s.plan(marked)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.query != nil {
+		wiresql.InferParams(p.query, p.tables, types)
+	}
+	oids := make([]uint32, count)
+	for i, columnType := range types {
+		oids[i] = columnOID(columnType)
+	}
+	return p, oids, nil
+}
+
+// bindParams replaces each $n with its bound value as a literal
+func bindParams(sql string, values [][]byte, formats []int16, oids []uint32) (string, error) {
+	return wiresql.SubstituteParams(sql, dialect.Tokenize(sql), func(n int, _ wiresql.Token) (string, error) {
+		if n < 1 || n > len(values) {
+			return "", newError(codeProtocolViolation, "there is no parameter $%d", n)
+		}
+		return bindLiteral(values[n-1], formats[n-1], oids[n-1], n)
+	})
+}
+
+// bindLiteral writes a parameter value as a SQL literal: NULL, booleans and
+// numbers as they are and everything else quoted
+func bindLiteral(value []byte, format int16, oid uint32, n int) (string, error) {
+	if value == nil {
+		return "NULL", nil
+	}
+	text := string(value)
+	if format == 1 {
+		var err error
+		if text, err = decodeBinary(value, oid); err != nil {
+			return "", newError(codeFeatureNotSupported, "parameter $%d: %v", n, err)
+		}
+	}
+
+	switch {
+	case isNumericOID(oid):
+		number, ok := wiresql.DecimalLiteral(text)
+		if !ok {
+			return "", newError(codeInvalidText, "invalid input syntax for type %s: \"%s\"", pgTypes[oid].name, text)
+		}
+		return number, nil
+	case oid == oidBool:
+		b, err := wiresql.ParseBool(text)
+		if err != nil {
+			return "", newError(codeInvalidText, "invalid input syntax for type boolean: \"%s\"", text)
+		}
+		return strings.ToUpper(strconv.FormatBool(b)), nil
+	}
+	return wiresql.QuoteString(text), nil
+}
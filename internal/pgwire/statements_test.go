@@ -0,0 +1,51 @@
+package pgwire
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestBindLiteral(t *testing.T) {
+	float8 := func(f float64) []byte { return binary.BigEndian.AppendUint64(nil, math.Float64bits(f)) }
+	tests := []struct {
+		value  []byte
+		format int16
+		oid    uint32
+		want   string
+		err    string
+	}{
+		{value: nil, oid: oidInt8, want: "NULL"},
+		{value: []byte("42"), oid: oidInt8, want: "42"},
+		{value: []byte(" -9007199254740993 "), oid: oidInt8, want: "-9007199254740993"},
+		{value: []byte("+2.50"), oid: oidNumeric, want: "2.50"},
+		{value: []byte(".5e-3"), oid: oidFloat8, want: ".5e-3"},
+		{value: []byte("NaN"), oid: oidFloat8, err: `22P02 invalid input syntax for type float8: "NaN"`},
+		{value: []byte("Infinity"), oid: oidFloat8, err: `22P02 invalid input syntax for type float8: "Infinity"`},
+		{value: []byte("-inf"), oid: oidNumeric, err: `22P02 invalid input syntax for type numeric: "-inf"`},
+		{value: []byte("0x1p-2"), oid: oidFloat8, err: `22P02 invalid input syntax for type float8: "0x1p-2"`},
+		{value: []byte("1e999"), oid: oidFloat8, err: `22P02 invalid input syntax for type float8: "1e999"`},
+		{value: []byte("1 OR 1=1"), oid: oidInt8, err: `22P02 invalid input syntax for type int8: "1 OR 1=1"`},
+		{value: float8(2.5), format: 1, oid: oidFloat8, want: "2.5"},
+		{value: float8(math.NaN()), format: 1, oid: oidFloat8, err: `22P02 invalid input syntax for type float8: "NaN"`},
+		{value: float8(math.Inf(1)), format: 1, oid: oidFloat8, err: `22P02 invalid input syntax for type float8: "+Inf"`},
+		{value: []byte("yes"), oid: oidBool, want: "TRUE"},
+		{value: []byte("maybe"), oid: oidBool, err: `22P02 invalid input syntax for type boolean: "maybe"`},
+		{value: []byte("it's"), oid: oidText, want: "'it''s'"},
+		{value: []byte("NaN"), oid: oidText, want: "'NaN'"},
+	}
+
+	for _, tt := range tests {
+		got, err := bindLiteral(tt.value, tt.format, tt.oid, 1)
+		switch {
+		case tt.err != "":
+			if err == nil || errorCode(err)+" "+err.Error() != tt.err {
+				t.Errorf("%q as %d: got error %v, want %q", tt.value, tt.oid, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("%q as %d: %v", tt.value, tt.oid, err)
+		case got != tt.want:
+			t.Errorf("%q as %d: got %s, want %s", tt.value, tt.oid, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package pgwire
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/simonm/qRest/internal/parser"
+)
+
+// Type OIDs from pg_type
+const (
+	oidBool        uint32 = 16
+	oidInt8        uint32 = 20
+	oidInt2        uint32 = 21
+	oidInt4        uint32 = 23
+	oidText        uint32 = 25
+	oidOID         uint32 = 26
+	oidJSON        uint32 = 114
+	oidFloat4      uint32 = 700
+	oidFloat8      uint32 = 701
+	oidUnknown     uint32 = 705
+	oidVarchar     uint32 = 1043
+	oidDate        uint32 = 1082
+	oidTimestamp   uint32 = 1114
+	oidTimestampTZ uint32 = 1184
+	oidNumeric     uint32 = 1700
+	oidJSONB       uint32 = 3802
+)
+
+// pgType describes a type as the catalog reports it
+type pgType struct {
+	oid      uint32
+	name     string // typname, as in information_schema.columns.udt_name
+	dataType string // SQL name, as in information_schema.columns.data_type
+	size     int16  // typlen; -1 for variable length
+}
+
+var pgTypes = map[uint32]pgType{
+	oidBool:        {oidBool, "bool", "boolean", 1},
+	oidInt8:        {oidInt8, "int8", "bigint", 8},
+	oidInt2:        {oidInt2, "int2", "smallint", 2},
+	oidInt4:        {oidInt4, "int4", "integer", 4},
+	oidText:        {oidText, "text", "text", -1},
+	oidOID:         {oidOID, "oid", "oid", 4},
+	oidJSON:        {oidJSON, "json", "json", -1},
+	oidFloat4:      {oidFloat4, "float4", "real", 4},
+	oidFloat8:      {oidFloat8, "float8", "double precision", 8},
+	oidVarchar:     {oidVarchar, "varchar", "character varying", -1},
+	oidDate:        {oidDate, "date", "date", 4},
+	oidTimestamp:   {oidTimestamp, "timestamp", "timestamp without time zone", 8},
+	oidTimestampTZ: {oidTimestampTZ, "timestamptz", "timestamp with time zone", 8},
+	oidNumeric:     {oidNumeric, "numeric", "numeric", -1},
+	oidJSONB:       {oidJSONB, "jsonb", "jsonb", -1},
+}
+
+// columnOID maps a response schema type to the Postgres type it is sent as
+func columnOID(columnType parser.ColumnType) uint32 {
+	switch columnType.Type {
+	case "integer":
+		if columnType.Format == "int32" {
+			return oidInt4
+		}
+		return oidInt8
+	case "number":
+		return oidFloat8
+	case "boolean":
+		return oidBool
+	case "array", "object":
+		return oidJSON
+	case "string":
+		switch columnType.Format {
+		case "date-time":
+			return oidTimestampTZ
+		case "date":
+			return oidDate
+		}
+	}
+	return oidText
+}
+
+// postgresEpoch is where binary timestamps and dates count from
+var postgresEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// typedValue converts a decoded JSON value to the Go value of a column's
+// type, reporting false when it does not fit, e.g. an API sending text in a
+// column its schema declares numeric
+func typedValue(value interface{}, oid uint32) (interface{}, bool) {
+	switch oid {
+	case oidInt2, oidInt4, oidInt8, oidOID:
+		switch v := value.(type) {
+		case float64:
+			if v == math.Trunc(v) {
+				return int64(v), true
+			}
+		case int:
+			return int64(v), true
+		case int64:
+			return v, true
+		case string:
+			n, err := strconv.ParseInt(v, 10, 64)
+			return n, err == nil
+		}
+	case oidFloat4, oidFloat8, oidNumeric:
+		switch v := value.(type) {
+		case float64:
+			return v, true
+		case int:
+			return float64(v), true
+		case int64:
+			return float64(v), true
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			return f, err == nil
+		}
+	case oidBool:
+		if v, ok := value.(bool); ok {
+			return v, true
+		}
+	case oidTimestamp, oidTimestampTZ:
+		switch v := value.(type) {
+		case time.Time:
+			return v, true
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, v)
+			return t, err == nil
+		}
+	case oidDate:
+		switch v := value.(type) {
+		case time.Time:
+			return v, true
+		case string:
+			t, err := time.Parse(time.DateOnly, v)
+			return t, err == nil
+		}
+	case oidJSON, oidJSONB:
+		data, err := json.Marshal(value)
+		return data, err == nil
+	default:
+		return textOf(value), true
+	}
+	return nil, false
+}
+
+// textOf formats a value for a text column: objects and arrays as JSON and
+// numbers without exponents
+func textOf(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+// encodeText writes a value in the text format of its column's type; nil is
+// NULL. Values that do not fit the type are sent as their text, which is what
+// a client reading the text format would see of them.
+func encodeText(value interface{}, oid uint32) []byte {
+	if value == nil {
+		return nil
+	}
+	typed, ok := typedValue(value, oid)
+	if !ok {
+		return []byte(textOf(value))
+	}
+	switch v := typed.(type) {
+	case int64:
+		return strconv.AppendInt(nil, v, 10)
+	case float64:
+		return strconv.AppendFloat(nil, v, 'g', -1, 64)
+	case bool:
+		if v {
+			return []byte("t")
+		}
+		return []byte("f")
+	case time.Time:
+		if oid == oidDate {
+			return []byte(v.Format(time.DateOnly))
+		}
+		if oid == oidTimestamp {
+			return []byte(v.Format("2006-01-02 15:04:05.999999"))
+		}
+		if _, offset := v.Zone(); offset%3600 == 0 {
+			return []byte(v.Format("2006-01-02 15:04:05.999999-07"))
+		}
+		return []byte(v.Format("2006-01-02 15:04:05.999999-07:00"))
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	}
+	return []byte(textOf(typed))
+}
+
+// encodeBinary writes a value in the binary format of its column's type,
+// sending NULL for values that do not fit it
+func encodeBinary(value interface{}, oid uint32) []byte {
+	if value == nil {
+		return nil
+	}
+	typed, ok := typedValue(value, oid)
+	if !ok {
+		return nil
+	}
+	switch oid {
+	case oidInt2:
+		return binary.BigEndian.AppendUint16(nil, uint16(typed.(int64)))
+	case oidInt4, oidOID:
+		return binary.BigEndian.AppendUint32(nil, uint32(typed.(int64)))
+	case oidInt8:
+		return binary.BigEndian.AppendUint64(nil, uint64(typed.(int64)))
+	case oidFloat4:
+		return binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(typed.(float64))))
+	case oidFloat8:
+		return binary.BigEndian.AppendUint64(nil, math.Float64bits(typed.(float64)))
+	case oidNumeric:
+		// numeric has no simple binary form; clients asking for it get text
+		return encodeText(value, oid)
+	case oidBool:
+		if typed.(bool) {
+			return []byte{1}
+		}
+		return []byte{0}
+	case oidTimestamp, oidTimestampTZ:
+		return binary.BigEndian.AppendUint64(nil, uint64(typed.(time.Time).Sub(postgresEpoch).Microseconds()))
+	case oidDate:
+		days := typed.(time.Time).Sub(postgresEpoch).Hours() / 24
+		return binary.BigEndian.AppendUint32(nil, uint32(int32(math.Floor(days))))
+	case oidJSONB:
+		return append([]byte{1}, typed.([]byte)...)
+	case oidJSON:
+		return typed.([]byte)
+	}
+	return []byte(typed.(string))
+}
+
+// decodeBinary reads a parameter sent in binary as the text of a literal
+func decodeBinary(data []byte, oid uint32) (string, error) {
+	switch {
+	case oid == oidInt2 && len(data) == 2:
+		return strconv.Itoa(int(int16(binary.BigEndian.Uint16(data)))), nil
+	case (oid == oidInt4 || oid == oidOID) && len(data) == 4:
+		return strconv.Itoa(int(int32(binary.BigEndian.Uint32(data)))), nil
+	case oid == oidInt8 && len(data) == 8:
+		return strconv.FormatInt(int64(binary.BigEndian.Uint64(data)), 10), nil
+	case oid == oidFloat4 && len(data) == 4:
+		return strconv.FormatFloat(float64(math.Float32frombits(binary.BigEndian.Uint32(data))), 'f', -1, 32), nil
+	case oid == oidFloat8 && len(data) == 8:
+		return strconv.FormatFloat(math.Float64frombits(binary.BigEndian.Uint64(data)), 'f', -1, 64), nil
+	case oid == oidBool && len(data) == 1:
+		return strconv.FormatBool(data[0] != 0), nil
+	case (oid == oidTimestamp || oid == oidTimestampTZ) && len(data) == 8:
+		t := postgresEpoch.Add(time.Duration(int64(binary.BigEndian.Uint64(data))) * time.Microsecond)
+		return t.Format(time.RFC3339Nano), nil
+	case oid == oidDate && len(data) == 4:
+		return postgresEpoch.AddDate(0, 0, int(int32(binary.BigEndian.Uint32(data)))).Format(time.DateOnly), nil
+	case oid == oidJSONB && len(data) > 0:
+		return string(data[1:]), nil
+	case oid == oidText || oid == oidVarchar || oid == oidJSON || oid == oidUnknown || oid == 0:
+		return string(data), nil
+	}
+	name := strconv.Itoa(int(oid))
+	if t, ok := pgTypes[oid]; ok {
+		name = t.name
+	}
+	return "", fmt.Errorf("binary parameters of type %s are not supported", name)
+}
+
+// isNumericOID reports whether parameters of a type are bound as numbers
+func isNumericOID(oid uint32) bool {
+	switch oid {
+	case oidInt2, oidInt4, oidInt8, oidOID, oidFloat4, oidFloat8, oidNumeric:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package translator
+
+// aggregateFunctions are the aggregate functions the executor can compute
+var aggregateFunctions = map[string]bool{
+	"COUNT": true, "SUM": true, "AVG": true, "MIN": true, "MAX": true,
+}
+
+// Aggregate is an aggregate function the executor computes over the fetched
+// records, once per GROUP BY group
+type Aggregate struct {
+	Function string // COUNT, SUM, AVG, MIN or MAX
+	Column   string // empty for COUNT(*)
+	Distinct bool
+	Name     string // output column: the alias or the expression, e.g. "COUNT(*)"
+}
+
+// IsAggregate reports whether the query groups rows or computes aggregates
+func (q *ParsedQuery) IsAggregate() bool {
+	return len(q.Aggregates) > 0 || len(q.GroupBy) > 0
+}
+
+// isAggregateSelect reports whether a SELECT needs grouping
+func isAggregateSelect(stmt *SelectStatement) bool {
+	if len(stmt.GroupBy) > 0 || stmt.Having != nil {
+		return true
+	}
+	for _, item := range stmt.Columns {
+		if item.Expr != nil && containsAggregate(item.Expr) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAggregate reports whether an expression calls a function
+func containsAggregate(expr Expr) bool {
+	found := false
+	walkExpr(expr, func(e Expr) {
+		if _, ok := e.(*FuncCall); ok {
+			found = true
+		}
+	})
+	return found
+}
+
+// walkExpr calls visit for expr and every expression below it
+func walkExpr(expr Expr, visit func(Expr)) {
+	if expr == nil {
+		return
+	}
+	visit(expr)
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		walkExpr(e.Left, visit)
+		walkExpr(e.Right, visit)
+	case *UnaryExpr:
+		walkExpr(e.Operand, visit)
+	case *BetweenExpr:
+		walkExpr(e.Expr, visit)
+		walkExpr(e.Low, visit)
+		walkExpr(e.High, visit)
+	case *InExpr:
+		walkExpr(e.Expr, visit)
+		for _, value := range e.Values {
+			walkExpr(value, visit)
+		}
+	case *IsNullExpr:
+		walkExpr(e.Expr, visit)
+	case *FuncCall:
+		for _, arg := range e.Args {
+			walkExpr(arg, visit)
+		}
+	}
+}
+
+// translateAggregateSelect resolves the SELECT list, GROUP BY and HAVING of a
+// grouped query. Plain columns must be grouped; everything else must be an
+// aggregate.
+func (t *SimpleSQLTranslator) translateAggregateSelect(stmt *SelectStatement, query *ParsedQuery) error {
+	for _, expr := range stmt.GroupBy {
+		ident, ok := expr.(*Identifier)
+		if !ok {
+			return errorAt(expr.Pos(), "unsupported GROUP BY expression %s", expr)
+		}
+		if !t.isColumnAllowed(ident.Name) {
+			return errorAt(ident.Pos(), "column '%s' not available. Available columns: %v",
+				ident.Name, t.grammar.AllowedColumns)
+		}
+		if !contains(query.GroupBy, ident.Name) {
+			query.GroupBy = append(query.GroupBy, ident.Name)
+		}
+	}
+
+	for _, item := range stmt.Columns {
+		if item.Star {
+			return errorAt(item.Pos(), "SELECT * cannot be combined with GROUP BY or aggregate functions")
+		}
+
+		switch e := item.Expr.(type) {
+		case *Identifier:
+			if !contains(query.GroupBy, e.Name) {
+				return errorAt(e.Pos(), "column '%s' must appear in the GROUP BY clause or be used in an aggregate function", e.Name)
+			}
+			query.Columns = append(query.Columns, e.Name)
+
+		case *FuncCall:
+			aggregate, err := t.translateAggregate(e)
+			if err != nil {
+				return err
+			}
+			if item.Alias != "" {
+				aggregate.Name = item.Alias
+			}
+			if t.outputColumn(query, aggregate.Name) {
+				return errorAt(item.Pos(), "duplicate output column '%s'; use AS to name it", aggregate.Name)
+			}
+			query.Aggregates = append(query.Aggregates, aggregate)
+			query.Columns = append(query.Columns, aggregate.Name)
+
+		default:
+			return errorAt(item.Pos(), "unsupported expression %s in SELECT list", item.Expr)
+		}
+	}
+
+	return t.translateHaving(stmt.Having, query)
+}
+
+// translateAggregate validates an aggregate function call
+func (t *SimpleSQLTranslator) translateAggregate(call *FuncCall) (Aggregate, error) {
+	if !aggregateFunctions[call.Name] {
+		return Aggregate{}, errorAt(call.Pos(), "unsupported function %s. Supported: COUNT, SUM, AVG, MIN, MAX", call.Name)
+	}
+	aggregate := Aggregate{Function: call.Name, Distinct: call.Distinct, Name: call.String()}
+
+	if call.Star {
+		if call.Name != "COUNT" {
+			return Aggregate{}, errorAt(call.Pos(), "%s(*) is not supported; only COUNT accepts *", call.Name)
+		}
+		return aggregate, nil
+	}
+
+	if len(call.Args) != 1 {
+		return Aggregate{}, errorAt(call.Pos(), "%s takes exactly one argument", call.Name)
+	}
+	ident, ok := call.Args[0].(*Identifier)
+	if !ok {
+		return Aggregate{}, errorAt(call.Args[0].Pos(), "%s must be applied to a column", call.Name)
+	}
+	if !t.isColumnAllowed(ident.Name) {
+		return Aggregate{}, errorAt(ident.Pos(), "column '%s' not available. Available columns: %v",
+			ident.Name, t.grammar.AllowedColumns)
+	}
+	aggregate.Column = ident.Name
+	return aggregate, nil
+}
+
+// resolveAggregate returns the output column computing call, adding a hidden
+// aggregate when the SELECT list does not already compute it
+func (t *SimpleSQLTranslator) resolveAggregate(call *FuncCall, query *ParsedQuery) (string, error) {
+	aggregate, err := t.translateAggregate(call)
+	if err != nil {
+		return "", err
+	}
+	for _, existing := range query.Aggregates {
+		if existing.Function == aggregate.Function && existing.Column == aggregate.Column &&
+			existing.Distinct == aggregate.Distinct {
+			return existing.Name, nil
+		}
+	}
+	query.Aggregates = append(query.Aggregates, aggregate)
+	return aggregate.Name, nil
+}
+
+// outputColumn reports whether name is a grouped column or a computed aggregate
+func (t *SimpleSQLTranslator) outputColumn(query *ParsedQuery, name string) bool {
+	if contains(query.GroupBy, name) {
+		return true
+	}
+	for _, aggregate := range query.Aggregates {
+		if aggregate.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// translateHaving converts HAVING into OR-ed groups of conditions on output
+// columns. Aggregate calls are replaced by the columns computing them.
+func (t *SimpleSQLTranslator) translateHaving(having Expr, query *ParsedQuery) error {
+	if having == nil {
+		return nil
+	}
+
+	rewritten, err := t.replaceAggregates(having, query)
+	if err != nil {
+		return err
+	}
+
+	t.scope = &conditionScope{checkColumn: func(ident *Identifier) error {
+		if !t.outputColumn(query, ident.Name) {
+			return errorAt(ident.Pos(), "column '%s' must appear in the GROUP BY clause or be used in an aggregate function", ident.Name)
+		}
+		return nil
+	}}
+	defer func() { t.scope = nil }()
+
+	groups, err := t.toDNF(rewritten, false)
+	if err != nil {
+		return err
+	}
+	query.Having = groups
+	return nil
+}
+
+// replaceAggregates returns a copy of expr with every aggregate call replaced
+// by a reference to its output column
+func (t *SimpleSQLTranslator) replaceAggregates(expr Expr, query *ParsedQuery) (Expr, error) {
+	var err error
+	replace := func(e Expr) Expr {
+		if err != nil {
+			return e
+		}
+		var replaced Expr
+		replaced, err = t.replaceAggregates(e, query)
+		return replaced
+	}
+
+	switch e := expr.(type) {
+	case *FuncCall:
+		name, err := t.resolveAggregate(e, query)
+		if err != nil {
+			return nil, err
+		}
+		return &Identifier{Position: e.Position, Name: name}, nil
+	case *BinaryExpr:
+		copied := *e
+		copied.Left, copied.Right = replace(e.Left), replace(e.Right)
+		return &copied, err
+	case *UnaryExpr:
+		copied := *e
+		copied.Operand = replace(e.Operand)
+		return &copied, err
+	case *BetweenExpr:
+		copied := *e
+		copied.Expr, copied.Low, copied.High = replace(e.Expr), replace(e.Low), replace(e.High)
+		return &copied, err
+	case *InExpr:
+		copied := *e
+		copied.Expr = replace(e.Expr)
+		copied.Values = make([]Expr, len(e.Values))
+		for i, value := range e.Values {
+			copied.Values[i] = replace(value)
+		}
+		return &copied, err
+	case *IsNullExpr:
+		copied := *e
+		copied.Expr = replace(e.Expr)
+		return &copied, err
+	}
+	return expr, nil
+}
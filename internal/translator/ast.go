@@ -0,0 +1,312 @@
+package translator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Node is implemented by every AST node
+type Node interface {
+	Pos() Position
+}
+
+// Statement is a complete SQL statement
+type Statement interface {
+	Node
+	statementNode()
+}
+
+// Expr is a value or boolean expression
+type Expr interface {
+	Node
+	String() string
+	exprNode()
+}
+
+// SelectStatement: SELECT columns FROM table [JOIN ...] [WHERE ...] [GROUP BY ...]
+// [HAVING ...] [ORDER BY ...] [LIMIT n [OFFSET m]]
+type SelectStatement struct {
+	Position
+	Columns []SelectItem
+	From    *TableRef
+	Joins   []JoinClause
+	Where   Expr
+	GroupBy []Expr
+	Having  Expr
+	OrderBy []OrderItem
+	Limit   Expr
+	Offset  Expr
+}
+
+// SelectItem is a single entry of the SELECT list; Star marks "*", or
+// "table.*" when Table is set
+type SelectItem struct {
+	Position
+	Star  bool
+	Table string
+	Expr  Expr
+	Alias string
+}
+
+// OrderItem: expr [ASC | DESC] [NULLS FIRST | NULLS LAST]
+type OrderItem struct {
+	Position
+	Expr  Expr
+	Desc  bool
+	Nulls string // FIRST, LAST or empty for the default
+}
+
+// TableRef names the table a statement operates on
+type TableRef struct {
+	Position
+	Name  string
+	Alias string
+}
+
+// JoinClause: [INNER | LEFT [OUTER]] JOIN table [alias] ON condition
+type JoinClause struct {
+	Position
+	Kind  string // INNER or LEFT
+	Table *TableRef
+	On    Expr
+}
+
+// InsertStatement: INSERT INTO table (columns) VALUES (values)
+type InsertStatement struct {
+	Position
+	Table   *TableRef
+	Columns []*Identifier
+	Values  []Expr
+}
+
+// UpdateStatement: UPDATE table SET column = value, ... [WHERE ...]
+type UpdateStatement struct {
+	Position
+	Table       *TableRef
+	Assignments []Assignment
+	Where       Expr
+}
+
+type Assignment struct {
+	Position
+	Column *Identifier
+	Value  Expr
+}
+
+// DeleteStatement: DELETE FROM table [WHERE ...]
+type DeleteStatement struct {
+	Position
+	Table *TableRef
+	Where Expr
+}
+
+func (*SelectStatement) statementNode() {}
+func (*InsertStatement) statementNode() {}
+func (*UpdateStatement) statementNode() {}
+func (*DeleteStatement) statementNode() {}
+
+// Identifier references a column by name. Dotted names such as "o.petId"
+// are kept whole; the translator resolves any table qualifier.
+type Identifier struct {
+	Position
+	Name   string
+	Quoted bool
+}
+
+type StringLiteral struct {
+	Position
+	Value string
+}
+
+// NumberLiteral keeps the source text; Value is an int or a float64
+type NumberLiteral struct {
+	Position
+	Raw   string
+	Value interface{}
+}
+
+// NullLiteral is the NULL keyword
+type NullLiteral struct {
+	Position
+}
+
+// BoolLiteral is TRUE or FALSE
+type BoolLiteral struct {
+	Position
+	Value bool
+}
+
+// TypedLiteral is a string preceded by its type: DATE '2024-01-31' or
+// TIMESTAMP '2024-01-31 12:00:00'. Type is upper case.
+type TypedLiteral struct {
+	Position
+	Type  string
+	Value string
+}
+
+// Placeholder is a parameter of a prepared statement, $1 for the first,
+// whose value is bound after the statement is planned
+type Placeholder struct {
+	Position
+	Index int
+}
+
+// BinaryExpr covers comparisons (=, <>, LIKE, ...) and the AND/OR connectives
+type BinaryExpr struct {
+	Position
+	Op    string
+	Left  Expr
+	Right Expr
+}
+
+// UnaryExpr is NOT or a sign applied to its operand
+type UnaryExpr struct {
+	Position
+	Op      string
+	Operand Expr
+}
+
+// BetweenExpr: expr [NOT] BETWEEN low AND high
+type BetweenExpr struct {
+	Position
+	Expr Expr
+	Low  Expr
+	High Expr
+	Not  bool
+}
+
+// InExpr: expr [NOT] IN (value, ...)
+type InExpr struct {
+	Position
+	Expr   Expr
+	Values []Expr
+	Not    bool
+}
+
+// IsNullExpr: expr IS [NOT] NULL
+type IsNullExpr struct {
+	Position
+	Expr Expr
+	Not  bool
+}
+
+// FuncCall is a function applied to its arguments, e.g. COUNT(*) or SUM(DISTINCT price).
+// Name is upper case; Star marks a "*" argument.
+type FuncCall struct {
+	Position
+	Name     string
+	Args     []Expr
+	Star     bool
+	Distinct bool
+}
+
+func (*Identifier) exprNode()    {}
+func (*StringLiteral) exprNode() {}
+func (*NumberLiteral) exprNode() {}
+func (*NullLiteral) exprNode()   {}
+func (*BoolLiteral) exprNode()   {}
+func (*TypedLiteral) exprNode()  {}
+func (*Placeholder) exprNode()   {}
+func (*BinaryExpr) exprNode()    {}
+func (*UnaryExpr) exprNode()     {}
+func (*BetweenExpr) exprNode()   {}
+func (*InExpr) exprNode()        {}
+func (*IsNullExpr) exprNode()    {}
+func (*FuncCall) exprNode()      {}
+
+func (e *Identifier) String() string {
+	if e.Quoted {
+		return `"` + e.Name + `"`
+	}
+	return e.Name
+}
+
+func (e *StringLiteral) String() string {
+	return "'" + strings.ReplaceAll(e.Value, "'", "''") + "'"
+}
+
+func (e *NumberLiteral) String() string {
+	return e.Raw
+}
+
+func (e *NullLiteral) String() string {
+	return "NULL"
+}
+
+func (e *BoolLiteral) String() string {
+	if e.Value {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+func (e *TypedLiteral) String() string {
+	return e.Type + " '" + strings.ReplaceAll(e.Value, "'", "''") + "'"
+}
+
+func (e *Placeholder) String() string {
+	return "$" + strconv.Itoa(e.Index)
+}
+
+func (e *BinaryExpr) String() string {
+	return fmt.Sprintf("(%s %s %s)", e.Left, e.Op, e.Right)
+}
+
+func (e *UnaryExpr) String() string {
+	if e.Op == "NOT" {
+		return fmt.Sprintf("(NOT %s)", e.Operand)
+	}
+	return e.Op + e.Operand.String()
+}
+
+func (e *BetweenExpr) String() string {
+	op := "BETWEEN"
+	if e.Not {
+		op = "NOT BETWEEN"
+	}
+	return fmt.Sprintf("(%s %s %s AND %s)", e.Expr, op, e.Low, e.High)
+}
+
+func (e *InExpr) String() string {
+	values := make([]string, len(e.Values))
+	for i, value := range e.Values {
+		values[i] = value.String()
+	}
+	op := "IN"
+	if e.Not {
+		op = "NOT IN"
+	}
+	return fmt.Sprintf("(%s %s (%s))", e.Expr, op, strings.Join(values, ", "))
+}
+
+func (e *IsNullExpr) String() string {
+	if e.Not {
+		return fmt.Sprintf("(%s IS NOT NULL)", e.Expr)
+	}
+	return fmt.Sprintf("(%s IS NULL)", e.Expr)
+}
+
+func (e *FuncCall) String() string {
+	if e.Star {
+		return e.Name + "(*)"
+	}
+	args := make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		args[i] = arg.String()
+	}
+	distinct := ""
+	if e.Distinct {
+		distinct = "DISTINCT "
+	}
+	return fmt.Sprintf("%s(%s%s)", e.Name, distinct, strings.Join(args, ", "))
+}
+
+// isComparisonOperator reports whether op compares a column with a value
+func isComparisonOperator(op string) bool {
+	switch strings.ToUpper(op) {
+	case "=", "!=", "<>", "<", "<=", ">", ">=", "LIKE", "ILIKE", "NOT LIKE", "NOT ILIKE":
+		return true
+	}
+	return false
+}
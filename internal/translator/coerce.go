@@ -0,0 +1,200 @@
+package translator
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/simonm/qRest/internal/parser"
+)
+
+// Param is a condition or assignment value standing for the nth parameter
+// of a prepared statement. A front end plans the statement before its values
+// are bound and types each parameter by the column it meets; it is never
+// coerced.
+type Param int
+
+// countParams returns the highest $n placeholder of a statement, or 0
+func countParams(sql string) int {
+	tokens, _ := Tokenize(sql)
+	count := 0
+	for _, token := range tokens {
+		if n, err := strconv.Atoi(strings.TrimPrefix(token.Value, "$")); token.Type == TokenParam && err == nil {
+			count = max(count, n)
+		}
+	}
+	return count
+}
+
+// dateTimeLayouts are the forms a date-time literal may take; those without
+// a zone are read as UTC
+var dateTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", time.DateOnly}
+
+// columnValue reads the literal a column is compared with or assigned and
+// coerces it to the column's declared type. LIKE patterns stay text. NULL
+// may only be assigned, as comparisons with it match no rows.
+func (t *SimpleSQLTranslator) columnValue(column, op string, expr Expr) (interface{}, error) {
+	value, err := t.literalValue(expr)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil && op != "" {
+		// NULL is never equal, or unequal, to anything
+		return nil, errorAt(expr.Pos(), "%s %s NULL is never true; use %s IS NULL or %s IS NOT NULL", column, op, column, column)
+	}
+	if strings.HasSuffix(op, "LIKE") {
+		return value, nil
+	}
+	columnType, ok := t.columnType(column)
+	if !ok {
+		return value, nil
+	}
+	return coerceValue(column, op, value, columnType, expr.Pos())
+}
+
+// columnType returns the declared type of a column. HAVING compares output
+// columns, which have none.
+func (t *SimpleSQLTranslator) columnType(column string) (parser.ColumnType, bool) {
+	if t.scope != nil && !t.qualified {
+		return parser.ColumnType{}, false
+	}
+	columnType, ok := t.grammar.ColumnTypes[column]
+	if !ok {
+		columnType, ok = t.grammar.ColumnTypes[ElementPath(column)]
+	}
+	return columnType, ok
+}
+
+// coerceValue converts a literal to a column's type and format, rejecting
+// values the column cannot hold: text that is not a number for a numeric
+// column, a malformed date, or a value outside the column's enum. Numbers
+// are accepted as text for text columns. op is empty for assignments.
+func coerceValue(column, op string, value interface{}, columnType parser.ColumnType, pos Position) (interface{}, error) {
+	if _, ok := value.(ColumnRef); ok || value == nil {
+		return value, nil
+	}
+	if _, ok := value.(Param); ok {
+		return value, nil
+	}
+
+	mismatch := func(kind string) error {
+		return errorAt(pos, "column '%s' is %s; %s is not", column, kind, formatValue(value))
+	}
+	switch columnType.Type {
+	case "integer":
+		var n int
+		switch v := value.(type) {
+		case int:
+			n = v
+		case float64:
+			if v != math.Trunc(v) || math.Abs(v) > math.MaxInt64 {
+				return nil, mismatch("an integer")
+			}
+			n = int(v)
+		case string:
+			parsed, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return nil, mismatch("an integer")
+			}
+			n = int(parsed)
+		default:
+			return nil, mismatch("an integer")
+		}
+		if columnType.Format == "int32" && (n < math.MinInt32 || n > math.MaxInt32) {
+			return nil, mismatch("a 32-bit integer")
+		}
+		value = n
+
+	case "number":
+		if s, ok := value.(string); ok {
+			f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return nil, mismatch("a number")
+			}
+			value = f
+		}
+
+	case "boolean":
+		switch v := value.(type) {
+		case bool:
+		case int:
+			if v != 0 && v != 1 {
+				return nil, mismatch("a boolean")
+			}
+			value = v == 1
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return nil, mismatch("a boolean")
+			}
+			value = b
+		default:
+			return nil, mismatch("a boolean")
+		}
+
+	case "string":
+		switch v := value.(type) {
+		case int:
+			value = strconv.Itoa(v)
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		s, ok := value.(string)
+		if !ok {
+			return nil, mismatch("text")
+		}
+		switch columnType.Format {
+		case "date":
+			if _, err := time.Parse(time.DateOnly, s); err != nil {
+				return nil, mismatch("a date (YYYY-MM-DD)")
+			}
+		case "date-time":
+			timestamp, ok := parseDateTime(s)
+			if !ok {
+				return nil, mismatch("a date-time (RFC 3339)")
+			}
+			value = timestamp.Format(time.RFC3339Nano)
+		}
+	}
+
+	// A value outside the enum matches no record, and the API would
+	// likely reject it. Excluding one is allowed: it excludes nothing.
+	if len(columnType.Enum) > 0 && isEqualityOperator(op) && !slices.Contains(columnType.Enum, formatEnumValue(value)) {
+		return nil, errorAt(pos, "%s is not a value of column '%s'. Allowed: %s",
+			formatValue(value), column, strings.Join(columnType.Enum, ", "))
+	}
+	return value, nil
+}
+
+// parseDateTime reads a date-time literal, or a date as its midnight in UTC
+func parseDateTime(s string) (time.Time, bool) {
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// isEqualityOperator reports whether op matches a column to given values; an
+// assignment, with no operator, sets one
+func isEqualityOperator(op string) bool {
+	switch op {
+	case "", "=", "IN":
+		return true
+	}
+	return false
+}
+
+// formatEnumValue renders a value as an enum lists it
+func formatEnumValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return formatValue(value)
+}
@@ -0,0 +1,353 @@
+package translator
+
+import (
+	"strings"
+
+	"github.com/simonm/qRest/internal/grammar"
+	"github.com/simonm/qRest/internal/parser"
+)
+
+// ColumnRef is a condition value naming another column, as in the ON clause
+// "o.petId = p.id". The executor compares both columns of each joined row.
+type ColumnRef string
+
+// JoinSource is one table read by a SELECT with JOINs: the query sent to its
+// API and, for joined tables, how its rows combine with the rows before it.
+// Columns of joined rows are qualified by the table alias, e.g. "p.name".
+type JoinSource struct {
+	Alias string
+	Kind  string       // INNER or LEFT; empty for the FROM table
+	Query *ParsedQuery // conditions on this table alone, pushed to its API where possible
+	Keys  []JoinKey    // ON equalities with earlier tables
+	On    []Condition  // remaining ON conditions, checked on each joined row
+}
+
+// JoinKey is an ON equality between a column of an earlier table and a column
+// of the joined table
+type JoinKey struct {
+	Outer string // qualified column of an earlier table, e.g. "o.petId"
+	Inner string // column of the joined table, e.g. "id"
+}
+
+// IsJoin reports whether the query reads several tables
+func (q *ParsedQuery) IsJoin() bool {
+	return len(q.Sources) > 1
+}
+
+// SetJoinTables registers the grammars of the tables a SELECT may join,
+// keyed by the name used in SQL
+func (t *SimpleSQLTranslator) SetJoinTables(grammars map[string]grammar.SQLGrammar) {
+	t.joinGrammars = grammars
+}
+
+// joinTable is a table of a joined SELECT while it is being translated
+type joinTable struct {
+	alias   string
+	grammar grammar.SQLGrammar
+	source  *JoinSource
+}
+
+// translateJoinSelect resolves a SELECT with JOINs. Columns are qualified by
+// table alias throughout, and the query's own columns, conditions, grouping
+// and ordering apply to the joined rows. Conditions on a single table move
+// to that table's query when doing so cannot change the result.
+func (t *SimpleSQLTranslator) translateJoinSelect(stmt *SelectStatement, query *ParsedQuery) (*ParsedQuery, error) {
+	tables := []*joinTable{{alias: tableAlias(stmt.From), grammar: t.grammar}}
+	for _, join := range stmt.Joins {
+		joinGrammar, exists := t.joinGrammars[join.Table.Name]
+		if !exists {
+			return nil, errorAt(join.Table.Pos(), "table '%s' not found for JOIN", join.Table.Name)
+		}
+		tables = append(tables, &joinTable{alias: tableAlias(join.Table), grammar: joinGrammar})
+	}
+
+	var columns []string
+	types := make(map[string]parser.ColumnType)
+	names := []string{stmt.From.Name}
+	for _, join := range stmt.Joins {
+		names = append(names, join.Table.Name)
+	}
+	for i, table := range tables {
+		for _, other := range tables[:i] {
+			if other.alias == table.alias {
+				return nil, errorAt(stmt.Pos(), "table alias '%s' is used twice; give each table its own alias", table.alias)
+			}
+		}
+		table.source = &JoinSource{
+			Alias: table.alias,
+			Query: &ParsedQuery{QueryType: "SELECT", TableName: names[i], Updates: make(map[string]interface{})},
+		}
+		if i > 0 {
+			// Known before WHERE is split, which must not filter the
+			// optional side of a LEFT JOIN
+			table.source.Kind = stmt.Joins[i-1].Kind
+		}
+		for _, column := range table.grammar.AllowedColumns {
+			columns = append(columns, table.alias+"."+column)
+		}
+		for column, columnType := range table.grammar.ColumnTypes {
+			types[table.alias+"."+column] = columnType
+		}
+	}
+
+	// Qualify every column reference; ORDER BY and HAVING may also name an
+	// output column by its alias
+	aliases := make(map[string]bool)
+	for _, item := range stmt.Columns {
+		if item.Alias != "" {
+			aliases[item.Alias] = true
+		}
+	}
+	exprs := []Expr{stmt.Where}
+	for _, item := range stmt.Columns {
+		if item.Star && item.Table != "" && findJoinTable(tables, item.Table) == nil {
+			return nil, errorAt(item.Pos(), "unknown table '%s' in %s.*", item.Table, item.Table)
+		}
+		exprs = append(exprs, item.Expr)
+	}
+	for _, join := range stmt.Joins {
+		exprs = append(exprs, join.On)
+	}
+	exprs = append(exprs, stmt.GroupBy...)
+	for _, expr := range exprs {
+		if err := resolveColumns(expr, tables, nil); err != nil {
+			return nil, err
+		}
+	}
+	if err := resolveColumns(stmt.Having, tables, aliases); err != nil {
+		return nil, err
+	}
+	for _, item := range stmt.OrderBy {
+		if err := resolveColumns(item.Expr, tables, aliases); err != nil {
+			return nil, err
+		}
+	}
+
+	// The joined rows are validated against a grammar of every qualified
+	// column; such conditions are always evaluated locally
+	joined := NewSimpleSQLTranslator(grammar.SQLGrammar{
+		TableName:      t.grammar.TableName,
+		AllowedColumns: columns,
+		ColumnTypes:    types,
+		WhereClause: grammar.WhereGrammar{
+			AllowedColumns: make(map[string][]string),
+			LocalColumns:   columns,
+		},
+		OrderBy: grammar.OrderByGrammar{AllowedColumns: columns},
+		Limit:   t.grammar.Limit,
+	})
+	joined.qualified = true
+
+	if isAggregateSelect(stmt) {
+		if err := joined.translateAggregateSelect(stmt, query); err != nil {
+			return nil, err
+		}
+	} else if err := joined.translateColumns(stmt.Columns, query); err != nil {
+		return nil, err
+	}
+
+	joined.scope = &conditionScope{
+		checkColumn: func(*Identifier) error { return nil },
+		columnRefs:  true,
+	}
+
+	if err := joined.translateWhere(stmt.Where, query); err != nil {
+		return nil, err
+	}
+	if len(query.Disjuncts) == 0 {
+		// Filtering a table before the join only matches filtering the joined
+		// rows when the table is not on the optional side of a LEFT JOIN
+		var remaining []Condition
+		for _, condition := range query.Conditions {
+			table := ownerTable(tables, condition.Column)
+			if table.source.Kind != "LEFT" && pushCondition(table, condition) {
+				continue
+			}
+			remaining = append(remaining, condition)
+		}
+		query.Conditions = remaining
+	}
+
+	for i, join := range stmt.Joins {
+		if err := joined.translateOn(join, tables[:i+2]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := joined.translateOrderBy(stmt.OrderBy, query); err != nil {
+		return nil, err
+	}
+	if err := joined.translateLimit(stmt.Limit, stmt.Offset, query); err != nil {
+		return nil, err
+	}
+
+	for _, table := range tables {
+		query.Sources = append(query.Sources, *table.source)
+	}
+	return query, nil
+}
+
+// translateOn splits a JOIN's ON clause into equality keys with earlier
+// tables, conditions on the joined table alone and conditions checked on each
+// joined row. tables ends with the table being joined.
+func (t *SimpleSQLTranslator) translateOn(join JoinClause, tables []*joinTable) error {
+	table := tables[len(tables)-1]
+
+	var call *FuncCall
+	walkExpr(join.On, func(e Expr) {
+		if f, ok := e.(*FuncCall); ok && call == nil {
+			call = f
+		}
+	})
+	if call != nil {
+		return errorAt(call.Pos(), "%s is not allowed in the ON clause of a JOIN", call)
+	}
+
+	var laterColumn *Identifier
+	walkExpr(join.On, func(e Expr) {
+		if ident, ok := e.(*Identifier); ok && laterColumn == nil && findJoinTable(tables, tableOf(ident.Name)) == nil {
+			laterColumn = ident
+		}
+	})
+	if laterColumn != nil {
+		return errorAt(laterColumn.Pos(), "column '%s' belongs to a table joined later", laterColumn.Name)
+	}
+
+	groups, err := t.toDNF(join.On, false)
+	if err != nil {
+		return err
+	}
+	if len(groups) != 1 {
+		return errorAt(join.On.Pos(), "OR is not supported in the ON clause of a JOIN")
+	}
+
+	for _, condition := range groups[0] {
+		if key, ok := joinKey(table, condition); ok {
+			table.source.Keys = append(table.source.Keys, key)
+			continue
+		}
+		if ownerTable(tables, condition.Column) == table && pushCondition(table, condition) {
+			continue
+		}
+		table.source.On = append(table.source.On, condition)
+	}
+	return nil
+}
+
+// joinKey recognises an ON equality between the joined table and an earlier one
+func joinKey(table *joinTable, condition Condition) (JoinKey, bool) {
+	ref, ok := condition.Value.(ColumnRef)
+	if !ok || condition.Operator != "=" {
+		return JoinKey{}, false
+	}
+	column, other := condition.Column, string(ref)
+	if tableOf(other) == table.alias {
+		column, other = other, column
+	}
+	if tableOf(column) != table.alias || tableOf(other) == table.alias {
+		return JoinKey{}, false
+	}
+	return JoinKey{Outer: other, Inner: strings.TrimPrefix(column, table.alias+".")}, true
+}
+
+// pushCondition moves a condition comparing one of the table's columns with a
+// literal into the table's own query, when its grammar accepts it
+func pushCondition(table *joinTable, condition Condition) bool {
+	if _, isRef := condition.Value.(ColumnRef); isRef {
+		return false
+	}
+	column := strings.TrimPrefix(condition.Column, table.alias+".")
+	check := NewSimpleSQLTranslator(table.grammar)
+	if err := check.checkOperator(&Identifier{Name: column}, condition.Operator); err != nil {
+		return false
+	}
+	condition.Column = column
+	table.source.Query.Conditions = append(table.source.Query.Conditions, condition)
+	return true
+}
+
+// resolveColumns rewrites every column reference below expr to its qualified
+// "alias.column" form. Names in skip are left as they are.
+func resolveColumns(expr Expr, tables []*joinTable, skip map[string]bool) error {
+	var err error
+	walkExpr(expr, func(e Expr) {
+		ident, ok := e.(*Identifier)
+		if !ok || err != nil || skip[ident.Name] {
+			return
+		}
+		ident.Name, err = resolveColumn(ident, tables)
+	})
+	return err
+}
+
+// resolveColumn qualifies a column reference, which may already name its
+// table. Unqualified names must belong to exactly one table.
+func resolveColumn(ident *Identifier, tables []*joinTable) (string, error) {
+	// A dotted name starts with a table alias, or else is a nested column path
+	// such as "category.name"
+	if alias, column, qualified := strings.Cut(ident.Name, "."); qualified && findJoinTable(tables, alias) != nil {
+		table := findJoinTable(tables, alias)
+		if !hasColumn(table.grammar.AllowedColumns, column) {
+			return "", errorAt(ident.Pos(), "column '%s' not available in table '%s'. Available columns: %v",
+				column, alias, table.grammar.AllowedColumns)
+		}
+		return ident.Name, nil
+	}
+
+	var owner *joinTable
+	for _, table := range tables {
+		if !hasColumn(table.grammar.AllowedColumns, ident.Name) {
+			continue
+		}
+		if owner != nil {
+			return "", errorAt(ident.Pos(), "column '%s' is ambiguous; qualify it with a table alias", ident.Name)
+		}
+		owner = table
+	}
+	if owner == nil {
+		if alias, _, qualified := strings.Cut(ident.Name, "."); qualified && !isJoinedColumn(tables, alias) {
+			return "", errorAt(ident.Pos(), "unknown table '%s' in column '%s'", alias, ident.Name)
+		}
+		return "", errorAt(ident.Pos(), "column '%s' not available in any joined table", ident.Name)
+	}
+	return owner.alias + "." + ident.Name, nil
+}
+
+// isJoinedColumn reports whether any of the tables has column
+func isJoinedColumn(tables []*joinTable, column string) bool {
+	for _, table := range tables {
+		if hasColumn(table.grammar.AllowedColumns, column) {
+			return true
+		}
+	}
+	return false
+}
+
+// tableAlias returns the name a table's columns are qualified with: its alias,
+// or its name without any API prefix
+func tableAlias(table *TableRef) string {
+	if table.Alias != "" {
+		return table.Alias
+	}
+	return table.Name[strings.LastIndex(table.Name, ".")+1:]
+}
+
+// tableOf returns the table alias of a qualified column
+func tableOf(column string) string {
+	alias, _, _ := strings.Cut(column, ".")
+	return alias
+}
+
+func findJoinTable(tables []*joinTable, alias string) *joinTable {
+	for _, table := range tables {
+		if table.alias == alias {
+			return table
+		}
+	}
+	return nil
+}
+
+// ownerTable returns the table a qualified column belongs to
+func ownerTable(tables []*joinTable, column string) *joinTable {
+	return findJoinTable(tables, tableOf(column))
+}
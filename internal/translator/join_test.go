@@ -0,0 +1,87 @@
+package translator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/simonm/qRest/internal/grammar"
+	"github.com/simonm/qRest/internal/parser"
+)
+
+// ordersGrammar is a table of orders filtered by petId on the API
+func ordersGrammar() grammar.SQLGrammar {
+	return grammar.SQLGrammar{
+		TableName:      "orders",
+		AllowedColumns: []string{"id", "petId"},
+		ColumnTypes: map[string]parser.ColumnType{
+			"id":    {Type: "integer"},
+			"petId": {Type: "integer"},
+		},
+		WhereClause: grammar.WhereGrammar{
+			AllowedColumns: map[string][]string{"petId": {"="}},
+			LocalColumns:   []string{"id", "petId"},
+		},
+		Limit: grammar.LimitGrammar{MaxLimit: 100, DefaultLimit: 20},
+	}
+}
+
+func TestParseSQLJoin(t *testing.T) {
+	tests := []struct {
+		sql        string
+		conditions []Condition   // checked on the joined rows
+		pushed     [][]Condition // sent to each table's API
+		keys       []JoinKey
+		on         []Condition
+	}{
+		{
+			sql:    "SELECT o.id, p.name FROM orders o JOIN pets p ON o.petId = p.id",
+			pushed: [][]Condition{nil, nil},
+			keys:   []JoinKey{{Outer: "o.petId", Inner: "id"}},
+		},
+		{
+			sql:    "SELECT o.id, p.name FROM orders o JOIN pets p ON o.petId = p.id WHERE p.status = 'sold' AND o.petId = 3",
+			pushed: [][]Condition{{{Column: "petId", Operator: "=", Value: 3}}, {{Column: "status", Operator: "=", Value: "sold"}}},
+			keys:   []JoinKey{{Outer: "o.petId", Inner: "id"}},
+		},
+		{
+			// Filtering the optional side before the join would keep the
+			// orders it removes, with nulls
+			sql:        "SELECT o.id, p.name FROM orders o LEFT JOIN pets p ON o.petId = p.id WHERE p.status = 'sold'",
+			conditions: []Condition{{Column: "p.status", Operator: "=", Value: "sold"}},
+			pushed:     [][]Condition{nil, nil},
+			keys:       []JoinKey{{Outer: "o.petId", Inner: "id"}},
+		},
+		{
+			// ON conditions on the optional side only narrow what matches
+			sql:    "SELECT o.id, p.name FROM orders o LEFT JOIN pets p ON o.petId = p.id AND p.status = 'sold'",
+			pushed: [][]Condition{nil, {{Column: "status", Operator: "=", Value: "sold"}}},
+			keys:   []JoinKey{{Outer: "o.petId", Inner: "id"}},
+		},
+		{
+			sql:    "SELECT o.id, p.name FROM orders o JOIN pets p ON p.id > o.petId",
+			pushed: [][]Condition{nil, nil},
+			on:     []Condition{{Column: "p.id", Operator: ">", Value: ColumnRef("o.petId")}},
+		},
+	}
+
+	for _, tt := range tests {
+		sqlTranslator := NewSimpleSQLTranslator(ordersGrammar())
+		sqlTranslator.SetJoinTables(map[string]grammar.SQLGrammar{"orders": ordersGrammar(), "pets": petsGrammar()})
+		query, err := sqlTranslator.ParseSQL(tt.sql)
+		if err != nil {
+			t.Errorf("%s: %v", tt.sql, err)
+			continue
+		}
+		if !reflect.DeepEqual(query.Conditions, tt.conditions) {
+			t.Errorf("%s: got conditions %v, want %v", tt.sql, query.Conditions, tt.conditions)
+		}
+		for i, source := range query.Sources {
+			if !reflect.DeepEqual(source.Query.Conditions, tt.pushed[i]) {
+				t.Errorf("%s: got %s conditions %v, want %v", tt.sql, source.Alias, source.Query.Conditions, tt.pushed[i])
+			}
+		}
+		if joined := query.Sources[1]; !reflect.DeepEqual(joined.Keys, tt.keys) || !reflect.DeepEqual(joined.On, tt.on) {
+			t.Errorf("%s: got keys %v on %v, want %v %v", tt.sql, joined.Keys, joined.On, tt.keys, tt.on)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package translator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// TokenType identifies the lexical class of a token
+type TokenType int
+
+const (
+	TokenEOF TokenType = iota
+	TokenIdent
+	TokenQuotedIdent
+	TokenKeyword
+	TokenString
+	TokenNumber
+	TokenOperator
+	TokenComma
+	TokenDot
+	TokenLParen
+	TokenRParen
+	TokenLBracket
+	TokenRBracket
+	TokenStar
+	TokenSemicolon
+	TokenParam
+)
+
+func (t TokenType) String() string {
+	switch t {
+	case TokenEOF:
+		return "end of input"
+	case TokenIdent, TokenQuotedIdent:
+		return "identifier"
+	case TokenKeyword:
+		return "keyword"
+	case TokenString:
+		return "string"
+	case TokenNumber:
+		return "number"
+	case TokenOperator:
+		return "operator"
+	case TokenComma:
+		return "','"
+	case TokenDot:
+		return "'.'"
+	case TokenLParen:
+		return "'('"
+	case TokenRParen:
+		return "')'"
+	case TokenLBracket:
+		return "'['"
+	case TokenRBracket:
+		return "']'"
+	case TokenStar:
+		return "'*'"
+	case TokenSemicolon:
+		return "';'"
+	case TokenParam:
+		return "parameter"
+	}
+	return "unknown token"
+}
+
+// keywords are reserved words; they are returned upper-cased as TokenKeyword
+var keywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "AND": true, "OR": true, "NOT": true,
+	"ORDER": true, "BY": true, "ASC": true, "DESC": true, "LIMIT": true, "OFFSET": true,
+	"INSERT": true, "INTO": true, "VALUES": true, "UPDATE": true, "SET": true, "DELETE": true,
+	"BETWEEN": true, "LIKE": true, "ILIKE": true, "IN": true, "AS": true,
+	"GROUP": true, "HAVING": true, "DISTINCT": true,
+	"JOIN": true, "INNER": true, "LEFT": true, "OUTER": true, "ON": true,
+	"IS": true, "NULL": true, "TRUE": true, "FALSE": true,
+}
+
+// Keywords returns the reserved words, sorted, for completion
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
+// Position is a 1-based line and column in the SQL source
+type Position struct {
+	Line   int
+	Column int
+}
+
+// Pos returns the position itself so that AST nodes can embed Position
+func (p Position) Pos() Position {
+	return p
+}
+
+type Token struct {
+	Type  TokenType
+	Value string
+	Pos   Position
+}
+
+func (t Token) String() string {
+	switch t.Type {
+	case TokenEOF, TokenComma, TokenDot, TokenLParen, TokenRParen, TokenLBracket, TokenRBracket, TokenStar, TokenSemicolon:
+		// Punctuation is named by its own text
+		return t.Type.String()
+	case TokenString:
+		return fmt.Sprintf("string '%s'", t.Value)
+	}
+	return fmt.Sprintf("%s '%s'", t.Type, t.Value)
+}
+
+// SyntaxError reports a lexing or parsing failure at a source position
+type SyntaxError struct {
+	Pos Position
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at line %d, column %d: %s", e.Pos.Line, e.Pos.Column, e.Msg)
+}
+
+// Lexer splits SQL source into tokens
+type Lexer struct {
+	input  []rune
+	offset int
+	line   int
+	column int
+}
+
+func NewLexer(input string) *Lexer {
+	return &Lexer{
+		input:  []rune(input),
+		line:   1,
+		column: 1,
+	}
+}
+
+// Tokenize returns all tokens of the input, terminated by a TokenEOF token
+func Tokenize(input string) ([]Token, error) {
+	lexer := NewLexer(input)
+	var tokens []Token
+	for {
+		token, err := lexer.Next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+		if token.Type == TokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+// Next returns the next token, skipping whitespace and comments
+func (l *Lexer) Next() (Token, error) {
+	if err := l.skipWhitespaceAndComments(); err != nil {
+		return Token{}, err
+	}
+
+	pos := Position{Line: l.line, Column: l.column}
+	if l.offset >= len(l.input) {
+		return Token{Type: TokenEOF, Pos: pos}, nil
+	}
+
+	r := l.peek(0)
+	switch {
+	case isIdentStart(r):
+		word := l.readWhile(isIdentPart)
+		upper := strings.ToUpper(word)
+		if keywords[upper] {
+			return Token{Type: TokenKeyword, Value: upper, Pos: pos}, nil
+		}
+		return Token{Type: TokenIdent, Value: word, Pos: pos}, nil
+
+	case unicode.IsDigit(r) || (r == '.' && unicode.IsDigit(l.peek(1))):
+		return l.readNumber(pos)
+
+	case r == '\'':
+		value, err := l.readQuoted('\'', pos)
+		if err != nil {
+			return Token{}, err
+		}
+		return Token{Type: TokenString, Value: value, Pos: pos}, nil
+
+	case r == '$' && unicode.IsDigit(l.peek(1)):
+		// $1, $2, ...: a parameter of a prepared statement, bound later
+		l.advance()
+		return Token{Type: TokenParam, Value: "$" + l.readWhile(unicode.IsDigit), Pos: pos}, nil
+
+	case r == '"' || r == '`':
+		value, err := l.readQuoted(r, pos)
+		if err != nil {
+			return Token{}, err
+		}
+		return Token{Type: TokenQuotedIdent, Value: value, Pos: pos}, nil
+	}
+
+	l.advance()
+	switch r {
+	case ',':
+		return Token{Type: TokenComma, Value: ",", Pos: pos}, nil
+	case '.':
+		return Token{Type: TokenDot, Value: ".", Pos: pos}, nil
+	case '(':
+		return Token{Type: TokenLParen, Value: "(", Pos: pos}, nil
+	case ')':
+		return Token{Type: TokenRParen, Value: ")", Pos: pos}, nil
+	case '[':
+		return Token{Type: TokenLBracket, Value: "[", Pos: pos}, nil
+	case ']':
+		return Token{Type: TokenRBracket, Value: "]", Pos: pos}, nil
+	case '*':
+		return Token{Type: TokenStar, Value: "*", Pos: pos}, nil
+	case ';':
+		return Token{Type: TokenSemicolon, Value: ";", Pos: pos}, nil
+	case '=', '+', '-':
+		return Token{Type: TokenOperator, Value: string(r), Pos: pos}, nil
+	case '<':
+		if l.peek(0) == '=' || l.peek(0) == '>' {
+			op := "<" + string(l.advance())
+			return Token{Type: TokenOperator, Value: op, Pos: pos}, nil
+		}
+		return Token{Type: TokenOperator, Value: "<", Pos: pos}, nil
+	case '>':
+		if l.peek(0) == '=' {
+			l.advance()
+			return Token{Type: TokenOperator, Value: ">=", Pos: pos}, nil
+		}
+		return Token{Type: TokenOperator, Value: ">", Pos: pos}, nil
+	case '!':
+		if l.peek(0) == '=' {
+			l.advance()
+			return Token{Type: TokenOperator, Value: "!=", Pos: pos}, nil
+		}
+	}
+
+	return Token{}, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("unexpected character '%c'", r)}
+}
+
+func (l *Lexer) skipWhitespaceAndComments() error {
+	for l.offset < len(l.input) {
+		r := l.peek(0)
+		switch {
+		case unicode.IsSpace(r):
+			l.advance()
+		case r == '-' && l.peek(1) == '-':
+			for l.offset < len(l.input) && l.peek(0) != '\n' {
+				l.advance()
+			}
+		case r == '/' && l.peek(1) == '*':
+			pos := Position{Line: l.line, Column: l.column}
+			l.advance()
+			l.advance()
+			for {
+				if l.offset >= len(l.input) {
+					return &SyntaxError{Pos: pos, Msg: "unterminated comment"}
+				}
+				if l.peek(0) == '*' && l.peek(1) == '/' {
+					l.advance()
+					l.advance()
+					break
+				}
+				l.advance()
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (l *Lexer) readNumber(pos Position) (Token, error) {
+	start := l.offset
+	l.readWhile(unicode.IsDigit)
+	if l.peek(0) == '.' {
+		l.advance()
+		l.readWhile(unicode.IsDigit)
+	}
+	if r := l.peek(0); r == 'e' || r == 'E' {
+		next := l.peek(1)
+		if unicode.IsDigit(next) || ((next == '+' || next == '-') && unicode.IsDigit(l.peek(2))) {
+			l.advance()
+			l.advance()
+			l.readWhile(unicode.IsDigit)
+		}
+	}
+	if isIdentStart(l.peek(0)) {
+		return Token{}, &SyntaxError{
+			Pos: Position{Line: l.line, Column: l.column},
+			Msg: fmt.Sprintf("invalid number '%s%c'", string(l.input[start:l.offset]), l.peek(0)),
+		}
+	}
+	return Token{Type: TokenNumber, Value: string(l.input[start:l.offset]), Pos: pos}, nil
+}
+
+// readQuoted reads a quoted string or identifier; the closing quote ends it
+func (l *Lexer) readQuoted(quote rune, pos Position) (string, error) {
+	l.advance()
+	var sb strings.Builder
+	for {
+		if l.offset >= len(l.input) {
+			return "", &SyntaxError{Pos: pos, Msg: "unterminated quoted string"}
+		}
+		r := l.advance()
+		if r == quote && l.peek(0) == quote {
+			// A doubled quote stands for itself, as in 'O''Brien'
+			l.advance()
+		} else if r == quote {
+			return sb.String(), nil
+		}
+		sb.WriteRune(r)
+	}
+}
+
+func (l *Lexer) readWhile(pred func(rune) bool) string {
+	start := l.offset
+	for l.offset < len(l.input) && pred(l.peek(0)) {
+		l.advance()
+	}
+	return string(l.input[start:l.offset])
+}
+
+func (l *Lexer) peek(ahead int) rune {
+	if l.offset+ahead >= len(l.input) {
+		return 0
+	}
+	return l.input[l.offset+ahead]
+}
+
+func (l *Lexer) advance() rune {
+	r := l.input[l.offset]
+	l.offset++
+	if r == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+	return r
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,88 @@
+package translator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		input  string
+		tokens []Token
+	}{
+		{
+			input: "select id, \"Name\" FROM pets;",
+			tokens: []Token{
+				{Type: TokenKeyword, Value: "SELECT", Pos: Position{1, 1}},
+				{Type: TokenIdent, Value: "id", Pos: Position{1, 8}},
+				{Type: TokenComma, Value: ",", Pos: Position{1, 10}},
+				{Type: TokenQuotedIdent, Value: "Name", Pos: Position{1, 12}},
+				{Type: TokenKeyword, Value: "FROM", Pos: Position{1, 19}},
+				{Type: TokenIdent, Value: "pets", Pos: Position{1, 24}},
+				{Type: TokenSemicolon, Value: ";", Pos: Position{1, 28}},
+				{Type: TokenEOF, Pos: Position{1, 29}},
+			},
+		},
+		{
+			input: "a<>1 b<=2.5 c>=.5e3 d!=-4",
+			tokens: []Token{
+				{Type: TokenIdent, Value: "a", Pos: Position{1, 1}},
+				{Type: TokenOperator, Value: "<>", Pos: Position{1, 2}},
+				{Type: TokenNumber, Value: "1", Pos: Position{1, 4}},
+				{Type: TokenIdent, Value: "b", Pos: Position{1, 6}},
+				{Type: TokenOperator, Value: "<=", Pos: Position{1, 7}},
+				{Type: TokenNumber, Value: "2.5", Pos: Position{1, 9}},
+				{Type: TokenIdent, Value: "c", Pos: Position{1, 13}},
+				{Type: TokenOperator, Value: ">=", Pos: Position{1, 14}},
+				{Type: TokenNumber, Value: ".5e3", Pos: Position{1, 16}},
+				{Type: TokenIdent, Value: "d", Pos: Position{1, 21}},
+				{Type: TokenOperator, Value: "!=", Pos: Position{1, 22}},
+				{Type: TokenOperator, Value: "-", Pos: Position{1, 24}},
+				{Type: TokenNumber, Value: "4", Pos: Position{1, 25}},
+				{Type: TokenEOF, Pos: Position{1, 26}},
+			},
+		},
+		{
+			input: "'O''Brien' -- comment\n/* block\ncomment */ tags[0]",
+			tokens: []Token{
+				{Type: TokenString, Value: "O'Brien", Pos: Position{1, 1}},
+				{Type: TokenIdent, Value: "tags", Pos: Position{3, 12}},
+				{Type: TokenLBracket, Value: "[", Pos: Position{3, 16}},
+				{Type: TokenNumber, Value: "0", Pos: Position{3, 17}},
+				{Type: TokenRBracket, Value: "]", Pos: Position{3, 18}},
+				{Type: TokenEOF, Pos: Position{3, 19}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tokens, err := Tokenize(tt.input)
+		if err != nil {
+			t.Errorf("%q: %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(tokens, tt.tokens) {
+			t.Errorf("%q: got tokens\n%v\nwant\n%v", tt.input, tokens, tt.tokens)
+		}
+	}
+}
+
+func TestTokenizeErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{input: "SELECT 'abc", err: "syntax error at line 1, column 8: unterminated quoted string"},
+		{input: "SELECT\n  12ab", err: "syntax error at line 2, column 5: invalid number '12a'"},
+		{input: "SELECT # FROM", err: "syntax error at line 1, column 8: unexpected character '#'"},
+		{input: "SELECT /* open", err: "syntax error at line 1, column 8: unterminated comment"},
+		{input: "WHERE a ! b", err: "syntax error at line 1, column 9: unexpected character '!'"},
+	}
+
+	for _, tt := range tests {
+		_, err := Tokenize(tt.input)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%q: got error %v, want %q", tt.input, err, tt.err)
+		}
+	}
+}
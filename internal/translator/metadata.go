@@ -0,0 +1,211 @@
+package translator
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/simonm/qRest/internal/grammar"
+	"github.com/simonm/qRest/internal/parser"
+)
+
+// The virtual tables describing the gateway's own tables. SHOW TABLES and
+// DESCRIBE are parsed into SELECTs on them.
+const (
+	TablesTable    = "information_schema.tables"
+	ColumnsTable   = "information_schema.columns"
+	OperatorsTable = "qrest.operators"
+)
+
+// metadataSchema is the schema the virtual tables list the tables in
+const metadataSchema = "qrest"
+
+// metadataColumn is a column of a virtual table
+type metadataColumn struct {
+	name       string
+	columnType parser.ColumnType
+}
+
+var (
+	textColumn    = parser.ColumnType{Type: "string"}
+	integerColumn = parser.ColumnType{Type: "integer", Format: "int32"}
+	booleanColumn = parser.ColumnType{Type: "boolean"}
+)
+
+// metadataColumns are the columns of each virtual table
+var metadataColumns = map[string][]metadataColumn{
+	TablesTable: {
+		{"table_schema", textColumn}, {"table_name", textColumn}, {"table_type", textColumn},
+		{"http_method", textColumn}, {"path", textColumn}, {"has_paging", booleanColumn}, {"max_limit", integerColumn},
+	},
+	ColumnsTable: {
+		{"table_schema", textColumn}, {"table_name", textColumn}, {"column_name", textColumn},
+		{"ordinal_position", integerColumn}, {"data_type", textColumn}, {"data_format", textColumn},
+		{"is_nullable", textColumn}, {"is_returned", textColumn}, {"is_filterable", textColumn}, {"operators", textColumn},
+	},
+	OperatorsTable: {
+		{"table_schema", textColumn}, {"table_name", textColumn}, {"column_name", textColumn},
+		{"operator", textColumn}, {"parameter", textColumn}, {"location", textColumn},
+	},
+}
+
+// MetadataTable is a virtual table: the capability and grammar statements on
+// it are translated with, and its rows, which are queried in memory
+type MetadataTable struct {
+	Capability parser.APICapability
+	Grammar    grammar.SQLGrammar
+	Records    []map[string]interface{}
+}
+
+// MetadataTableNames returns the names of the virtual tables, sorted
+func MetadataTableNames() []string {
+	names := make([]string, 0, len(metadataColumns))
+	for name := range metadataColumns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupMetadataTable builds the virtual table name from the capabilities and
+// grammars of the tables it describes. It reports false when name is not a
+// virtual table.
+func LookupMetadataTable(name string, capabilities map[string]parser.APICapability, grammars map[string]grammar.SQLGrammar) (MetadataTable, bool) {
+	columns, ok := metadataColumns[name]
+	if !ok {
+		return MetadataTable{}, false
+	}
+
+	table := MetadataTable{
+		Capability: parser.APICapability{TableName: name, ColumnTypes: make(map[string]parser.ColumnType)},
+		Records:    make([]map[string]interface{}, 0),
+	}
+	for _, column := range columns {
+		table.Capability.ResponseColumns = append(table.Capability.ResponseColumns, column.name)
+		table.Capability.ColumnTypes[column.name] = column.columnType
+	}
+	// Every clause is evaluated on the records, with no limit on the rows
+	// returned
+	names := table.Capability.ResponseColumns
+	table.Grammar = grammar.SQLGrammar{
+		TableName:      name,
+		AllowedColumns: names,
+		WhereClause: grammar.WhereGrammar{
+			AllowedColumns: make(map[string][]string),
+			LocalColumns:   names,
+		},
+		OrderBy:     grammar.OrderByGrammar{AllowedColumns: names},
+		Limit:       grammar.LimitGrammar{MaxLimit: math.MaxInt32},
+		ColumnTypes: table.Capability.ColumnTypes,
+	}
+
+	add := func(values ...interface{}) {
+		record := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			record[column.name] = values[i]
+		}
+		table.Records = append(table.Records, record)
+	}
+
+	tableNames := make([]string, 0, len(grammars))
+	for tableName := range grammars {
+		tableNames = append(tableNames, tableName)
+	}
+	sort.Strings(tableNames)
+
+	generator := grammar.NewGrammarGenerator()
+	for _, tableName := range tableNames {
+		capability, tableGrammar := capabilities[tableName], grammars[tableName]
+		switch name {
+		case TablesTable:
+			add(metadataSchema, tableName, "BASE TABLE", capability.Method, capability.Path,
+				tableGrammar.Limit.HasPaging, tableGrammar.Limit.MaxLimit)
+
+		case ColumnsTable:
+			for i, column := range tableColumns(capability, tableGrammar) {
+				operators := tableGrammar.WhereClause.AllowedColumns[column.name]
+				var operatorList interface{}
+				if len(operators) > 0 {
+					operatorList = strings.Join(operators, ", ")
+				}
+				add(metadataSchema, tableName, column.name, i+1, nullString(column.columnType.Type),
+					nullString(column.columnType.Format), yesNo(column.nullable()), yesNo(column.returned), yesNo(len(operators) > 0), operatorList)
+			}
+
+		case OperatorsTable:
+			for _, param := range capability.Parameters {
+				column := generator.FilterColumn(param.Name)
+				if column == "" {
+					continue
+				}
+				operators := param.Operators
+				if len(operators) == 0 {
+					operators = []string{"="}
+				}
+				for _, operator := range operators {
+					add(metadataSchema, tableName, column, operator, param.Name, param.Location)
+				}
+			}
+		}
+	}
+	return table, true
+}
+
+// describedColumn is a column of a table as information_schema.columns
+// lists it
+type describedColumn struct {
+	name       string
+	columnType parser.ColumnType
+	returned   bool
+}
+
+// tableColumns lists the columns a table returns, then those its API filters
+// on without returning them, typed from the parameters that filter them
+func tableColumns(capability parser.APICapability, tableGrammar grammar.SQLGrammar) []describedColumn {
+	var columns []describedColumn
+	returned := make(map[string]bool)
+	for _, column := range tableGrammar.AllowedColumns {
+		returned[column] = true
+		columns = append(columns, describedColumn{name: column, columnType: capability.ColumnTypes[column], returned: true})
+	}
+
+	var filterOnly []string
+	for column := range tableGrammar.WhereClause.AllowedColumns {
+		if !returned[column] {
+			filterOnly = append(filterOnly, column)
+		}
+	}
+	sort.Strings(filterOnly)
+	generator := grammar.NewGrammarGenerator()
+	for _, column := range filterOnly {
+		var columnType parser.ColumnType
+		for _, param := range capability.Parameters {
+			if param.Name == column || columnType.Type == "" && generator.FilterColumn(param.Name) == column {
+				columnType = parser.ColumnType{Type: param.Type, Format: param.Format}
+			}
+		}
+		columns = append(columns, describedColumn{name: column, columnType: columnType})
+	}
+	return columns
+}
+
+// nullable reports whether a column may be null: filter-only columns, which
+// records do not hold, always are
+func (c describedColumn) nullable() bool {
+	return !c.returned || c.columnType.Type == "" || c.columnType.Nullable
+}
+
+// nullString is a text value that is null when empty
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "YES"
+	}
+	return "NO"
+}
@@ -0,0 +1,213 @@
+package translator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/simonm/qRest/internal/grammar"
+	"github.com/simonm/qRest/internal/parser"
+)
+
+func TestParseMetadataStatements(t *testing.T) {
+	tests := []struct {
+		sql     string
+		table   string
+		columns string
+		where   string
+	}{
+		{sql: "SHOW TABLES", table: TablesTable, columns: "table_name, http_method, path"},
+		{sql: "show tables like 'p%'", table: TablesTable, columns: "table_name, http_method, path", where: "(table_name LIKE 'p%')"},
+		{sql: "SHOW TABLES WHERE http_method = 'GET'", table: TablesTable, columns: "table_name, http_method, path", where: "(http_method = 'GET')"},
+		{sql: "DESCRIBE pets", table: ColumnsTable, columns: "column_name, data_type, data_format, is_returned, operators", where: "(table_name = 'pets')"},
+		{sql: "DESC store.orders;", table: ColumnsTable, columns: "column_name, data_type, data_format, is_returned, operators", where: "(table_name = 'store.orders')"},
+		{sql: "SHOW COLUMNS FROM pets", table: ColumnsTable, columns: "column_name, data_type, data_format, is_returned, operators", where: "(table_name = 'pets')"},
+		{sql: "SHOW COLUMNS IN pets", table: ColumnsTable, columns: "column_name, data_type, data_format, is_returned, operators", where: "(table_name = 'pets')"},
+	}
+
+	for _, tt := range tests {
+		stmt, err := Parse(tt.sql)
+		if err != nil {
+			t.Errorf("%s: %v", tt.sql, err)
+			continue
+		}
+		selectStmt := stmt.(*SelectStatement)
+		columns := make([]string, len(selectStmt.Columns))
+		for i, item := range selectStmt.Columns {
+			columns[i] = item.Expr.String()
+		}
+		if got := selectStmt.From.Name; got != tt.table {
+			t.Errorf("%s: got table %s, want %s", tt.sql, got, tt.table)
+		}
+		if got := strings.Join(columns, ", "); got != tt.columns {
+			t.Errorf("%s: got columns %s, want %s", tt.sql, got, tt.columns)
+		}
+		where := ""
+		if selectStmt.Where != nil {
+			where = selectStmt.Where.String()
+		}
+		if where != tt.where {
+			t.Errorf("%s: got where %s, want %s", tt.sql, where, tt.where)
+		}
+	}
+}
+
+func TestParseMetadataErrors(t *testing.T) {
+	tests := []struct {
+		sql string
+		err string
+	}{
+		{sql: "SHOW INDEXES", err: "syntax error at line 1, column 6: expected TABLES or COLUMNS, found identifier 'INDEXES'"},
+		{sql: "SHOW TABLES LIKE 5", err: "syntax error at line 1, column 18"},
+		{sql: "SHOW COLUMNS pets", err: "syntax error at line 1, column 14: expected 'IN', found identifier 'pets'"},
+		{sql: "DESCRIBE", err: "syntax error at line 1, column 9"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.sql)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("%q: got error %v, want %q", tt.sql, err, tt.err)
+		}
+	}
+}
+
+func TestMetadataTableNames(t *testing.T) {
+	want := []string{ColumnsTable, TablesTable, OperatorsTable}
+	if got := MetadataTableNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// metadataAPI is a pets table whose API filters on status, on id with
+// operator suffixes and on tag, which it does not return
+func metadataAPI() (map[string]parser.APICapability, map[string]grammar.SQLGrammar) {
+	capabilities := map[string]parser.APICapability{
+		"pets": {
+			Path:   "/pets",
+			Method: "GET",
+			Parameters: []parser.Parameter{
+				{Name: "status", Type: "string", Location: "query"},
+				{Name: "id_gt", Type: "integer", Format: "int64", Location: "query", Operators: []string{">"}},
+				{Name: "tag", Type: "string", Location: "query"},
+				{Name: "limit", Type: "integer", Location: "query"},
+			},
+			ColumnTypes: map[string]parser.ColumnType{
+				"id":   {Type: "integer", Format: "int64"},
+				"name": {Type: "string", Nullable: true},
+			},
+		},
+		"pets_post": {Path: "/pets", Method: "POST"},
+	}
+	grammars := map[string]grammar.SQLGrammar{
+		"pets": {
+			TableName:      "pets",
+			AllowedColumns: []string{"id", "name"},
+			WhereClause: grammar.WhereGrammar{
+				AllowedColumns: map[string][]string{"id": {">"}, "status": {"="}, "tag": {"="}},
+			},
+			Limit: grammar.LimitGrammar{MaxLimit: 100, HasPaging: true},
+		},
+		"pets_post": {TableName: "pets_post", Limit: grammar.LimitGrammar{MaxLimit: 1}},
+	}
+	return capabilities, grammars
+}
+
+func TestLookupMetadataTable(t *testing.T) {
+	capabilities, grammars := metadataAPI()
+	tests := []struct {
+		table string
+		want  [][]interface{}
+	}{
+		{
+			table: TablesTable,
+			want: [][]interface{}{
+				{"qrest", "pets", "BASE TABLE", "GET", "/pets", true, 100},
+				{"qrest", "pets_post", "BASE TABLE", "POST", "/pets", false, 1},
+			},
+		},
+		{
+			table: ColumnsTable,
+			want: [][]interface{}{
+				{"qrest", "pets", "id", 1, "integer", "int64", "NO", "YES", "YES", ">"},
+				{"qrest", "pets", "name", 2, "string", nil, "YES", "YES", "NO", nil},
+				{"qrest", "pets", "status", 3, "string", nil, "YES", "NO", "YES", "="},
+				{"qrest", "pets", "tag", 4, "string", nil, "YES", "NO", "YES", "="},
+			},
+		},
+		{
+			table: OperatorsTable,
+			want: [][]interface{}{
+				{"qrest", "pets", "status", "=", "status", "query"},
+				{"qrest", "pets", "id", ">", "id_gt", "query"},
+				{"qrest", "pets", "tag", "=", "tag", "query"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		table, ok := LookupMetadataTable(tt.table, capabilities, grammars)
+		if !ok {
+			t.Errorf("%s: not found", tt.table)
+			continue
+		}
+		var rows [][]interface{}
+		for _, record := range table.Records {
+			row := make([]interface{}, len(table.Capability.ResponseColumns))
+			for i, column := range table.Capability.ResponseColumns {
+				row[i] = record[column]
+			}
+			rows = append(rows, row)
+		}
+		if !reflect.DeepEqual(rows, tt.want) {
+			t.Errorf("%s: got rows %v, want %v", tt.table, rows, tt.want)
+		}
+	}
+
+	if _, ok := LookupMetadataTable("information_schema.views", capabilities, grammars); ok {
+		t.Error("found information_schema.views, want no such table")
+	}
+}
+
+func TestParseSQLMetadata(t *testing.T) {
+	capabilities, grammars := metadataAPI()
+	tests := []struct {
+		sql        string
+		columns    []string
+		conditions []Condition
+	}{
+		{
+			sql:        "SHOW TABLES LIKE 'pets%'",
+			columns:    []string{"table_name", "http_method", "path"},
+			conditions: []Condition{{Column: "table_name", Operator: "LIKE", Value: "pets%"}},
+		},
+		{
+			sql:        "DESCRIBE pets",
+			columns:    []string{"column_name", "data_type", "data_format", "is_returned", "operators"},
+			conditions: []Condition{{Column: "table_name", Operator: "=", Value: "pets"}},
+		},
+		{
+			sql:        "SELECT column_name, operator FROM qrest.operators WHERE operator != '=' ORDER BY column_name",
+			columns:    []string{"column_name", "operator"},
+			conditions: []Condition{{Column: "operator", Operator: "!=", Value: "="}},
+		},
+	}
+
+	for _, tt := range tests {
+		stmt, err := Parse(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		table, _ := LookupMetadataTable(stmt.(*SelectStatement).From.Name, capabilities, grammars)
+		query, err := NewSimpleSQLTranslator(table.Grammar).ParseSQL(tt.sql)
+		if err != nil {
+			t.Errorf("%s: %v", tt.sql, err)
+			continue
+		}
+		if !reflect.DeepEqual(query.Columns, tt.columns) {
+			t.Errorf("%s: got columns %v, want %v", tt.sql, query.Columns, tt.columns)
+		}
+		if !reflect.DeepEqual(query.Conditions, tt.conditions) {
+			t.Errorf("%s: got conditions %v, want %v", tt.sql, query.Conditions, tt.conditions)
+		}
+	}
+}
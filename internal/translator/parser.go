@@ -0,0 +1,901 @@
+package translator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parser is a recursive-descent parser producing an AST from SQL tokens
+type Parser struct {
+	tokens []Token
+	pos    int
+}
+
+// Parse parses a single SQL statement, optionally terminated by a semicolon
+func Parse(sql string) (Statement, error) {
+	tokens, err := Tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Parser{tokens: tokens}
+	stmt, err := p.parseStatement()
+	if err != nil {
+		return nil, err
+	}
+
+	p.accept(TokenSemicolon, "")
+	if p.peek().Type != TokenEOF {
+		return nil, p.unexpected("end of statement")
+	}
+
+	return stmt, nil
+}
+
+// ExtractTableName returns the table a statement reads from or writes to
+func ExtractTableName(sql string) (string, error) {
+	stmt, err := Parse(sql)
+	if err != nil {
+		return "", err
+	}
+
+	switch s := stmt.(type) {
+	case *SelectStatement:
+		return s.From.Name, nil
+	case *InsertStatement:
+		return s.Table.Name, nil
+	case *UpdateStatement:
+		return s.Table.Name, nil
+	case *DeleteStatement:
+		return s.Table.Name, nil
+	}
+	return "", fmt.Errorf("unsupported statement")
+}
+
+// ExtractTableNames returns every table a statement refers to, the FROM
+// table first followed by any joined tables
+func ExtractTableNames(sql string) ([]string, error) {
+	stmt, err := Parse(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	selectStmt, ok := stmt.(*SelectStatement)
+	if !ok {
+		name, err := ExtractTableName(sql)
+		if err != nil {
+			return nil, err
+		}
+		return []string{name}, nil
+	}
+
+	names := []string{selectStmt.From.Name}
+	for _, join := range selectStmt.Joins {
+		names = append(names, join.Table.Name)
+	}
+	return names, nil
+}
+
+func (p *Parser) parseStatement() (Statement, error) {
+	token := p.peek()
+	if token.Type == TokenKeyword {
+		switch token.Value {
+		case "SELECT":
+			return p.parseSelect()
+		case "INSERT":
+			return p.parseInsert()
+		case "UPDATE":
+			return p.parseUpdate()
+		case "DELETE":
+			return p.parseDelete()
+		case "DESC":
+			return p.parseDescribe()
+		}
+	}
+	if token.Type == TokenIdent {
+		switch strings.ToUpper(token.Value) {
+		case "SHOW":
+			return p.parseShow()
+		case "DESCRIBE":
+			return p.parseDescribe()
+		}
+	}
+	return nil, &SyntaxError{
+		Pos: token.Pos,
+		Msg: "unsupported SQL statement type. Only SELECT, INSERT, UPDATE, DELETE, SHOW and DESCRIBE are supported",
+	}
+}
+
+func (p *Parser) parseSelect() (*SelectStatement, error) {
+	stmt := &SelectStatement{Position: p.next().Pos}
+
+	for {
+		item, err := p.parseSelectItem()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Columns = append(stmt.Columns, item)
+		if !p.accept(TokenComma, "") {
+			break
+		}
+	}
+
+	if _, err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	table, err := p.parseTableRef(true)
+	if err != nil {
+		return nil, err
+	}
+	stmt.From = table
+
+	for {
+		join, ok, err := p.parseJoin()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		stmt.Joins = append(stmt.Joins, join)
+	}
+
+	if p.acceptKeyword("WHERE") {
+		if stmt.Where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.acceptKeyword("GROUP") {
+		if _, err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			stmt.GroupBy = append(stmt.GroupBy, expr)
+			if !p.accept(TokenComma, "") {
+				break
+			}
+		}
+	}
+
+	if p.acceptKeyword("HAVING") {
+		if stmt.Having, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.acceptKeyword("ORDER") {
+		if _, err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			item := OrderItem{Position: p.peek().Pos}
+			if item.Expr, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+			if p.acceptKeyword("DESC") {
+				item.Desc = true
+			} else {
+				p.acceptKeyword("ASC")
+			}
+			if p.acceptWord("NULLS") {
+				switch {
+				case p.acceptWord("FIRST"):
+					item.Nulls = "FIRST"
+				case p.acceptWord("LAST"):
+					item.Nulls = "LAST"
+				default:
+					return nil, p.unexpected("FIRST or LAST")
+				}
+			}
+			stmt.OrderBy = append(stmt.OrderBy, item)
+			if !p.accept(TokenComma, "") {
+				break
+			}
+		}
+	}
+
+	if p.acceptKeyword("LIMIT") {
+		if stmt.Limit, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("OFFSET") {
+		if stmt.Offset, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	return stmt, nil
+}
+
+func (p *Parser) parseSelectItem() (SelectItem, error) {
+	item := SelectItem{Position: p.peek().Pos}
+	if p.accept(TokenStar, "") {
+		item.Star = true
+		return item, nil
+	}
+	if token := p.peek(); (token.Type == TokenIdent || token.Type == TokenQuotedIdent) && p.peekAt(1).Type == TokenDot && p.peekAt(2).Type == TokenStar {
+		// table.*
+		p.next()
+		p.next()
+		p.next()
+		item.Star = true
+		item.Table = token.Value
+		return item, nil
+	}
+
+	expr, err := p.parseExpr()
+	if err != nil {
+		return item, err
+	}
+	item.Expr = expr
+
+	if p.acceptKeyword("AS") {
+		alias, err := p.expectIdent()
+		if err != nil {
+			return item, err
+		}
+		item.Alias = alias.Name
+	} else if token := p.peek(); token.Type == TokenIdent || token.Type == TokenQuotedIdent {
+		p.next()
+		item.Alias = token.Value
+	}
+
+	return item, nil
+}
+
+// parseTableRef parses a table name, optionally followed by an alias
+func (p *Parser) parseTableRef(allowAlias bool) (*TableRef, error) {
+	name, err := p.parseQualifiedName()
+	if err != nil {
+		return nil, err
+	}
+	table := &TableRef{Position: name.Position, Name: name.Name}
+
+	if !allowAlias {
+		return table, nil
+	}
+	if p.acceptKeyword("AS") {
+		alias, err := p.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+		table.Alias = alias.Name
+	} else if token := p.peek(); token.Type == TokenIdent || token.Type == TokenQuotedIdent {
+		p.next()
+		table.Alias = token.Value
+	}
+	return table, nil
+}
+
+// parseJoin parses a JOIN clause; ok is false when the next token starts none
+func (p *Parser) parseJoin() (JoinClause, bool, error) {
+	join := JoinClause{Position: p.peek().Pos, Kind: "INNER"}
+	switch {
+	case p.acceptKeyword("JOIN"):
+	case p.acceptKeyword("INNER"):
+		if _, err := p.expectKeyword("JOIN"); err != nil {
+			return join, false, err
+		}
+	case p.acceptKeyword("LEFT"):
+		join.Kind = "LEFT"
+		p.acceptKeyword("OUTER")
+		if _, err := p.expectKeyword("JOIN"); err != nil {
+			return join, false, err
+		}
+	default:
+		return join, false, nil
+	}
+
+	table, err := p.parseTableRef(true)
+	if err != nil {
+		return join, false, err
+	}
+	join.Table = table
+
+	if _, err := p.expectKeyword("ON"); err != nil {
+		return join, false, err
+	}
+	if join.On, err = p.parseExpr(); err != nil {
+		return join, false, err
+	}
+	return join, true, nil
+}
+
+// parseQualifiedName parses a possibly dotted and indexed name such as
+// "o.petId" or "tags[0].name"
+func (p *Parser) parseQualifiedName() (*Identifier, error) {
+	ident, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.peek().Type == TokenDot && p.peekAt(1).Type != TokenStar:
+			p.next()
+			part, err := p.expectIdent()
+			if err != nil {
+				return nil, err
+			}
+			ident.Name += "." + part.Name
+			ident.Quoted = ident.Quoted || part.Quoted
+
+		case p.accept(TokenLBracket, ""):
+			index, err := p.expect(TokenNumber, "")
+			if err != nil {
+				return nil, err
+			}
+			if _, err := strconv.Atoi(index.Value); err != nil {
+				return nil, &SyntaxError{Pos: index.Pos, Msg: fmt.Sprintf("invalid array index '%s'", index.Value)}
+			}
+			if _, err := p.expect(TokenRBracket, ""); err != nil {
+				return nil, err
+			}
+			ident.Name += "[" + index.Value + "]"
+
+		default:
+			return ident, nil
+		}
+	}
+}
+
+// parseShow parses SHOW TABLES [LIKE 'pattern' | WHERE condition] and SHOW
+// COLUMNS FROM table into SELECTs on the virtual tables
+func (p *Parser) parseShow() (*SelectStatement, error) {
+	pos := p.next().Pos
+	switch {
+	case p.acceptWord("TABLES"):
+		stmt := metadataSelect(pos, TablesTable, "table_name", "http_method", "path")
+		switch {
+		case p.acceptKeyword("LIKE"):
+			pattern, err := p.expect(TokenString, "")
+			if err != nil {
+				return nil, err
+			}
+			stmt.Where = &BinaryExpr{
+				Position: pattern.Pos,
+				Op:       "LIKE",
+				Left:     &Identifier{Position: pattern.Pos, Name: "table_name"},
+				Right:    &StringLiteral{Position: pattern.Pos, Value: pattern.Value},
+			}
+		case p.acceptKeyword("WHERE"):
+			where, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			stmt.Where = where
+		}
+		return stmt, nil
+
+	case p.acceptWord("COLUMNS"):
+		if !p.acceptKeyword("FROM") {
+			if _, err := p.expectKeyword("IN"); err != nil {
+				return nil, err
+			}
+		}
+		return p.parseDescribedTable(pos)
+	}
+	return nil, p.unexpected("TABLES or COLUMNS")
+}
+
+// parseDescribe parses DESCRIBE table or DESC table
+func (p *Parser) parseDescribe() (*SelectStatement, error) {
+	return p.parseDescribedTable(p.next().Pos)
+}
+
+// parseDescribedTable reads the table of DESCRIBE or SHOW COLUMNS, whose
+// columns are read from information_schema.columns
+func (p *Parser) parseDescribedTable(pos Position) (*SelectStatement, error) {
+	table, err := p.parseQualifiedName()
+	if err != nil {
+		return nil, err
+	}
+	stmt := metadataSelect(pos, ColumnsTable, "column_name", "data_type", "data_format", "is_returned", "operators")
+	stmt.Where = &BinaryExpr{
+		Position: table.Pos(),
+		Op:       "=",
+		Left:     &Identifier{Position: table.Pos(), Name: "table_name"},
+		Right:    &StringLiteral{Position: table.Pos(), Value: table.Name},
+	}
+	return stmt, nil
+}
+
+// metadataSelect builds SELECT columns FROM table for a statement at pos
+func metadataSelect(pos Position, table string, columns ...string) *SelectStatement {
+	stmt := &SelectStatement{Position: pos, From: &TableRef{Position: pos, Name: table}}
+	for _, column := range columns {
+		stmt.Columns = append(stmt.Columns, SelectItem{Position: pos, Expr: &Identifier{Position: pos, Name: column}})
+	}
+	return stmt
+}
+
+func (p *Parser) parseInsert() (*InsertStatement, error) {
+	stmt := &InsertStatement{Position: p.next().Pos}
+	if _, err := p.expectKeyword("INTO"); err != nil {
+		return nil, err
+	}
+
+	table, err := p.parseTableRef(false)
+	if err != nil {
+		return nil, err
+	}
+	stmt.Table = table
+
+	if _, err := p.expect(TokenLParen, "("); err != nil {
+		return nil, err
+	}
+	for {
+		column, err := p.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Columns = append(stmt.Columns, column)
+		if !p.accept(TokenComma, "") {
+			break
+		}
+	}
+	if _, err := p.expect(TokenRParen, ")"); err != nil {
+		return nil, err
+	}
+
+	if _, err := p.expectKeyword("VALUES"); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(TokenLParen, "("); err != nil {
+		return nil, err
+	}
+	for {
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Values = append(stmt.Values, value)
+		if !p.accept(TokenComma, "") {
+			break
+		}
+	}
+	if _, err := p.expect(TokenRParen, ")"); err != nil {
+		return nil, err
+	}
+
+	return stmt, nil
+}
+
+func (p *Parser) parseUpdate() (*UpdateStatement, error) {
+	stmt := &UpdateStatement{Position: p.next().Pos}
+
+	table, err := p.parseTableRef(false)
+	if err != nil {
+		return nil, err
+	}
+	stmt.Table = table
+
+	if _, err := p.expectKeyword("SET"); err != nil {
+		return nil, err
+	}
+	for {
+		column, err := p.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(TokenOperator, "="); err != nil {
+			return nil, err
+		}
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Assignments = append(stmt.Assignments, Assignment{
+			Position: column.Position,
+			Column:   column,
+			Value:    value,
+		})
+		if !p.accept(TokenComma, "") {
+			break
+		}
+	}
+
+	if p.acceptKeyword("WHERE") {
+		if stmt.Where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	return stmt, nil
+}
+
+func (p *Parser) parseDelete() (*DeleteStatement, error) {
+	stmt := &DeleteStatement{Position: p.next().Pos}
+	if _, err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+
+	table, err := p.parseTableRef(false)
+	if err != nil {
+		return nil, err
+	}
+	stmt.Table = table
+
+	if p.acceptKeyword("WHERE") {
+		if stmt.Where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	return stmt, nil
+}
+
+// Expression grammar, lowest precedence first:
+//
+//	expr       := or
+//	or         := and { OR and }
+//	and        := not { AND not }
+//	not        := NOT not | comparison
+//	comparison := operand [ compOp operand | [NOT] LIKE operand | [NOT] BETWEEN operand AND operand
+//	                      | [NOT] IN ( operand { , operand } ) | IS [NOT] NULL ]
+//	operand    := [+|-] primary
+//	primary    := identifier { . identifier | [ number ] } | string | number | NULL | TRUE | FALSE
+//	              | DATE string | TIMESTAMP string | ( expr )
+func (p *Parser) parseExpr() (Expr, error) {
+	return p.parseOr()
+}
+
+func (p *Parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("OR") {
+		pos := p.next().Pos
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Position: pos, Op: "OR", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *Parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("AND") {
+		pos := p.next().Pos
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Position: pos, Op: "AND", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *Parser) parseNot() (Expr, error) {
+	if p.peekKeyword("NOT") {
+		pos := p.next().Pos
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Position: pos, Op: "NOT", Operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *Parser) parseComparison() (Expr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	token := p.peek()
+	if token.Type == TokenKeyword && token.Value == "IS" {
+		p.next()
+		not := p.acceptKeyword("NOT")
+		if _, err := p.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+		return &IsNullExpr{Position: token.Pos, Expr: left, Not: not}, nil
+	}
+
+	not := false
+	if token.Type == TokenKeyword && token.Value == "NOT" {
+		// NOT here can only introduce a negated LIKE, BETWEEN or IN
+		next := p.peekAt(1)
+		if next.Type == TokenKeyword && (next.Value == "LIKE" || next.Value == "ILIKE" || next.Value == "BETWEEN" || next.Value == "IN") {
+			p.next()
+			not = true
+			token = p.peek()
+		}
+	}
+
+	switch {
+	case token.Type == TokenOperator && isComparisonOperator(token.Value):
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &BinaryExpr{Position: token.Pos, Op: token.Value, Left: left, Right: right}, nil
+
+	case token.Type == TokenKeyword && (token.Value == "LIKE" || token.Value == "ILIKE"):
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		op := token.Value
+		if not {
+			op = "NOT " + op
+		}
+		return &BinaryExpr{Position: token.Pos, Op: op, Left: left, Right: right}, nil
+
+	case token.Type == TokenKeyword && token.Value == "BETWEEN":
+		p.next()
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &BetweenExpr{Position: token.Pos, Expr: left, Low: low, High: high, Not: not}, nil
+
+	case token.Type == TokenKeyword && token.Value == "IN":
+		p.next()
+		if _, err := p.expect(TokenLParen, "("); err != nil {
+			return nil, err
+		}
+		in := &InExpr{Position: token.Pos, Expr: left, Not: not}
+		for {
+			value, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			in.Values = append(in.Values, value)
+			if !p.accept(TokenComma, "") {
+				break
+			}
+		}
+		if _, err := p.expect(TokenRParen, ")"); err != nil {
+			return nil, err
+		}
+		return in, nil
+	}
+
+	return left, nil
+}
+
+func (p *Parser) parseOperand() (Expr, error) {
+	token := p.peek()
+	if token.Type == TokenOperator && (token.Value == "-" || token.Value == "+") {
+		p.next()
+		operand, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		// Fold signs into numeric literals so "-5" stays a literal
+		if number, ok := operand.(*NumberLiteral); ok {
+			if token.Value == "-" {
+				return newNumberLiteral(token.Pos, "-"+number.Raw)
+			}
+			return newNumberLiteral(token.Pos, number.Raw)
+		}
+		return &UnaryExpr{Position: token.Pos, Op: token.Value, Operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *Parser) parsePrimary() (Expr, error) {
+	token := p.peek()
+	switch token.Type {
+	case TokenIdent, TokenQuotedIdent:
+		if token.Type == TokenIdent && p.peekAt(1).Type == TokenLParen {
+			return p.parseFuncCall()
+		}
+		if token.Type == TokenIdent && p.peekAt(1).Type == TokenString {
+			return p.parseTypedLiteral()
+		}
+		return p.parseQualifiedName()
+
+	case TokenKeyword:
+		switch token.Value {
+		case "NULL":
+			p.next()
+			return &NullLiteral{Position: token.Pos}, nil
+		case "TRUE", "FALSE":
+			p.next()
+			return &BoolLiteral{Position: token.Pos, Value: token.Value == "TRUE"}, nil
+		}
+
+	case TokenString:
+		p.next()
+		return &StringLiteral{Position: token.Pos, Value: token.Value}, nil
+
+	case TokenNumber:
+		p.next()
+		return newNumberLiteral(token.Pos, token.Value)
+
+	case TokenParam:
+		p.next()
+		index, err := strconv.Atoi(token.Value[1:])
+		if err != nil || index < 1 {
+			return nil, &SyntaxError{Pos: token.Pos, Msg: fmt.Sprintf("invalid parameter '%s'", token.Value)}
+		}
+		return &Placeholder{Position: token.Pos, Index: index}, nil
+
+	case TokenLParen:
+		p.next()
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(TokenRParen, ")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+
+	return nil, p.unexpected("expression")
+}
+
+// parseTypedLiteral parses DATE 'yyyy-mm-dd' or TIMESTAMP 'yyyy-mm-dd
+// hh:mm:ss', checking that the string is a valid value of the type. DATE and
+// TIMESTAMP are not reserved, so they remain usable as column names.
+func (p *Parser) parseTypedLiteral() (*TypedLiteral, error) {
+	name := p.peek()
+	typeName := strings.ToUpper(name.Value)
+	if typeName != "DATE" && typeName != "TIMESTAMP" {
+		return nil, &SyntaxError{Pos: name.Pos, Msg: fmt.Sprintf("unknown literal type '%s'; expected DATE or TIMESTAMP", name.Value)}
+	}
+	p.next()
+	value := p.next()
+
+	valid := false
+	if typeName == "DATE" {
+		_, err := time.Parse(time.DateOnly, value.Value)
+		valid = err == nil
+	} else {
+		_, valid = parseDateTime(value.Value)
+	}
+	if !valid {
+		return nil, &SyntaxError{Pos: value.Pos, Msg: fmt.Sprintf("invalid %s literal '%s'", typeName, value.Value)}
+	}
+	return &TypedLiteral{Position: name.Pos, Type: typeName, Value: value.Value}, nil
+}
+
+// parseFuncCall parses name([DISTINCT] arg, ...) or name(*)
+func (p *Parser) parseFuncCall() (*FuncCall, error) {
+	name := p.next()
+	call := &FuncCall{Position: name.Pos, Name: strings.ToUpper(name.Value)}
+	p.next() // (
+
+	switch {
+	case p.accept(TokenStar, ""):
+		call.Star = true
+	case p.peek().Type == TokenRParen:
+	default:
+		call.Distinct = p.acceptKeyword("DISTINCT")
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, arg)
+			if !p.accept(TokenComma, "") {
+				break
+			}
+		}
+	}
+
+	if _, err := p.expect(TokenRParen, ")"); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+func newNumberLiteral(pos Position, raw string) (*NumberLiteral, error) {
+	if intVal, err := strconv.Atoi(raw); err == nil {
+		return &NumberLiteral{Position: pos, Raw: raw, Value: intVal}, nil
+	}
+	floatVal, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("invalid number '%s'", raw)}
+	}
+	return &NumberLiteral{Position: pos, Raw: raw, Value: floatVal}, nil
+}
+
+func (p *Parser) peek() Token {
+	return p.peekAt(0)
+}
+
+func (p *Parser) peekAt(ahead int) Token {
+	if p.pos+ahead >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+ahead]
+}
+
+func (p *Parser) next() Token {
+	token := p.peek()
+	if p.pos < len(p.tokens)-1 {
+		p.pos++
+	}
+	return token
+}
+
+func (p *Parser) peekKeyword(keyword string) bool {
+	token := p.peek()
+	return token.Type == TokenKeyword && token.Value == keyword
+}
+
+// accept consumes the next token if it has the given type (and value, when non-empty)
+func (p *Parser) accept(tokenType TokenType, value string) bool {
+	token := p.peek()
+	if token.Type != tokenType || (value != "" && token.Value != value) {
+		return false
+	}
+	p.next()
+	return true
+}
+
+func (p *Parser) acceptKeyword(keyword string) bool {
+	return p.accept(TokenKeyword, keyword)
+}
+
+// acceptWord consumes a non-reserved keyword such as NULLS. These stay plain
+// identifiers in the lexer so they can still be used as column names.
+func (p *Parser) acceptWord(word string) bool {
+	token := p.peek()
+	if token.Type != TokenIdent || !strings.EqualFold(token.Value, word) {
+		return false
+	}
+	p.next()
+	return true
+}
+
+func (p *Parser) expect(tokenType TokenType, value string) (Token, error) {
+	token := p.peek()
+	if token.Type != tokenType || (value != "" && token.Value != value) {
+		want := tokenType.String()
+		if value != "" {
+			want = "'" + value + "'"
+		}
+		return token, p.unexpected(want)
+	}
+	return p.next(), nil
+}
+
+func (p *Parser) expectKeyword(keyword string) (Token, error) {
+	return p.expect(TokenKeyword, keyword)
+}
+
+func (p *Parser) expectIdent() (*Identifier, error) {
+	token := p.peek()
+	if token.Type != TokenIdent && token.Type != TokenQuotedIdent {
+		return nil, p.unexpected("identifier")
+	}
+	p.next()
+	return &Identifier{Position: token.Pos, Name: token.Value, Quoted: token.Type == TokenQuotedIdent}, nil
+}
+
+func (p *Parser) unexpected(want string) error {
+	token := p.peek()
+	return &SyntaxError{
+		Pos: token.Pos,
+		Msg: fmt.Sprintf("expected %s, found %s", want, token),
+	}
+}
@@ -0,0 +1,114 @@
+package translator
+
+import (
+	"testing"
+)
+
+func TestParseWhere(t *testing.T) {
+	tests := []struct {
+		where string
+		want  string
+	}{
+		// AND binds tighter than OR, NOT tighter than AND
+		{where: "a = 1 OR b = 2 AND c = 3", want: "((a = 1) OR ((b = 2) AND (c = 3)))"},
+		{where: "(a = 1 OR b = 2) AND c = 3", want: "(((a = 1) OR (b = 2)) AND (c = 3))"},
+		{where: "NOT a = 1 AND b = 2", want: "((NOT (a = 1)) AND (b = 2))"},
+		{where: "NOT NOT a = 1", want: "(NOT (NOT (a = 1)))"},
+		{where: "a = 1 AND b = 2 AND c = 3", want: "(((a = 1) AND (b = 2)) AND (c = 3))"},
+		{where: "name NOT LIKE 'R%' OR name ILIKE 'r%'", want: "((name NOT LIKE 'R%') OR (name ILIKE 'r%'))"},
+		{where: "price NOT BETWEEN 1 AND 10 AND id = 2", want: "((price NOT BETWEEN 1 AND 10) AND (id = 2))"},
+		{where: "id NOT IN (1, -2, 3.5)", want: "(id NOT IN (1, -2, 3.5))"},
+		{where: "5 < id", want: "(5 < id)"},
+		{where: "category.name = 'dog' AND tags[0].name = 'x'", want: "((category.name = 'dog') AND (tags[0].name = 'x'))"},
+		{where: "born IS NULL OR born is not null", want: "((born IS NULL) OR (born IS NOT NULL))"},
+		{where: "NOT price IS NULL AND a = 1", want: "((NOT (price IS NULL)) AND (a = 1))"},
+		{where: "active = true AND deleted = FALSE AND note = NULL", want: "(((active = TRUE) AND (deleted = FALSE)) AND (note = NULL))"},
+		{where: "born >= DATE '2024-01-31' AND seen < timestamp '2024-01-31 12:00:00'", want: "((born >= DATE '2024-01-31') AND (seen < TIMESTAMP '2024-01-31 12:00:00'))"},
+		{where: "date = 'today' AND timestamp > 5", want: "((date = 'today') AND (timestamp > 5))"},
+	}
+
+	for _, tt := range tests {
+		stmt, err := Parse("SELECT * FROM pets WHERE " + tt.where)
+		if err != nil {
+			t.Errorf("%s: %v", tt.where, err)
+			continue
+		}
+		if got := stmt.(*SelectStatement).Where.String(); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.where, got, tt.want)
+		}
+	}
+}
+
+func TestParseStatements(t *testing.T) {
+	tests := []struct {
+		sql   string
+		check func(Statement) bool
+	}{
+		{
+			sql: "SELECT p.id AS pet, name FROM pets p ORDER BY name DESC LIMIT 5 OFFSET 10;",
+			check: func(s Statement) bool {
+				stmt := s.(*SelectStatement)
+				return len(stmt.Columns) == 2 && stmt.Columns[0].Alias == "pet" && stmt.From.Alias == "p" &&
+					len(stmt.OrderBy) == 1 && stmt.OrderBy[0].Desc && stmt.Limit.String() == "5" && stmt.Offset.String() == "10"
+			},
+		},
+		{
+			sql: "INSERT INTO pets (id, name) VALUES (1, 'Rex')",
+			check: func(s Statement) bool {
+				stmt := s.(*InsertStatement)
+				return stmt.Table.Name == "pets" && len(stmt.Columns) == 2 && len(stmt.Values) == 2
+			},
+		},
+		{
+			sql: "UPDATE pets SET name = 'Rex', status = 'sold' WHERE id = 1",
+			check: func(s Statement) bool {
+				stmt := s.(*UpdateStatement)
+				return len(stmt.Assignments) == 2 && stmt.Where.String() == "(id = 1)"
+			},
+		},
+		{
+			sql: "DELETE FROM pets WHERE id = 1",
+			check: func(s Statement) bool {
+				return s.(*DeleteStatement).Where.String() == "(id = 1)"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		stmt, err := Parse(tt.sql)
+		if err != nil {
+			t.Errorf("%s: %v", tt.sql, err)
+			continue
+		}
+		if !tt.check(stmt) {
+			t.Errorf("%s: unexpected statement %+v", tt.sql, stmt)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		sql string
+		err string
+	}{
+		{sql: "SELECT FROM pets", err: "syntax error at line 1, column 8: expected expression, found keyword 'FROM'"},
+		{sql: "SELECT * pets", err: "syntax error at line 1, column 10: expected 'FROM', found identifier 'pets'"},
+		{sql: "SELECT * FROM pets WHERE (id = 1", err: "syntax error at line 1, column 33: expected ')', found end of input"},
+		{sql: "SELECT * FROM pets WHERE id BETWEEN 1 OR 2", err: "syntax error at line 1, column 39: expected 'AND', found keyword 'OR'"},
+		{sql: "SELECT * FROM pets WHERE id IN ()", err: "syntax error at line 1, column 33: expected expression, found ')'"},
+		{sql: "SELECT *\nFROM pets\nWHERE id = 1 name = 2", err: "syntax error at line 3, column 14: expected end of statement, found identifier 'name'"},
+		{sql: "SELECT * FROM pets WHERE born IS 5", err: "syntax error at line 1, column 34: expected 'NULL', found number '5'"},
+		{sql: "SELECT * FROM pets WHERE born IS NOT TRUE", err: "syntax error at line 1, column 38: expected 'NULL', found keyword 'TRUE'"},
+		{sql: "SELECT * FROM pets WHERE born = DATE '2024-02-30'", err: "syntax error at line 1, column 38: invalid DATE literal '2024-02-30'"},
+		{sql: "SELECT * FROM pets WHERE born = TIMESTAMP 'noon'", err: "syntax error at line 1, column 43: invalid TIMESTAMP literal 'noon'"},
+		{sql: "SELECT * FROM pets WHERE price = MONEY '5'", err: "syntax error at line 1, column 34: unknown literal type 'MONEY'; expected DATE or TIMESTAMP"},
+		{sql: "DROP TABLE pets", err: "syntax error at line 1, column 1"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.sql)
+		if err == nil || len(err.Error()) < len(tt.err) || err.Error()[:len(tt.err)] != tt.err {
+			t.Errorf("%q: got error %v, want %q", tt.sql, err, tt.err)
+		}
+	}
+}
@@ -3,8 +3,8 @@ package translator
 import (
 	"fmt"
 	"regexp"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/simonm/qRest/internal/grammar"
 )
@@ -16,25 +16,86 @@ type ParsedQuery struct {
 	Values      []interface{} // For INSERT
 	Updates     map[string]interface{} // For UPDATE
 	Conditions  []Condition
+	Disjuncts   [][]Condition // WHERE with OR: a row matches if any group matches
+	GroupBy     []string
+	Aggregates  []Aggregate   // computed columns, including those only used by HAVING or ORDER BY
+	Having      [][]Condition // conditions on output columns, OR-ed like Disjuncts
 	OrderBy     []OrderByField
 	Limit       int
 	Offset      int
+	NoRows      bool          // LIMIT 0: the result is empty, so nothing is fetched
+	Params      int           // highest $n placeholder; the query cannot run until they are bound
+	Sources     []JoinSource // tables of a SELECT with JOINs, the FROM table first
 }
 
+// ConditionGroups returns the WHERE clause as OR-ed groups of AND-ed conditions
+func (q *ParsedQuery) ConditionGroups() [][]Condition {
+	if len(q.Disjuncts) > 0 {
+		return q.Disjuncts
+	}
+	return [][]Condition{q.Conditions}
+}
+
+// Condition compares a column with a value. The IN operator carries a []interface{} value;
+// IS NULL and IS NOT NULL carry none.
 type Condition struct {
 	Column   string
 	Operator string
 	Value    interface{}
 }
 
+func (c Condition) String() string {
+	if c.Operator == "IS NULL" || c.Operator == "IS NOT NULL" {
+		return c.Column + " " + c.Operator
+	}
+	switch value := c.Value.(type) {
+	case []interface{}:
+		parts := make([]string, len(value))
+		for i, v := range value {
+			parts[i] = formatValue(v)
+		}
+		return fmt.Sprintf("%s %s (%s)", c.Column, c.Operator, strings.Join(parts, ", "))
+	}
+	return fmt.Sprintf("%s %s %s", c.Column, c.Operator, formatValue(c.Value))
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case nil:
+		return "NULL"
+	case Param:
+		return fmt.Sprintf("$%d", int(v))
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
 type OrderByField struct {
 	Column string
 	Order  string // ASC or DESC
+	Nulls  string // FIRST or LAST
 }
 
-// SimpleSQLTranslator uses regex-based SQL parsing for the PoC
+// SimpleSQLTranslator validates parsed SQL statements against a table's grammar
 type SimpleSQLTranslator struct {
-	grammar grammar.SQLGrammar
+	grammar      grammar.SQLGrammar
+	joinGrammars map[string]grammar.SQLGrammar // tables a SELECT may join, by SQL name
+	scope        *conditionScope              // set while translating conditions that are not on the table's columns
+	qualified    bool                         // columns carry a table alias, as in a joined SELECT
+}
+
+// conditionScope validates condition columns in place of the grammar: HAVING
+// refers to output columns and join filters to columns of several tables.
+// Conditions in such scopes are always evaluated locally.
+type conditionScope struct {
+	checkColumn func(*Identifier) error
+	columnRefs  bool // a condition may compare two columns
 }
 
 func NewSimpleSQLTranslator(grammar grammar.SQLGrammar) *SimpleSQLTranslator {
@@ -48,42 +109,33 @@ func (t *SimpleSQLTranslator) ParseSQL(sql string) (*ParsedQuery, error) {
 		Updates: make(map[string]interface{}),
 	}
 	
-	// Normalize SQL
-	sql = strings.TrimSpace(sql)
-	if !strings.HasSuffix(sql, ";") {
-		sql += ";"
+	stmt, err := Parse(sql)
+	if err != nil {
+		return nil, err
 	}
+	query.Params = countParams(sql)
 	
-	// Determine query type
-	sqlUpper := strings.ToUpper(sql)
-	switch {
-	case strings.HasPrefix(sqlUpper, "SELECT"):
+	// Derive the query from the statement's AST
+	switch s := stmt.(type) {
+	case *SelectStatement:
 		query.QueryType = "SELECT"
-		return t.parseSelectQuery(sql, query)
-	case strings.HasPrefix(sqlUpper, "INSERT"):
+		return t.translateSelect(s, query)
+	case *InsertStatement:
 		query.QueryType = "INSERT"
-		return t.parseInsertQuery(sql, query)
-	case strings.HasPrefix(sqlUpper, "UPDATE"):
+		return t.translateInsert(s, query)
+	case *UpdateStatement:
 		query.QueryType = "UPDATE"
-		return t.parseUpdateQuery(sql, query)
-	case strings.HasPrefix(sqlUpper, "DELETE"):
+		return t.translateUpdate(s, query)
+	case *DeleteStatement:
 		query.QueryType = "DELETE"
-		return t.parseDeleteQuery(sql, query)
+		return t.translateDelete(s, query)
 	default:
 		return nil, fmt.Errorf("unsupported SQL statement type. Only SELECT, INSERT, UPDATE, DELETE are supported")
 	}
 }
 
-func (t *SimpleSQLTranslator) parseSelectQuery(sql string, query *ParsedQuery) (*ParsedQuery, error) {
-	// Extract SELECT columns
-	if err := t.parseSelect(sql, query); err != nil {
-		return nil, err
-	}
-	
-	// Extract FROM table
-	if err := t.parseFrom(sql, query); err != nil {
-		return nil, err
-	}
+func (t *SimpleSQLTranslator) translateSelect(stmt *SelectStatement, query *ParsedQuery) (*ParsedQuery, error) {
+	query.TableName = stmt.From.Name
 	
 	// Validate table name
 	if query.TableName != t.grammar.TableName {
@@ -91,270 +143,416 @@ func (t *SimpleSQLTranslator) parseSelectQuery(sql string, query *ParsedQuery) (
 			query.TableName, t.grammar.TableName)
 	}
 	
-	// Extract WHERE conditions
-	if err := t.parseWhere(sql, query); err != nil {
+	if len(stmt.Joins) > 0 {
+		return t.translateJoinSelect(stmt, query)
+	}
+	
+	// Resolve SELECT columns, grouping and aggregates
+	if isAggregateSelect(stmt) {
+		if err := t.translateAggregateSelect(stmt, query); err != nil {
+			return nil, err
+		}
+	} else if err := t.translateColumns(stmt.Columns, query); err != nil {
 		return nil, err
 	}
 	
-	// Extract ORDER BY
-	if err := t.parseOrderBy(sql, query); err != nil {
+	// Convert WHERE conditions
+	if err := t.translateWhere(stmt.Where, query); err != nil {
 		return nil, err
 	}
 	
-	// Extract LIMIT and OFFSET
-	if err := t.parseLimit(sql, query); err != nil {
+	// Convert ORDER BY
+	if err := t.translateOrderBy(stmt.OrderBy, query); err != nil {
+		return nil, err
+	}
+	
+	// Convert LIMIT and OFFSET
+	if err := t.translateLimit(stmt.Limit, stmt.Offset, query); err != nil {
 		return nil, err
 	}
 	
 	return query, nil
 }
 
-func (t *SimpleSQLTranslator) parseSelect(sql string, query *ParsedQuery) error {
-	// Match SELECT columns FROM
-	re := regexp.MustCompile(`(?i)SELECT\s+(.+?)\s+FROM`)
-	matches := re.FindStringSubmatch(sql)
-	if len(matches) < 2 {
-		return fmt.Errorf("invalid SELECT statement")
-	}
-	
-	columnsStr := strings.TrimSpace(matches[1])
-	
-	if columnsStr == "*" {
-		// SELECT * - use all available columns
-		query.Columns = append(query.Columns, t.grammar.AllowedColumns...)
-	} else {
-		// Parse column list
-		columns := strings.Split(columnsStr, ",")
-		for _, col := range columns {
-			columnName := strings.TrimSpace(col)
-			
-			// Remove quotes if present
-			columnName = strings.Trim(columnName, "\"'`")
-			
-			// Validate column against grammar
-			if !t.isColumnAllowed(columnName) {
-				return fmt.Errorf("column '%s' not available. Available columns: %v", 
-					columnName, t.grammar.AllowedColumns)
+func (t *SimpleSQLTranslator) translateColumns(items []SelectItem, query *ParsedQuery) error {
+	for _, item := range items {
+		if item.Star && item.Table != "" {
+			// SELECT t.* - the columns qualified by that table's alias in a
+			// join, or every column when t is the only table
+			qualified := 0
+			for _, column := range t.grammar.AllowedColumns {
+				if strings.HasPrefix(column, item.Table+".") {
+					if t.starColumn(column) {
+						query.Columns = append(query.Columns, column)
+					}
+					qualified++
+				}
 			}
-			
-			query.Columns = append(query.Columns, columnName)
+			if qualified > 0 {
+				continue
+			}
+			if item.Table != query.TableName {
+				return errorAt(item.Pos(), "unknown table '%s' in %s.*", item.Table, item.Table)
+			}
+		}
+		if item.Star {
+			// SELECT * - use all available columns
+			for _, column := range t.grammar.AllowedColumns {
+				if t.starColumn(column) {
+					query.Columns = append(query.Columns, column)
+				}
+			}
+			continue
 		}
+		
+		ident, ok := item.Expr.(*Identifier)
+		if !ok {
+			return errorAt(item.Pos(), "unsupported expression %s in SELECT list", item.Expr)
+		}
+		
+		// Validate column against grammar
+		if !t.isColumnAllowed(ident.Name) {
+			return errorAt(ident.Pos(), "column '%s' not available. Available columns: %v", 
+				ident.Name, t.grammar.AllowedColumns)
+		}
+		
+		query.Columns = append(query.Columns, ident.Name)
 	}
 	
 	return nil
 }
 
-func (t *SimpleSQLTranslator) parseFrom(sql string, query *ParsedQuery) error {
-	// Match FROM table_name
-	re := regexp.MustCompile(`(?i)FROM\s+(\w+)`)
-	matches := re.FindStringSubmatch(sql)
-	if len(matches) < 2 {
-		return fmt.Errorf("no FROM clause found")
+func (t *SimpleSQLTranslator) translateWhere(where Expr, query *ParsedQuery) error {
+	if where == nil {
+		return nil
 	}
 	
-	query.TableName = strings.TrimSpace(matches[1])
-	return nil
-}
-
-func (t *SimpleSQLTranslator) parseWhere(sql string, query *ParsedQuery) error {
-	// Match WHERE clause
-	re := regexp.MustCompile(`(?i)WHERE\s+(.+?)(?:\s+ORDER\s+BY|\s+LIMIT|\s*;|$)`)
-	matches := re.FindStringSubmatch(sql)
-	if len(matches) < 2 {
-		return nil // No WHERE clause
+	var call *FuncCall
+	walkExpr(where, func(e Expr) {
+		if f, ok := e.(*FuncCall); ok && call == nil {
+			call = f
+		}
+	})
+	if call != nil {
+		return errorAt(call.Pos(), "%s is not allowed in WHERE; filter aggregates with HAVING", call)
 	}
 	
-	whereClause := strings.TrimSpace(matches[1])
-	
-	// Split by AND (we don't support OR yet)
-	conditions := strings.Split(whereClause, " AND ")
+	groups, err := t.toDNF(where, false)
+	if err != nil {
+		return err
+	}
 	
-	for _, condStr := range conditions {
-		condition, err := t.parseCondition(strings.TrimSpace(condStr))
-		if err != nil {
-			return err
-		}
-		query.Conditions = append(query.Conditions, *condition)
+	// A plain AND list stays in Conditions; OR produces alternative groups
+	if len(groups) == 1 {
+		query.Conditions = groups[0]
+	} else {
+		query.Disjuncts = groups
 	}
 	
 	return nil
 }
 
-func (t *SimpleSQLTranslator) parseCondition(condStr string) (*Condition, error) {
-	// Handle different operators
-	operators := []string{">=", "<=", "!=", "<>", ">", "<", "=", "LIKE", "ILIKE"}
-	
-	for _, op := range operators {
-		re := regexp.MustCompile(fmt.Sprintf(`(?i)(\w+)\s*%s\s*(.+)`, regexp.QuoteMeta(op)))
-		matches := re.FindStringSubmatch(condStr)
-		if len(matches) == 3 {
-			column := strings.TrimSpace(matches[1])
-			valueStr := strings.TrimSpace(matches[2])
-			
-			// Validate column
-			if !t.isColumnAllowed(column) {
-				return nil, fmt.Errorf("column '%s' not available for filtering", column)
-			}
-			
-			// Validate operator
-			allowedOps, exists := t.grammar.WhereClause.AllowedColumns[column]
-			if !exists || !contains(allowedOps, op) {
-				return nil, fmt.Errorf("operator '%s' not supported for column '%s'. Allowed: %v", 
-					op, column, allowedOps)
-			}
-			
-			// Parse value
-			value, err := t.parseValue(valueStr)
+// translateCondition converts a single comparison, negating it when it sits under NOT
+func (t *SimpleSQLTranslator) translateCondition(expr Expr, negate bool) (Condition, error) {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		if !isComparisonOperator(e.Op) {
+			return Condition{}, errorAt(e.Pos(), "unsupported condition: %s", e)
+		}
+		
+		ident, valueExpr, op, err := splitComparison(e)
+		if err != nil {
+			return Condition{}, err
+		}
+		if negate {
+			op = negateOperator(op)
+		}
+		
+		if err := t.checkOperator(ident, op); err != nil {
+			return Condition{}, err
+		}
+		
+		value, err := t.columnValue(ident.Name, op, valueExpr)
+		if err != nil {
+			return Condition{}, err
+		}
+		
+		return Condition{
+			Column:   ident.Name,
+			Operator: op,
+			Value:    value,
+		}, nil
+		
+	case *InExpr:
+		ident, ok := e.Expr.(*Identifier)
+		if !ok {
+			return Condition{}, errorAt(e.Pos(), "unsupported condition: %s", e)
+		}
+		
+		op := "IN"
+		if e.Not != negate {
+			op = "NOT IN"
+		}
+		if err := t.checkOperator(ident, op); err != nil {
+			return Condition{}, err
+		}
+		
+		values := make([]interface{}, 0, len(e.Values))
+		for _, valueExpr := range e.Values {
+			value, err := t.columnValue(ident.Name, op, valueExpr)
 			if err != nil {
-				return nil, err
+				return Condition{}, err
 			}
-			
-			return &Condition{
-				Column:   column,
-				Operator: op,
-				Value:    value,
-			}, nil
-		}
-	}
-	
-	// Handle BETWEEN
-	re := regexp.MustCompile(`(?i)(\w+)\s+BETWEEN\s+(.+?)\s+AND\s+(.+)`)
-	matches := re.FindStringSubmatch(condStr)
-	if len(matches) == 4 {
-		column := strings.TrimSpace(matches[1])
-		fromStr := strings.TrimSpace(matches[2])
-		toStr := strings.TrimSpace(matches[3])
+			values = append(values, value)
+		}
 		
-		// Validate column and BETWEEN operator
-		if !t.isColumnAllowed(column) {
-			return nil, fmt.Errorf("column '%s' not available for filtering", column)
+		return Condition{
+			Column:   ident.Name,
+			Operator: op,
+			Value:    values,
+		}, nil
+
+	case *IsNullExpr:
+		ident, ok := e.Expr.(*Identifier)
+		if !ok {
+			return Condition{}, errorAt(e.Pos(), "unsupported condition: %s", e)
 		}
 		
-		allowedOps, exists := t.grammar.WhereClause.AllowedColumns[column]
-		if !exists || !contains(allowedOps, "BETWEEN") {
-			return nil, fmt.Errorf("BETWEEN operator not supported for column '%s'", column)
+		op := "IS NULL"
+		if e.Not != negate {
+			op = "IS NOT NULL"
+		}
+		if err := t.checkOperator(ident, op); err != nil {
+			return Condition{}, err
 		}
 		
-		// Parse values
-		fromValue, err := t.parseValue(fromStr)
-		if err != nil {
+		return Condition{
+			Column:   ident.Name,
+			Operator: op,
+		}, nil
+	}
+	
+	return Condition{}, errorAt(expr.Pos(), "unsupported condition: %s", expr)
+}
+
+// translateBetween converts [NOT] BETWEEN. BETWEEN keeps both bounds in one
+// condition; NOT BETWEEN becomes "column < low OR column > high".
+func (t *SimpleSQLTranslator) translateBetween(e *BetweenExpr, negate bool) ([][]Condition, error) {
+	ident, ok := e.Expr.(*Identifier)
+	if !ok {
+		return nil, errorAt(e.Pos(), "unsupported condition: %s", e)
+	}
+	
+	low, err := t.columnValue(ident.Name, "BETWEEN", e.Low)
+	if err != nil {
+		return nil, err
+	}
+	high, err := t.columnValue(ident.Name, "BETWEEN", e.High)
+	if err != nil {
+		return nil, err
+	}
+	
+	if e.Not != negate {
+		if err := t.checkOperator(ident, "<"); err != nil {
 			return nil, err
 		}
-		
-		_, err = t.parseValue(toStr)
-		if err != nil {
+		if err := t.checkOperator(ident, ">"); err != nil {
 			return nil, err
 		}
-		
-		// Return first condition (we'll add the second one separately)
-		// This is a simplified approach - in practice we'd handle BETWEEN properly
-		return &Condition{
-			Column:   column,
-			Operator: ">=",
-			Value:    fromValue,
+		return [][]Condition{
+			{{Column: ident.Name, Operator: "<", Value: low}},
+			{{Column: ident.Name, Operator: ">", Value: high}},
 		}, nil
 	}
 	
-	return nil, fmt.Errorf("unsupported condition: %s", condStr)
+	if err := t.checkOperator(ident, "BETWEEN"); err != nil {
+		return nil, err
+	}
+	return [][]Condition{{{
+		Column:   ident.Name,
+		Operator: "BETWEEN",
+		Value:    []interface{}{low, high},
+	}}}, nil
 }
 
-func (t *SimpleSQLTranslator) parseOrderBy(sql string, query *ParsedQuery) error {
-	// Match ORDER BY clause
-	re := regexp.MustCompile(`(?i)ORDER\s+BY\s+(.+?)(?:\s+LIMIT|\s*;|$)`)
-	matches := re.FindStringSubmatch(sql)
-	if len(matches) < 2 {
-		return nil // No ORDER BY clause
+// checkOperator validates a column and operator used in a WHERE condition
+func (t *SimpleSQLTranslator) checkOperator(ident *Identifier, op string) error {
+	if t.scope != nil {
+		return t.scope.checkColumn(ident)
 	}
 	
-	orderByClause := strings.TrimSpace(matches[1])
+	if !t.isColumnAllowed(ident.Name) {
+		return errorAt(ident.Pos(), "column '%s' not available for filtering", ident.Name)
+	}
 	
-	// Split by comma for multiple columns
-	orderFields := strings.Split(orderByClause, ",")
+	allowedOps, exists := t.grammar.WhereClause.AllowedColumns[ident.Name]
+	if exists && op == "IN" && contains(allowedOps, "=") {
+		// The executor can fall back to one request per value
+		return nil
+	}
+	if exists && op == "BETWEEN" && (contains(allowedOps, ">=") || contains(allowedOps, "<=")) {
+		// The executor sends the bound the API has and applies the other locally
+		return nil
+	}
+	if exists && contains(allowedOps, op) {
+		return nil
+	}
+	if hasColumn(t.grammar.WhereClause.LocalColumns, ident.Name) {
+		// No API parameter: the executor evaluates the condition on the returned records
+		return nil
+	}
 	
-	for _, fieldStr := range orderFields {
-		fieldStr = strings.TrimSpace(fieldStr)
-		parts := strings.Fields(fieldStr)
-		
-		if len(parts) == 0 {
-			continue
+	return errorAt(ident.Pos(), "operator '%s' not supported for column '%s'. Allowed: %v", 
+		op, ident.Name, allowedOps)
+}
+
+func (t *SimpleSQLTranslator) translateOrderBy(items []OrderItem, query *ParsedQuery) error {
+	for _, item := range items {
+		column, err := t.orderByColumn(item, query)
+		if err != nil {
+			return err
 		}
 		
-		column := parts[0]
 		order := "ASC"
-		
-		if len(parts) > 1 {
-			orderWord := strings.ToUpper(parts[1])
-			if orderWord == "DESC" {
-				order = "DESC"
-			}
+		if item.Desc {
+			order = "DESC"
 		}
 		
-		// Validate column
-		if !contains(t.grammar.OrderBy.AllowedColumns, column) {
-			return fmt.Errorf("column '%s' not available for ordering. Available: %v", 
-				column, t.grammar.OrderBy.AllowedColumns)
+		// NULL sorts as the largest value unless NULLS FIRST/LAST says otherwise
+		nulls := item.Nulls
+		if nulls == "" {
+			nulls = "LAST"
+			if item.Desc {
+				nulls = "FIRST"
+			}
 		}
 		
 		query.OrderBy = append(query.OrderBy, OrderByField{
 			Column: column,
 			Order:  order,
+			Nulls:  nulls,
 		})
 	}
 	
 	return nil
 }
 
-func (t *SimpleSQLTranslator) parseLimit(sql string, query *ParsedQuery) error {
+// orderByColumn resolves an ORDER BY item to the column it sorts on. Grouped
+// queries sort their output, so they may also order by an aggregate or alias.
+func (t *SimpleSQLTranslator) orderByColumn(item OrderItem, query *ParsedQuery) (string, error) {
+	switch e := item.Expr.(type) {
+	case *Identifier:
+		if query.IsAggregate() {
+			if !t.outputColumn(query, e.Name) {
+				return "", errorAt(e.Pos(), "column '%s' must appear in the GROUP BY clause or be used in an aggregate function", e.Name)
+			}
+			return e.Name, nil
+		}
+		
+		// Validate column
+		if !hasColumn(t.grammar.OrderBy.AllowedColumns, e.Name) {
+			return "", errorAt(e.Pos(), "column '%s' not available for ordering. Available: %v", 
+				e.Name, t.grammar.OrderBy.AllowedColumns)
+		}
+		return e.Name, nil
+		
+	case *FuncCall:
+		if !query.IsAggregate() {
+			return "", errorAt(e.Pos(), "ORDER BY %s requires an aggregate query", e)
+		}
+		return t.resolveAggregate(e, query)
+	}
+	
+	return "", errorAt(item.Pos(), "unsupported ORDER BY expression %s", item.Expr)
+}
+
+func (t *SimpleSQLTranslator) translateLimit(limitExpr, offsetExpr Expr, query *ParsedQuery) error {
 	// Set default limit
 	query.Limit = t.grammar.Limit.DefaultLimit
 	
-	// Match LIMIT clause
-	re := regexp.MustCompile(`(?i)LIMIT\s+(\d+)(?:\s+OFFSET\s+(\d+))?`)
-	matches := re.FindStringSubmatch(sql)
-	if len(matches) >= 2 {
-		limit, err := strconv.Atoi(matches[1])
+	if limitExpr != nil {
+		limit, err := integerValue(limitExpr, "LIMIT")
 		if err != nil {
-			return fmt.Errorf("invalid LIMIT value: %s", matches[1])
+			return err
 		}
 		
 		if limit > t.grammar.Limit.MaxLimit {
-			return fmt.Errorf("LIMIT %d exceeds maximum allowed limit of %d", 
+			return errorAt(limitExpr.Pos(), "LIMIT %d exceeds maximum allowed limit of %d", 
 				limit, t.grammar.Limit.MaxLimit)
 		}
 		
 		query.Limit = limit
-		
-		// Check for OFFSET
-		if len(matches) >= 3 && matches[2] != "" {
-			offset, err := strconv.Atoi(matches[2])
-			if err != nil {
-				return fmt.Errorf("invalid OFFSET value: %s", matches[2])
-			}
-			query.Offset = offset
+		query.NoRows = limit == 0
+	}
+	
+	if offsetExpr != nil {
+		offset, err := integerValue(offsetExpr, "OFFSET")
+		if err != nil {
+			return err
 		}
+		query.Offset = offset
 	}
 	
 	return nil
 }
 
-func (t *SimpleSQLTranslator) parseValue(valueStr string) (interface{}, error) {
-	// Remove quotes
-	valueStr = strings.Trim(valueStr, "'\"")
-	
-	// Try to parse as number first
-	if intVal, err := strconv.Atoi(valueStr); err == nil {
-		return intVal, nil
-	}
-	if floatVal, err := strconv.ParseFloat(valueStr, 64); err == nil {
-		return floatVal, nil
+// literalValue converts a literal expression to the value sent to the API.
+// NULL is nil and TIMESTAMP literals are normalized to RFC 3339.
+func (t *SimpleSQLTranslator) literalValue(expr Expr) (interface{}, error) {
+	switch e := expr.(type) {
+	case *StringLiteral:
+		return e.Value, nil
+	case *NumberLiteral:
+		return e.Value, nil
+	case *NullLiteral:
+		return nil, nil
+	case *BoolLiteral:
+		return e.Value, nil
+	case *TypedLiteral:
+		if e.Type == "TIMESTAMP" {
+			timestamp, _ := parseDateTime(e.Value)
+			return timestamp.Format(time.RFC3339Nano), nil
+		}
+		return e.Value, nil
+	case *Placeholder:
+		return Param(e.Index), nil
+	case *Identifier:
+		if t.scope != nil && t.scope.columnRefs {
+			if err := t.scope.checkColumn(e); err != nil {
+				return nil, err
+			}
+			return ColumnRef(e.Name), nil
+		}
 	}
-	
-	// Return as string
-	return valueStr, nil
+	return nil, errorAt(expr.Pos(), "expected a literal value, found %s", expr)
 }
 
 func (t *SimpleSQLTranslator) isColumnAllowed(column string) bool {
-	return contains(t.grammar.AllowedColumns, column)
+	return hasColumn(t.grammar.AllowedColumns, column)
+}
+
+// starColumn reports whether SELECT * returns column. Nested paths such as
+// "category.name" are only returned when named.
+func (t *SimpleSQLTranslator) starColumn(column string) bool {
+	if t.qualified {
+		_, column, _ = strings.Cut(column, ".")
+	}
+	return !strings.ContainsAny(column, ".[")
+}
+
+// arrayIndex matches the element index of a column path, e.g. "[0]" in "tags[0].name"
+var arrayIndex = regexp.MustCompile(`\[\d+\]`)
+
+// ElementPath returns the path of a column in any element of its array, e.g.
+// "tags[].name" for "tags[0].name", as response columns list it
+func ElementPath(column string) string {
+	return arrayIndex.ReplaceAllString(column, "[]")
+}
+
+// hasColumn reports whether columns lists column. Array elements are listed
+// once, so "tags[0].name" is found as "tags[].name".
+func hasColumn(columns []string, column string) bool {
+	return contains(columns, column) || contains(columns, ElementPath(column))
 }
 
 func contains(slice []string, item string) bool {
@@ -367,41 +565,26 @@ func contains(slice []string, item string) bool {
 }
 
 // INSERT INTO table (col1, col2) VALUES (val1, val2)
-func (t *SimpleSQLTranslator) parseInsertQuery(sql string, query *ParsedQuery) (*ParsedQuery, error) {
-	// Match INSERT INTO table_name
-	re := regexp.MustCompile(`(?i)INSERT\s+INTO\s+(\w+)\s*\(([^)]+)\)\s*VALUES\s*\(([^)]+)\)`)
-	matches := re.FindStringSubmatch(sql)
-	if len(matches) < 4 {
-		return nil, fmt.Errorf("invalid INSERT statement format")
-	}
-	
-	query.TableName = strings.TrimSpace(matches[1])
+func (t *SimpleSQLTranslator) translateInsert(stmt *InsertStatement, query *ParsedQuery) (*ParsedQuery, error) {
+	query.TableName = stmt.Table.Name
 	
 	// Validate table name
 	expectedTable := strings.TrimSuffix(t.grammar.TableName, "_post")
 	if query.TableName != expectedTable {
-		return nil, fmt.Errorf("table '%s' not found for INSERT. Available table: %s", 
+		return nil, errorAt(stmt.Table.Pos(), "table '%s' not found for INSERT. Available table: %s", 
 			query.TableName, expectedTable)
 	}
 	
-	// Parse columns
-	columns := strings.Split(matches[2], ",")
-	for _, col := range columns {
-		columnName := strings.TrimSpace(col)
-		query.Columns = append(query.Columns, columnName)
-	}
-	
-	// Parse values
-	values := strings.Split(matches[3], ",")
-	if len(values) != len(query.Columns) {
-		return nil, fmt.Errorf("column count doesn't match value count")
+	if len(stmt.Values) != len(stmt.Columns) {
+		return nil, errorAt(stmt.Pos(), "column count doesn't match value count")
 	}
 	
-	for _, val := range values {
-		value, err := t.parseValue(strings.TrimSpace(val))
+	for i, column := range stmt.Columns {
+		value, err := t.columnValue(column.Name, "", stmt.Values[i])
 		if err != nil {
 			return nil, err
 		}
+		query.Columns = append(query.Columns, column.Name)
 		query.Values = append(query.Values, value)
 	}
 	
@@ -409,84 +592,116 @@ func (t *SimpleSQLTranslator) parseInsertQuery(sql string, query *ParsedQuery) (
 }
 
 // UPDATE table SET col1 = val1, col2 = val2 WHERE id = 123
-func (t *SimpleSQLTranslator) parseUpdateQuery(sql string, query *ParsedQuery) (*ParsedQuery, error) {
-	// Match UPDATE table_name SET
-	re := regexp.MustCompile(`(?i)UPDATE\s+(\w+)\s+SET\s+(.+?)(?:\s+WHERE\s+(.+?))?(?:\s*;|$)`)
-	matches := re.FindStringSubmatch(sql)
-	if len(matches) < 3 {
-		return nil, fmt.Errorf("invalid UPDATE statement format")
-	}
-	
-	query.TableName = strings.TrimSpace(matches[1])
+func (t *SimpleSQLTranslator) translateUpdate(stmt *UpdateStatement, query *ParsedQuery) (*ParsedQuery, error) {
+	query.TableName = stmt.Table.Name
 	
 	// Validate table name
 	expectedTable := strings.TrimSuffix(t.grammar.TableName, "_put")
 	expectedTable = strings.TrimSuffix(expectedTable, "_patch")
 	if query.TableName != expectedTable {
-		return nil, fmt.Errorf("table '%s' not found for UPDATE. Available table: %s", 
+		return nil, errorAt(stmt.Table.Pos(), "table '%s' not found for UPDATE. Available table: %s", 
 			query.TableName, expectedTable)
 	}
 	
-	// Parse SET clause
-	setPart := matches[2]
-	assignments := strings.Split(setPart, ",")
-	for _, assignment := range assignments {
-		parts := strings.Split(assignment, "=")
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid SET clause: %s", assignment)
-		}
-		
-		column := strings.TrimSpace(parts[0])
-		value, err := t.parseValue(strings.TrimSpace(parts[1]))
+	// Convert SET clause
+	for _, assignment := range stmt.Assignments {
+		value, err := t.columnValue(assignment.Column.Name, "", assignment.Value)
 		if err != nil {
 			return nil, err
 		}
-		
-		query.Updates[column] = value
+		query.Updates[assignment.Column.Name] = value
 	}
 	
-	// Parse WHERE clause if present
-	if len(matches) > 3 && matches[3] != "" {
-		wherePart := matches[3]
-		condition, err := t.parseCondition(wherePart)
-		if err != nil {
-			return nil, err
-		}
-		query.Conditions = append(query.Conditions, *condition)
+	// Convert WHERE clause if present
+	if err := t.translateWhere(stmt.Where, query); err != nil {
+		return nil, err
+	}
+	if err := checkMutationWhere("UPDATE", stmt.Where, query); err != nil {
+		return nil, err
 	}
 	
 	return query, nil
 }
 
 // DELETE FROM table WHERE id = 123
-func (t *SimpleSQLTranslator) parseDeleteQuery(sql string, query *ParsedQuery) (*ParsedQuery, error) {
-	// Match DELETE FROM table_name
-	re := regexp.MustCompile(`(?i)DELETE\s+FROM\s+(\w+)(?:\s+WHERE\s+(.+?))?(?:\s*;|$)`)
-	matches := re.FindStringSubmatch(sql)
-	if len(matches) < 2 {
-		return nil, fmt.Errorf("invalid DELETE statement format")
-	}
-	
-	query.TableName = strings.TrimSpace(matches[1])
+func (t *SimpleSQLTranslator) translateDelete(stmt *DeleteStatement, query *ParsedQuery) (*ParsedQuery, error) {
+	query.TableName = stmt.Table.Name
 	
 	// Validate table name
 	expectedTable := strings.TrimSuffix(t.grammar.TableName, "_delete")
 	if query.TableName != expectedTable {
-		return nil, fmt.Errorf("table '%s' not found for DELETE. Available table: %s", 
+		return nil, errorAt(stmt.Table.Pos(), "table '%s' not found for DELETE. Available table: %s", 
 			query.TableName, expectedTable)
 	}
 	
-	// Parse WHERE clause if present
-	if len(matches) > 2 && matches[2] != "" {
-		wherePart := matches[2]
-		condition, err := t.parseCondition(wherePart)
-		if err != nil {
-			return nil, err
-		}
-		query.Conditions = append(query.Conditions, *condition)
-	} else {
+	if stmt.Where == nil {
 		return nil, fmt.Errorf("DELETE without WHERE clause is not allowed for safety")
 	}
 	
+	if err := t.translateWhere(stmt.Where, query); err != nil {
+		return nil, err
+	}
+	if err := checkMutationWhere("DELETE", stmt.Where, query); err != nil {
+		return nil, err
+	}
+	
 	return query, nil
-}
\ No newline at end of file
+}
+
+// checkMutationWhere rejects WHERE conditions an UPDATE or DELETE cannot send.
+// The record is addressed by its id in the path and nothing else reaches the
+// API, so any other condition would be silently ignored.
+func checkMutationWhere(statement string, where Expr, query *ParsedQuery) error {
+	if len(query.Disjuncts) > 0 {
+		return errorAt(where.Pos(), "OR conditions are not supported in %s", statement)
+	}
+	for _, condition := range query.Conditions {
+		if condition.Column != "id" || condition.Operator != "=" {
+			return errorAt(where.Pos(), "condition %s is not supported in %s; only id = value can be sent to the API", 
+				condition, statement)
+		}
+	}
+	return nil
+}
+
+// integerValue reads a non-negative integer literal for LIMIT or OFFSET
+func integerValue(expr Expr, clause string) (int, error) {
+	number, ok := expr.(*NumberLiteral)
+	if !ok {
+		return 0, errorAt(expr.Pos(), "invalid %s value: %s", clause, expr)
+	}
+	value, ok := number.Value.(int)
+	if !ok || value < 0 {
+		return 0, errorAt(expr.Pos(), "invalid %s value: %s", clause, number.Raw)
+	}
+	return value, nil
+}
+
+// flipOperator mirrors a comparison so that its operands can be swapped
+func flipOperator(op string) string {
+	switch op {
+	case "<":
+		return ">"
+	case "<=":
+		return ">="
+	case ">":
+		return "<"
+	case ">=":
+		return "<="
+	}
+	return op
+}
+
+// ValidationError reports a well-formed statement that the table's grammar rejects
+type ValidationError struct {
+	Pos Position
+	Msg string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s (line %d, column %d)", e.Msg, e.Pos.Line, e.Pos.Column)
+}
+
+func errorAt(pos Position, format string, args ...interface{}) error {
+	return &ValidationError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,336 @@
+package translator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/simonm/qRest/internal/grammar"
+	"github.com/simonm/qRest/internal/parser"
+)
+
+// petsGrammar is a table with API parameters for id, name and status and
+// columns that are only filtered locally
+func petsGrammar() grammar.SQLGrammar {
+	return grammar.SQLGrammar{
+		TableName:      "pets",
+		AllowedColumns: []string{"id", "name", "status", "price", "born", "active"},
+		ColumnTypes: map[string]parser.ColumnType{
+			"id":     {Type: "integer", Format: "int64"},
+			"name":   {Type: "string"},
+			"status": {Type: "string", Enum: []string{"available", "pending", "sold"}},
+			"price":  {Type: "number", Nullable: true},
+			"born":   {Type: "string", Format: "date", Nullable: true},
+			"active": {Type: "boolean"},
+		},
+		WhereClause: grammar.WhereGrammar{
+			AllowedColumns: map[string][]string{
+				"id":     {"="},
+				"name":   {"=", "LIKE"},
+				"status": {"="},
+			},
+			LocalColumns: []string{"id", "name", "status", "price", "born", "active"},
+		},
+		OrderBy: grammar.OrderByGrammar{AllowedColumns: []string{"id", "name"}},
+		Limit:   grammar.LimitGrammar{MaxLimit: 100, DefaultLimit: 20, HasPaging: true},
+	}
+}
+
+func TestParseSQLMutations(t *testing.T) {
+	tests := []struct {
+		sql        string
+		conditions []Condition
+		err        string
+	}{
+		{sql: "DELETE FROM pets WHERE id = 1", conditions: []Condition{{Column: "id", Operator: "=", Value: 1}}},
+		{sql: "UPDATE pets SET status = 'sold' WHERE id = 1", conditions: []Condition{{Column: "id", Operator: "=", Value: 1}}},
+		{sql: "DELETE FROM pets WHERE id = 1 AND status = 'sold'", err: "condition status = 'sold' is not supported in DELETE"},
+		{sql: "UPDATE pets SET name = 'Rex' WHERE id = 1 AND price > 10", err: "condition price > 10 is not supported in UPDATE"},
+		{sql: "DELETE FROM pets WHERE id > 1", err: "condition id > 1 is not supported in DELETE"},
+		{sql: "DELETE FROM pets WHERE id = 1 OR id = 2", err: "condition id IN (1, 2) is not supported in DELETE"},
+		{sql: "DELETE FROM pets WHERE id = 1 OR name = 'Rex'", err: "OR conditions are not supported in DELETE"},
+		{sql: "DELETE FROM pets", err: "DELETE without WHERE clause"},
+	}
+
+	for _, tt := range tests {
+		query, err := NewSimpleSQLTranslator(petsGrammar()).ParseSQL(tt.sql)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want %q", tt.sql, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.sql, err)
+			continue
+		}
+		if !reflect.DeepEqual(query.Conditions, tt.conditions) {
+			t.Errorf("%s: got conditions %v, want %v", tt.sql, query.Conditions, tt.conditions)
+		}
+	}
+}
+
+func TestParseSQLConditions(t *testing.T) {
+	tests := []struct {
+		sql        string
+		conditions []Condition
+		disjuncts  [][]Condition
+		err        string
+	}{
+		{
+			sql:        "SELECT * FROM pets WHERE name LIKE 'R%'",
+			conditions: []Condition{{Column: "name", Operator: "LIKE", Value: "R%"}},
+		},
+		{
+			sql:        "SELECT * FROM pets WHERE name NOT LIKE 'R%'",
+			conditions: []Condition{{Column: "name", Operator: "NOT LIKE", Value: "R%"}},
+		},
+		{
+			sql:        "SELECT * FROM pets WHERE name NOT ILIKE 'r%'",
+			conditions: []Condition{{Column: "name", Operator: "NOT ILIKE", Value: "r%"}},
+		},
+		{
+			sql:        "SELECT * FROM pets WHERE NOT (name LIKE 'R%')",
+			conditions: []Condition{{Column: "name", Operator: "NOT LIKE", Value: "R%"}},
+		},
+		{
+			sql:        "SELECT * FROM pets WHERE NOT (name NOT LIKE 'R%')",
+			conditions: []Condition{{Column: "name", Operator: "LIKE", Value: "R%"}},
+		},
+		{
+			sql:        "SELECT * FROM pets WHERE id >= 2 AND NOT (price < 10)",
+			conditions: []Condition{{Column: "id", Operator: ">=", Value: 2}, {Column: "price", Operator: ">=", Value: 10}},
+		},
+		{
+			sql: "SELECT * FROM pets WHERE status = 'sold' OR name = 'Rex'",
+			disjuncts: [][]Condition{
+				{{Column: "status", Operator: "=", Value: "sold"}},
+				{{Column: "name", Operator: "=", Value: "Rex"}},
+			},
+		},
+		{
+			sql:        "SELECT * FROM pets WHERE born IS NOT NULL",
+			conditions: []Condition{{Column: "born", Operator: "IS NOT NULL"}},
+		},
+		{
+			sql:        "SELECT * FROM pets WHERE status != 'lost'",
+			conditions: []Condition{{Column: "status", Operator: "!=", Value: "lost"}},
+		},
+		{
+			sql:        "SELECT * FROM pets WHERE status NOT IN ('lost', 'sold')",
+			conditions: []Condition{{Column: "status", Operator: "NOT IN", Value: []interface{}{"lost", "sold"}}},
+		},
+		{
+			sql:        "SELECT * FROM pets WHERE NOT (status = 'lost')",
+			conditions: []Condition{{Column: "status", Operator: "!=", Value: "lost"}},
+		},
+		{sql: "SELECT * FROM pets WHERE owner = 1", err: "column 'owner' not available for filtering"},
+		{sql: "SELECT * FROM pets WHERE status = 'lost'", err: "'lost' is not a value of column 'status'. Allowed: available, pending, sold"},
+		{sql: "SELECT * FROM pets WHERE status IN ('sold', 'lost')", err: "'lost' is not a value of column 'status'"},
+		{sql: "SELECT * FROM pets WHERE price = NULL", err: "price = NULL is never true"},
+	}
+
+	for _, tt := range tests {
+		query, err := NewSimpleSQLTranslator(petsGrammar()).ParseSQL(tt.sql)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want %q", tt.sql, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.sql, err)
+			continue
+		}
+		if !reflect.DeepEqual(query.Conditions, tt.conditions) {
+			t.Errorf("%s: got conditions %v, want %v", tt.sql, query.Conditions, tt.conditions)
+		}
+		if !reflect.DeepEqual(query.Disjuncts, tt.disjuncts) {
+			t.Errorf("%s: got disjuncts %v, want %v", tt.sql, query.Disjuncts, tt.disjuncts)
+		}
+	}
+}
+
+func TestParseSQLLimit(t *testing.T) {
+	tests := []struct {
+		sql    string
+		limit  int
+		offset int
+		noRows bool
+		err    string
+	}{
+		{sql: "SELECT * FROM pets", limit: 20},
+		{sql: "SELECT * FROM pets LIMIT 5 OFFSET 10", limit: 5, offset: 10},
+		{sql: "SELECT * FROM pets LIMIT 0", limit: 0, noRows: true},
+		{sql: "SELECT * FROM pets LIMIT 101", err: "LIMIT 101 exceeds maximum allowed limit of 100"},
+		{sql: "SELECT * FROM pets LIMIT -1", err: "invalid LIMIT value"},
+		{sql: "SELECT * FROM pets LIMIT 'a'", err: "invalid LIMIT value"},
+	}
+
+	for _, tt := range tests {
+		query, err := NewSimpleSQLTranslator(petsGrammar()).ParseSQL(tt.sql)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want %q", tt.sql, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.sql, err)
+			continue
+		}
+		if query.Limit != tt.limit || query.Offset != tt.offset || query.NoRows != tt.noRows {
+			t.Errorf("%s: got limit %d offset %d no rows %v, want %d %d %v", tt.sql,
+				query.Limit, query.Offset, query.NoRows, tt.limit, tt.offset, tt.noRows)
+		}
+	}
+}
+
+func TestParseSQLPlaceholders(t *testing.T) {
+	tests := []struct {
+		sql        string
+		conditions []Condition
+		updates    map[string]interface{}
+		params     int
+		err        string
+	}{
+		{
+			sql:        "SELECT * FROM pets WHERE id = $1 AND status = $2",
+			conditions: []Condition{{Column: "id", Operator: "=", Value: Param(1)}, {Column: "status", Operator: "=", Value: Param(2)}},
+			updates:    map[string]interface{}{},
+			params:     2,
+		},
+		{
+			sql:        "SELECT * FROM pets WHERE id IN ($1, $3)",
+			conditions: []Condition{{Column: "id", Operator: "IN", Value: []interface{}{Param(1), Param(3)}}},
+			updates:    map[string]interface{}{},
+			params:     3,
+		},
+		{
+			sql:        "UPDATE pets SET active = $2 WHERE id = $1",
+			conditions: []Condition{{Column: "id", Operator: "=", Value: Param(1)}},
+			updates:    map[string]interface{}{"active": Param(2)},
+			params:     2,
+		},
+		{
+			sql:        "SELECT * FROM pets WHERE name = '$1'",
+			conditions: []Condition{{Column: "name", Operator: "=", Value: "$1"}},
+			updates:    map[string]interface{}{},
+		},
+		// A literal is coerced whatever it holds
+		{sql: "SELECT * FROM pets WHERE id = '\x001'", err: "column 'id' is an integer"},
+		{sql: "SELECT * FROM pets WHERE id = $0", err: "invalid parameter '$0'"},
+		{sql: "SELECT * FROM pets LIMIT $1", err: "invalid LIMIT value: $1"},
+	}
+
+	for _, tt := range tests {
+		query, err := NewSimpleSQLTranslator(petsGrammar()).ParseSQL(tt.sql)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: got error %v, want %q", tt.sql, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.sql, err)
+			continue
+		}
+		if !reflect.DeepEqual(query.Conditions, tt.conditions) {
+			t.Errorf("%s: got conditions %v, want %v", tt.sql, query.Conditions, tt.conditions)
+		}
+		if !reflect.DeepEqual(query.Updates, tt.updates) {
+			t.Errorf("%s: got updates %v, want %v", tt.sql, query.Updates, tt.updates)
+		}
+		if query.Params != tt.params {
+			t.Errorf("%s: got %d params, want %d", tt.sql, query.Params, tt.params)
+		}
+	}
+}
+
+func TestParseSQLLiterals(t *testing.T) {
+	sqlGrammar := petsGrammar()
+	sqlGrammar.AllowedColumns = append(sqlGrammar.AllowedColumns, "seen")
+	sqlGrammar.ColumnTypes["seen"] = parser.ColumnType{Type: "string", Format: "date-time"}
+	sqlGrammar.WhereClause.LocalColumns = append(sqlGrammar.WhereClause.LocalColumns, "seen")
+
+	tests := []struct {
+		sql        string
+		conditions []Condition
+		disjuncts  [][]Condition
+		values     []interface{}
+		updates    map[string]interface{}
+		err        string
+	}{
+		{
+			sql:        "SELECT * FROM pets WHERE active = TRUE AND price IS NULL",
+			conditions: []Condition{{Column: "active", Operator: "=", Value: true}, {Column: "price", Operator: "IS NULL"}},
+		},
+		{
+			sql:        "SELECT * FROM pets WHERE active = 0",
+			conditions: []Condition{{Column: "active", Operator: "=", Value: false}},
+		},
+		{
+			sql:        "SELECT * FROM pets WHERE born >= DATE '2024-01-31'",
+			conditions: []Condition{{Column: "born", Operator: ">=", Value: "2024-01-31"}},
+		},
+		{
+			sql:        "SELECT * FROM pets WHERE seen < TIMESTAMP '2024-01-31 12:00:00'",
+			conditions: []Condition{{Column: "seen", Operator: "<", Value: "2024-01-31T12:00:00Z"}},
+		},
+		{
+			sql:        "SELECT * FROM pets WHERE seen > TIMESTAMP '2024-01-31T12:00:00.5+02:00'",
+			conditions: []Condition{{Column: "seen", Operator: ">", Value: "2024-01-31T12:00:00.5+02:00"}},
+		},
+		{
+			sql:        "SELECT * FROM pets WHERE NOT (born IS NULL)",
+			conditions: []Condition{{Column: "born", Operator: "IS NOT NULL"}},
+		},
+		{
+			sql: "SELECT * FROM pets WHERE born IS NULL OR price IS NOT NULL",
+			disjuncts: [][]Condition{
+				{{Column: "born", Operator: "IS NULL"}},
+				{{Column: "price", Operator: "IS NOT NULL"}},
+			},
+		},
+		{
+			sql:    "INSERT INTO pets (id, name, price, active) VALUES (1, 'Rex', NULL, FALSE)",
+			values: []interface{}{1, "Rex", nil, false},
+		},
+		{
+			sql:        "UPDATE pets SET price = NULL, active = TRUE WHERE id = 1",
+			conditions: []Condition{{Column: "id", Operator: "=", Value: 1}},
+			updates:    map[string]interface{}{"price": nil, "active": true},
+		},
+		{sql: "SELECT * FROM pets WHERE price != NULL", err: "price != NULL is never true; use price IS NULL or price IS NOT NULL"},
+		{sql: "SELECT * FROM pets WHERE price IN (1, NULL)", err: "price IN NULL is never true"},
+		{sql: "SELECT * FROM pets WHERE name = TRUE", err: "column 'name' is text; TRUE is not"},
+		{sql: "SELECT * FROM pets WHERE born = TIMESTAMP '2024-01-31 12:00:00'", err: "column 'born' is a date (YYYY-MM-DD)"},
+		{sql: "SELECT * FROM pets WHERE owner IS NULL", err: "column 'owner' not available for filtering"},
+	}
+
+	for _, tt := range tests {
+		query, err := NewSimpleSQLTranslator(sqlGrammar).ParseSQL(tt.sql)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want %q", tt.sql, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.sql, err)
+			continue
+		}
+		if !reflect.DeepEqual(query.Conditions, tt.conditions) {
+			t.Errorf("%s: got conditions %v, want %v", tt.sql, query.Conditions, tt.conditions)
+		}
+		if !reflect.DeepEqual(query.Disjuncts, tt.disjuncts) {
+			t.Errorf("%s: got disjuncts %v, want %v", tt.sql, query.Disjuncts, tt.disjuncts)
+		}
+		if !reflect.DeepEqual(query.Values, tt.values) {
+			t.Errorf("%s: got values %v, want %v", tt.sql, query.Values, tt.values)
+		}
+		if tt.updates != nil && !reflect.DeepEqual(query.Updates, tt.updates) {
+			t.Errorf("%s: got updates %v, want %v", tt.sql, query.Updates, tt.updates)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package translator
+
+// maxDisjuncts bounds how many OR groups a WHERE clause may expand to; each
+// group can cost the executor a separate API request
+const maxDisjuncts = 16
+
+// toDNF rewrites a boolean expression into disjunctive normal form: a list of
+// groups that are OR-ed together, each group being AND-ed conditions. NOT is
+// pushed down to the comparisons using De Morgan's laws.
+func (t *SimpleSQLTranslator) toDNF(expr Expr, negate bool) ([][]Condition, error) {
+	switch e := expr.(type) {
+	case *UnaryExpr:
+		if e.Op == "NOT" {
+			return t.toDNF(e.Operand, !negate)
+		}
+
+	case *BetweenExpr:
+		return t.translateBetween(e, negate)
+
+	case *BinaryExpr:
+		if e.Op != "AND" && e.Op != "OR" {
+			break
+		}
+
+		isOr := e.Op == "OR"
+		if negate {
+			isOr = !isOr
+		}
+
+		// col = 'a' OR col = 'b' becomes a single multi-value condition
+		if isOr && !negate {
+			if condition, ok, err := t.equalityList(e); err != nil || ok {
+				return [][]Condition{{condition}}, err
+			}
+		}
+
+		left, err := t.toDNF(e.Left, negate)
+		if err != nil {
+			return nil, err
+		}
+		right, err := t.toDNF(e.Right, negate)
+		if err != nil {
+			return nil, err
+		}
+
+		var groups [][]Condition
+		if isOr {
+			groups = append(left, right...)
+		} else {
+			// (a OR b) AND (c OR d) => ac OR ad OR bc OR bd
+			for _, l := range left {
+				for _, r := range right {
+					group := make([]Condition, 0, len(l)+len(r))
+					group = append(group, l...)
+					group = append(group, r...)
+					groups = append(groups, group)
+				}
+			}
+		}
+
+		if len(groups) > maxDisjuncts {
+			return nil, errorAt(e.Pos(), "WHERE clause expands to more than %d OR alternatives", maxDisjuncts)
+		}
+		return groups, nil
+	}
+
+	condition, err := t.translateCondition(expr, negate)
+	if err != nil {
+		return nil, err
+	}
+	return [][]Condition{{condition}}, nil
+}
+
+// equalityList recognises an OR of equality tests against one column and
+// returns it as an IN condition. ok is false when the expression has another shape.
+func (t *SimpleSQLTranslator) equalityList(expr *BinaryExpr) (Condition, bool, error) {
+	var leaves []Expr
+	var collect func(Expr)
+	collect = func(e Expr) {
+		if binary, ok := e.(*BinaryExpr); ok && binary.Op == "OR" {
+			collect(binary.Left)
+			collect(binary.Right)
+			return
+		}
+		leaves = append(leaves, e)
+	}
+	collect(expr)
+
+	var column *Identifier
+	var valueExprs []Expr
+	for _, leaf := range leaves {
+		comparison, ok := leaf.(*BinaryExpr)
+		if !ok || comparison.Op != "=" {
+			return Condition{}, false, nil
+		}
+		ident, valueExpr, _, err := splitComparison(comparison)
+		if err != nil || isColumnRef(valueExpr) {
+			return Condition{}, false, nil
+		}
+		if column != nil && ident.Name != column.Name {
+			return Condition{}, false, nil
+		}
+		column = ident
+		valueExprs = append(valueExprs, valueExpr)
+	}
+
+	if err := t.checkOperator(column, "="); err != nil {
+		return Condition{}, false, err
+	}
+
+	values := make([]interface{}, 0, len(valueExprs))
+	for _, valueExpr := range valueExprs {
+		value, err := t.columnValue(column.Name, "IN", valueExpr)
+		if err != nil {
+			return Condition{}, false, err
+		}
+		values = append(values, value)
+	}
+
+	return Condition{Column: column.Name, Operator: "IN", Value: values}, true, nil
+}
+
+// splitComparison returns the column, value and operator of a comparison,
+// flipping it when written value-first (e.g. "25 < age")
+func splitComparison(e *BinaryExpr) (*Identifier, Expr, string, error) {
+	if ident, ok := e.Left.(*Identifier); ok {
+		return ident, e.Right, e.Op, nil
+	}
+	if ident, ok := e.Right.(*Identifier); ok {
+		return ident, e.Left, flipOperator(e.Op), nil
+	}
+	return nil, nil, "", errorAt(e.Pos(), "condition %s must compare a column with a value", e)
+}
+
+func isColumnRef(expr Expr) bool {
+	_, ok := expr.(*Identifier)
+	return ok
+}
+
+// negateOperator returns the operator matching exactly the rows op rejects
+func negateOperator(op string) string {
+	switch op {
+	case "=":
+		return "!="
+	case "!=", "<>":
+		return "="
+	case "<":
+		return ">="
+	case "<=":
+		return ">"
+	case ">":
+		return "<="
+	case ">=":
+		return "<"
+	case "LIKE", "ILIKE":
+		return "NOT " + op
+	case "NOT LIKE":
+		return "LIKE"
+	case "NOT ILIKE":
+		return "ILIKE"
+	case "IN":
+		return "NOT IN"
+	case "NOT IN":
+		return "IN"
+	case "IS NULL":
+		return "IS NOT NULL"
+	case "IS NOT NULL":
+		return "IS NULL"
+	}
+	return op
+}
@@ -0,0 +1,95 @@
+package translator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWhereToDNF(t *testing.T) {
+	tests := []struct {
+		where      string
+		conditions []Condition
+		disjuncts  [][]Condition
+		err        string
+	}{
+		{
+			where: "NOT (id = 1 OR name = 'Rex')",
+			conditions: []Condition{
+				{Column: "id", Operator: "!=", Value: 1},
+				{Column: "name", Operator: "!=", Value: "Rex"},
+			},
+		},
+		{
+			where: "NOT (id = 1 AND name = 'Rex')",
+			disjuncts: [][]Condition{
+				{{Column: "id", Operator: "!=", Value: 1}},
+				{{Column: "name", Operator: "!=", Value: "Rex"}},
+			},
+		},
+		{
+			// An OR of equalities on one column is a single IN condition
+			where: "(status = 'sold' OR status = 'pending') AND name = 'Rex'",
+			conditions: []Condition{
+				{Column: "status", Operator: "IN", Value: []interface{}{"sold", "pending"}},
+				{Column: "name", Operator: "=", Value: "Rex"},
+			},
+		},
+		{
+			where: "(id = 1 OR name = 'Rex') AND (price > 10 OR price < 1)",
+			disjuncts: [][]Condition{
+				{{Column: "id", Operator: "=", Value: 1}, {Column: "price", Operator: ">", Value: 10}},
+				{{Column: "id", Operator: "=", Value: 1}, {Column: "price", Operator: "<", Value: 1}},
+				{{Column: "name", Operator: "=", Value: "Rex"}, {Column: "price", Operator: ">", Value: 10}},
+				{{Column: "name", Operator: "=", Value: "Rex"}, {Column: "price", Operator: "<", Value: 1}},
+			},
+		},
+		{
+			where:      "NOT id IN (1, 2)",
+			conditions: []Condition{{Column: "id", Operator: "NOT IN", Value: []interface{}{1, 2}}},
+		},
+		{
+			where:      "price BETWEEN 1 AND 10",
+			conditions: []Condition{{Column: "price", Operator: "BETWEEN", Value: []interface{}{1, 10}}},
+		},
+		{
+			where: "NOT price BETWEEN 1 AND 10",
+			disjuncts: [][]Condition{
+				{{Column: "price", Operator: "<", Value: 1}},
+				{{Column: "price", Operator: ">", Value: 10}},
+			},
+		},
+		{
+			where:      "10 <= price",
+			conditions: []Condition{{Column: "price", Operator: ">=", Value: 10}},
+		},
+		{
+			// Five ANDed pairs of alternatives expand to 32 groups
+			where: "(id = 1 OR name = 'a') AND (id = 2 OR name = 'b') AND (id = 3 OR name = 'c') AND " +
+				"(id = 4 OR name = 'd') AND (id = 5 OR name = 'e')",
+			err: "WHERE clause expands to more than 16 OR alternatives",
+		},
+		{where: "id = name", err: "expected a literal value, found name"},
+		{where: "1 = 1", err: "condition (1 = 1) must compare a column with a value"},
+	}
+
+	for _, tt := range tests {
+		query, err := NewSimpleSQLTranslator(petsGrammar()).ParseSQL("SELECT * FROM pets WHERE " + tt.where)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want %q", tt.where, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.where, err)
+			continue
+		}
+		if !reflect.DeepEqual(query.Conditions, tt.conditions) {
+			t.Errorf("%s: got conditions %v, want %v", tt.where, query.Conditions, tt.conditions)
+		}
+		if !reflect.DeepEqual(query.Disjuncts, tt.disjuncts) {
+			t.Errorf("%s: got disjuncts %v, want %v", tt.where, query.Disjuncts, tt.disjuncts)
+		}
+	}
+}
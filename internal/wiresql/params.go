@@ -0,0 +1,132 @@
+package wiresql
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/simonm/qRest/internal/executor"
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/translator"
+)
+
+// paramNumber returns the position of the nth placeholder of a statement:
+// $n gives its own, ? is numbered in order
+func paramNumber(t Token, n int) int {
+	if t.Text == "?" {
+		return n
+	}
+	number, _ := strconv.Atoi(t.Text[1:])
+	return number
+}
+
+// CountParams returns the number of parameters a statement takes: the
+// highest $n it refers to, or its number of ? placeholders
+func CountParams(tokens []Token) int {
+	count, n := 0, 0
+	for _, t := range tokens {
+		if t.Kind == Param {
+			n++
+			if number := paramNumber(t, n); number > count {
+				count = number
+			}
+		}
+	}
+	return count
+}
+
+// SubstituteParams replaces each placeholder with the text value gives the
+// parameter it refers to; previous is the token before it
+func SubstituteParams(sql string, tokens []Token, value func(n int, previous Token) (string, error)) (string, error) {
+	var bound strings.Builder
+	last, n := 0, 0
+	for i, t := range tokens {
+		if t.Kind != Param {
+			continue
+		}
+		n++
+		var previous Token
+		if i > 0 {
+			previous = tokens[i-1]
+		}
+		text, err := value(paramNumber(t, n), previous)
+		if err != nil {
+			return "", err
+		}
+		bound.WriteString(sql[last:t.Start])
+		bound.WriteString(text)
+		last = t.End
+	}
+	bound.WriteString(sql[last:])
+	return bound.String(), nil
+}
+
+// MarkParams prepares a statement to be planned before its parameters are
+// bound: each placeholder becomes the $n the translator reads, except those
+// of LIMIT and OFFSET, which are planned as 0 and typed as integers
+func MarkParams(sql string, tokens []Token, count int) (string, []parser.ColumnType) {
+	types := make([]parser.ColumnType, count)
+	marked, _ := SubstituteParams(sql, tokens, func(n int, previous Token) (string, error) {
+		if previous.Is("LIMIT") || previous.Is("OFFSET") {
+			if n > 0 && n <= count {
+				types[n-1] = parser.ColumnType{Type: "integer"}
+			}
+			return "0", nil
+		}
+		return "$" + strconv.Itoa(n), nil
+	})
+	return marked, types
+}
+
+// InferParams types the parameters of a translated statement by the columns
+// they are compared with or assigned to, keeping the types already known.
+// Those it cannot infer are left untyped.
+func InferParams(query *translator.ParsedQuery, tables []executor.JoinTable, types []parser.ColumnType) {
+	infer := func(value interface{}, columnType parser.ColumnType) {
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+		for _, value := range values {
+			param, ok := value.(translator.Param)
+			if n := int(param); ok && n > 0 && n <= len(types) && types[n-1].Type == "" {
+				types[n-1] = columnType
+			}
+		}
+	}
+
+	for _, group := range query.ConditionGroups() {
+		for _, condition := range group {
+			infer(condition.Value, executor.ColumnType(tables, query, condition.Column))
+		}
+	}
+	for column, value := range query.Updates {
+		infer(value, executor.ColumnType(tables, query, column))
+	}
+	if query.QueryType == "INSERT" {
+		for i, value := range query.Values {
+			if i < len(query.Columns) {
+				infer(value, executor.ColumnType(tables, query, query.Columns[i]))
+			}
+		}
+	}
+	for i, source := range query.Sources {
+		if source.Query != nil && i < len(tables) {
+			for _, group := range source.Query.ConditionGroups() {
+				for _, condition := range group {
+					infer(condition.Value, tables[i].Capability.ColumnTypes[condition.Column])
+				}
+			}
+		}
+		for _, condition := range source.On {
+			infer(condition.Value, executor.ColumnType(tables, query, condition.Column))
+		}
+	}
+	if len(query.Having) > 0 {
+		types := executor.ColumnTypes(tables, query)
+		for _, group := range query.Having {
+			for _, condition := range group {
+				infer(condition.Value, types[condition.Column])
+			}
+		}
+	}
+}
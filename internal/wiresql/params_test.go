@@ -0,0 +1,95 @@
+package wiresql
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/simonm/qRest/internal/parser"
+)
+
+func TestCountParams(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		sql     string
+		want    int
+	}{
+		{postgres, "SELECT * FROM t WHERE a = $1 AND b = $1", 1},
+		{postgres, "SELECT * FROM t WHERE a = $3 AND b = ?", 3},
+		{postgres, "SELECT '$1', price$2 FROM t -- $4", 0},
+		{mysql, "SELECT * FROM t WHERE a = ? AND b = ? # ?", 2},
+		{mysql, "SELECT '?', `?`, $1 FROM t /* ? */", 0},
+	}
+	for _, tt := range tests {
+		if got := CountParams(tt.dialect.Tokenize(tt.sql)); got != tt.want {
+			t.Errorf("%q: got %d params, want %d", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestSubstituteParams(t *testing.T) {
+	values := func(n int, previous Token) (string, error) {
+		if n > 2 {
+			return "", fmt.Errorf("no parameter %d", n)
+		}
+		return strconv.Itoa(n*10) + "/" + previous.Text, nil
+	}
+	tests := []struct {
+		dialect Dialect
+		sql     string
+		want    string
+		err     string
+	}{
+		{dialect: postgres, sql: "SELECT $2, $1 FROM t WHERE a=$2", want: "SELECT 20/SELECT, 10/, FROM t WHERE a=20/="},
+		{dialect: mysql, sql: "SELECT ?, '?' FROM t LIMIT ?", want: "SELECT 10/SELECT, '?' FROM t LIMIT 20/LIMIT"},
+		{dialect: postgres, sql: "SELECT 1", want: "SELECT 1"},
+		{dialect: mysql, sql: "SELECT ?, ?, ?", err: "no parameter 3"},
+	}
+	for _, tt := range tests {
+		got, err := SubstituteParams(tt.sql, tt.dialect.Tokenize(tt.sql), values)
+		switch {
+		case tt.err != "":
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%q: got error %v, want %q", tt.sql, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("%q: %v", tt.sql, err)
+		case got != tt.want:
+			t.Errorf("%q: got %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestMarkParams(t *testing.T) {
+	integer := parser.ColumnType{Type: "integer"}
+	tests := []struct {
+		dialect Dialect
+		sql     string
+		want    string
+		types   []parser.ColumnType
+	}{
+		{
+			dialect: mysql,
+			sql:     "SELECT id FROM t WHERE name = ? LIMIT ? OFFSET ?",
+			want:    "SELECT id FROM t WHERE name = $1 LIMIT 0 OFFSET 0",
+			types:   []parser.ColumnType{{}, integer, integer},
+		},
+		{
+			dialect: postgres,
+			sql:     "SELECT id FROM t WHERE name = $2 limit $1",
+			want:    "SELECT id FROM t WHERE name = $2 limit 0",
+			types:   []parser.ColumnType{integer, {}},
+		},
+	}
+	for _, tt := range tests {
+		tokens := tt.dialect.Tokenize(tt.sql)
+		got, types := MarkParams(tt.sql, tokens, CountParams(tokens))
+		if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.sql, got, tt.want)
+		}
+		if !reflect.DeepEqual(types, tt.types) {
+			t.Errorf("%q: got types %+v, want %+v", tt.sql, types, tt.types)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Package wiresql splits, tokenizes and binds the statements clients send
+// the wire protocol listeners. A Dialect holds what differs between the
+// protocols: how placeholders are written, # comments and @variables.
+package wiresql
+
+import "strings"
+
+// TokenKind classifies the tokens of a statement as far as a listener needs
+// to route it; the translator parses the statements the gateway runs
+type TokenKind int
+
+const (
+	Word     TokenKind = iota // keyword or identifier
+	Ident                     // quoted identifier
+	String                    // single-quoted string
+	Number                    // numeric literal
+	Param                     // $n or ? placeholder
+	Variable                  // @user or @@system variable
+	Op                        // punctuation and operators
+)
+
+// Token is a word, literal or operator of a statement
+type Token struct {
+	Kind  TokenKind
+	Text  string // as written, quotes included
+	Start int    // byte offsets in the statement
+	End   int
+	Depth int // parentheses enclosing the token
+}
+
+// Value returns the text of a token, strings and quoted identifiers without
+// their quotes
+func (t Token) Value() string {
+	switch t.Kind {
+	case String, Ident:
+		quote := t.Text[:1]
+		return strings.ReplaceAll(t.Text[1:len(t.Text)-1], quote+quote, quote)
+	}
+	return t.Text
+}
+
+// Is reports whether a token is the given word, ignoring case
+func (t Token) Is(word string) bool {
+	return t.Kind == Word && strings.EqualFold(t.Text, word)
+}
+
+// IsName reports whether a token names a table or column
+func (t Token) IsName() bool {
+	return t.Kind == Word || t.Kind == Ident
+}
+
+// Dialect is how a protocol's clients write statements
+type Dialect struct {
+	// Placeholder starts a parameter: '$' for numbered $n parameters, '?'
+	// for positional ones
+	Placeholder byte
+	// Operator is the two-character operator read as one token, such as
+	// Postgres's :: cast
+	Operator string
+	// HashComments starts a comment at #, as MySQL does
+	HashComments bool
+	// Backticks quotes identifiers with ` as well as "
+	Backticks bool
+	// Variables reads @user and @@system variables
+	Variables bool
+}
+
+// Tokenize splits a statement into tokens, skipping whitespace and comments.
+// An unterminated quote runs to the end, for the translator to report.
+// Backslashes do not escape.
+func (d Dialect) Tokenize(sql string) []Token {
+	var tokens []Token
+	depth := 0
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		start := i
+		kind := Op
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+			continue
+		case c == '#' && d.HashComments || strings.HasPrefix(sql[i:], "--"):
+			if end := strings.IndexByte(sql[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(sql)
+			}
+			continue
+		case strings.HasPrefix(sql[i:], "/*"):
+			if end := strings.Index(sql[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(sql)
+			}
+			continue
+		case c == '\'':
+			kind = String
+			i = quoteEnd(sql, i)
+		case c == '"' || c == '`' && d.Backticks:
+			kind = Ident
+			i = quoteEnd(sql, i)
+		case isDigit(c) || c == '.' && i+1 < len(sql) && isDigit(sql[i+1]):
+			kind = Number
+			for i < len(sql) && (isDigit(sql[i]) || sql[i] == '.') {
+				i++
+			}
+			if i+1 < len(sql) && (sql[i] == 'e' || sql[i] == 'E') && (isDigit(sql[i+1]) || sql[i+1] == '-' || sql[i+1] == '+') {
+				for i += 2; i < len(sql) && isDigit(sql[i]); i++ {
+				}
+			}
+		case c == '?' && d.Placeholder == '?':
+			kind = Param
+			i++
+		case c == '$' && d.Placeholder == '$' && i+1 < len(sql) && isDigit(sql[i+1]):
+			kind = Param
+			for i++; i < len(sql) && isDigit(sql[i]); i++ {
+			}
+		case c == '@' && d.Variables:
+			kind = Variable
+			for i++; i < len(sql) && (sql[i] == '@' || sql[i] == '.' || isWordByte(sql[i])); i++ {
+			}
+		case isWordByte(c):
+			kind = Word
+			for i < len(sql) && (isWordByte(sql[i]) || sql[i] == '$') {
+				i++
+			}
+		case d.Operator != "" && strings.HasPrefix(sql[i:], d.Operator):
+			i += len(d.Operator)
+		default:
+			i++
+		}
+
+		t := Token{Kind: kind, Text: sql[start:i], Start: start, End: i, Depth: depth}
+		switch t.Text {
+		case "(":
+			depth++
+		case ")":
+			if depth > 0 {
+				depth--
+			}
+			t.Depth = depth
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens
+}
+
+// quoteEnd returns the offset just past the quoted token starting at i; a
+// doubled quote is part of it
+func quoteEnd(sql string, i int) int {
+	quote := sql[i]
+	for j := i + 1; j < len(sql); j++ {
+		if sql[j] == quote {
+			if j+1 < len(sql) && sql[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(sql)
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c) || c >= 0x80
+}
+
+// SplitStatements splits a query at its semicolons, dropping empty
+// statements
+func (d Dialect) SplitStatements(sql string) []string {
+	var statements []string
+	first := -1
+	for _, t := range d.Tokenize(sql) {
+		switch {
+		case t.Text == ";":
+			if first >= 0 {
+				statements = append(statements, sql[first:t.Start])
+			}
+			first = -1
+		case first < 0:
+			first = t.Start
+		}
+	}
+	if first >= 0 {
+		statements = append(statements, sql[first:])
+	}
+	return statements
+}
+
+// SplitList splits tokens at the commas outside parentheses
+func SplitList(tokens []Token) [][]Token {
+	if len(tokens) == 0 {
+		return nil
+	}
+	var items [][]Token
+	depth, start := tokens[0].Depth, 0
+	for i, t := range tokens {
+		if t.Text == "," && t.Depth == depth {
+			items = append(items, tokens[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, tokens[start:])
+}
+
+// Describe writes tokens back as text for error messages
+func Describe(tokens []Token) string {
+	texts := make([]string, len(tokens))
+	for i, t := range tokens {
+		texts[i] = t.Text
+	}
+	return strings.Join(texts, " ")
+}
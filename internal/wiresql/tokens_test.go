@@ -0,0 +1,133 @@
+package wiresql
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var (
+	postgres = Dialect{Placeholder: '$', Operator: "::"}
+	mysql    = Dialect{Placeholder: '?', Operator: ":=", HashComments: true, Backticks: true, Variables: true}
+)
+
+// kinds names token kinds in test expectations
+var kinds = map[TokenKind]string{Word: "word", Ident: "ident", String: "string", Number: "number", Param: "param", Variable: "variable", Op: "op"}
+
+func describeTokens(tokens []Token) []string {
+	described := make([]string, len(tokens))
+	for i, t := range tokens {
+		described[i] = fmt.Sprintf("%s %s %d", kinds[t.Kind], t.Text, t.Depth)
+	}
+	return described
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		sql     string
+		want    []string
+	}{
+		{
+			dialect: postgres,
+			sql:     "SELECT id::text FROM \"my pets\" WHERE name = 'it''s' AND id > $12",
+			want: []string{
+				"word SELECT 0", "word id 0", "op :: 0", "word text 0", "word FROM 0", `ident "my pets" 0`,
+				"word WHERE 0", "word name 0", "op = 0", "string 'it''s' 0", "word AND 0", "word id 0", "op > 0", "param $12 0",
+			},
+		},
+		{
+			dialect: postgres,
+			sql:     "SELECT ? # 1, `a` -- note\n/* block */ FROM t",
+			want:    []string{"word SELECT 0", "op ? 0", "op # 0", "number 1 0", "op , 0", "op ` 0", "word a 0", "op ` 0", "word FROM 0", "word t 0"},
+		},
+		{
+			dialect: mysql,
+			sql:     "SELECT `my pets`, @x := ?, @@session.autocommit # rest ?\nFROM t -- and ?",
+			want: []string{
+				"word SELECT 0", "ident `my pets` 0", "op , 0", "variable @x 0", "op := 0", "param ? 0", "op , 0",
+				"variable @@session.autocommit 0", "word FROM 0", "word t 0",
+			},
+		},
+		{
+			dialect: mysql,
+			sql:     "SELECT count(*), 1.5e-3, .5 FROM t WHERE id IN (1, (2))",
+			want: []string{
+				"word SELECT 0", "word count 0", "op ( 0", "op * 1", "op ) 0", "op , 0", "number 1.5e-3 0", "op , 0", "number .5 0",
+				"word FROM 0", "word t 0", "word WHERE 0", "word id 0", "word IN 0", "op ( 0", "number 1 1", "op , 1", "op ( 1", "number 2 2", "op ) 1", "op ) 0",
+			},
+		},
+		{
+			dialect: mysql,
+			sql:     "SELECT 'a\\' /* open",
+			want:    []string{"word SELECT 0", `string 'a\' 0`},
+		},
+		{
+			dialect: postgres,
+			sql:     "SELECT 'open",
+			want:    []string{"word SELECT 0", "string 'open 0"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := describeTokens(tt.dialect.Tokenize(tt.sql)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestTokenValue(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"'it''s'", "it's"},
+		{`"say ""hi"""`, `say "hi"`},
+		{"`a``b`", "a`b"},
+		{"Pets", "Pets"},
+	}
+	for _, tt := range tests {
+		tokens := mysql.Tokenize(tt.sql)
+		if len(tokens) != 1 {
+			t.Errorf("%s: got %d tokens, want 1", tt.sql, len(tokens))
+			continue
+		}
+		if got := tokens[0].Value(); got != tt.want {
+			t.Errorf("%s: got value %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		sql     string
+		want    []string
+	}{
+		{postgres, "SELECT 1; SELECT ';'", []string{"SELECT 1", "SELECT ';'"}},
+		{postgres, ";; SELECT 1 -- a; b\n;", []string{"SELECT 1 -- a; b\n"}},
+		{mysql, "SELECT 1 # a; b\n; SET @x = 2;", []string{"SELECT 1 # a; b\n", "SET @x = 2"}},
+		{postgres, "SELECT 1 # a; b", []string{"SELECT 1 # a", "b"}},
+		{mysql, "  ", nil},
+	}
+	for _, tt := range tests {
+		if got := tt.dialect.SplitStatements(tt.sql); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	tokens := postgres.Tokenize("a, f(b, c), 'd,e'")
+	var got []string
+	for _, item := range SplitList(tokens) {
+		got = append(got, Describe(item))
+	}
+	want := []string{"a", "f ( b , c )", "'d,e'"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if items := SplitList(nil); items != nil {
+		t.Errorf("got %q for no tokens, want nil", items)
+	}
+}
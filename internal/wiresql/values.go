@@ -0,0 +1,54 @@
+package wiresql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RecordRows puts the values of records in column order
+func RecordRows(records []map[string]interface{}, columns []string) [][]interface{} {
+	rows := make([][]interface{}, len(records))
+	for i, record := range records {
+		row := make([]interface{}, len(columns))
+		for j, column := range columns {
+			row[j] = record[column]
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+// QuoteString writes s as a SQL string literal
+func QuoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// decimalPattern matches the numbers a statement can spell, which excludes
+// the NaN, Inf and hexadecimal forms ParseFloat also reads
+var decimalPattern = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$`)
+
+// DecimalLiteral returns a numeric parameter as the number literal it is
+// bound as, reporting false unless it is a finite decimal
+func DecimalLiteral(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if !decimalPattern.MatchString(text) {
+		return "", false
+	}
+	if _, err := strconv.ParseFloat(text, 64); err != nil {
+		return "", false
+	}
+	return strings.TrimPrefix(text, "+"), true
+}
+
+// ParseBool reads a boolean as Postgres and MySQL clients spell them
+func ParseBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "t", "true", "y", "yes", "on", "1":
+		return true, nil
+	case "f", "false", "n", "no", "off", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", s)
+}
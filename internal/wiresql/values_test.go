@@ -0,0 +1,67 @@
+package wiresql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecimalLiteral(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+		ok   bool
+	}{
+		{"42", "42", true},
+		{" -7 ", "-7", true},
+		{"+2.50", "2.50", true},
+		{"5.", "5.", true},
+		{".5e-3", ".5e-3", true},
+		{"1E+10", "1E+10", true},
+		{"NaN", "", false},
+		{"-Inf", "", false},
+		{"Infinity", "", false},
+		{"0x10", "", false},
+		{"1_000", "", false},
+		{"1e999", "", false},
+		{"1 OR 1=1", "", false},
+		{"", "", false},
+		{".", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := DecimalLiteral(tt.text)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%q: got %q, %v, want %q, %v", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseBool(t *testing.T) {
+	for _, text := range []string{"t", "TRUE", " yes ", "On", "1", "y"} {
+		if got, err := ParseBool(text); err != nil || !got {
+			t.Errorf("%q: got %v, %v, want true", text, got, err)
+		}
+	}
+	for _, text := range []string{"f", "False", "no", "OFF", "0", "n"} {
+		if got, err := ParseBool(text); err != nil || got {
+			t.Errorf("%q: got %v, %v, want false", text, got, err)
+		}
+	}
+	if _, err := ParseBool("maybe"); err == nil || err.Error() != `invalid boolean "maybe"` {
+		t.Errorf("maybe: got error %v, want invalid boolean", err)
+	}
+}
+
+func TestQuoteString(t *testing.T) {
+	if got, want := QuoteString("it's ''"), "'it''s '''''"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestRecordRows(t *testing.T) {
+	records := []map[string]interface{}{{"id": 1, "name": "Rex"}, {"id": 2}}
+	got := RecordRows(records, []string{"name", "id"})
+	want := [][]interface{}{{"Rex", 1}, {nil, 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+// Package wiretest serves the pets table of an apitest pets API to the
+// tests of the wire protocol listeners
+package wiretest
+
+import (
+	"fmt"
+
+	"github.com/simonm/qRest/internal/apitest"
+	"github.com/simonm/qRest/internal/auth"
+	"github.com/simonm/qRest/internal/executor"
+	"github.com/simonm/qRest/internal/grammar"
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/translator"
+)
+
+// Backend serves the pets table, its id, name and status columns, of the
+// API apitest.NewPets starts
+type Backend struct {
+	capabilities map[string]parser.APICapability
+	grammars     map[string]grammar.SQLGrammar
+	executor     *executor.RESTExecutor
+}
+
+func NewBackend(api *apitest.Server) *Backend {
+	b := &Backend{
+		capabilities: map[string]parser.APICapability{
+			"pets": {
+				Path: "/pets", Method: "GET", TableName: "pets", BaseURL: api.URL,
+				ResponseColumns: []string{"id", "name", "status"},
+				ColumnTypes: map[string]parser.ColumnType{
+					"id":     {Type: "integer"},
+					"name":   {Type: "string"},
+					"status": {Type: "string"},
+				},
+				Parameters: []parser.Parameter{{Name: "status", Type: "string", Location: "query", Operators: []string{"="}}},
+			},
+		},
+		grammars: make(map[string]grammar.SQLGrammar),
+		executor: executor.NewRESTExecutor(auth.None{}),
+	}
+	for name, capability := range b.capabilities {
+		b.grammars[name] = grammar.NewGrammarGenerator().GenerateGrammar(capability)
+	}
+	return b
+}
+
+func (b *Backend) Tables() map[string]parser.APICapability {
+	return b.capabilities
+}
+
+func (b *Backend) Grammars() map[string]grammar.SQLGrammar {
+	return b.grammars
+}
+
+func (b *Backend) Translate(sql string) (*translator.ParsedQuery, []executor.JoinTable, error) {
+	names, err := translator.ExtractTableNames(sql)
+	if err != nil {
+		return nil, nil, err
+	}
+	tables := make([]executor.JoinTable, len(names))
+	for i, name := range names {
+		capability, ok := b.capabilities[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("Table '%s' not found", name)
+		}
+		tables[i] = executor.JoinTable{Capability: capability, Executor: b.executor}
+	}
+	sqlTranslator := translator.NewSimpleSQLTranslator(b.grammars[names[0]])
+	sqlTranslator.SetJoinTables(b.grammars)
+	query, err := sqlTranslator.ParseSQL(sql)
+	return query, tables, err
+}
@@ -0,0 +1,181 @@
+package sqldriver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/simonm/qRest/internal/auth"
+	"github.com/simonm/qRest/internal/cache"
+	"github.com/simonm/qRest/internal/config"
+	"github.com/simonm/qRest/internal/executor"
+	"github.com/simonm/qRest/internal/grammar"
+	"github.com/simonm/qRest/internal/parser"
+	"github.com/simonm/qRest/internal/translator"
+)
+
+// catalog resolves the tables a statement names and runs it. Plain names
+// belong to the API of the data source name; "api.table" names a table of
+// another configured API. Specs are loaded on first use.
+type catalog struct {
+	cfg       *config.Config
+	apiConfig *config.APIConfig
+	flatten   bool
+	specs     *parser.SpecCache
+	responses *cache.Cache // shared by the executors of every API
+
+	mu           sync.Mutex
+	capabilities map[string]map[string]parser.APICapability
+	grammars     map[string]map[string]grammar.SQLGrammar
+	executors    map[string]*executor.RESTExecutor
+}
+
+func newCatalog(cfg *config.Config, apiConfig *config.APIConfig, flatten bool) *catalog {
+	specs := parser.NewSpecCache(config.GetSpecCacheDir())
+	specs.SetOffline(cfg.Defaults.Offline)
+	return &catalog{
+		cfg:          cfg,
+		apiConfig:    apiConfig,
+		flatten:      flatten,
+		specs:        specs,
+		responses:    cache.New(cache.NewStore(cfg.Defaults.CacheBackend, config.GetResponseCacheDir())),
+		capabilities: make(map[string]map[string]parser.APICapability),
+		grammars:     make(map[string]map[string]grammar.SQLGrammar),
+		executors:    make(map[string]*executor.RESTExecutor),
+	}
+}
+
+// resolve returns the table name refers to and the grammar statements on it
+// are checked against. information_schema and qrest name the virtual tables
+// describing the data source's API, unless an API of that name is configured.
+func (c *catalog) resolve(name string) (executor.JoinTable, grammar.SQLGrammar, error) {
+	apiConfig, table := c.apiConfig, name
+	if prefix, rest, qualified := strings.Cut(name, "."); qualified {
+		apiConfig = c.cfg.FindAPI(prefix)
+		if apiConfig == nil {
+			if metadata, ok, err := c.metadataTable(name); ok || err != nil {
+				return executor.JoinTable{Capability: metadata.Capability, Records: metadata.Records}, metadata.Grammar, err
+			}
+			return executor.JoinTable{}, grammar.SQLGrammar{}, fmt.Errorf("API '%s' of table '%s' not found in configuration", prefix, name)
+		}
+		table = rest
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(apiConfig); err != nil {
+		return executor.JoinTable{}, grammar.SQLGrammar{}, err
+	}
+
+	capability, exists := c.capabilities[apiConfig.Name][table]
+	if !exists {
+		available := make([]string, 0, len(c.capabilities[apiConfig.Name]))
+		for table := range c.capabilities[apiConfig.Name] {
+			available = append(available, table)
+		}
+		sort.Strings(available)
+		return executor.JoinTable{}, grammar.SQLGrammar{}, fmt.Errorf("table '%s' not found. Available tables: %v", name, available)
+	}
+
+	// The grammar is named as the statement names the table
+	tableGrammar := c.grammars[apiConfig.Name][table]
+	tableGrammar.TableName = name
+	return executor.JoinTable{Capability: capability, Executor: c.executors[apiConfig.Name]}, tableGrammar, nil
+}
+
+// metadataTable builds the virtual table name describing the tables of the
+// data source's API, reporting false when name is not one
+func (c *catalog) metadataTable(name string) (translator.MetadataTable, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(c.apiConfig); err != nil {
+		return translator.MetadataTable{}, false, err
+	}
+	metadata, ok := translator.LookupMetadataTable(name, c.capabilities[c.apiConfig.Name], c.grammars[c.apiConfig.Name])
+	return metadata, ok, nil
+}
+
+// load parses the spec of an API and sets up its executor unless it already
+// is. The caller holds c.mu.
+func (c *catalog) load(apiConfig *config.APIConfig) error {
+	if _, loaded := c.capabilities[apiConfig.Name]; loaded {
+		return nil
+	}
+
+	apiParser, err := parser.NewOpenAPIParser(
+		apiConfig.SpecURL,
+		apiConfig.BaseURL,
+		apiConfig.Auth.Type,
+		apiConfig.Auth.Token,
+		c.specs,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to load OpenAPI spec for '%s': %w", apiConfig.Name, err)
+	}
+	apiParser.SetFlattenNested(c.flatten || apiConfig.FlattenNested)
+	capabilities, err := apiParser.ParseCapabilities()
+	if err != nil {
+		return fmt.Errorf("failed to parse capabilities for '%s': %w", apiConfig.Name, err)
+	}
+
+	authenticator, err := auth.NewSchemeResolver(apiConfig.Auth)
+	if err != nil {
+		return fmt.Errorf("failed to set up auth for '%s': %w", apiConfig.Name, err)
+	}
+	apiExecutor := executor.NewRESTExecutor(authenticator)
+	apiExecutor.SetTimeout(apiConfig.GetRequestTimeout(&c.cfg.Defaults))
+	apiExecutor.SetMaxRequests(c.cfg.Defaults.MaxRequests)
+	apiExecutor.SetFlattenNested(c.flatten || apiConfig.FlattenNested)
+	apiExecutor.SetRetryPolicy(apiConfig.Retry.Attempts, apiConfig.Retry.GetRetryDelay(), apiConfig.Retry.RetryPost)
+	apiExecutor.SetCache(c.responses, apiConfig.GetResponseCacheTTL(&c.cfg.Defaults))
+
+	grammarGen := grammar.NewGrammarGenerator()
+	capabilityMap := make(map[string]parser.APICapability)
+	grammarMap := make(map[string]grammar.SQLGrammar)
+	for _, capability := range capabilities {
+		capabilityMap[capability.TableName] = capability
+		grammarMap[capability.TableName] = grammarGen.GenerateGrammar(capability)
+	}
+
+	c.capabilities[apiConfig.Name] = capabilityMap
+	c.grammars[apiConfig.Name] = grammarMap
+	c.executors[apiConfig.Name] = apiExecutor
+	return nil
+}
+
+// execute parses and runs a statement whose placeholders are already bound,
+// returning its result along with the types of its columns
+func (c *catalog) execute(sql string) (*executor.QueryResult, *translator.ParsedQuery, map[string]parser.ColumnType, error) {
+	tableNames, err := translator.ExtractTableNames(sql)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("qrest: failed to parse SQL: %w", err)
+	}
+
+	tables := make([]executor.JoinTable, len(tableNames))
+	joinGrammars := make(map[string]grammar.SQLGrammar)
+	for i, name := range tableNames {
+		table, tableGrammar, err := c.resolve(name)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("qrest: %w", err)
+		}
+		tables[i] = table
+		joinGrammars[name] = tableGrammar
+	}
+
+	sqlTranslator := translator.NewSimpleSQLTranslator(joinGrammars[tableNames[0]])
+	sqlTranslator.SetJoinTables(joinGrammars)
+	query, err := sqlTranslator.ParseSQL(sql)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("qrest: %w", err)
+	}
+
+	result, err := executor.Execute(tables, query)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("qrest: query execution failed: %w", err)
+	}
+	if result.Error != "" {
+		return nil, nil, nil, fmt.Errorf("qrest: API error: %s", result.Error)
+	}
+	return result, query, executor.ColumnTypes(tables, query), nil
+}
@@ -0,0 +1,213 @@
+package sqldriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errNoTransactions = errors.New("qrest: transactions are not supported")
+
+// conn runs statements through the catalog of its connector. REST requests
+// hold no session, so connections keep no state of their own.
+type conn struct {
+	catalog *catalog
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query, numInput: countPlaceholders(query)}, nil
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, errNoTransactions
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	bound, err := bindPlaceholders(query, args)
+	if err != nil {
+		return nil, err
+	}
+	result, _, types, err := c.catalog.execute(bound)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(result, types), nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	bound, err := bindPlaceholders(query, args)
+	if err != nil {
+		return nil, err
+	}
+	result, parsed, _, err := c.catalog.execute(bound)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.QueryType == "SELECT" {
+		return execResult(result.Total), nil
+	}
+	// A mutation is one request on one resource
+	return execResult(1), nil
+}
+
+// stmt is a statement prepared on a connection; it is parsed only when run,
+// once its placeholders are bound
+type stmt struct {
+	conn     *conn
+	query    string
+	numInput int
+}
+
+func (s *stmt) Close() error { return nil }
+
+func (s *stmt) NumInput() int { return s.numInput }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+// execResult counts the rows a statement returned or changed
+type execResult int64
+
+func (r execResult) LastInsertId() (int64, error) {
+	return 0, errors.New("qrest: LastInsertId is not supported; select the created resource instead")
+}
+
+func (r execResult) RowsAffected() (int64, error) {
+	return int64(r), nil
+}
+
+// scanPlaceholders calls found with the offset of each "?" outside quoted
+// strings and identifiers and outside comments
+func scanPlaceholders(query string, found func(offset int)) {
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return
+			}
+			i += end
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return
+			}
+			i += end + 3
+		case c == '?':
+			found(i)
+		}
+	}
+}
+
+func countPlaceholders(query string) int {
+	count := 0
+	scanPlaceholders(query, func(int) { count++ })
+	return count
+}
+
+// bindPlaceholders replaces each "?" with its argument as a SQL literal
+func bindPlaceholders(query string, args []driver.NamedValue) (string, error) {
+	if count := countPlaceholders(query); count != len(args) {
+		return "", fmt.Errorf("qrest: statement has %d placeholders but %d arguments were given", count, len(args))
+	}
+	if len(args) == 0 {
+		return query, nil
+	}
+
+	var bound strings.Builder
+	var err error
+	last, n := 0, 0
+	scanPlaceholders(query, func(offset int) {
+		if err != nil {
+			return
+		}
+		arg := args[n]
+		n++
+		if arg.Name != "" {
+			err = fmt.Errorf("qrest: named argument %q is not supported; use ? placeholders", arg.Name)
+			return
+		}
+		var value string
+		if value, err = literal(arg.Value); err != nil {
+			err = fmt.Errorf("qrest: argument %d: %w", arg.Ordinal, err)
+			return
+		}
+		bound.WriteString(query[last:offset])
+		bound.WriteString(value)
+		last = offset + 1
+	})
+	if err != nil {
+		return "", err
+	}
+	bound.WriteString(query[last:])
+	return bound.String(), nil
+}
+
+// literal writes a driver value as a SQL literal
+func literal(value driver.Value) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "NULL", nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("%v has no SQL literal", v)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(v)), nil
+	case string:
+		return quoteString(v), nil
+	case []byte:
+		return quoteString(string(v)), nil
+	case time.Time:
+		return quoteString(v.Format(time.RFC3339Nano)), nil
+	}
+	return "", fmt.Errorf("unsupported type %T", value)
+}
+
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,100 @@
+package sqldriver
+
+import (
+	"database/sql/driver"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCountPlaceholders(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"SELECT id FROM pets WHERE status = ? AND name = ?", 2},
+		{"SELECT id FROM pets WHERE name = 'why?' AND status = ?", 1},
+		{"SELECT \"odd?\" FROM pets WHERE name = 'it''s?'", 0},
+		{"SELECT `a?b` FROM pets", 0},
+		{"SELECT id FROM pets -- status = ?\nWHERE name = ?", 1},
+		{"SELECT id FROM pets WHERE /* name = ? */ status = ?", 1},
+		{"SELECT id FROM pets WHERE status = ? -- trailing ?", 1},
+		{"SELECT id FROM pets /* unterminated ?", 0},
+		{"SELECT id FROM pets WHERE name = '--' AND status = ?", 1},
+		{"SELECT 2 - ? FROM pets", 1},
+	}
+	for _, tt := range tests {
+		if got := countPlaceholders(tt.query); got != tt.want {
+			t.Errorf("countPlaceholders(%q) = %d, want %d", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestBindPlaceholders(t *testing.T) {
+	born := time.Date(2019, 5, 4, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		query string
+		args  []driver.Value
+		want  string
+		err   string
+	}{
+		{
+			query: "SELECT id FROM pets WHERE name = ? AND status = '?' -- ?",
+			args:  []driver.Value{"O'Brien"},
+			want:  "SELECT id FROM pets WHERE name = 'O''Brien' AND status = '?' -- ?",
+		},
+		{
+			query: "SELECT id FROM pets WHERE id = ? AND weight < ? AND vaccinated = ? AND tag = ?",
+			args:  []driver.Value{int64(-3), 2.5, true, []byte("a'b")},
+			want:  "SELECT id FROM pets WHERE id = -3 AND weight < 2.5 AND vaccinated = TRUE AND tag = 'a''b'",
+		},
+		{
+			query: "SELECT id FROM pets WHERE born = ? AND status = ?",
+			args:  []driver.Value{born, nil},
+			want:  "SELECT id FROM pets WHERE born = '2019-05-04T10:30:00Z' AND status = NULL",
+		},
+		{
+			query: "SELECT id FROM pets WHERE weight < ?",
+			args:  []driver.Value{1e21},
+			want:  "SELECT id FROM pets WHERE weight < 1000000000000000000000",
+		},
+		{
+			query: "SELECT id FROM pets WHERE status = ?",
+			err:   "qrest: statement has 1 placeholders but 0 arguments were given",
+		},
+		{
+			query: "SELECT id FROM pets WHERE weight < ?",
+			args:  []driver.Value{math.NaN()},
+			err:   "qrest: argument 1: NaN has no SQL literal",
+		},
+		{
+			query: "SELECT id FROM pets WHERE weight < ?",
+			args:  []driver.Value{math.Inf(-1)},
+			err:   "qrest: argument 1: -Inf has no SQL literal",
+		},
+		{
+			query: "SELECT id FROM pets WHERE status = ?",
+			args:  []driver.Value{struct{}{}},
+			err:   "qrest: argument 1: unsupported type struct {}",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := bindPlaceholders(tt.query, namedValues(tt.args))
+		switch {
+		case tt.err != "":
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: got error %v, want %q", tt.query, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", tt.query, err)
+		case got != tt.want:
+			t.Errorf("%s: got %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	named := []driver.NamedValue{{Name: "status", Ordinal: 1, Value: "sold"}}
+	if _, err := bindPlaceholders("SELECT id FROM pets WHERE status = ?", named); err == nil {
+		t.Error("bound a named argument, want an error")
+	}
+}
@@ -0,0 +1,124 @@
+// Package sqldriver is a database/sql driver that runs SQL against the REST
+// APIs of a qRest configuration. Importing it registers the "qrest" driver:
+//
+//	import _ "github.com/simonm/qRest/sqldriver"
+//
+//	db, err := sql.Open("qrest", "config=qRest.toml&api=petstore")
+//	rows, err := db.Query("SELECT id, name FROM findByStatus WHERE status = ?", "available")
+//
+// The data source name is a URL query:
+//
+//	config   configuration file; the usual locations are searched when empty
+//	api      API that plain table names belong to; the first configured by default
+//	offline  load specs from the spec cache only (true or false)
+//	flatten  expose nested objects as parent_child columns (true or false)
+//
+// Tables of other configured APIs are named "api.table", as in the CLI.
+// Column types come from the response schemas of the spec, and "?"
+// placeholders are bound to arguments before the statement is parsed.
+// Transactions are not supported.
+package sqldriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/simonm/qRest/internal/config"
+)
+
+func init() {
+	sql.Register("qrest", &Driver{})
+}
+
+// Driver opens connections to the APIs a data source name configures
+type Driver struct{}
+
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	connector, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(context.Background())
+}
+
+// OpenConnector loads the configuration once; the connections it makes share
+// the loaded specs, the executors and the response cache
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	options, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.LoadConfig(options.configPath)
+	if err != nil {
+		return nil, fmt.Errorf("qrest: failed to load configuration: %w", err)
+	}
+	if len(cfg.APIs) == 0 {
+		return nil, fmt.Errorf("qrest: no APIs configured")
+	}
+	apiConfig := &cfg.APIs[0]
+	if options.api != "" {
+		if apiConfig = cfg.FindAPI(options.api); apiConfig == nil {
+			return nil, fmt.Errorf("qrest: API '%s' not found in configuration", options.api)
+		}
+	}
+	if options.offline {
+		cfg.Defaults.Offline = true
+	}
+
+	return &connector{driver: d, catalog: newCatalog(cfg, apiConfig, options.flatten)}, nil
+}
+
+type dsnOptions struct {
+	configPath string
+	api        string
+	offline    bool
+	flatten    bool
+}
+
+func parseDSN(dsn string) (dsnOptions, error) {
+	var options dsnOptions
+	values, err := url.ParseQuery(dsn)
+	if err != nil {
+		return options, fmt.Errorf("qrest: invalid data source name: %w", err)
+	}
+	for key := range values {
+		value := values.Get(key)
+		switch key {
+		case "config":
+			options.configPath = value
+		case "api":
+			options.api = value
+		case "offline", "flatten":
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return options, fmt.Errorf("qrest: invalid %s value %q in data source name", key, value)
+			}
+			if key == "offline" {
+				options.offline = enabled
+			} else {
+				options.flatten = enabled
+			}
+		default:
+			return options, fmt.Errorf("qrest: unknown data source name parameter %q", key)
+		}
+	}
+	return options, nil
+}
+
+type connector struct {
+	driver  *Driver
+	catalog *catalog
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &conn{catalog: c.catalog}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
@@ -0,0 +1,277 @@
+package sqldriver
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/simonm/qRest/internal/apitest"
+)
+
+// openPets opens a database on a pets API through a configuration file
+// naming its spec and base URL
+func openPets(t *testing.T) (*sql.DB, *apitest.Server) {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	api := apitest.NewPets(t)
+
+	dir := t.TempDir()
+	specPath := filepath.Join(dir, "pets.yaml")
+	if err := os.WriteFile(specPath, []byte(apitest.PetsSpec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "qRest.toml")
+	config := fmt.Sprintf("[[apis]]\nname = \"pets\"\nspec_url = %q\nbase_url = %q\n\n[apis.auth]\ntype = \"none\"\n", specPath, api.URL)
+	if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("qrest", "config="+url.QueryEscape(configPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, api
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		sql      string
+		args     []interface{}
+		ids      []int64
+		requests []string
+	}{
+		{
+			sql:      "SELECT id FROM pets WHERE status = ?",
+			args:     []interface{}{"sold"},
+			ids:      []int64{1, 3},
+			requests: []string{"/pets?status=sold"},
+		},
+		{
+			sql:      "SELECT id FROM pets -- which status?\nWHERE status = ? /* not name = ? */",
+			args:     []interface{}{"available"},
+			ids:      []int64{2},
+			requests: []string{"/pets?status=available"},
+		},
+		{
+			sql:      "SELECT id FROM pets WHERE name != 'Who?' AND id > ? ORDER BY id DESC",
+			args:     []interface{}{1},
+			ids:      []int64{3, 2},
+			requests: []string{"/pets"},
+		},
+		{
+			sql:      "SELECT id FROM pets WHERE status = ?",
+			args:     []interface{}{"it's'; DROP"},
+			ids:      []int64{},
+			requests: []string{"/pets?status=it%27s%27%3B+DROP"},
+		},
+		{
+			sql:      "SELECT id FROM pets WHERE vaccinated = ? AND born > ?",
+			args:     []interface{}{true, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+			ids:      []int64{3},
+			requests: []string{"/pets"},
+		},
+	}
+
+	for _, tt := range tests {
+		db, api := openPets(t)
+		rows, err := db.Query(tt.sql, tt.args...)
+		if err != nil {
+			t.Errorf("%s: %v", tt.sql, err)
+			continue
+		}
+		ids := []int64{}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				t.Fatalf("%s: %v", tt.sql, err)
+			}
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			t.Errorf("%s: %v", tt.sql, err)
+		}
+		rows.Close()
+
+		if !reflect.DeepEqual(ids, tt.ids) {
+			t.Errorf("%s: got ids %v, want %v", tt.sql, ids, tt.ids)
+		}
+		if requests := api.Requests(); !reflect.DeepEqual(requests, tt.requests) {
+			t.Errorf("%s: got requests %q, want %q", tt.sql, requests, tt.requests)
+		}
+	}
+}
+
+func TestScanTypes(t *testing.T) {
+	db, _ := openPets(t)
+	rows, err := db.Query("SELECT id, name, born, vaccinated FROM pets ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var typeNames []string
+	for _, columnType := range columnTypes {
+		nullable, _ := columnType.Nullable()
+		typeNames = append(typeNames, fmt.Sprintf("%s %s null=%v", columnType.DatabaseTypeName(), columnType.ScanType(), nullable))
+	}
+	wantTypes := []string{"INTEGER int64 null=false", "TEXT string null=false", "TIMESTAMP time.Time null=true", "BOOLEAN bool null=true"}
+	if !reflect.DeepEqual(typeNames, wantTypes) {
+		t.Errorf("got column types %q, want %q", typeNames, wantTypes)
+	}
+
+	type pet struct {
+		id         int64
+		name       string
+		born       sql.NullTime
+		vaccinated bool
+	}
+	var pets []pet
+	for rows.Next() {
+		var p pet
+		if err := rows.Scan(&p.id, &p.name, &p.born, &p.vaccinated); err != nil {
+			t.Fatal(err)
+		}
+		pets = append(pets, p)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []pet{
+		{1, "Rex", sql.NullTime{Time: time.Date(2019, 5, 4, 10, 30, 0, 0, time.UTC), Valid: true}, true},
+		{2, "Bo", sql.NullTime{}, false},
+		{3, "Tom", sql.NullTime{Time: time.Date(2021, 11, 20, 8, 0, 0, 0, time.UTC), Valid: true}, true},
+	}
+	if !reflect.DeepEqual(pets, want) {
+		t.Errorf("got pets %+v, want %+v", pets, want)
+	}
+}
+
+func TestExec(t *testing.T) {
+	db, api := openPets(t)
+
+	result, err := db.Exec("INSERT INTO pets (name, status) VALUES (?, ?)", "Kit's", "available")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n != 1 {
+		t.Errorf("insert: got %d rows affected (%v), want 1", n, err)
+	}
+	if _, err := result.LastInsertId(); err == nil {
+		t.Error("insert: got a last insert id, want an error")
+	}
+
+	result, err = db.Exec("SELECT id FROM pets WHERE status = ?", "sold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n != 2 {
+		t.Errorf("select: got %d rows affected (%v), want 2", n, err)
+	}
+
+	want := []string{"/pets", "/pets?status=sold"}
+	if requests := api.Requests(); !reflect.DeepEqual(requests, want) {
+		t.Errorf("got requests %q, want %q", requests, want)
+	}
+}
+
+func TestPrepare(t *testing.T) {
+	db, api := openPets(t)
+	stmt, err := db.Prepare("SELECT name FROM pets WHERE status = ? -- or ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	for _, status := range []string{"sold", "available"} {
+		var name string
+		if err := stmt.QueryRow(status).Scan(&name); err != nil {
+			t.Fatalf("%s: %v", status, err)
+		}
+	}
+	if _, err := stmt.Query("sold", "available"); err == nil {
+		t.Error("ran a statement with too many arguments, want an error")
+	}
+
+	want := []string{"/pets?status=sold", "/pets?status=available"}
+	if requests := api.Requests(); !reflect.DeepEqual(requests, want) {
+		t.Errorf("got requests %q, want %q", requests, want)
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	db, _ := openPets(t)
+	tests := []struct {
+		sql  string
+		args []interface{}
+		err  string
+	}{
+		{
+			sql: "SELECT id FROM pets WHERE status = ?",
+			err: "qrest: statement has 1 placeholders but 0 arguments were given",
+		},
+		{
+			sql:  "SELECT id FROM pets WHERE name = '?'",
+			args: []interface{}{"Rex"},
+			err:  "qrest: statement has 0 placeholders but 1 arguments were given",
+		},
+		{
+			sql: "SELECT id FROM owners",
+			err: "qrest: table 'owners' not found. Available tables: [pets pets_post]",
+		},
+		{
+			sql: "SELECT id FROM other.pets",
+			err: "qrest: API 'other' of table 'other.pets' not found in configuration",
+		},
+	}
+
+	for _, tt := range tests {
+		rows, err := db.Query(tt.sql, tt.args...)
+		if err == nil {
+			rows.Close()
+		}
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%s: got error %v, want %q", tt.sql, err, tt.err)
+		}
+	}
+
+	if _, err := db.Begin(); err != errNoTransactions {
+		t.Errorf("begin: got error %v, want %v", err, errNoTransactions)
+	}
+}
+
+func TestParseDSN(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want dsnOptions
+		err  string
+	}{
+		{dsn: "", want: dsnOptions{}},
+		{dsn: "config=%2Ftmp%2FqRest.toml&api=pets&offline=true&flatten=1", want: dsnOptions{configPath: "/tmp/qRest.toml", api: "pets", offline: true, flatten: true}},
+		{dsn: "offline=maybe", err: `qrest: invalid offline value "maybe" in data source name`},
+		{dsn: "host=localhost", err: `qrest: unknown data source name parameter "host"`},
+	}
+	for _, tt := range tests {
+		got, err := parseDSN(tt.dsn)
+		switch {
+		case tt.err != "":
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%q: got error %v, want %q", tt.dsn, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("%q: %v", tt.dsn, err)
+		case got != tt.want:
+			t.Errorf("%q: got %+v, want %+v", tt.dsn, got, tt.want)
+		}
+	}
+}
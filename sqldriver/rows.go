@@ -0,0 +1,164 @@
+package sqldriver
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/simonm/qRest/internal/executor"
+	"github.com/simonm/qRest/internal/parser"
+)
+
+// rows returns the records of a result in SELECT order, converting values to
+// the column's schema type
+type rows struct {
+	columns []string
+	types   []parser.ColumnType
+	data    []map[string]interface{}
+	next    int
+}
+
+func newRows(result *executor.QueryResult, types map[string]parser.ColumnType) *rows {
+	columns := result.Columns
+	if len(columns) == 0 {
+		// Mutations return the resource as the API sends it
+		seen := make(map[string]bool)
+		for _, record := range result.Data {
+			for column := range record {
+				if !seen[column] {
+					seen[column] = true
+					columns = append(columns, column)
+				}
+			}
+		}
+		sort.Strings(columns)
+	}
+
+	r := &rows{columns: columns, types: make([]parser.ColumnType, len(columns)), data: result.Data}
+	for i, column := range columns {
+		r.types[i] = types[column]
+	}
+	return r
+}
+
+func (r *rows) Columns() []string { return r.columns }
+
+func (r *rows) Close() error {
+	r.data = nil
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.data) {
+		return io.EOF
+	}
+	record := r.data[r.next]
+	r.next++
+	for i, column := range r.columns {
+		value, err := convert(record[column], r.types[i])
+		if err != nil {
+			return err
+		}
+		dest[i] = value
+	}
+	return nil
+}
+
+// convert turns a decoded JSON value into a driver value: integers as int64,
+// timestamps as time.Time, and objects and arrays as their JSON
+func convert(value interface{}, columnType parser.ColumnType) (driver.Value, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		if columnType.Type == "integer" && v == float64(int64(v)) {
+			return int64(v), nil
+		}
+		return v, nil
+	case int:
+		return int64(v), nil
+	case string:
+		if t, ok := parseTimestamp(v, columnType.Format); ok {
+			return t, nil
+		}
+		return v, nil
+	case bool:
+		return v, nil
+	}
+	return json.Marshal(value)
+}
+
+func parseTimestamp(s, format string) (time.Time, bool) {
+	layout := ""
+	switch format {
+	case "date-time":
+		layout = time.RFC3339Nano
+	case "date":
+		layout = time.DateOnly
+	default:
+		return time.Time{}, false
+	}
+	t, err := time.Parse(layout, s)
+	return t, err == nil
+}
+
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	columnType := r.types[index]
+	switch columnType.Type {
+	case "integer":
+		return "INTEGER"
+	case "number":
+		return "DOUBLE"
+	case "boolean":
+		return "BOOLEAN"
+	case "array", "object":
+		return "JSON"
+	case "string":
+		switch columnType.Format {
+		case "date-time":
+			return "TIMESTAMP"
+		case "date":
+			return "DATE"
+		}
+		return "TEXT"
+	}
+	return ""
+}
+
+var (
+	scanTypeInt64   = reflect.TypeOf(int64(0))
+	scanTypeFloat64 = reflect.TypeOf(float64(0))
+	scanTypeBool    = reflect.TypeOf(false)
+	scanTypeString  = reflect.TypeOf("")
+	scanTypeTime    = reflect.TypeOf(time.Time{})
+	scanTypeJSON    = reflect.TypeOf(json.RawMessage{})
+	scanTypeAny     = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+func (r *rows) ColumnTypeScanType(index int) reflect.Type {
+	switch r.ColumnTypeDatabaseTypeName(index) {
+	case "INTEGER":
+		return scanTypeInt64
+	case "DOUBLE":
+		return scanTypeFloat64
+	case "BOOLEAN":
+		return scanTypeBool
+	case "JSON":
+		return scanTypeJSON
+	case "TIMESTAMP", "DATE":
+		return scanTypeTime
+	case "TEXT":
+		return scanTypeString
+	}
+	return scanTypeAny
+}
+
+// ColumnTypeNullable reports a column nullable unless the response schema
+// requires it and does not allow null. Columns of no known type may be null.
+func (r *rows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	columnType := r.types[index]
+	return columnType.Type == "" || columnType.Nullable, true
+}